@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/export"
+	"github.com/angel/go-api-sqlite/internal/items"
+)
+
+// runExport implements "api export": write the items matching a filter to a
+// CSV, NDJSON or XLSX file
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := fs.String("db", "./data.db", "Database file to export from")
+	format := fs.String("format", "", "csv, ndjson or xlsx (default: from the -out extension, else csv)")
+	filterExpr := fs.String("filter", "", "Filter expression selecting the items to export")
+	columns := fs.String("columns", "", "Comma-separated fields to include, in order (default: all)")
+	out := fs.String("out", "", "File to write (default: items-<timestamp>.<format>)")
+	excelSafe := fs.Bool("excel-safe", false, "Prefix CSV text that spreadsheets would evaluate as a formula with '")
+	fs.Parse(args)
+
+	name := *format
+	if name == "" && *out != "" {
+		name = strings.TrimPrefix(filepath.Ext(*out), ".")
+	}
+	f, err := export.Negotiate(name, "")
+	if err != nil {
+		log.Fatalf("Invalid -format %q: must be csv, ndjson or xlsx", name)
+	}
+	req := export.Request{Format: f, ListRequest: items.ListRequest{Filter: *filterExpr}, ExcelSafe: *excelSafe}
+	if *columns != "" {
+		for _, c := range strings.Split(*columns, ",") {
+			req.Columns = append(req.Columns, strings.TrimSpace(c))
+		}
+	}
+	e, err := export.Prepare(req)
+	if err != nil {
+		fatalAPIError("Invalid export", err)
+	}
+
+	db, err := database.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+
+	path := *out
+	if path == "" {
+		path = e.Filename(time.Now())
+	}
+	// Write to a temporary file so a failed export leaves no partial output
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		log.Fatalf("Error creating %s: %v", tmp, err)
+	}
+	count, err := e.Write(context.Background(), db, file)
+	if err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		log.Fatalf("Export failed: %v", err)
+	}
+	fmt.Printf("Wrote %d items to %s\n", count, path)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/export"
+	"github.com/angel/go-api-sqlite/internal/importer"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// runImport implements "api import": validate a CSV or NDJSON file and
+// upsert its rows into the items table
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dbPath := fs.String("db", "./data.db", "Database file to import into")
+	format := fs.String("format", "", "csv or ndjson (default: from the file extension, else csv)")
+	mapping := fs.String("map", "", `Item fields read from other columns, e.g. "name=Product,value=Unit Price"`)
+	key := fs.String("key", "", "Column holding the supplier's key; rows are upserted by it")
+	source := fs.String("source", importer.DefaultSource, "Namespace of the keys, such as the supplier name")
+	dryRun := fs.Bool("dry-run", false, "Validate and report what would change without writing")
+	batchSize := fs.Int("batch-size", importer.DefaultBatchSize, "Rows committed per transaction")
+	report := fs.String("report", "", "Write the per-row errors to this CSV file")
+	moneyRounding := fs.String("money-rounding", "", "Rounding of item values, as taken by the server")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: api import [flags] <file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		log.Fatal("import requires the path of a file")
+	}
+	path := fs.Arg(0)
+
+	name := *format
+	if name == "" {
+		name = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	f, err := export.Negotiate(name, "")
+	if err != nil {
+		log.Fatalf("Invalid -format %q: must be csv or ndjson", name)
+	}
+	rules, err := money.ParseRules(*moneyRounding)
+	if err != nil {
+		log.Fatalf("Invalid -money-rounding: %v", err)
+	}
+	m, err := importer.ParseMapping(*mapping)
+	if err != nil {
+		log.Fatalf("Invalid -map: %v", err)
+	}
+	im, err := importer.Prepare(importer.Request{
+		Format:    f,
+		Mapping:   m,
+		Key:       *key,
+		Source:    *source,
+		DryRun:    *dryRun,
+		BatchSize: *batchSize,
+	}, rules)
+	if err != nil {
+		fatalAPIError("Invalid import", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Error opening %s: %v", path, err)
+	}
+	defer file.Close()
+
+	db, err := database.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+
+	res, err := im.Run(context.Background(), db, file, func(res importer.Result) {
+		log.Printf("Processed %d rows", res.Rows)
+	})
+	verb := "Imported"
+	if *dryRun {
+		verb = "Dry run of"
+	}
+	fmt.Printf("%s %d rows: %d created, %d updated, %d unchanged, %d failed\n",
+		verb, res.Rows, res.Created, res.Updated, res.Unchanged, res.Failed)
+	for _, c := range res.Changes {
+		fmt.Printf("row %d: %s %s\n", c.Row, c.Action, c.ID)
+	}
+
+	if *report != "" {
+		out, err := os.Create(*report)
+		if err != nil {
+			log.Fatalf("Error creating %s: %v", *report, err)
+		}
+		if err := res.WriteReport(out); err != nil {
+			log.Fatalf("Error writing %s: %v", *report, err)
+		}
+		if err := out.Close(); err != nil {
+			log.Fatalf("Error writing %s: %v", *report, err)
+		}
+	} else {
+		for _, e := range res.Errors {
+			fmt.Printf("row %d: %s\n", e.Row, e.Message)
+		}
+	}
+	if res.Truncated {
+		fmt.Printf("Only the first %d errors and changes are listed\n", importer.MaxReportedRows)
+	}
+	if err != nil {
+		fatalAPIError("Import failed", err)
+	}
+}
+
+// fatalAPIError logs the field violations of err before exiting
+func fatalAPIError(msg string, err error) {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		for _, v := range apiErr.Violations {
+			log.Printf("%s: %s", v.Field, v.Description)
+		}
+	}
+	log.Fatalf("%s: %v", msg, err)
+}
@@ -1,59 +1,535 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"expvar"
+	"flag"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/angel/go-api-sqlite/internal/attachments"
+	"github.com/angel/go-api-sqlite/internal/auth"
+	"github.com/angel/go-api-sqlite/internal/backup"
+	"github.com/angel/go-api-sqlite/internal/collections"
+	connectserver "github.com/angel/go-api-sqlite/internal/connect"
 	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/gateway"
 	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
 	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/importer"
+	"github.com/angel/go-api-sqlite/internal/jobs"
+	"github.com/angel/go-api-sqlite/internal/loadshed"
+	"github.com/angel/go-api-sqlite/internal/middleware"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/outbox"
+	"github.com/angel/go-api-sqlite/internal/ratelimit"
+	"github.com/angel/go-api-sqlite/internal/replication"
+	"github.com/angel/go-api-sqlite/internal/server"
+	"github.com/angel/go-api-sqlite/internal/tlsconfig"
+	"github.com/angel/go-api-sqlite/internal/walship"
+	"github.com/angel/go-api-sqlite/internal/webhook"
 	pb "github.com/angel/go-api-sqlite/proto"
 	"github.com/gorilla/mux"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			runBackup(os.Args[2:])
+			return
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "restore-wal":
+			runRestoreWAL(os.Args[2:])
+			return
+		case "reindex":
+			runReindex(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		}
+	}
+
+	dbPath := flag.String("db", "./data.db", "SQLite database file")
+	mode := flag.String("mode", "primary", "primary, or replica to follow -primary-grpc-addr and serve reads")
+	primaryGRPC := flag.String("primary-grpc-addr", "", "gRPC address of the primary in replica mode")
+	primaryHTTP := flag.String("primary-http-url", "", "HTTP URL of the primary, used to forward REST writes")
+	primaryCA := flag.String("primary-tls-ca", "", "CA bundle used to verify the primary's certificate (default: plaintext)")
+	replicaWrites := flag.String("replica-writes", "reject", "What a replica does with writes: reject or forward")
+	replicaMaxLag := flag.Duration("replica-max-lag", 30*time.Second,
+		"Replica lag above which the health check reports 503")
+	httpAPI := flag.String("http-api", "both",
+		"HTTP API to serve: rest (hand-written handlers), gateway (transcoded from gRPC) or both")
+	corsOrigins := flag.String("cors-origins", "",
+		"Comma-separated list of origins allowed to make browser requests, or * for any")
+	httpAddr := flag.String("http-addr", ":8080", "HTTP listen address")
+	grpcAddr := flag.String("grpc-addr", ":50051", "gRPC listen address")
+	singlePort := flag.Bool("single-port", false,
+		"Serve HTTP and native gRPC on -http-addr, multiplexed by content type")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file for the HTTP and gRPC listeners")
+	tlsKey := flag.String("tls-key", "", "TLS private key file for the HTTP and gRPC listeners")
+	tlsClientCA := flag.String("tls-client-ca", "", "CA bundle used to verify client certificates (enables mTLS)")
+	tlsClientAuth := flag.String("tls-client-auth", "require",
+		"Client certificate policy with -tls-client-ca: require or verify-if-given")
+	tlsPrincipals := flag.String("tls-principals", "",
+		"JSON file mapping client certificate subjects to principals (default: certificate CN)")
+	tlsReload := flag.Duration("tls-reload-interval", 30*time.Second,
+		"How often certificate files are checked for changes")
+	rateRead := flag.String("rate-limit-read", "", "Per-client read budget such as 100/s or 600/m (default: unlimited)")
+	rateWrite := flag.String("rate-limit-write", "", "Per-client write budget such as 10/s (default: unlimited)")
+	rateOverrides := flag.String("rate-limit-overrides", "",
+		`Per-route budgets, e.g. "POST /api/items=5/s,/proto.ItemService/CreateItem=5/s"`)
+	rateStore := flag.String("rate-limit-store", "memory", "Rate limiter state: memory or sqlite")
+	rateKeys := flag.String("rate-limit-api-keys", "",
+		"JSON file mapping the API keys clients may send in X-API-Key to client names (default: keys are ignored)")
+	shed := flag.Bool("load-shedding", true, "Limit concurrent writes and shed excess load with 503/UNAVAILABLE")
+	shedMaxLimit := flag.Int("shed-max-concurrency", 32, "Upper bound of the adaptive write concurrency limit")
+	shedQueue := flag.Int("shed-queue", 128, "Writes allowed to wait for a slot before new ones are shed")
+	shedQueueTimeout := flag.Duration("shed-queue-timeout", time.Second, "Longest a write waits for a slot")
+	shedLatency := flag.Duration("shed-latency-target", 100*time.Millisecond,
+		"Write latency above which the concurrency limit is reduced")
+	backupDir := flag.String("backup-dir", "", "Directory for snapshots; enables the /api/admin/backups endpoints")
+	backupInterval := flag.Duration("backup-interval", 0, "Take a snapshot at this interval (default: disabled)")
+	backupDaily := flag.Int("backup-keep-daily", 7, "Number of daily snapshots to keep")
+	backupWeekly := flag.Int("backup-keep-weekly", 4, "Number of weekly snapshots to keep")
+	walDir := flag.String("wal-ship-dir", "", "Continuously ship WAL frames to this directory for point-in-time recovery")
+	walInterval := flag.Duration("wal-ship-interval", time.Second, "How often new WAL frames are shipped")
+	walSnapshot := flag.Duration("wal-snapshot-interval", 24*time.Hour, "How often a new snapshot generation is started")
+	walRetention := flag.Duration("wal-retention", 24*time.Hour, "How far back point-in-time restores remain possible")
+	importAsync := flag.Int64("import-async-threshold", 8<<20, "Request size in bytes above which imports run in the background")
+	importSpool := flag.String("import-spool-dir", "", "Directory for uploads waiting for a background import (default: system temp dir)")
+	jobWorkers := flag.Int("job-workers", 2, "Number of background jobs run at once")
+	jobLease := flag.Duration("job-lease", 30*time.Second,
+		"How long a job stays claimed without a heartbeat before another worker takes it over")
+	jobRetention := flag.Duration("job-retention", 7*24*time.Hour, "How long succeeded, failed and cancelled jobs are kept")
+	webhookAttempts := flag.Int("webhook-max-attempts", 8, "Attempts to deliver a webhook before it is dead-lettered")
+	webhookTimeout := flag.Duration("webhook-timeout", 10*time.Second, "Longest a webhook receiver may take to respond")
+	webhookRetention := flag.Duration("webhook-retention", 7*24*time.Hour, "How long delivered and dead-lettered webhook deliveries are kept")
+	webhookAllow := flag.String("webhook-allow-networks", "",
+		"Comma-separated CIDRs of internal networks webhook receivers may be in, such as 10.1.0.0/16 (default: none)")
+	outboxSink := flag.String("outbox-sink", "",
+		"Publish item events to nats://host:port/prefix, kafka://broker:port/topic, kafka-rest+http://proxy:port/topic or file:///path (default: none)")
+	changeRetention := flag.Duration("change-log-retention", 7*24*time.Hour,
+		"How long the change log keeps changes a replica has not applied")
+	outboxRetention := flag.Duration("outbox-retention", 24*time.Hour,
+		"How long published outbox events are kept, or unpublished ones without -outbox-sink")
+	attachmentDir := flag.String("attachment-dir", "./attachments", "Directory of the attachment blob store")
+	attachmentMaxSize := flag.Int64("attachment-max-size", 25<<20, "Largest attachment accepted, in bytes")
+	attachmentTypes := flag.String("attachment-types", "image/*,application/pdf",
+		"Comma-separated sniffed MIME types accepted as attachments; type/* accepts a whole family")
+	attachmentExpiry := flag.Duration("attachment-upload-expiry", 24*time.Hour,
+		"How long a resumable upload may go without a chunk before it is discarded")
+	attachmentGC := flag.Duration("attachment-gc-interval", time.Minute,
+		"How often unreferenced attachment blobs and expired uploads are removed")
+	moneyRounding := flag.String("money-rounding", "",
+		"Rounding of item values such as *=half-up,JPY=0:down (default: half-even to each currency's minor units)")
+	flag.Parse()
+
+	if *httpAPI != "rest" && *httpAPI != "gateway" && *httpAPI != "both" {
+		log.Fatalf("Invalid -http-api value %q: must be rest, gateway or both", *httpAPI)
+	}
+	if *mode != "primary" && *mode != "replica" {
+		log.Fatalf("Invalid -mode value %q: must be primary or replica", *mode)
+	}
+	rules, err := money.ParseRules(*moneyRounding)
+	if err != nil {
+		log.Fatalf("Invalid -money-rounding: %v", err)
+	}
+	allowedNetworks, err := webhook.ParseNetworks(*webhookAllow)
+	if err != nil {
+		log.Fatalf("Invalid -webhook-allow-networks: %v", err)
+	}
+	webhookPolicy := webhook.Policy{AllowedNetworks: allowedNetworks}
+
 	// Initialize database
-	db, err := database.InitDB()
+	var dbOpts []database.Option
+	if *walDir != "" {
+		// The WAL shipper decides when frames are checkpointed
+		dbOpts = append(dbOpts, database.WithManualCheckpoints())
+	}
+	db, err := database.Open(*dbPath, dbOpts...)
 	if err != nil {
 		log.Fatal("Error initializing database:", err)
 	}
 	defer db.Close()
 
+	// Ship WAL frames to the replica directory
+	if *walDir != "" {
+		shipper := walship.New(db, walship.Config{
+			DBPath:           *dbPath,
+			Dir:              *walDir,
+			SyncInterval:     *walInterval,
+			SnapshotInterval: *walSnapshot,
+			Retention:        *walRetention,
+		})
+		expvar.Publish("replication", expvar.Func(func() any { return shipper.Stats() }))
+		go shipper.Run(context.Background())
+	}
+
+	// Prune the change log below what every replica has applied. Replicas
+	// log their own changes too and prune them by age.
+	go replication.NewPruner(db, *changeRetention).Run(context.Background())
+
+	// Run background jobs, deliver webhooks and relay the outbox on the
+	// primary; replicas only report on them
+	queue := jobs.New(db, jobs.Config{Workers: *jobWorkers, LeaseDuration: *jobLease, Retention: *jobRetention})
+	importer.RegisterJobs(queue, db, rules)
+	if *mode == "primary" {
+		go queue.Run(context.Background())
+		dispatcher := webhook.NewDispatcher(db, webhook.Config{
+			MaxAttempts: *webhookAttempts,
+			Timeout:     *webhookTimeout,
+			Retention:   *webhookRetention,
+			Policy:      webhookPolicy,
+		})
+		go dispatcher.Run(context.Background())
+
+		var sink outbox.Sink
+		if *outboxSink != "" {
+			if sink, err = outbox.OpenSink(*outboxSink); err != nil {
+				log.Fatalf("Invalid -outbox-sink: %v", err)
+			}
+			defer sink.Close()
+		}
+		relay := outbox.NewRelay(db, sink, outbox.Config{Retention: *outboxRetention})
+		go relay.Run(context.Background())
+	}
+
+	// Attachment content lives next to the primary's database and is not
+	// replicated, so replicas send attachment requests to the primary
+	var attachmentSvc *attachments.Service
+	if *mode == "primary" {
+		attachmentSvc, err = attachments.New(db, attachments.Config{
+			Dir:          *attachmentDir,
+			MaxSize:      *attachmentMaxSize,
+			Types:        strings.Split(*attachmentTypes, ","),
+			UploadExpiry: *attachmentExpiry,
+			GCInterval:   *attachmentGC,
+		})
+		if err != nil {
+			log.Fatalf("Failed to open attachment store: %v", err)
+		}
+		go attachmentSvc.Run(context.Background())
+	}
+
 	// Create router
 	router := mux.NewRouter()
 
 	// Initialize handlers
-	h := handlers.NewHandler(db)
+	h := handlers.NewHandler(db, rules)
+	var itemServer pb.ItemServiceServer = grpcserver.NewItemServer(db, rules)
+
+	// Follow the primary's change stream and serve reads locally
+	var follower *replication.Follower
+	var primaryURL *url.URL
+	if *mode == "replica" {
+		var primary *grpc.ClientConn
+		follower, primary, err = newFollower(db, *primaryGRPC, *primaryCA)
+		if err != nil {
+			log.Fatalf("Failed to start replica: %v", err)
+		}
+		defer primary.Close()
+		go follower.Run(context.Background())
+		expvar.Publish("replica", expvar.Func(func() any { return follower.Status() }))
+
+		switch *replicaWrites {
+		case "reject":
+			itemServer = replication.NewItemServer(itemServer, nil)
+		case "forward":
+			if *primaryHTTP == "" {
+				log.Fatal("-replica-writes=forward requires -primary-http-url")
+			}
+			if primaryURL, err = url.Parse(*primaryHTTP); err != nil {
+				log.Fatalf("Invalid -primary-http-url: %v", err)
+			}
+			itemServer = replication.NewItemServer(itemServer, pb.NewItemServiceClient(primary))
+		default:
+			log.Fatalf("Invalid -replica-writes value %q: must be reject or forward", *replicaWrites)
+		}
+	}
 
 	// Define routes
-	router.HandleFunc("/api/health", h.HealthCheck).Methods("GET")
-	router.HandleFunc("/api/items", h.GetItems).Methods("GET")
-	router.HandleFunc("/api/items", h.CreateItem).Methods("POST")
-	router.HandleFunc("/api/items/{id}", h.GetItem).Methods("GET")
-	router.HandleFunc("/api/items/{id}", h.UpdateItem).Methods("PUT")
-	router.HandleFunc("/api/items/{id}", h.DeleteItem).Methods("DELETE")
+	if follower != nil {
+		router.HandleFunc("/api/health", follower.HealthCheck(*replicaMaxLag)).Methods("GET")
+	} else {
+		router.HandleFunc("/api/health", h.HealthCheck).Methods("GET")
+	}
+	router.Handle("/debug/vars", expvar.Handler()).Methods("GET")
+	jh := handlers.NewJobHandler(queue)
+	router.HandleFunc("/api/jobs/{id:[^/:]+}", jh.GetJob).Methods("GET")
+	router.HandleFunc("/api/jobs/{id:[^/:]+}:cancel", jh.CancelJob).Methods("POST")
+	wh := handlers.NewWebhookHandler(db, webhookPolicy)
+	router.HandleFunc("/api/webhooks", wh.ListWebhooks).Methods("GET")
+	router.HandleFunc("/api/webhooks", wh.CreateWebhook).Methods("POST")
+	router.HandleFunc("/api/webhooks/{id:[^/:]+}", wh.GetWebhook).Methods("GET")
+	router.HandleFunc("/api/webhooks/{id:[^/:]+}", wh.UpdateWebhook).Methods("PUT")
+	router.HandleFunc("/api/webhooks/{id:[^/:]+}", wh.DeleteWebhook).Methods("DELETE")
+	router.HandleFunc("/api/webhooks/{id:[^/:]+}:replay", wh.ReplayDeadDeliveries).Methods("POST")
+	router.HandleFunc("/api/webhooks/{id}/deliveries", wh.ListDeliveries).Methods("GET")
+	router.HandleFunc("/api/webhooks/{id}/deliveries/{delivery_id:[^/:]+}:replay", wh.ReplayDelivery).Methods("POST")
+	mh := handlers.NewMetadataIndexHandler(db)
+	router.HandleFunc("/api/admin/metadata-indexes", mh.ListMetadataIndexes).Methods("GET")
+	router.HandleFunc("/api/admin/metadata-indexes", mh.CreateMetadataIndex).Methods("POST")
+	router.HandleFunc("/api/admin/metadata-indexes/{key}", mh.DeleteMetadataIndex).Methods("DELETE")
+	ch := handlers.NewCollectionHandler(db, rules)
+	router.HandleFunc("/api/collections", ch.ListCollections).Methods("GET")
+	router.HandleFunc("/api/collections", ch.CreateCollection).Methods("POST")
+	router.HandleFunc("/api/collections/{id:[^/:]+}", ch.GetCollection).Methods("GET")
+	router.HandleFunc("/api/collections/{id:[^/:]+}", ch.UpdateCollection).Methods("PUT")
+	router.HandleFunc("/api/collections/{id:[^/:]+}", ch.DeleteCollection).Methods("DELETE")
+	router.HandleFunc("/api/collections/{id}/items", ch.ListCollectionItems).Methods("GET")
+	router.HandleFunc("/api/collections/{id}/items", ch.AddCollectionItem).Methods("POST")
+	router.HandleFunc("/api/collections/{id}/items/{item_id}", ch.RemoveCollectionItem).Methods("DELETE")
+	if attachmentSvc != nil {
+		ah := handlers.NewAttachmentHandler(attachmentSvc)
+		router.HandleFunc("/api/items/{id}/attachments", ah.ListAttachments).Methods("GET")
+		router.HandleFunc("/api/items/{id}/attachments", ah.CreateAttachment).Methods("POST")
+		router.HandleFunc("/api/items/{id}/attachments/uploads/{upload_id}", ah.GetUpload).Methods("GET")
+		router.HandleFunc("/api/items/{id}/attachments/uploads/{upload_id}", ah.WriteUploadChunk).Methods("PUT")
+		router.HandleFunc("/api/items/{id}/attachments/uploads/{upload_id}", ah.CancelUpload).Methods("DELETE")
+		router.HandleFunc("/api/items/{id}/attachments/{attachment_id}", ah.GetAttachment).Methods("GET")
+		router.HandleFunc("/api/items/{id}/attachments/{attachment_id}", ah.DeleteAttachment).Methods("DELETE")
+		router.HandleFunc("/api/items/{id}/attachments/{attachment_id}/content", ah.GetAttachmentContent).Methods("GET")
+	}
+	sh := handlers.NewSchemaHandler(db, rules)
+	router.HandleFunc("/api/admin/schemas", sh.ListSchemas).Methods("GET")
+	router.HandleFunc("/api/admin/schemas", sh.CreateSchema).Methods("POST")
+	router.HandleFunc("/api/admin/schemas:deactivate", sh.DeactivateSchema).Methods("POST")
+	router.HandleFunc("/api/admin/schemas/{version:[^/:]+}", sh.GetSchema).Methods("GET")
+	router.HandleFunc("/api/admin/schemas/{version:[^/:]+}:dry-run", sh.DryRunSchema).Methods("POST")
+	router.HandleFunc("/api/admin/schemas/{version:[^/:]+}:activate", sh.ActivateSchema).Methods("POST")
+	if *httpAPI != "gateway" {
+		router.HandleFunc("/api/items", h.GetItems).Methods("GET")
+		router.HandleFunc("/api/items", h.CreateItem).Methods("POST")
+		router.HandleFunc("/api/items/search", h.SearchItems).Methods("GET")
+		router.HandleFunc("/api/items/stats", h.GetItemStats).Methods("GET")
+		router.HandleFunc("/api/items/export", h.ExportItems).Methods("GET")
+		ih := handlers.NewImportHandler(db, rules, queue, *importSpool, *importAsync)
+		router.HandleFunc("/api/items/import", ih.ImportItems).Methods("POST")
+		router.HandleFunc("/api/items/import/{id}/report", ih.GetImportReport).Methods("GET")
+		router.HandleFunc("/api/items/{id}", h.GetItem).Methods("GET")
+		router.HandleFunc("/api/items/{id}/tree", h.GetItemTree).Methods("GET")
+		router.HandleFunc("/api/items/{id}", h.UpdateItem).Methods("PUT")
+		router.HandleFunc("/api/items/{id}", h.DeleteItem).Methods("DELETE")
+	}
+
+	// Snapshot the database on demand and on a schedule
+	if *backupDir != "" {
+		retention := backup.Retention{Daily: *backupDaily, Weekly: *backupWeekly}
+		bh := handlers.NewBackupHandler(db, *backupDir, retention)
+		router.HandleFunc("/api/admin/backups", bh.ListBackups).Methods("GET")
+		router.HandleFunc("/api/admin/backups", bh.CreateBackup).Methods("POST")
+
+		if *backupInterval > 0 {
+			scheduler := &backup.Scheduler{DB: db, Dir: *backupDir, Interval: *backupInterval, Retention: retention}
+			go scheduler.Run(context.Background())
+		}
+	}
+
+	// Mount the HTTP/JSON gateway generated from the proto annotations
+	if *httpAPI != "rest" {
+		gw, err := gateway.NewHandler(context.Background(), itemServer)
+		if err != nil {
+			log.Fatalf("Failed to create gateway: %v", err)
+		}
+		router.PathPrefix("/v1/").Handler(gw)
+	}
+
+	// Load TLS certificates, reloading them when they change on disk
+	var tlsConfig *tls.Config
+	var grpcOpts []grpc.ServerOption
+	var principals *auth.PrincipalMapper
+	if *tlsCert != "" || *tlsKey != "" {
+		clientAuth := tlsconfig.RequireClientCert
+		if *tlsClientAuth == "verify-if-given" {
+			clientAuth = tlsconfig.VerifyClientCertIfGiven
+		} else if *tlsClientAuth != "require" {
+			log.Fatalf("Invalid -tls-client-auth value %q: must be require or verify-if-given", *tlsClientAuth)
+		}
+
+		reloader, err := tlsconfig.NewReloader(tlsconfig.Config{
+			CertFile:       *tlsCert,
+			KeyFile:        *tlsKey,
+			ClientCAFile:   *tlsClientCA,
+			ClientAuth:     clientAuth,
+			ReloadInterval: *tlsReload,
+		})
+		if err != nil {
+			log.Fatalf("Failed to load TLS configuration: %v", err)
+		}
+		go reloader.Start(context.Background())
+		tlsConfig = reloader.ServerConfig()
+
+		// Map verified client certificates to principals
+		if *tlsClientCA != "" {
+			principals = auth.NewPrincipalMapper(nil)
+			if *tlsPrincipals != "" {
+				if principals, err = auth.LoadPrincipalMapper(*tlsPrincipals); err != nil {
+					log.Fatalf("Failed to load principal map: %v", err)
+				}
+			}
+			grpcOpts = append(grpcOpts,
+				grpc.ChainUnaryInterceptor(principals.UnaryInterceptor),
+				grpc.ChainStreamInterceptor(principals.StreamInterceptor))
+		}
+	}
+
+	// Rate limit clients by known API key, principal or IP address
+	limiter, err := newLimiter(db, *rateRead, *rateWrite, *rateOverrides, *rateStore, *rateKeys)
+	if err != nil {
+		log.Fatalf("Invalid rate limit configuration: %v", err)
+	}
+	if limiter != nil {
+		grpcOpts = append(grpcOpts,
+			grpc.ChainUnaryInterceptor(limiter.UnaryInterceptor),
+			grpc.ChainStreamInterceptor(limiter.StreamInterceptor))
+	}
+
+	// Queue concurrent writes and shed load before SQLite lock contention
+	var shedder *loadshed.Limiter
+	if *shed {
+		cfg := loadshed.DefaultConfig()
+		cfg.MaxLimit = *shedMaxLimit
+		cfg.MaxQueue = *shedQueue
+		cfg.QueueTimeout = *shedQueueTimeout
+		cfg.LatencyTarget = *shedLatency
+		shedder = loadshed.NewLimiter(cfg)
+		grpcOpts = append(grpcOpts,
+			grpc.ChainUnaryInterceptor(shedder.UnaryInterceptor),
+			grpc.ChainStreamInterceptor(shedder.StreamInterceptor))
+	}
+
+	// Create gRPC server
+	if tlsConfig != nil && !*singlePort {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := grpc.NewServer(grpcOpts...)
+	pb.RegisterItemServiceServer(s, itemServer)
+	pb.RegisterJobServiceServer(s, jobs.NewServer(queue))
+	if follower == nil {
+		pb.RegisterSchemaServiceServer(s, grpcserver.NewSchemaServer(db, rules))
+		pb.RegisterWebhookServiceServer(s, webhook.NewServer(db, webhookPolicy))
+		pb.RegisterCollectionServiceServer(s, collections.NewServer(db, rules))
+		pb.RegisterAttachmentServiceServer(s, attachments.NewServer(attachmentSvc))
+		pb.RegisterReplicationServiceServer(s, replication.NewServer(db, rules))
+	}
+
+	// Enable server reflection so tools like grpcurl can discover services
+	reflection.Register(s)
+
+	// Serve gRPC-Web and Connect on the HTTP port next to the REST routes
+	rpcPrefix, rpcHandler := connectserver.Handler(itemServer)
+	var handler http.Handler = connectserver.Multiplex(rpcPrefix, rpcHandler, router)
+	if follower != nil {
+		handler = follower.Middleware(primaryURL)(handler)
+	}
+	if shedder != nil {
+		handler = shedder.Middleware(handler)
+	}
+	if limiter != nil {
+		handler = limiter.Middleware(handler)
+	}
+	if principals != nil {
+		handler = principals.Middleware(handler)
+	}
+	if *corsOrigins != "" {
+		handler = middleware.CORS(strings.Split(*corsOrigins, ","))(handler)
+	}
+
+	if *singlePort {
+		// Serve REST, gRPC-Web, Connect and native gRPC on a single listener
+		lis, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen: %v", err)
+		}
+		log.Printf("HTTP and gRPC server starting on %s", *httpAddr)
+		if err := server.Serve(server.NewSinglePort(s, handler, tlsConfig), lis); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// Start gRPC server
-	lis, err := net.Listen("tcp", ":50051")
+	lis, err := net.Listen("tcp", *grpcAddr)
 	if err != nil {
 		log.Fatalf("Failed to listen for gRPC: %v", err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterItemServiceServer(s, grpcserver.NewItemServer(db))
 
 	// Start gRPC server in a goroutine
 	go func() {
-		log.Println("gRPC server starting on :50051")
+		log.Printf("gRPC server starting on %s", *grpcAddr)
 		if err := s.Serve(lis); err != nil {
 			log.Fatalf("Failed to serve gRPC: %v", err)
 		}
 	}()
 
 	// Start HTTP server
-	log.Println("HTTP server starting on :8080")
-	if err := http.ListenAndServe(":8080", router); err != nil {
+	httpLis, err := net.Listen("tcp", *httpAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen for HTTP: %v", err)
+	}
+	log.Printf("HTTP server starting on %s", *httpAddr)
+	if err := server.Serve(&http.Server{Handler: handler, TLSConfig: tlsConfig}, httpLis); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// newLimiter builds the rate limiter from the command line flags. It returns
+// nil when no budget is configured.
+func newLimiter(db *sql.DB, read, write, overrides, store, apiKeys string) (*ratelimit.Limiter, error) {
+	if read == "" && write == "" && overrides == "" {
+		return nil, nil
+	}
+
+	var cfg ratelimit.Config
+	var err error
+	if read != "" {
+		if cfg.Read, err = ratelimit.ParseRate(read); err != nil {
+			return nil, err
+		}
+	}
+	if write != "" {
+		if cfg.Write, err = ratelimit.ParseRate(write); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Overrides, err = ratelimit.ParseOverrides(overrides); err != nil {
+		return nil, err
+	}
+	if apiKeys != "" {
+		if cfg.APIKeys, err = ratelimit.LoadAPIKeys(apiKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	switch store {
+	case "memory":
+		return ratelimit.NewLimiter(cfg, ratelimit.NewMemoryStore()), nil
+	case "sqlite":
+		s, err := ratelimit.NewSQLiteStore(db)
+		if err != nil {
+			return nil, err
+		}
+		go s.Run(context.Background())
+		return ratelimit.NewLimiter(cfg, s), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", store)
+	}
+}
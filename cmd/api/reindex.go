@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+)
+
+// runReindex implements "api reindex": rebuild the full-text search index
+// from the items table
+func runReindex(args []string) {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	dbPath := fs.String("db", "./data.db", "Database file to reindex")
+	fs.Parse(args)
+
+	db, err := database.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+
+	count, err := database.Reindex(context.Background(), db)
+	if err != nil {
+		log.Fatalf("Reindex failed: %v", err)
+	}
+	fmt.Printf("Indexed %d items\n", count)
+}
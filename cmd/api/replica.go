@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/angel/go-api-sqlite/internal/replication"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newFollower connects to the primary and creates the follower applying its
+// change stream to db
+func newFollower(db *sql.DB, addr, caFile string) (*replication.Follower, *grpc.ClientConn, error) {
+	if addr == "" {
+		return nil, nil, errors.New("replica mode requires -primary-grpc-addr")
+	}
+
+	creds := insecure.NewCredentials()
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		creds = credentials.NewTLS(&tls.Config{RootCAs: pool})
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, nil, err
+	}
+	follower, err := replication.NewFollower(db, conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return follower, conn, nil
+}
@@ -2,17 +2,34 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"log"
+	"os"
 	"time"
 
 	pb "github.com/angel/go-api-sqlite/proto"
+	"google.golang.org/genproto/googleapis/type/decimal"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
+	addr := flag.String("addr", "localhost:50051", "gRPC server address")
+	caFile := flag.String("ca", "", "CA bundle used to verify the server certificate (enables TLS)")
+	certFile := flag.String("cert", "", "Client certificate for mutual TLS")
+	keyFile := flag.String("key", "", "Client private key for mutual TLS")
+	flag.Parse()
+
+	creds := insecure.NewCredentials()
+	if *caFile != "" {
+		creds = credentials.NewTLS(loadTLSConfig(*caFile, *certFile, *keyFile))
+	}
+
 	// Connect to gRPC server
-	conn, err := grpc.Dial("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(*addr, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
@@ -27,12 +44,12 @@ func main() {
 	log.Println("Creating item...")
 	item, err := client.CreateItem(ctx, &pb.CreateItemRequest{
 		Name:  "Test Item",
-		Value: 29.99,
+		Value: &decimal.Decimal{Value: "29.99"},
 	})
 	if err != nil {
 		log.Fatalf("Failed to create item: %v", err)
 	}
-	log.Printf("Created item: ID=%s, Name=%s, Value=%.2f\n", item.Id, item.Name, item.Value)
+	log.Printf("Created item: ID=%s, Name=%s, Value=%s\n", item.Id, item.Name, item.GetValue().GetValue())
 
 	// Get the item
 	log.Println("\nGetting item...")
@@ -42,19 +59,19 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to get item: %v", err)
 	}
-	log.Printf("Got item: ID=%s, Name=%s, Value=%.2f\n", getItem.Id, getItem.Name, getItem.Value)
+	log.Printf("Got item: ID=%s, Name=%s, Value=%s\n", getItem.Id, getItem.Name, getItem.GetValue().GetValue())
 
 	// Update the item
 	log.Println("\nUpdating item...")
 	updatedItem, err := client.UpdateItem(ctx, &pb.UpdateItemRequest{
 		Id:    item.Id,
 		Name:  "Updated Test Item",
-		Value: 39.99,
+		Value: &decimal.Decimal{Value: "39.99"},
 	})
 	if err != nil {
 		log.Fatalf("Failed to update item: %v", err)
 	}
-	log.Printf("Updated item: ID=%s, Name=%s, Value=%.2f\n", updatedItem.Id, updatedItem.Name, updatedItem.Value)
+	log.Printf("Updated item: ID=%s, Name=%s, Value=%s\n", updatedItem.Id, updatedItem.Name, updatedItem.GetValue().GetValue())
 
 	// List all items
 	log.Println("\nListing all items...")
@@ -64,7 +81,7 @@ func main() {
 	}
 	log.Printf("Found %d items:\n", len(listResponse.Items))
 	for _, it := range listResponse.Items {
-		log.Printf("- ID=%s, Name=%s, Value=%.2f\n", it.Id, it.Name, it.Value)
+		log.Printf("- ID=%s, Name=%s, Value=%s\n", it.Id, it.Name, it.GetValue().GetValue())
 	}
 
 	// Delete the item
@@ -88,3 +105,26 @@ func main() {
 		log.Fatal("Item still exists after deletion!")
 	}
 }
+
+// loadTLSConfig builds a client TLS configuration trusting caFile and, when
+// given, presenting a client certificate for mutual TLS
+func loadTLSConfig(caFile, certFile, keyFile string) *tls.Config {
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		log.Fatalf("Failed to read CA bundle: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		log.Fatalf("No certificates found in %s", caFile)
+	}
+
+	cfg := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg
+}
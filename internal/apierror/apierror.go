@@ -0,0 +1,234 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain identifies this service in ErrorInfo details
+const Domain = "go-api-sqlite"
+
+// Stable reason codes shared by the REST and gRPC transports
+const (
+	ReasonValidationFailed = "VALIDATION_FAILED"
+	ReasonMalformedRequest = "MALFORMED_REQUEST"
+	ReasonNotFound         = "NOT_FOUND"
+	ReasonAlreadyExists    = "ALREADY_EXISTS"
+	ReasonDatabaseBusy     = "DATABASE_BUSY"
+	ReasonInternal         = "INTERNAL"
+)
+
+// FieldViolation describes a single invalid field in a request
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// Error is a transport-neutral API error that renders as a gRPC status with
+// rich details or as an RFC 7807 problem document
+type Error struct {
+	Code          codes.Code
+	Reason        string
+	Message       string
+	Violations    []FieldViolation
+	RetryAfter    time.Duration
+	CorrelationID string
+	Metadata      map[string]string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// InvalidArgument returns a validation error carrying the given field violations
+func InvalidArgument(message string, violations ...FieldViolation) *Error {
+	return &Error{
+		Code:       codes.InvalidArgument,
+		Reason:     ReasonValidationFailed,
+		Message:    message,
+		Violations: violations,
+	}
+}
+
+// Malformed returns an error for request bodies that could not be decoded
+func Malformed(err error) *Error {
+	return &Error{
+		Code:    codes.InvalidArgument,
+		Reason:  ReasonMalformedRequest,
+		Message: fmt.Sprintf("malformed request body: %v", err),
+	}
+}
+
+// NotFound returns an error for a missing resource
+func NotFound(resource, id string) *Error {
+	return &Error{
+		Code:     codes.NotFound,
+		Reason:   ReasonNotFound,
+		Message:  resource + " not found",
+		Metadata: map[string]string{"resource": resource, "id": id},
+	}
+}
+
+// AlreadyExists returns an error for a resource that cannot be created twice
+func AlreadyExists(resource, id string) *Error {
+	return &Error{
+		Code:     codes.AlreadyExists,
+		Reason:   ReasonAlreadyExists,
+		Message:  resource + " already exists",
+		Metadata: map[string]string{"resource": resource, "id": id},
+	}
+}
+
+// Internal sanitizes an unexpected error. The raw error is logged together
+// with a correlation ID and only the ID is returned to the caller. SQLite lock
+// contention is reported as a retryable Unavailable error instead.
+func Internal(err error, op string) *Error {
+	id := uuid.New().String()
+	log.Printf("internal error [correlation_id=%s] %s: %v", id, op, err)
+
+	if database.IsBusy(err) {
+		return &Error{
+			Code:          codes.Unavailable,
+			Reason:        ReasonDatabaseBusy,
+			Message:       "database is busy, please retry",
+			RetryAfter:    time.Second,
+			CorrelationID: id,
+		}
+	}
+	return &Error{
+		Code:          codes.Internal,
+		Reason:        ReasonInternal,
+		Message:       "internal server error",
+		CorrelationID: id,
+	}
+}
+
+// From converts any error into an *Error, sanitizing unknown errors
+func From(err error, op string) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return Internal(err, op)
+}
+
+// FromStatus rebuilds an *Error from a gRPC status, recovering the reason,
+// field violations and retry delay from its details
+func FromStatus(st *status.Status) *Error {
+	e := &Error{Code: st.Code(), Reason: reasonForCode(st.Code()), Message: st.Message()}
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			e.Reason = d.Reason
+			for k, v := range d.Metadata {
+				if k == "correlation_id" {
+					e.CorrelationID = v
+					continue
+				}
+				if e.Metadata == nil {
+					e.Metadata = make(map[string]string)
+				}
+				e.Metadata[k] = v
+			}
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				e.Violations = append(e.Violations, FieldViolation{Field: v.Field, Description: v.Description})
+			}
+		case *errdetails.RetryInfo:
+			e.RetryAfter = d.RetryDelay.AsDuration()
+		}
+	}
+	return e
+}
+
+// GRPCStatus renders the error as a gRPC status with BadRequest, ErrorInfo and
+// RetryInfo details. It lets handlers return *Error directly from RPCs.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Message)
+
+	info := &errdetails.ErrorInfo{Reason: e.Reason, Domain: Domain}
+	if len(e.Metadata) > 0 || e.CorrelationID != "" {
+		info.Metadata = make(map[string]string, len(e.Metadata)+1)
+		for k, v := range e.Metadata {
+			info.Metadata[k] = v
+		}
+		if e.CorrelationID != "" {
+			info.Metadata["correlation_id"] = e.CorrelationID
+		}
+	}
+	details := []protoadapt.MessageV1{info}
+
+	if len(e.Violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, v := range e.Violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		details = append(details, br)
+	}
+	if e.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)})
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// HTTPStatus maps the gRPC code to the equivalent HTTP status
+func (e *Error) HTTPStatus() int {
+	switch e.Code {
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled:
+		return 499
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// reasonForCode derives a reason such as NOT_FOUND from a gRPC code name
+func reasonForCode(code codes.Code) string {
+	var b strings.Builder
+	for i, r := range code.String() {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package apierror
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProblemContentType is the media type of RFC 7807 error bodies
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document extended with the same
+// reason code and field violations exposed over gRPC
+type Problem struct {
+	Type          string           `json:"type"`
+	Title         string           `json:"title"`
+	Status        int              `json:"status"`
+	Detail        string           `json:"detail,omitempty"`
+	Instance      string           `json:"instance,omitempty"`
+	Code          string           `json:"code"`
+	CorrelationID string           `json:"correlation_id,omitempty"`
+	RetryAfter    int              `json:"retry_after,omitempty"`
+	Errors        []FieldViolation `json:"errors,omitempty"`
+}
+
+// Problem converts the error into a problem document for the given request path
+func (e *Error) Problem(instance string) Problem {
+	p := Problem{
+		Type:          "urn:problem-type:" + Domain + ":" + strings.ToLower(strings.ReplaceAll(e.Reason, "_", "-")),
+		Title:         http.StatusText(e.HTTPStatus()),
+		Status:        e.HTTPStatus(),
+		Detail:        e.Message,
+		Instance:      instance,
+		Code:          e.Reason,
+		CorrelationID: e.CorrelationID,
+		Errors:        e.Violations,
+	}
+	if e.RetryAfter > 0 {
+		p.RetryAfter = int(math.Ceil(e.RetryAfter.Seconds()))
+	}
+	return p
+}
+
+// Write renders err as an application/problem+json response. Errors that are
+// not *Error are sanitized with Internal.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := From(err, r.Method+" "+r.URL.Path)
+	problem := apiErr.Problem(r.URL.Path)
+
+	if apiErr.CorrelationID != "" {
+		w.Header().Set("X-Correlation-ID", apiErr.CorrelationID)
+	}
+	if problem.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(problem.RetryAfter))
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
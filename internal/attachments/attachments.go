@@ -0,0 +1,665 @@
+package attachments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+// Reasons reported for chunks that cannot be written
+const (
+	// ReasonUploadOffsetMismatch is a chunk that does not start where the
+	// upload left off
+	ReasonUploadOffsetMismatch = "UPLOAD_OFFSET_MISMATCH"
+	// ReasonUploadBusy is a chunk sent while another one is being written
+	ReasonUploadBusy = "UPLOAD_BUSY"
+)
+
+// MaxFilenameLength is the most characters kept of a file name
+const MaxFilenameLength = 255
+
+// Config tunes attachment storage
+type Config struct {
+	// Dir holds the blob store
+	Dir string
+	// MaxSize is the largest attachment in bytes
+	MaxSize int64
+	// Types lists the sniffed MIME types accepted, such as image/png, or
+	// image/* for a whole family
+	Types []string
+	// UploadExpiry is how long a resumable upload may go without a chunk
+	// before it is discarded
+	UploadExpiry time.Duration
+	// GCInterval is how often unreferenced blobs and expired uploads are
+	// removed
+	GCInterval time.Duration
+}
+
+// DefaultConfig accepts images and PDFs of up to 25 MiB
+func DefaultConfig() Config {
+	return Config{
+		Dir:          "./attachments",
+		MaxSize:      25 << 20,
+		Types:        []string{"image/*", "application/pdf"},
+		UploadExpiry: 24 * time.Hour,
+		GCInterval:   time.Minute,
+	}
+}
+
+// Attachment is a file attached to an item. Its content is the blob with
+// the given SHA-256; ContentType is sniffed from the content, not taken from
+// the client.
+type Attachment struct {
+	ID          string    `json:"id"`
+	ItemID      string    `json:"item_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Upload is a resumable upload in progress. Chunks are appended in order
+// until Received reaches Size, which creates the attachment.
+type Upload struct {
+	ID        string    `json:"id"`
+	ItemID    string    `json:"item_id"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	Received  int64     `json:"received"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Collected counts what one garbage collection removed
+type Collected struct {
+	Blobs   int `json:"blobs"`
+	Uploads int `json:"uploads"`
+}
+
+// Service stores attachments in a database and their content in a Store.
+// Run one service per store directory.
+type Service struct {
+	db    *sql.DB
+	store *Store
+	cfg   Config
+
+	// gc is held for writing while garbage is collected, and for reading
+	// from when a blob is committed until its attachment row is written, so
+	// a blob is never removed between the two
+	gc sync.RWMutex
+	// uploads holds a *sync.Mutex per upload, taken while a chunk is written
+	uploads sync.Map
+	wake    chan struct{}
+}
+
+// New opens the blob store in cfg.Dir, filling unset fields of cfg from
+// DefaultConfig
+func New(db *sql.DB, cfg Config) (*Service, error) {
+	def := DefaultConfig()
+	if cfg.Dir == "" {
+		cfg.Dir = def.Dir
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = def.MaxSize
+	}
+	if cfg.Types == nil {
+		cfg.Types = def.Types
+	}
+	if cfg.UploadExpiry <= 0 {
+		cfg.UploadExpiry = def.UploadExpiry
+	}
+	if cfg.GCInterval <= 0 {
+		cfg.GCInterval = def.GCInterval
+	}
+	store, err := OpenStore(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{db: db, store: store, cfg: cfg, wake: make(chan struct{}, 1)}, nil
+}
+
+// MaxSize is the largest attachment accepted, in bytes
+func (s *Service) MaxSize() int64 {
+	return s.cfg.MaxSize
+}
+
+// Create stores the content of r as an attachment of an item. Content that
+// is empty, too large or of a type not accepted is an apierror
+// InvalidArgument. Errors are *apierror.Error values.
+func (s *Service) Create(ctx context.Context, itemID, filename string, r io.Reader) (*Attachment, error) {
+	if err := s.checkItem(ctx, itemID); err != nil {
+		return nil, err
+	}
+	return s.create(ctx, itemID, filename, r)
+}
+
+func (s *Service) create(ctx context.Context, itemID, filename string, r io.Reader) (*Attachment, error) {
+	blob, err := s.store.write(r, s.cfg.MaxSize)
+	if errors.Is(err, errTooLarge) {
+		return nil, apierror.InvalidArgument("invalid attachment", apierror.FieldViolation{Field: "file",
+			Description: fmt.Sprintf("file must be at most %d bytes", s.cfg.MaxSize)})
+	}
+	if err != nil {
+		// The reader may fail with an *apierror.Error of its own
+		return nil, apierror.From(err, "storing attachment")
+	}
+	var violation string
+	switch {
+	case blob.size == 0:
+		violation = "file must not be empty"
+	case !s.accepts(blob.contentType):
+		violation = fmt.Sprintf("file type %s is not accepted; must be one of %s", blob.contentType, strings.Join(s.cfg.Types, ", "))
+	}
+	if violation != "" {
+		s.store.discard(blob)
+		return nil, apierror.InvalidArgument("invalid attachment", apierror.FieldViolation{Field: "file", Description: violation})
+	}
+
+	s.gc.RLock()
+	defer s.gc.RUnlock()
+	if err := s.store.commit(blob); err != nil {
+		s.store.discard(blob)
+		return nil, apierror.Internal(err, "storing attachment")
+	}
+
+	a := &Attachment{
+		ID:          uuid.New().String(),
+		ItemID:      itemID,
+		Filename:    cleanFilename(filename),
+		ContentType: blob.contentType,
+		Size:        blob.size,
+		SHA256:      blob.sha256,
+		CreatedAt:   time.Now().UTC(),
+	}
+	err = database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		// The item may have been deleted while the content was written
+		result, err := tx.ExecContext(ctx, `INSERT INTO attachments (id, item_id, filename, content_type, size, sha256, created_at)
+			SELECT ?, ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM items WHERE id = ?)`,
+			a.ID, a.ItemID, a.Filename, a.ContentType, a.Size, a.SHA256, a.CreatedAt, itemID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return apierror.NotFound("item", itemID)
+		}
+		return nil
+	})
+	if err != nil {
+		s.queueGarbage(blob.sha256)
+		return nil, apierror.From(err, "storing attachment")
+	}
+	return a, nil
+}
+
+// queueGarbage queues a blob for collection unless an attachment uses it
+func (s *Service) queueGarbage(sum string) {
+	err := database.WithTx(context.Background(), s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT OR IGNORE INTO blob_garbage (sha256)
+			SELECT ? WHERE NOT EXISTS (SELECT 1 FROM attachments WHERE sha256 = ?)`, sum, sum)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error queueing blob %s for collection: %v", sum, err)
+	}
+}
+
+// accepts reports whether a sniffed MIME type is one of cfg.Types
+func (s *Service) accepts(contentType string) bool {
+	for _, t := range s.cfg.Types {
+		if t == contentType || t == "*/*" {
+			return true
+		}
+		if family, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(contentType, family+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkItem returns an apierror NotFound for a missing item
+func (s *Service) checkItem(ctx context.Context, itemID string) error {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM items WHERE id = ?)", itemID).Scan(&exists); err != nil {
+		return apierror.Internal(err, "reading item")
+	}
+	if !exists {
+		return apierror.NotFound("item", itemID)
+	}
+	return nil
+}
+
+const attachmentColumns = "id, item_id, filename, content_type, size, sha256, created_at"
+
+func scanAttachment(row interface{ Scan(...any) error }) (*Attachment, error) {
+	var a Attachment
+	if err := row.Scan(&a.ID, &a.ItemID, &a.Filename, &a.ContentType, &a.Size, &a.SHA256, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// List returns the attachments of an item, oldest first. Errors are
+// *apierror.Error values.
+func (s *Service) List(ctx context.Context, itemID string) ([]*Attachment, error) {
+	if err := s.checkItem(ctx, itemID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE item_id = ? ORDER BY created_at, id", itemID)
+	if err != nil {
+		return nil, apierror.Internal(err, "listing attachments")
+	}
+	defer rows.Close()
+
+	list := make([]*Attachment, 0)
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, apierror.Internal(err, "scanning attachment")
+		}
+		list = append(list, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "listing attachments")
+	}
+	return list, nil
+}
+
+// Get returns an attachment of an item. Errors are *apierror.Error values.
+func (s *Service) Get(ctx context.Context, itemID, id string) (*Attachment, error) {
+	a, err := scanAttachment(s.db.QueryRowContext(ctx,
+		"SELECT "+attachmentColumns+" FROM attachments WHERE id = ? AND item_id = ?", id, itemID))
+	if err == sql.ErrNoRows {
+		return nil, apierror.NotFound("attachment", id)
+	}
+	if err != nil {
+		return nil, apierror.Internal(err, "reading attachment")
+	}
+	return a, nil
+}
+
+// Open returns an attachment with its content, which the caller closes.
+// Errors are *apierror.Error values.
+func (s *Service) Open(ctx context.Context, itemID, id string) (*Attachment, *os.File, error) {
+	a, err := s.Get(ctx, itemID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := s.store.Open(a.SHA256)
+	if err != nil {
+		return nil, nil, apierror.Internal(err, "opening attachment")
+	}
+	return a, f, nil
+}
+
+// Delete removes an attachment. Its blob is collected once no attachment
+// uses it. Errors are *apierror.Error values.
+func (s *Service) Delete(ctx context.Context, itemID, id string) error {
+	err := database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM attachments WHERE id = ? AND item_id = ?", id, itemID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return apierror.NotFound("attachment", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return apierror.From(err, "deleting attachment")
+	}
+	s.Wake()
+	return nil
+}
+
+// StartUpload begins a resumable upload of size bytes. Errors are
+// *apierror.Error values.
+func (s *Service) StartUpload(ctx context.Context, itemID, filename string, size int64) (*Upload, error) {
+	if size <= 0 || size > s.cfg.MaxSize {
+		return nil, apierror.InvalidArgument("invalid upload", apierror.FieldViolation{Field: "size",
+			Description: fmt.Sprintf("size must be between 1 and %d bytes", s.cfg.MaxSize)})
+	}
+	if err := s.checkItem(ctx, itemID); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	u := &Upload{ID: uuid.New().String(), ItemID: itemID, Filename: cleanFilename(filename), Size: size, CreatedAt: now, UpdatedAt: now}
+
+	// The row comes first: Collect removes upload files without one
+	err := database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO attachment_uploads (id, item_id, filename, size, received, created_at, updated_at)
+			VALUES (?, ?, ?, ?, 0, ?, ?)`, u.ID, u.ItemID, u.Filename, u.Size, u.CreatedAt, u.UpdatedAt)
+		return err
+	})
+	if err != nil {
+		return nil, apierror.Internal(err, "creating upload")
+	}
+	return u, nil
+}
+
+const uploadColumns = "id, item_id, filename, size, received, created_at, updated_at"
+
+// GetUpload returns an upload in progress. Errors are *apierror.Error
+// values.
+func (s *Service) GetUpload(ctx context.Context, itemID, id string) (*Upload, error) {
+	var u Upload
+	err := s.db.QueryRowContext(ctx, "SELECT "+uploadColumns+" FROM attachment_uploads WHERE id = ? AND item_id = ?", id, itemID).
+		Scan(&u.ID, &u.ItemID, &u.Filename, &u.Size, &u.Received, &u.CreatedAt, &u.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, apierror.NotFound("upload", id)
+	}
+	if err != nil {
+		return nil, apierror.Internal(err, "reading upload")
+	}
+	return &u, nil
+}
+
+// WriteChunk appends the content of r to an upload. The chunk must start at
+// offset Received, or it fails with ReasonUploadOffsetMismatch; what arrives
+// before r fails is kept, so a client resumes from the new Received. The
+// chunk that completes the upload returns the attachment. Errors are
+// *apierror.Error values.
+func (s *Service) WriteChunk(ctx context.Context, itemID, id string, offset int64, r io.Reader) (*Upload, *Attachment, error) {
+	// Look the upload up before taking its lock, so unknown IDs add no lock
+	if _, err := s.GetUpload(ctx, itemID, id); err != nil {
+		return nil, nil, err
+	}
+	mu, _ := s.uploads.LoadOrStore(id, &sync.Mutex{})
+	if !mu.(*sync.Mutex).TryLock() {
+		return nil, nil, &apierror.Error{Code: codes.Aborted, Reason: ReasonUploadBusy,
+			Message: "another chunk of this upload is being written"}
+	}
+	defer mu.(*sync.Mutex).Unlock()
+
+	// The upload may have completed or been cancelled before the lock was
+	// taken, in which case the lock is dropped again
+	u, err := s.GetUpload(ctx, itemID, id)
+	if err != nil {
+		s.uploads.CompareAndDelete(id, mu)
+		return nil, nil, err
+	}
+	if offset != u.Received {
+		return nil, nil, &apierror.Error{Code: codes.Aborted, Reason: ReasonUploadOffsetMismatch,
+			Message:  fmt.Sprintf("chunk starts at byte %d but the upload has received %d", offset, u.Received),
+			Metadata: map[string]string{"received": fmt.Sprint(u.Received)}}
+	}
+
+	path := s.store.uploadPath(id)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, apierror.Internal(err, "writing upload")
+	}
+	// Drop anything past Received left by a chunk that failed to record
+	if err := f.Truncate(u.Received); err != nil {
+		f.Close()
+		return nil, nil, apierror.Internal(err, "writing upload")
+	}
+	if _, err := f.Seek(u.Received, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, apierror.Internal(err, "writing upload")
+	}
+	n, copyErr := io.Copy(f, io.LimitReader(r, u.Size-u.Received+1))
+	if u.Received+n > u.Size {
+		// Refuse the whole chunk so the upload stays resumable
+		n = 0
+		f.Truncate(u.Received)
+		copyErr = apierror.InvalidArgument("invalid chunk", apierror.FieldViolation{Field: "chunk",
+			Description: fmt.Sprintf("chunk goes past the upload size of %d bytes", u.Size)})
+	}
+	if err := f.Sync(); err != nil && copyErr == nil {
+		copyErr = apierror.Internal(err, "writing upload")
+	}
+	f.Close()
+
+	u.Received += n
+	u.UpdatedAt = time.Now().UTC()
+	err = database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE attachment_uploads SET received = ?, updated_at = ? WHERE id = ?",
+			u.Received, u.UpdatedAt, id)
+		return err
+	})
+	if err != nil {
+		return nil, nil, apierror.Internal(err, "recording upload")
+	}
+	if copyErr != nil {
+		return nil, nil, apierror.From(copyErr, "receiving chunk")
+	}
+	if u.Received < u.Size {
+		return u, nil, nil
+	}
+
+	content, err := os.Open(path)
+	if err != nil {
+		return nil, nil, apierror.Internal(err, "reading upload")
+	}
+	a, err := s.create(ctx, itemID, u.Filename, content)
+	content.Close()
+	if err != nil {
+		// Content that is rejected would be rejected again on a retry
+		var apiErr *apierror.Error
+		if errors.As(err, &apiErr) && apiErr.Code == codes.InvalidArgument {
+			s.removeUpload(id)
+		}
+		return nil, nil, err
+	}
+	s.removeUpload(id)
+	return u, a, nil
+}
+
+// CancelUpload discards an upload in progress. Errors are *apierror.Error
+// values.
+func (s *Service) CancelUpload(ctx context.Context, itemID, id string) error {
+	if _, err := s.GetUpload(ctx, itemID, id); err != nil {
+		return err
+	}
+	s.removeUpload(id)
+	return nil
+}
+
+// removeUpload deletes an upload and its content
+func (s *Service) removeUpload(id string) {
+	err := database.WithTx(context.Background(), s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM attachment_uploads WHERE id = ?", id)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error deleting upload %s: %v", id, err)
+		return
+	}
+	os.Remove(s.store.uploadPath(id))
+	s.uploads.Delete(id)
+}
+
+// Wake asks Run to collect garbage now
+func (s *Service) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run collects garbage every cfg.GCInterval and when woken until ctx ends
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.GCInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Collect(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error collecting attachment garbage: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// Collect removes the blobs no attachment uses any more, uploads without a
+// chunk for cfg.UploadExpiry and the content of uploads whose item was
+// deleted
+func (s *Service) Collect(ctx context.Context) (Collected, error) {
+	s.gc.Lock()
+	defer s.gc.Unlock()
+	var c Collected
+
+	// Temporary blobs this old were left by a crash
+	expired := time.Now().Add(-s.cfg.UploadExpiry)
+	tmp, _ := filepath.Glob(filepath.Join(s.store.dir, "tmp", "*"))
+	for _, path := range tmp {
+		if info, err := os.Stat(path); err == nil && info.ModTime().Before(expired) {
+			os.Remove(path)
+		}
+	}
+
+	// List expired uploads outside a transaction so an idle collector takes
+	// no write lock
+	var ids []string
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM attachment_uploads WHERE updated_at < ?", expired.UTC())
+	if err != nil {
+		return c, err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return c, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return c, err
+	}
+	if len(ids) > 0 {
+		var removed []string
+		err := database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+			removed = removed[:0]
+			for _, id := range ids {
+				// The upload may have been resumed since it was listed
+				result, err := tx.ExecContext(ctx, "DELETE FROM attachment_uploads WHERE id = ? AND updated_at < ?", id, expired.UTC())
+				if err != nil {
+					return err
+				}
+				if n, err := result.RowsAffected(); err != nil {
+					return err
+				} else if n > 0 {
+					removed = append(removed, id)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return c, err
+		}
+		// Drop their chunk locks, which would otherwise stay in memory
+		for _, id := range removed {
+			s.uploads.Delete(id)
+		}
+	}
+
+	uploads, err := os.ReadDir(filepath.Join(s.store.dir, "uploads"))
+	if err != nil {
+		return c, err
+	}
+	for _, entry := range uploads {
+		if mu, ok := s.uploads.Load(entry.Name()); ok && !mu.(*sync.Mutex).TryLock() {
+			// A chunk is being written
+			continue
+		} else if ok {
+			mu.(*sync.Mutex).Unlock()
+		}
+		var exists bool
+		err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM attachment_uploads WHERE id = ?)", entry.Name()).Scan(&exists)
+		if err != nil {
+			return c, err
+		}
+		if !exists {
+			if err := os.Remove(s.store.uploadPath(entry.Name())); err != nil {
+				return c, err
+			}
+			s.uploads.Delete(entry.Name())
+			c.Uploads++
+		}
+	}
+
+	rows, err = s.db.QueryContext(ctx, `SELECT sha256, EXISTS (SELECT 1 FROM attachments a WHERE a.sha256 = g.sha256)
+		FROM blob_garbage g`)
+	if err != nil {
+		return c, err
+	}
+	type candidate struct {
+		sum  string
+		used bool
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var cand candidate
+		if err := rows.Scan(&cand.sum, &cand.used); err != nil {
+			rows.Close()
+			return c, err
+		}
+		candidates = append(candidates, cand)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return c, err
+	}
+	for _, cand := range candidates {
+		if !cand.used {
+			if err := s.store.remove(cand.sum); err != nil {
+				return c, err
+			}
+			c.Blobs++
+		}
+		err := database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "DELETE FROM blob_garbage WHERE sha256 = ?", cand.sum)
+			return err
+		})
+		if err != nil {
+			return c, err
+		}
+	}
+	if c.Blobs > 0 || c.Uploads > 0 {
+		log.Printf("Collected %d unreferenced blobs and %d abandoned uploads", c.Blobs, c.Uploads)
+	}
+	return c, nil
+}
+
+// cleanFilename keeps the base name of a client file name without control
+// characters, or "attachment" when nothing is left
+func cleanFilename(name string) string {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name))
+	if utf8.RuneCountInString(name) > MaxFilenameLength {
+		name = string([]rune(name)[:MaxFilenameLength])
+	}
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	return name
+}
@@ -0,0 +1,157 @@
+package attachments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// chunkSize is the most content sent in one DownloadAttachment message
+const chunkSize = 64 << 10
+
+// Server implements the AttachmentService gRPC service
+type Server struct {
+	pb.UnimplementedAttachmentServiceServer
+	svc *Service
+}
+
+// NewServer creates an attachment server backed by svc
+func NewServer(svc *Service) *Server {
+	return &Server{svc: svc}
+}
+
+// UploadAttachment stores the chunks sent after an info message as one
+// attachment
+func (s *Server) UploadAttachment(stream pb.AttachmentService_UploadAttachmentServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF || (err == nil && first.GetInfo() == nil) {
+		return apierror.InvalidArgument("invalid upload", apierror.FieldViolation{Field: "info",
+			Description: "the first message must carry info"})
+	}
+	if err != nil {
+		return err
+	}
+	info := first.GetInfo()
+
+	pr, pw := io.Pipe()
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err == io.EOF {
+				pw.Close()
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if req.GetInfo() != nil {
+				pw.CloseWithError(apierror.InvalidArgument("invalid upload", apierror.FieldViolation{Field: "info",
+					Description: "info must only be sent first"}))
+				return
+			}
+			if _, err := pw.Write(req.GetChunk()); err != nil {
+				return
+			}
+		}
+	}()
+	a, err := s.svc.Create(stream.Context(), info.ItemId, info.Filename, pr)
+	// Unblocks the receiver when Create stopped reading early
+	pr.CloseWithError(errors.New("upload ended"))
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(attachmentProto(a))
+}
+
+// DownloadAttachment sends an attachment followed by its content
+func (s *Server) DownloadAttachment(req *pb.DownloadAttachmentRequest, stream pb.AttachmentService_DownloadAttachmentServer) error {
+	a, f, err := s.svc.Open(stream.Context(), req.ItemId, req.Id)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if req.Offset < 0 || req.Offset > a.Size {
+		return apierror.InvalidArgument("invalid download", apierror.FieldViolation{Field: "offset",
+			Description: fmt.Sprintf("offset must be between 0 and %d", a.Size)})
+	}
+	if req.Length < 0 {
+		return apierror.InvalidArgument("invalid download", apierror.FieldViolation{Field: "length",
+			Description: "length must not be negative"})
+	}
+	length := a.Size - req.Offset
+	if req.Length > 0 {
+		length = min(length, req.Length)
+	}
+
+	if err := stream.Send(&pb.DownloadAttachmentResponse{
+		Data: &pb.DownloadAttachmentResponse_Attachment{Attachment: attachmentProto(a)},
+	}); err != nil {
+		return err
+	}
+	r := io.NewSectionReader(f, req.Offset, length)
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.DownloadAttachmentResponse{
+				Data: &pb.DownloadAttachmentResponse_Chunk{Chunk: buf[:n]},
+			}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return apierror.Internal(err, "reading attachment")
+		}
+	}
+}
+
+// ListAttachments returns the attachments of an item
+func (s *Server) ListAttachments(ctx context.Context, req *pb.ListAttachmentsRequest) (*pb.ListAttachmentsResponse, error) {
+	list, err := s.svc.List(ctx, req.ItemId)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListAttachmentsResponse{}
+	for _, a := range list {
+		resp.Attachments = append(resp.Attachments, attachmentProto(a))
+	}
+	return resp, nil
+}
+
+// GetAttachment returns an attachment without its content
+func (s *Server) GetAttachment(ctx context.Context, req *pb.GetAttachmentRequest) (*pb.Attachment, error) {
+	a, err := s.svc.Get(ctx, req.ItemId, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return attachmentProto(a), nil
+}
+
+// DeleteAttachment removes an attachment
+func (s *Server) DeleteAttachment(ctx context.Context, req *pb.DeleteAttachmentRequest) (*pb.DeleteAttachmentResponse, error) {
+	if err := s.svc.Delete(ctx, req.ItemId, req.Id); err != nil {
+		return nil, err
+	}
+	return &pb.DeleteAttachmentResponse{Success: true}, nil
+}
+
+func attachmentProto(a *Attachment) *pb.Attachment {
+	return &pb.Attachment{
+		Id:          a.ID,
+		ItemId:      a.ItemID,
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Sha256:      a.SHA256,
+		CreatedAt:   timestamppb.New(a.CreatedAt),
+	}
+}
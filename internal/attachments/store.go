@@ -0,0 +1,143 @@
+package attachments
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen is how many leading bytes MIME sniffing looks at
+const sniffLen = 512
+
+// errTooLarge is returned by Store.write for content over the size limit
+var errTooLarge = errors.New("content is too large")
+
+// Store keeps blobs in a directory, named by the SHA-256 of their content so
+// identical files are stored once. Blobs are written to a temporary file
+// first and renamed into place, so a blob is either complete or absent.
+//
+// The layout is blobs/ab/abcdef… for content, uploads/<id> for resumable
+// uploads in progress and tmp/ for blobs being written.
+type Store struct {
+	dir string
+}
+
+// OpenStore creates the store directories under dir if needed
+func OpenStore(dir string) (*Store, error) {
+	for _, sub := range []string{"blobs", "uploads", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+// tempBlob is content written to the store but not yet committed
+type tempBlob struct {
+	path        string
+	sha256      string
+	size        int64
+	contentType string
+}
+
+// write copies r into a temporary file, hashing it and sniffing its MIME
+// type. Content over maxSize bytes is discarded with errTooLarge.
+func (s *Store) write(r io.Reader, maxSize int64) (*tempBlob, error) {
+	f, err := os.CreateTemp(filepath.Join(s.dir, "tmp"), "blob-*")
+	if err != nil {
+		return nil, err
+	}
+	blob := &tempBlob{path: f.Name()}
+	ok := false
+	defer func() {
+		if !ok {
+			f.Close()
+			os.Remove(blob.path)
+		}
+	}()
+
+	h := sha256.New()
+	head := &prefixWriter{max: sniffLen}
+	blob.size, err = io.Copy(io.MultiWriter(f, h, head), io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if blob.size > maxSize {
+		return nil, errTooLarge
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	blob.sha256 = hex.EncodeToString(h.Sum(nil))
+	blob.contentType, _, _ = mime.ParseMediaType(http.DetectContentType(head.buf))
+	ok = true
+	return blob, nil
+}
+
+// commit moves a temporary blob into place, or drops it when the store
+// already has the same content
+func (s *Store) commit(blob *tempBlob) error {
+	path := s.path(blob.sha256)
+	if _, err := os.Stat(path); err == nil {
+		return os.Remove(blob.path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(blob.path, path)
+}
+
+// discard removes a temporary blob
+func (s *Store) discard(blob *tempBlob) {
+	os.Remove(blob.path)
+}
+
+// Open opens the blob with the given SHA-256
+func (s *Store) Open(sum string) (*os.File, error) {
+	return os.Open(s.path(sum))
+}
+
+// remove deletes a blob; a missing blob is not an error
+func (s *Store) remove(sum string) error {
+	err := os.Remove(s.path(sum))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path is where the blob with the given SHA-256 is stored. Sums are only
+// ever produced by write, but are checked so a bad one cannot escape dir.
+func (s *Store) path(sum string) string {
+	if len(sum) != sha256.Size*2 || strings.Trim(sum, "0123456789abcdef") != "" {
+		return filepath.Join(s.dir, "blobs", "invalid")
+	}
+	return filepath.Join(s.dir, "blobs", sum[:2], sum)
+}
+
+// uploadPath is where the content of a resumable upload is gathered
+func (s *Store) uploadPath(id string) string {
+	return filepath.Join(s.dir, "uploads", filepath.Base(id))
+}
+
+// prefixWriter keeps the first max bytes written to it
+type prefixWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if n := w.max - len(w.buf); n > 0 {
+		w.buf = append(w.buf, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/attachments"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// png is enough of a PNG file for its type to be sniffed
+var png = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{1}, 100)...)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+// setup opens a database with items a and b and a service storing blobs in
+// the returned directory
+func setup(t *testing.T, cfg attachments.Config) (*sql.DB, *attachments.Service, string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, database.Migrate(db))
+	for _, id := range []string{"a", "b"} {
+		item := models.Item{ID: id, Name: "Widget"}
+		require.NoError(t, items.Validate(&item, money.Rules{}))
+		require.NoError(t, database.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+			return items.Insert(context.Background(), tx, &item)
+		}))
+	}
+	cfg.Dir = t.TempDir()
+	svc, err := attachments.New(db, cfg)
+	require.NoError(t, err)
+	return db, svc, cfg.Dir
+}
+
+// apiError returns err as an apierror
+func apiError(t *testing.T, err error) *apierror.Error {
+	t.Helper()
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr), "%v is not an apierror", err)
+	return apiErr
+}
+
+// blobs counts the blob files in the store
+func blobs(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "blobs", "*", "*"))
+	require.NoError(t, err)
+	return len(files)
+}
+
+func TestCreateDeduplicates(t *testing.T) {
+	_, svc, dir := setup(t, attachments.Config{})
+	ctx := context.Background()
+
+	first, err := svc.Create(ctx, "a", "../photo.png", bytes.NewReader(png))
+	require.NoError(t, err)
+	assert.Equal(t, "photo.png", first.Filename)
+	assert.Equal(t, "image/png", first.ContentType)
+	assert.Equal(t, int64(len(png)), first.Size)
+	assert.Len(t, first.SHA256, 64)
+
+	second, err := svc.Create(ctx, "b", "copy.png", bytes.NewReader(png))
+	require.NoError(t, err)
+	assert.Equal(t, first.SHA256, second.SHA256)
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Equal(t, 1, blobs(t, dir))
+
+	pdf, err := svc.Create(ctx, "a", "doc.pdf", strings.NewReader("%PDF-1.4\n%test"))
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", pdf.ContentType)
+	assert.Equal(t, 2, blobs(t, dir))
+
+	list, err := svc.List(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, first.ID, list[0].ID)
+
+	got, f, err := svc.Open(ctx, "b", second.ID)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, second.ID, got.ID)
+	_, err = svc.Get(ctx, "a", second.ID)
+	assert.Equal(t, codes.NotFound, apiError(t, err).Code)
+}
+
+func TestCreateRejects(t *testing.T) {
+	_, svc, dir := setup(t, attachments.Config{MaxSize: 64})
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, "a", "big.png", bytes.NewReader(png))
+	e := apiError(t, err)
+	assert.Equal(t, codes.InvalidArgument, e.Code)
+	assert.Contains(t, e.Violations[0].Description, "at most 64 bytes")
+
+	// The type is sniffed from the content, whatever the name says
+	_, err = svc.Create(ctx, "a", "script.png", strings.NewReader("<html><script>alert(1)</script>"))
+	e = apiError(t, err)
+	assert.Equal(t, codes.InvalidArgument, e.Code)
+	assert.Contains(t, e.Violations[0].Description, "text/html is not accepted")
+
+	_, err = svc.Create(ctx, "a", "empty.png", strings.NewReader(""))
+	assert.Equal(t, codes.InvalidArgument, apiError(t, err).Code)
+
+	_, err = svc.Create(ctx, "missing", "photo.png", bytes.NewReader(png[:32]))
+	assert.Equal(t, codes.NotFound, apiError(t, err).Code)
+	assert.Equal(t, 0, blobs(t, dir))
+}
+
+func TestResumableUpload(t *testing.T) {
+	_, svc, dir := setup(t, attachments.Config{})
+	ctx := context.Background()
+
+	_, err := svc.StartUpload(ctx, "a", "photo.png", 0)
+	assert.Equal(t, codes.InvalidArgument, apiError(t, err).Code)
+	_, err = svc.StartUpload(ctx, "missing", "photo.png", 10)
+	assert.Equal(t, codes.NotFound, apiError(t, err).Code)
+
+	u, err := svc.StartUpload(ctx, "a", "photo.png", int64(len(png)))
+	require.NoError(t, err)
+
+	u, a, err := svc.WriteChunk(ctx, "a", u.ID, 0, bytes.NewReader(png[:40]))
+	require.NoError(t, err)
+	assert.Nil(t, a)
+	assert.Equal(t, int64(40), u.Received)
+
+	// A chunk resent from the start does not line up
+	_, _, err = svc.WriteChunk(ctx, "a", u.ID, 0, bytes.NewReader(png[:40]))
+	e := apiError(t, err)
+	assert.Equal(t, codes.Aborted, e.Code)
+	assert.Equal(t, attachments.ReasonUploadOffsetMismatch, e.Reason)
+	assert.Equal(t, "40", e.Metadata["received"])
+
+	got, err := svc.GetUpload(ctx, "a", u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), got.Received)
+
+	// A chunk running past the declared size is refused whole
+	_, _, err = svc.WriteChunk(ctx, "a", u.ID, 40, bytes.NewReader(append(png[40:], 0)))
+	assert.Equal(t, codes.InvalidArgument, apiError(t, err).Code)
+	got, err = svc.GetUpload(ctx, "a", u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), got.Received)
+
+	u2, err := svc.StartUpload(ctx, "a", "photo.png", int64(len(png)))
+	require.NoError(t, err)
+	_, _, err = svc.WriteChunk(ctx, "a", u2.ID, 0, bytes.NewReader(png[:70]))
+	require.NoError(t, err)
+	u2, a, err = svc.WriteChunk(ctx, "a", u2.ID, 70, bytes.NewReader(png[70:]))
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	assert.Equal(t, "image/png", a.ContentType)
+	assert.Equal(t, int64(len(png)), a.Size)
+	_, err = svc.GetUpload(ctx, "a", u2.ID)
+	assert.Equal(t, codes.NotFound, apiError(t, err).Code)
+
+	require.NoError(t, svc.CancelUpload(ctx, "a", u.ID))
+	_, err = svc.GetUpload(ctx, "a", u.ID)
+	assert.Equal(t, codes.NotFound, apiError(t, err).Code)
+	_, err = os.Stat(filepath.Join(dir, "uploads", u.ID))
+	assert.True(t, os.IsNotExist(err))
+
+	// Chunks for unknown or finished uploads are refused
+	for _, id := range []string{"unknown", u.ID, u2.ID} {
+		_, _, err = svc.WriteChunk(ctx, "a", id, 0, bytes.NewReader(png))
+		assert.Equal(t, codes.NotFound, apiError(t, err).Code, id)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	db, svc, dir := setup(t, attachments.Config{})
+	ctx := context.Background()
+
+	a, err := svc.Create(ctx, "a", "photo.png", bytes.NewReader(png))
+	require.NoError(t, err)
+	_, err = svc.Create(ctx, "b", "photo.png", bytes.NewReader(png))
+	require.NoError(t, err)
+	_, err = svc.Create(ctx, "a", "doc.pdf", strings.NewReader("%PDF-1.4\n%test"))
+	require.NoError(t, err)
+	_, err = svc.StartUpload(ctx, "a", "photo.png", 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, blobs(t, dir))
+
+	// Deleting the item removes its attachments and upload; the shared blob
+	// stays while b still uses it
+	require.NoError(t, database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		_, _, err := items.Delete(ctx, tx, money.Rules{}, "a")
+		return err
+	}))
+	_, err = svc.Get(ctx, "a", a.ID)
+	assert.Equal(t, codes.NotFound, apiError(t, err).Code)
+	c, err := svc.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Blobs)
+	assert.Equal(t, 1, blobs(t, dir))
+
+	list, err := svc.List(ctx, "b")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.NoError(t, svc.Delete(ctx, "b", list[0].ID))
+	c, err = svc.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Blobs)
+	assert.Equal(t, 0, blobs(t, dir))
+
+	c, err = svc.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, attachments.Collected{}, c)
+}
+
+func TestCollectExpiredUploads(t *testing.T) {
+	_, svc, dir := setup(t, attachments.Config{UploadExpiry: time.Millisecond})
+	ctx := context.Background()
+
+	u, err := svc.StartUpload(ctx, "a", "photo.png", int64(len(png)))
+	require.NoError(t, err)
+	_, _, err = svc.WriteChunk(ctx, "a", u.ID, 0, bytes.NewReader(png[:10]))
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	c, err := svc.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Uploads)
+	_, err = svc.GetUpload(ctx, "a", u.ID)
+	assert.Equal(t, codes.NotFound, apiError(t, err).Code)
+	_, err = os.Stat(filepath.Join(dir, "uploads", u.ID))
+	assert.True(t, os.IsNotExist(err))
+}
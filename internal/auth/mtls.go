@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// PrincipalMapper maps client certificate subjects to principal names
+type PrincipalMapper struct {
+	// subjects maps a distinguished name such as "CN=reporting,O=Example"
+	// to a principal. When empty the certificate common name is used.
+	subjects map[string]string
+}
+
+// NewPrincipalMapper creates a mapper from subject to principal names
+func NewPrincipalMapper(subjects map[string]string) *PrincipalMapper {
+	return &PrincipalMapper{subjects: subjects}
+}
+
+// LoadPrincipalMapper reads a JSON object mapping subjects to principals
+func LoadPrincipalMapper(path string) (*PrincipalMapper, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	subjects := make(map[string]string)
+	if err := json.Unmarshal(data, &subjects); err != nil {
+		return nil, fmt.Errorf("parsing principal map %s: %w", path, err)
+	}
+	return NewPrincipalMapper(subjects), nil
+}
+
+// Map returns the principal for a verified client certificate. With a
+// configured mapping, unknown subjects are rejected.
+func (m *PrincipalMapper) Map(cert *x509.Certificate) (Principal, bool) {
+	subject := cert.Subject.String()
+	if len(m.subjects) == 0 {
+		return Principal{Name: cert.Subject.CommonName, Subject: subject}, cert.Subject.CommonName != ""
+	}
+	name, ok := m.subjects[subject]
+	return Principal{Name: name, Subject: subject}, ok
+}
+
+// unmappedError is returned for certificates without a principal mapping
+func unmappedError(subject string) *apierror.Error {
+	return &apierror.Error{
+		Code:     codes.PermissionDenied,
+		Reason:   "UNKNOWN_CLIENT_CERTIFICATE",
+		Message:  "client certificate is not mapped to a principal",
+		Metadata: map[string]string{"subject": subject},
+	}
+}
+
+// Middleware stores the principal of the TLS client certificate in the
+// request context. Requests without a certificate pass through unchanged.
+func (m *PrincipalMapper) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cert := r.TLS.PeerCertificates[0]
+		p, ok := m.Map(cert)
+		if !ok {
+			apierror.Write(w, r, unmappedError(cert.Subject.String()))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+// UnaryInterceptor stores the principal of the TLS client certificate in the
+// RPC context
+func (m *PrincipalMapper) UnaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := m.contextWithPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor is the streaming equivalent of UnaryInterceptor
+func (m *PrincipalMapper) StreamInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := m.contextWithPrincipal(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+func (m *PrincipalMapper) contextWithPrincipal(ctx context.Context) (context.Context, error) {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	info, ok := pr.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return ctx, nil
+	}
+	cert := info.State.PeerCertificates[0]
+	p, ok := m.Map(cert)
+	if !ok {
+		return nil, unmappedError(cert.Subject.String())
+	}
+	return WithPrincipal(ctx, p), nil
+}
+
+// contextStream overrides the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,24 @@
+package auth
+
+import "context"
+
+// Principal identifies the authenticated caller of a request
+type Principal struct {
+	// Name is the principal the caller was mapped to
+	Name string
+	// Subject is the distinguished name of the client certificate
+	Subject string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored in ctx, if any
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
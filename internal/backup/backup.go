@@ -0,0 +1,323 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/mattn/go-sqlite3"
+)
+
+// stepPages is how many pages are copied per backup step. Copying in steps
+// lets writers make progress between them.
+const stepPages = 1024
+
+// manifestSuffix marks manifest files in a backup directory
+const manifestSuffix = ".json"
+
+// Manifest describes a compressed snapshot and is stored next to it
+type Manifest struct {
+	// File is the snapshot file name, relative to the manifest
+	File          string    `json:"file"`
+	CreatedAt     time.Time `json:"created_at"`
+	SHA256        string    `json:"sha256"`
+	Size          int64     `json:"size"`
+	SchemaVersion int       `json:"schema_version"`
+}
+
+// Snapshot writes a consistent, gzip-compressed copy of db into dir using
+// the SQLite online backup API, together with a checksum manifest
+func Snapshot(ctx context.Context, db *sql.DB, dir string) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// Copy the live database into a temporary SQLite file
+	tmp, err := os.CreateTemp(dir, ".snapshot-*.db")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := copyDatabase(ctx, db, tmp.Name()); err != nil {
+		return nil, fmt.Errorf("backing up database: %w", err)
+	}
+	version, err := schemaVersion(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	m := &Manifest{
+		File:          "data-" + now.Format("20060102T150405.000Z") + ".db.gz",
+		CreatedAt:     now,
+		SchemaVersion: version,
+	}
+	if m.SHA256, m.Size, err = compress(tmp.Name(), filepath.Join(dir, m.File)); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(ManifestPath(dir, m), data, 0o644); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// copyDatabase runs the online backup from a pooled connection of db into
+// the database file at dest
+func copyDatabase(ctx context.Context, db *sql.DB, dest string) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		src, ok := database.SQLiteConn(driverConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", driverConn)
+		}
+		destConn, err := (&sqlite3.SQLiteDriver{}).Open(dest)
+		if err != nil {
+			return err
+		}
+		defer destConn.Close()
+
+		b, err := destConn.(*sqlite3.SQLiteConn).Backup("main", src, "main")
+		if err != nil {
+			return err
+		}
+		for {
+			done, err := b.Step(stepPages)
+			if err != nil {
+				b.Close()
+				return err
+			}
+			if done {
+				return b.Finish()
+			}
+			select {
+			case <-ctx.Done():
+				b.Close()
+				return ctx.Err()
+			case <-time.After(time.Millisecond):
+			}
+		}
+	})
+}
+
+// compress gzips src into dest and returns the checksum and size of dest
+func compress(src, dest string) (string, int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", 0, err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return "", 0, err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	zw := gzip.NewWriter(io.MultiWriter(out, hash))
+	if _, err := io.Copy(zw, in); err != nil {
+		return "", 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := out.Sync(); err != nil {
+		return "", 0, err
+	}
+	info, err := out.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), info.Size(), nil
+}
+
+// Restore verifies the snapshot described by the manifest at path and
+// replaces the database at dbPath with it. The server must be stopped.
+func Restore(path, dbPath string) (*Manifest, error) {
+	m, err := ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if m.SchemaVersion > database.SchemaVersion {
+		return nil, fmt.Errorf("snapshot schema version %d is newer than supported version %d",
+			m.SchemaVersion, database.SchemaVersion)
+	}
+
+	snapshot := filepath.Join(filepath.Dir(path), m.File)
+	sum, err := checksum(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if sum != m.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch for %s: manifest has %s, file has %s", m.File, m.SHA256, sum)
+	}
+
+	// Decompress next to the target so the final rename is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), ".restore-*.db")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if err := decompress(snapshot, tmp); err != nil {
+		return nil, err
+	}
+
+	if err := verify(tmp.Name(), m.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	// Stale WAL files would be replayed on top of the restored database
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if err := os.Rename(tmp.Name(), dbPath); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decompress gunzips src into dest and closes dest
+func decompress(src string, dest *os.File) error {
+	defer dest.Close()
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, zr); err != nil {
+		return err
+	}
+	if err := zr.Close(); err != nil {
+		return err
+	}
+	return dest.Sync()
+}
+
+// verify checks the integrity and schema version of the database at path
+func verify(path string, want int) error {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	version, err := database.UserVersion(db)
+	if err != nil {
+		return err
+	}
+	if version != want {
+		return fmt.Errorf("snapshot schema version %d does not match manifest version %d", version, want)
+	}
+	return nil
+}
+
+// schemaVersion reads the user_version of the database file at path
+func schemaVersion(path string) (int, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	return database.UserVersion(db)
+}
+
+// checksum returns the hex SHA-256 of the file at path
+func checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ReadManifest loads a manifest file
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %w", path, err)
+	}
+	if m.File == "" || m.SHA256 == "" || strings.ContainsAny(m.File, `/\`) {
+		return nil, fmt.Errorf("invalid manifest %s", path)
+	}
+	return &m, nil
+}
+
+// List returns the manifests in dir, newest first
+func List(dir string) ([]*Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*Manifest
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), manifestSuffix) {
+			continue
+		}
+		m, err := ReadManifest(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.After(manifests[j].CreatedAt)
+	})
+	return manifests, nil
+}
+
+// ManifestPath returns where the manifest for m is stored in dir
+func ManifestPath(dir string, m *Manifest) string {
+	return filepath.Join(dir, strings.TrimSuffix(m.File, ".db.gz")+manifestSuffix)
+}
@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Retention decides which snapshots are kept when pruning: the newest
+// snapshot of each of the last Daily days and of each of the last Weekly ISO
+// weeks that have snapshots. The newest snapshot is always kept.
+type Retention struct {
+	Daily  int
+	Weekly int
+}
+
+// Prune deletes the snapshots in dir that fall outside the retention policy
+// and returns the manifests that were removed
+func Prune(dir string, policy Retention) ([]*Manifest, error) {
+	manifests, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := policy.keep(manifests)
+	var removed []*Manifest
+	for i, m := range manifests {
+		if keep[i] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, m.File)); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		if err := os.Remove(ManifestPath(dir, m)); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, m)
+	}
+	return removed, nil
+}
+
+// keep marks the manifests to retain; manifests must be sorted newest first
+func (r Retention) keep(manifests []*Manifest) []bool {
+	keep := make([]bool, len(manifests))
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for i, m := range manifests {
+		t := m.CreatedAt.UTC()
+		day := t.Format("2006-01-02")
+		year, week := t.ISOWeek()
+		weekKey := fmt.Sprintf("%d-W%02d", year, week)
+
+		if i == 0 {
+			keep[i] = true
+		}
+		if !days[day] && len(days) < r.Daily {
+			days[day] = true
+			keep[i] = true
+		}
+		if !weeks[weekKey] && len(weeks) < r.Weekly {
+			weeks[weekKey] = true
+			keep[i] = true
+		}
+	}
+	return keep
+}
+
+// Scheduler takes snapshots at a fixed interval and prunes old ones
+type Scheduler struct {
+	DB        *sql.DB
+	Dir       string
+	Interval  time.Duration
+	Retention Retention
+}
+
+// Run takes snapshots until ctx is cancelled. Failures are logged and the
+// next run is attempted on schedule.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		m, err := Snapshot(ctx, s.DB, s.Dir)
+		if err != nil {
+			log.Printf("Scheduled backup failed: %v", err)
+			continue
+		}
+		log.Printf("Scheduled backup written to %s", filepath.Join(s.Dir, m.File))
+
+		removed, err := Prune(s.Dir, s.Retention)
+		if err != nil {
+			log.Printf("Pruning backups failed: %v", err)
+		}
+		for _, r := range removed {
+			log.Printf("Pruned backup %s", r.File)
+		}
+	}
+}
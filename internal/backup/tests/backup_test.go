@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/backup"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB opens a file database with n items
+func openTestDB(t *testing.T, n int) (*sql.DB, string) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	db, err := database.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	for i := 0; i < n; i++ {
+		_, err := db.Exec("INSERT INTO items (id, name, value_units) VALUES (?, ?, ?)", fmt.Sprint(i), "Test Item", i)
+		require.NoError(t, err)
+	}
+	return db, path
+}
+
+func countItems(t *testing.T, path string) int {
+	db, err := database.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM items").Scan(&count))
+	return count
+}
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	db, _ := openTestDB(t, 100)
+	dir := t.TempDir()
+
+	m, err := backup.Snapshot(context.Background(), db, dir)
+	require.NoError(t, err)
+	assert.Equal(t, database.SchemaVersion, m.SchemaVersion)
+	assert.Len(t, m.SHA256, 64)
+	assert.FileExists(t, filepath.Join(dir, m.File))
+
+	// Writes after the snapshot are not part of it
+	_, err = db.Exec("INSERT INTO items (id, name, value_units) VALUES ('late', 'Test Item', 10000)")
+	require.NoError(t, err)
+
+	manifests, err := backup.List(dir)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	_, err = backup.Restore(backup.ManifestPath(dir, m), target)
+	require.NoError(t, err)
+	assert.Equal(t, 100, countItems(t, target))
+}
+
+func TestRestoreRejectsCorruptSnapshot(t *testing.T) {
+	db, _ := openTestDB(t, 10)
+	dir := t.TempDir()
+
+	m, err := backup.Snapshot(context.Background(), db, dir)
+	require.NoError(t, err)
+	manifest := backup.ManifestPath(dir, m)
+
+	// Flip a byte of the compressed snapshot
+	snapshot := filepath.Join(dir, m.File)
+	data, err := os.ReadFile(snapshot)
+	require.NoError(t, err)
+	data[len(data)/2] ^= 0xff
+	require.NoError(t, os.WriteFile(snapshot, data, 0o644))
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	_, err = backup.Restore(manifest, target)
+	assert.ErrorContains(t, err, "checksum mismatch")
+	assert.NoFileExists(t, target)
+}
+
+func TestRestoreRejectsNewerSchema(t *testing.T) {
+	db, _ := openTestDB(t, 1)
+	dir := t.TempDir()
+
+	m, err := backup.Snapshot(context.Background(), db, dir)
+	require.NoError(t, err)
+	manifest := backup.ManifestPath(dir, m)
+
+	m.SchemaVersion = database.SchemaVersion + 1
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(manifest, data, 0o644))
+
+	_, err = backup.Restore(manifest, filepath.Join(t.TempDir(), "restored.db"))
+	assert.ErrorContains(t, err, "newer than supported")
+}
+
+func TestPruneRetention(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Two snapshots a day for 30 days
+	for day := 0; day < 30; day++ {
+		for _, hour := range []int{0, 6} {
+			created := start.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)
+			m := backup.Manifest{
+				File:      "data-" + created.Format("20060102T150405.000Z") + ".db.gz",
+				CreatedAt: created,
+				SHA256:    "0",
+			}
+			data, err := json.Marshal(m)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, m.File), nil, 0o644))
+			require.NoError(t, os.WriteFile(backup.ManifestPath(dir, &m), data, 0o644))
+		}
+	}
+
+	removed, err := backup.Prune(dir, backup.Retention{Daily: 7, Weekly: 4})
+	require.NoError(t, err)
+
+	kept, err := backup.List(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 60, len(kept)+len(removed))
+
+	// The newest snapshot of each of the last 7 days (March 24-30), plus the
+	// newest of each earlier ISO week among the last 4: March 30 starts a
+	// week, so only March 22 and March 15 are added
+	days := make(map[string]bool)
+	for _, m := range kept {
+		days[m.CreatedAt.Format("2006-01-02")] = true
+		assert.Equal(t, 18, m.CreatedAt.Hour(), "only the newest snapshot of a day is kept")
+	}
+	assert.Len(t, kept, 9)
+	assert.True(t, days["2026-03-30"])
+	assert.True(t, days["2026-03-24"])
+	assert.False(t, days["2026-03-23"])
+	assert.True(t, days["2026-03-22"])
+	assert.True(t, days["2026-03-15"])
+	assert.False(t, days["2026-03-08"])
+	assert.NoFileExists(t, filepath.Join(dir, removed[0].File))
+}
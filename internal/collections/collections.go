@@ -0,0 +1,313 @@
+package collections
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/google/uuid"
+)
+
+// Limits on collections and their item pages
+const (
+	MaxNameLength        = 200
+	MaxDescriptionLength = 10000
+	DefaultPageSize      = 100
+	MaxPageSize          = 1000
+)
+
+// Collection is a named group of items. An item can be in any number of
+// collections. Items added to a collection with a schema version must match
+// that schema as well as the active one.
+type Collection struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	SchemaVersion *int64    `json:"schema_version,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Params are the settable fields of a collection
+type Params struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	SchemaVersion *int64 `json:"schema_version"`
+}
+
+// validate trims the name and checks p, including that its schema version
+// exists. Errors are *apierror.Error values.
+func (p *Params) validate(ctx context.Context, q items.Querier) error {
+	var violations []apierror.FieldViolation
+	p.Name = strings.TrimSpace(p.Name)
+	switch {
+	case p.Name == "":
+		violations = append(violations, apierror.FieldViolation{Field: "name", Description: "name is required"})
+	case utf8.RuneCountInString(p.Name) > MaxNameLength:
+		violations = append(violations, apierror.FieldViolation{Field: "name",
+			Description: fmt.Sprintf("name must be at most %d characters", MaxNameLength)})
+	}
+	if utf8.RuneCountInString(p.Description) > MaxDescriptionLength {
+		violations = append(violations, apierror.FieldViolation{Field: "description",
+			Description: fmt.Sprintf("description must be at most %d characters", MaxDescriptionLength)})
+	}
+	if p.SchemaVersion != nil {
+		var exists bool
+		err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM item_schemas WHERE version = ?)", *p.SchemaVersion).Scan(&exists)
+		if err != nil {
+			return apierror.Internal(err, "reading schema")
+		}
+		if !exists {
+			violations = append(violations, apierror.FieldViolation{Field: "schema_version",
+				Description: fmt.Sprintf("schema version %d does not exist", *p.SchemaVersion)})
+		}
+	}
+	if len(violations) > 0 {
+		return apierror.InvalidArgument("invalid collection", violations...)
+	}
+	return nil
+}
+
+const collectionColumns = "id, name, description, schema_version, created_at, updated_at"
+
+func scanCollection(row items.Scanner) (*Collection, error) {
+	var c Collection
+	var version sql.NullInt64
+	if err := row.Scan(&c.ID, &c.Name, &c.Description, &version, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if version.Valid {
+		c.SchemaVersion = &version.Int64
+	}
+	return &c, nil
+}
+
+// Create adds a collection. Errors are *apierror.Error values.
+func Create(ctx context.Context, db *sql.DB, p Params) (*Collection, error) {
+	if err := p.validate(ctx, db); err != nil {
+		return nil, err
+	}
+	id := uuid.New().String()
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, `INSERT INTO collections (id, name, description, schema_version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, id, p.Name, p.Description, p.SchemaVersion, now, now)
+	if err != nil {
+		return nil, apierror.Internal(err, "creating collection")
+	}
+	return Get(ctx, db, id)
+}
+
+// Get returns a collection. Errors are *apierror.Error values.
+func Get(ctx context.Context, q items.Querier, id string) (*Collection, error) {
+	c, err := scanCollection(q.QueryRowContext(ctx, "SELECT "+collectionColumns+" FROM collections WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, apierror.NotFound("collection", id)
+	}
+	if err != nil {
+		return nil, apierror.Internal(err, "reading collection")
+	}
+	return c, nil
+}
+
+// List returns every collection, ordered by name
+func List(ctx context.Context, db *sql.DB) ([]*Collection, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+collectionColumns+" FROM collections ORDER BY name, id")
+	if err != nil {
+		return nil, apierror.Internal(err, "listing collections")
+	}
+	defer rows.Close()
+
+	list := make([]*Collection, 0)
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, apierror.Internal(err, "scanning collection")
+		}
+		list = append(list, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "listing collections")
+	}
+	return list, nil
+}
+
+// Update replaces the fields of a collection. Items already in it are not
+// checked against a new schema version; dry run it on the collection first.
+// Errors are *apierror.Error values.
+func Update(ctx context.Context, db *sql.DB, id string, p Params) (*Collection, error) {
+	if err := p.validate(ctx, db); err != nil {
+		return nil, err
+	}
+	result, err := db.ExecContext(ctx, `UPDATE collections SET name = ?, description = ?, schema_version = ?, updated_at = ?
+		WHERE id = ?`, p.Name, p.Description, p.SchemaVersion, time.Now().UTC(), id)
+	if err != nil {
+		return nil, apierror.Internal(err, "updating collection")
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, apierror.Internal(err, "updating collection")
+	} else if n == 0 {
+		return nil, apierror.NotFound("collection", id)
+	}
+	return Get(ctx, db, id)
+}
+
+// Delete removes a collection. Its items are kept. Errors are
+// *apierror.Error values.
+func Delete(ctx context.Context, db *sql.DB, id string) error {
+	result, err := db.ExecContext(ctx, "DELETE FROM collections WHERE id = ?", id)
+	if err != nil {
+		return apierror.Internal(err, "deleting collection")
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return apierror.Internal(err, "deleting collection")
+	} else if n == 0 {
+		return apierror.NotFound("collection", id)
+	}
+	return nil
+}
+
+// AddItem puts an item in a collection. The item must match the schema of
+// the collection, as well as those it already had to match. Errors are
+// *apierror.Error values.
+func AddItem(ctx context.Context, db *sql.DB, rules money.Rules, collectionID, itemID string) error {
+	err := database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		if _, err := Get(ctx, tx, collectionID); err != nil {
+			return err
+		}
+		item, err := items.Get(ctx, tx, rules, itemID)
+		if err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, `INSERT INTO collection_items (collection_id, item_id, added_at)
+			VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, collectionID, itemID, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return apierror.AlreadyExists("collection item", itemID)
+		}
+		return items.CheckSchema(ctx, tx, item)
+	})
+	if err != nil {
+		return apierror.From(err, "adding item to collection")
+	}
+	return nil
+}
+
+// RemoveItem takes an item out of a collection. Errors are *apierror.Error
+// values.
+func RemoveItem(ctx context.Context, db *sql.DB, collectionID, itemID string) error {
+	result, err := db.ExecContext(ctx, "DELETE FROM collection_items WHERE collection_id = ? AND item_id = ?",
+		collectionID, itemID)
+	if err != nil {
+		return apierror.Internal(err, "removing item from collection")
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return apierror.Internal(err, "removing item from collection")
+	} else if n == 0 {
+		if _, err := Get(ctx, db, collectionID); err != nil {
+			return err
+		}
+		return apierror.NotFound("collection item", itemID)
+	}
+	return nil
+}
+
+// ItemsRequest selects a page of the items in a collection
+type ItemsRequest struct {
+	PageSize  int
+	PageToken string
+}
+
+// ItemsPage is a page of the items in a collection, ordered by id
+type ItemsPage struct {
+	Items         []models.Item `json:"items"`
+	NextPageToken string        `json:"next_page_token,omitempty"`
+}
+
+// ListItems returns a page of the items in a collection. Pages continue
+// after the last id of the previous one, so items added or removed between
+// requests do not shift the pages. Errors are *apierror.Error values.
+func ListItems(ctx context.Context, db *sql.DB, rules money.Rules, collectionID string, req ItemsRequest) (*ItemsPage, error) {
+	var violations []apierror.FieldViolation
+	if req.PageSize < 0 || req.PageSize > MaxPageSize {
+		violations = append(violations, apierror.FieldViolation{Field: "page_size",
+			Description: "page_size must be between 0 and " + strconv.Itoa(MaxPageSize)})
+	}
+	after, err := decodePageToken(req.PageToken)
+	if err != nil {
+		violations = append(violations, apierror.FieldViolation{Field: "page_token", Description: "page_token is invalid"})
+	}
+	if len(violations) > 0 {
+		return nil, apierror.InvalidArgument("invalid collection items request", violations...)
+	}
+	if _, err := Get(ctx, db, collectionID); err != nil {
+		return nil, err
+	}
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
+	// One extra row tells whether another page follows
+	rows, err := db.QueryContext(ctx, "SELECT "+items.Columns+` FROM collection_items
+		JOIN items ON items.id = collection_items.item_id
+		WHERE collection_items.collection_id = ? AND collection_items.item_id > ?
+		ORDER BY collection_items.item_id
+		LIMIT ?`, collectionID, after, pageSize+1)
+	if err != nil {
+		return nil, apierror.Internal(err, "listing collection items")
+	}
+	defer rows.Close()
+
+	page := &ItemsPage{Items: make([]models.Item, 0, pageSize)}
+	for rows.Next() {
+		if len(page.Items) == pageSize {
+			page.NextPageToken = encodePageToken(page.Items[pageSize-1].ID)
+			break
+		}
+		var item models.Item
+		if err := items.Scan(rows, rules, &item); err != nil {
+			return nil, apierror.Internal(err, "scanning collection item")
+		}
+		page.Items = append(page.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "listing collection items")
+	}
+	return page, nil
+}
+
+// encodePageToken makes an opaque token for the page after an item id
+func encodePageToken(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("after:" + id))
+}
+
+// decodePageToken returns the item id stored in a page token
+func decodePageToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	id, ok := strings.CutPrefix(string(raw), "after:")
+	if !ok {
+		return "", errors.New("unknown page token format")
+	}
+	return id, nil
+}
@@ -0,0 +1,112 @@
+package collections
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/money"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the CollectionService gRPC service
+type Server struct {
+	pb.UnimplementedCollectionServiceServer
+	db    *sql.DB
+	rules money.Rules
+}
+
+// NewServer creates a collection server for the collections in db, whose
+// items are written the way rules round them
+func NewServer(db *sql.DB, rules money.Rules) *Server {
+	return &Server{db: db, rules: rules}
+}
+
+// CreateCollection adds a collection
+func (s *Server) CreateCollection(ctx context.Context, req *pb.CreateCollectionRequest) (*pb.Collection, error) {
+	c, err := Create(ctx, s.db, Params{Name: req.Name, Description: req.Description, SchemaVersion: req.SchemaVersion})
+	if err != nil {
+		return nil, err
+	}
+	return collectionProto(c), nil
+}
+
+// GetCollection returns a collection
+func (s *Server) GetCollection(ctx context.Context, req *pb.GetCollectionRequest) (*pb.Collection, error) {
+	c, err := Get(ctx, s.db, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return collectionProto(c), nil
+}
+
+// ListCollections returns every collection
+func (s *Server) ListCollections(ctx context.Context, req *pb.ListCollectionsRequest) (*pb.ListCollectionsResponse, error) {
+	list, err := List(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListCollectionsResponse{}
+	for _, c := range list {
+		resp.Collections = append(resp.Collections, collectionProto(c))
+	}
+	return resp, nil
+}
+
+// UpdateCollection changes a collection
+func (s *Server) UpdateCollection(ctx context.Context, req *pb.UpdateCollectionRequest) (*pb.Collection, error) {
+	c, err := Update(ctx, s.db, req.Id, Params{Name: req.Name, Description: req.Description, SchemaVersion: req.SchemaVersion})
+	if err != nil {
+		return nil, err
+	}
+	return collectionProto(c), nil
+}
+
+// DeleteCollection removes a collection
+func (s *Server) DeleteCollection(ctx context.Context, req *pb.DeleteCollectionRequest) (*pb.DeleteCollectionResponse, error) {
+	if err := Delete(ctx, s.db, req.Id); err != nil {
+		return nil, err
+	}
+	return &pb.DeleteCollectionResponse{Success: true}, nil
+}
+
+// AddCollectionItem puts an item in a collection
+func (s *Server) AddCollectionItem(ctx context.Context, req *pb.AddCollectionItemRequest) (*pb.AddCollectionItemResponse, error) {
+	if err := AddItem(ctx, s.db, s.rules, req.CollectionId, req.ItemId); err != nil {
+		return nil, err
+	}
+	return &pb.AddCollectionItemResponse{Success: true}, nil
+}
+
+// RemoveCollectionItem takes an item out of a collection
+func (s *Server) RemoveCollectionItem(ctx context.Context, req *pb.RemoveCollectionItemRequest) (*pb.RemoveCollectionItemResponse, error) {
+	if err := RemoveItem(ctx, s.db, req.CollectionId, req.ItemId); err != nil {
+		return nil, err
+	}
+	return &pb.RemoveCollectionItemResponse{Success: true}, nil
+}
+
+// ListCollectionItems returns a page of the items in a collection
+func (s *Server) ListCollectionItems(ctx context.Context, req *pb.ListCollectionItemsRequest) (*pb.ListCollectionItemsResponse, error) {
+	page, err := ListItems(ctx, s.db, s.rules, req.CollectionId, ItemsRequest{PageSize: int(req.PageSize), PageToken: req.PageToken})
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListCollectionItemsResponse{NextPageToken: page.NextPageToken}
+	for i := range page.Items {
+		resp.Items = append(resp.Items, items.Proto(&page.Items[i]))
+	}
+	return resp, nil
+}
+
+func collectionProto(c *Collection) *pb.Collection {
+	return &pb.Collection{
+		Id:            c.ID,
+		Name:          c.Name,
+		Description:   c.Description,
+		SchemaVersion: c.SchemaVersion,
+		CreatedAt:     timestamppb.New(c.CreatedAt),
+		UpdatedAt:     timestamppb.New(c.UpdatedAt),
+	}
+}
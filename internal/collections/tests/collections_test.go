@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/collections"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, database.Migrate(db))
+	return db
+}
+
+func insert(t *testing.T, db *sql.DB, item models.Item) {
+	t.Helper()
+	require.NoError(t, items.Validate(&item, money.Rules{}))
+	require.NoError(t, database.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		return items.Insert(context.Background(), tx, &item)
+	}))
+}
+
+// code returns the gRPC code of an apierror
+func code(t *testing.T, err error) codes.Code {
+	t.Helper()
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr), "%v is not an apierror", err)
+	return apiErr.Code
+}
+
+func TestCRUD(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	c, err := collections.Create(ctx, db, collections.Params{Name: "  Summer catalog ", Description: "Seasonal"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, c.ID)
+	assert.Equal(t, "Summer catalog", c.Name)
+	assert.Nil(t, c.SchemaVersion)
+
+	_, err = collections.Create(ctx, db, collections.Params{Name: "Bundles"})
+	require.NoError(t, err)
+	list, err := collections.List(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "Bundles", list[0].Name)
+
+	version := int64(3)
+	_, err = collections.Create(ctx, db, collections.Params{SchemaVersion: &version})
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, []apierror.FieldViolation{
+		{Field: "name", Description: "name is required"},
+		{Field: "schema_version", Description: "schema version 3 does not exist"},
+	}, apiErr.Violations)
+
+	schema, err := items.CreateSchema(ctx, db, []byte(`{}`))
+	require.NoError(t, err)
+	c, err = collections.Update(ctx, db, c.ID, collections.Params{Name: "Winter catalog", SchemaVersion: &schema.Version})
+	require.NoError(t, err)
+	assert.Equal(t, "Winter catalog", c.Name)
+	assert.Empty(t, c.Description)
+	assert.Equal(t, &schema.Version, c.SchemaVersion)
+
+	_, err = collections.Update(ctx, db, "missing", collections.Params{Name: "x"})
+	assert.Equal(t, codes.NotFound, code(t, err))
+
+	require.NoError(t, collections.Delete(ctx, db, c.ID))
+	_, err = collections.Get(ctx, db, c.ID)
+	assert.Equal(t, codes.NotFound, code(t, err))
+	assert.Equal(t, codes.NotFound, code(t, collections.Delete(ctx, db, c.ID)))
+}
+
+func TestItems(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	for i := range 5 {
+		insert(t, db, models.Item{ID: fmt.Sprintf("item-%d", i), Name: "Widget"})
+	}
+	c, err := collections.Create(ctx, db, collections.Params{Name: "All"})
+	require.NoError(t, err)
+	other, err := collections.Create(ctx, db, collections.Params{Name: "Some"})
+	require.NoError(t, err)
+
+	for _, id := range []string{"item-3", "item-0", "item-4", "item-1"} {
+		require.NoError(t, collections.AddItem(ctx, db, money.Rules{}, c.ID, id))
+	}
+	require.NoError(t, collections.AddItem(ctx, db, money.Rules{}, other.ID, "item-0"))
+	assert.Equal(t, codes.AlreadyExists, code(t, collections.AddItem(ctx, db, money.Rules{}, c.ID, "item-0")))
+	assert.Equal(t, codes.NotFound, code(t, collections.AddItem(ctx, db, money.Rules{}, c.ID, "missing")))
+	assert.Equal(t, codes.NotFound, code(t, collections.AddItem(ctx, db, money.Rules{}, "missing", "item-2")))
+
+	// Pages follow the item ids
+	var ids []string
+	req := collections.ItemsRequest{PageSize: 3}
+	for {
+		page, err := collections.ListItems(ctx, db, money.Rules{}, c.ID, req)
+		require.NoError(t, err)
+		for _, item := range page.Items {
+			ids = append(ids, item.ID)
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		req.PageToken = page.NextPageToken
+	}
+	assert.Equal(t, []string{"item-0", "item-1", "item-3", "item-4"}, ids)
+
+	_, err = collections.ListItems(ctx, db, money.Rules{}, c.ID, collections.ItemsRequest{PageSize: -1, PageToken: "not a token"})
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Len(t, apiErr.Violations, 2)
+	_, err = collections.ListItems(ctx, db, money.Rules{}, "missing", collections.ItemsRequest{})
+	assert.Equal(t, codes.NotFound, code(t, err))
+
+	require.NoError(t, collections.RemoveItem(ctx, db, c.ID, "item-3"))
+	assert.Equal(t, codes.NotFound, code(t, collections.RemoveItem(ctx, db, c.ID, "item-3")))
+	assert.Equal(t, codes.NotFound, code(t, collections.RemoveItem(ctx, db, "missing", "item-3")))
+
+	// Deleting an item takes it out of every collection; deleting a
+	// collection keeps its items
+	require.NoError(t, database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		_, _, err := items.Delete(ctx, tx, money.Rules{}, "item-0")
+		return err
+	}))
+	page, err := collections.ListItems(ctx, db, money.Rules{}, other.ID, collections.ItemsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	require.NoError(t, collections.Delete(ctx, db, c.ID))
+	_, err = items.Get(ctx, db, money.Rules{}, "item-1")
+	require.NoError(t, err)
+	var memberships int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM collection_items").Scan(&memberships))
+	assert.Zero(t, memberships)
+}
+
+func TestCollectionSchema(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	insert(t, db, models.Item{ID: "cheap", Name: "Cheap", Value: money.MustParse("5")})
+	insert(t, db, models.Item{ID: "dear", Name: "Dear", Value: money.MustParse("5000")})
+
+	schema, err := items.CreateSchema(ctx, db, []byte(`{"properties": {"value": {"maximum": 1000}}}`))
+	require.NoError(t, err)
+	c, err := collections.Create(ctx, db, collections.Params{Name: "Budget", SchemaVersion: &schema.Version})
+	require.NoError(t, err)
+
+	// Only items matching the collection's schema can join it
+	require.NoError(t, collections.AddItem(ctx, db, money.Rules{}, c.ID, "cheap"))
+	err = collections.AddItem(ctx, db, money.Rules{}, c.ID, "dear")
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, []apierror.FieldViolation{{Field: "value", Description: "value must be at most 1000"}}, apiErr.Violations)
+	page, err := collections.ListItems(ctx, db, money.Rules{}, c.ID, collections.ItemsRequest{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+
+	// Members keep matching it while the schema is not active
+	item, err := items.Get(ctx, db, money.Rules{}, "cheap")
+	require.NoError(t, err)
+	item.Value = money.MustParse("2000")
+	require.True(t, errors.As(items.Check(ctx, db, money.Rules{}, item), &apiErr))
+	other := models.Item{ID: "dear", Name: "Dear", Value: money.MustParse("2000")}
+	require.NoError(t, items.Check(ctx, db, money.Rules{}, &other))
+
+	run, err := items.DryRunSchema(ctx, db, money.Rules{}, schema.Version, c.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, run.Checked)
+	assert.Zero(t, run.Failed)
+	_, err = items.DryRunSchema(ctx, db, money.Rules{}, schema.Version, "missing")
+	assert.Equal(t, codes.NotFound, code(t, err))
+}
@@ -0,0 +1,91 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"connectrpc.com/connect"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/angel/go-api-sqlite/proto/protoconnect"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ItemHandler adapts a gRPC ItemServiceServer to the Connect handler
+// interface so the same implementation serves gRPC-Web and Connect clients
+type ItemHandler struct {
+	srv pb.ItemServiceServer
+}
+
+// NewItemHandler creates a Connect handler backed by srv
+func NewItemHandler(srv pb.ItemServiceServer) *ItemHandler {
+	return &ItemHandler{srv: srv}
+}
+
+// Handler returns the path prefix and HTTP handler serving ItemService over
+// the Connect, gRPC-Web and gRPC protocols
+func Handler(srv pb.ItemServiceServer, opts ...connect.HandlerOption) (string, http.Handler) {
+	return protoconnect.NewItemServiceHandler(NewItemHandler(srv), opts...)
+}
+
+func (h *ItemHandler) CreateItem(ctx context.Context, req *connect.Request[pb.CreateItemRequest]) (*connect.Response[pb.Item], error) {
+	return unary(ctx, req, h.srv.CreateItem)
+}
+
+func (h *ItemHandler) GetItem(ctx context.Context, req *connect.Request[pb.GetItemRequest]) (*connect.Response[pb.Item], error) {
+	return unary(ctx, req, h.srv.GetItem)
+}
+
+func (h *ItemHandler) ListItems(ctx context.Context, req *connect.Request[pb.ListItemsRequest]) (*connect.Response[pb.ListItemsResponse], error) {
+	return unary(ctx, req, h.srv.ListItems)
+}
+
+func (h *ItemHandler) SearchItems(ctx context.Context, req *connect.Request[pb.SearchItemsRequest]) (*connect.Response[pb.SearchItemsResponse], error) {
+	return unary(ctx, req, h.srv.SearchItems)
+}
+
+func (h *ItemHandler) GetItemStats(ctx context.Context, req *connect.Request[pb.GetItemStatsRequest]) (*connect.Response[pb.ItemStats], error) {
+	return unary(ctx, req, h.srv.GetItemStats)
+}
+
+func (h *ItemHandler) UpdateItem(ctx context.Context, req *connect.Request[pb.UpdateItemRequest]) (*connect.Response[pb.Item], error) {
+	return unary(ctx, req, h.srv.UpdateItem)
+}
+
+func (h *ItemHandler) DeleteItem(ctx context.Context, req *connect.Request[pb.DeleteItemRequest]) (*connect.Response[pb.DeleteItemResponse], error) {
+	return unary(ctx, req, h.srv.DeleteItem)
+}
+
+func (h *ItemHandler) GetItemTree(ctx context.Context, req *connect.Request[pb.GetItemTreeRequest]) (*connect.Response[pb.ItemTree], error) {
+	return unary(ctx, req, h.srv.GetItemTree)
+}
+
+// unary calls a gRPC-style method and wraps its result for Connect
+func unary[Req, Res any](ctx context.Context, req *connect.Request[Req], call func(context.Context, *Req) (*Res, error)) (*connect.Response[Res], error) {
+	res, err := call(ctx, req.Msg)
+	if err != nil {
+		return nil, ToConnectError(err)
+	}
+	return connect.NewResponse(res), nil
+}
+
+// ToConnectError converts a gRPC status error into a Connect error, keeping
+// the status details such as ErrorInfo, BadRequest and RetryInfo
+func ToConnectError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	connectErr := connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+	for _, d := range st.Details() {
+		msg, ok := d.(proto.Message)
+		if !ok {
+			continue
+		}
+		if detail, err := connect.NewErrorDetail(msg); err == nil {
+			connectErr.AddDetail(detail)
+		}
+	}
+	return connectErr
+}
@@ -0,0 +1,33 @@
+package connect
+
+import (
+	"net/http"
+	"strings"
+)
+
+// IsRPCRequest reports whether r uses the gRPC-Web or Connect protocol. Connect
+// unary calls use plain application/json or application/proto bodies, so they
+// are recognized by their path prefix or Connect-Protocol-Version header.
+func IsRPCRequest(r *http.Request, prefix string) bool {
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc-web"),
+		strings.HasPrefix(contentType, "application/connect+"):
+		return true
+	case r.Header.Get("Connect-Protocol-Version") != "":
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, prefix)
+}
+
+// Multiplex routes gRPC-Web and Connect requests to rpc and everything else to
+// fallback, so a single HTTP port serves REST, gRPC-Web and Connect
+func Multiplex(prefix string, rpc, fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsRPCRequest(r, prefix) {
+			rpc.ServeHTTP(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	connectserver "github.com/angel/go-api-sqlite/internal/connect"
+	"github.com/angel/go-api-sqlite/internal/database"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/middleware"
+	"github.com/angel/go-api-sqlite/internal/money"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/angel/go-api-sqlite/proto/protoconnect"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/genproto/googleapis/type/decimal"
+)
+
+var server *httptest.Server
+
+// setupTestDB creates an in-memory SQLite database for testing
+func setupTestDB() (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	err = database.Migrate(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	db, err := setupTestDB()
+	if err != nil {
+		log.Fatalf("Failed to setup test database: %v", err)
+	}
+
+	// REST fallback that only answers a marker route
+	rest := http.NewServeMux()
+	rest.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("rest"))
+	})
+
+	prefix, rpc := connectserver.Handler(grpcserver.NewItemServer(db, money.Rules{}))
+	handler := middleware.CORS([]string{"https://app.example.com"})(connectserver.Multiplex(prefix, rpc, rest))
+	server = httptest.NewServer(handler)
+
+	exitCode := m.Run()
+
+	server.Close()
+	db.Close()
+	os.Exit(exitCode)
+}
+
+func TestProtocols(t *testing.T) {
+	ctx := context.Background()
+
+	clients := map[string]protoconnect.ItemServiceClient{
+		"Connect binary": protoconnect.NewItemServiceClient(server.Client(), server.URL),
+		"Connect JSON":   protoconnect.NewItemServiceClient(server.Client(), server.URL, connect.WithProtoJSON()),
+		"gRPC-Web":       protoconnect.NewItemServiceClient(server.Client(), server.URL, connect.WithGRPCWeb()),
+	}
+
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			created, err := client.CreateItem(ctx, connect.NewRequest(&pb.CreateItemRequest{
+				Name:  "Test Item",
+				Value: &decimal.Decimal{Value: "29.99"},
+			}))
+			require.NoError(t, err)
+			assert.NotEmpty(t, created.Msg.Id)
+
+			got, err := client.GetItem(ctx, connect.NewRequest(&pb.GetItemRequest{Id: created.Msg.Id}))
+			require.NoError(t, err)
+			assert.Equal(t, created.Msg.Name, got.Msg.Name)
+			assert.Equal(t, created.Msg.GetValue().GetValue(), got.Msg.GetValue().GetValue())
+
+			list, err := client.ListItems(ctx, connect.NewRequest(&pb.ListItemsRequest{}))
+			require.NoError(t, err)
+			assert.NotEmpty(t, list.Msg.Items)
+
+			deleted, err := client.DeleteItem(ctx, connect.NewRequest(&pb.DeleteItemRequest{Id: created.Msg.Id}))
+			require.NoError(t, err)
+			assert.True(t, deleted.Msg.Success)
+		})
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	client := protoconnect.NewItemServiceClient(server.Client(), server.URL, connect.WithGRPCWeb())
+
+	_, err := client.CreateItem(context.Background(), connect.NewRequest(&pb.CreateItemRequest{Value: &decimal.Decimal{Value: "1"}}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+
+	var reasons []string
+	for _, d := range connectErr.Details() {
+		msg, err := d.Value()
+		require.NoError(t, err)
+		if info, ok := msg.(*errdetails.ErrorInfo); ok {
+			reasons = append(reasons, info.Reason)
+		}
+	}
+	assert.Contains(t, reasons, apierror.ReasonValidationFailed)
+}
+
+func TestMultiplexFallsBackToREST(t *testing.T) {
+	resp, err := server.Client().Get(server.URL + "/api/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		origin      string
+		wantAllowed bool
+	}{
+		{name: "Allowed origin", origin: "https://app.example.com", wantAllowed: true},
+		{name: "Unknown origin", origin: "https://evil.example.com", wantAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodOptions, server.URL+protoconnect.ItemServiceCreateItemProcedure, nil)
+			require.NoError(t, err)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+
+			resp, err := server.Client().Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			if !tt.wantAllowed {
+				assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+				return
+			}
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+			assert.Equal(t, tt.origin, resp.Header.Get("Access-Control-Allow-Origin"))
+			assert.True(t, strings.Contains(resp.Header.Get("Access-Control-Allow-Headers"), "X-Grpc-Web"))
+			assert.Contains(t, resp.Header.Get("Access-Control-Expose-Headers"), "Grpc-Status")
+		})
+	}
+}
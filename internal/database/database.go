@@ -2,12 +2,79 @@ package database
 
 import (
 	"database/sql"
-	_ "github.com/mattn/go-sqlite3"
+	"fmt"
+	"net/url"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Driver names registered by this package. Both gate writes; see gatedDriver.
+const (
+	gatedDriverName        = "sqlite3_gated"
+	manualCheckpointDriver = "sqlite3_manual_checkpoint"
 )
 
+func init() {
+	sql.Register(gatedDriverName, gatedDriver{&sqlite3.SQLiteDriver{}})
+	sql.Register(manualCheckpointDriver, gatedDriver{&sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			_, err := conn.Exec("PRAGMA wal_autocheckpoint = 0", nil)
+			return err
+		},
+	}})
+}
+
+// BusyTimeout is how long SQLite waits on a locked database, in milliseconds,
+// before returning SQLITE_BUSY
+const BusyTimeout = 5000
+
+// SchemaVersion is the schema version created by this build, stored in
+// PRAGMA user_version. Backups record it so restores can reject snapshots
+// from a newer build.
+const SchemaVersion = 14
+
 // InitDB initializes the SQLite database connection
 func InitDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", "./data.db")
+	return Open("./data.db")
+}
+
+// DSN builds the connection string for path. It enables WAL so readers do
+// not block the writer, a busy timeout so short lock waits are absorbed by
+// SQLite, and immediate transactions so writers take the lock up front
+// instead of failing on upgrade.
+func DSN(path string) string {
+	params := url.Values{}
+	params.Set("_busy_timeout", fmt.Sprint(BusyTimeout))
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_txlock", "immediate")
+	return "file:" + path + "?" + params.Encode()
+}
+
+// Option configures Open
+type Option func(*options)
+
+type options struct {
+	driver string
+}
+
+// WithManualCheckpoints disables automatic WAL checkpoints on every
+// connection, so that a WAL shipper decides when frames are copied into the
+// database file
+func WithManualCheckpoints() Option {
+	return func(o *options) {
+		o.driver = manualCheckpointDriver
+	}
+}
+
+// Open opens the database at path and creates the tables
+func Open(path string, opts ...Option) (*sql.DB, error) {
+	o := options{driver: gatedDriverName}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	db, err := sql.Open(o.driver, DSN(path))
 	if err != nil {
 		return nil, err
 	}
@@ -15,29 +82,30 @@ func InitDB() (*sql.DB, error) {
 	// Test the connection
 	err = db.Ping()
 	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Create or upgrade the schema
+	err = Migrate(db)
+	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	// Create tables if they don't exist
-	err = createTables(db)
+	// Create or repair the full-text index when FTS5 is compiled in
+	err = ensureSearchIndex(db)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-// createTables creates the necessary database tables
-func createTables(db *sql.DB) error {
-	// Example table creation - modify according to your needs
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS items (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		value REAL NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	_, err := db.Exec(createTableSQL)
-	return err
+// UserVersion returns the schema version recorded in the database
+func UserVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
 }
@@ -0,0 +1,259 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// gates holds one write gate per database file. SQLite allows a single
+// writer, so every write in this process queues on the gate of its file
+// rather than contending for the file lock.
+var gates sync.Map
+
+func gateFor(dsn string) chan struct{} {
+	path, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	gate, _ := gates.LoadOrStore(path, make(chan struct{}, 1))
+	return gate.(chan struct{})
+}
+
+// gatedDriver opens SQLite connections that take the write gate of their
+// database for write transactions and for writes run outside one, making
+// the pool a single writer with concurrent readers. Read-only transactions
+// begin deferred and do not take the gate.
+type gatedDriver struct {
+	*sqlite3.SQLiteDriver
+}
+
+func (d gatedDriver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &gatedConn{SQLiteConn: c.(*sqlite3.SQLiteConn), gate: gateFor(dsn)}, nil
+}
+
+// SQLiteConn returns the SQLite connection behind a driver connection from
+// sql.Conn.Raw
+func SQLiteConn(driverConn any) (*sqlite3.SQLiteConn, bool) {
+	switch c := driverConn.(type) {
+	case *sqlite3.SQLiteConn:
+		return c, true
+	case *gatedConn:
+		return c.SQLiteConn, true
+	}
+	return nil, false
+}
+
+type gatedConn struct {
+	*sqlite3.SQLiteConn
+	gate chan struct{}
+}
+
+// acquire takes the write gate, waiting at most BusyTimeout like SQLite
+// waits on its own lock. A timeout is reported as SQLITE_BUSY so that WithTx
+// retries it.
+func (c *gatedConn) acquire(ctx context.Context) error {
+	timer := time.NewTimer(BusyTimeout * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case c.gate <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *gatedConn) release() {
+	<-c.gate
+}
+
+func (c *gatedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *gatedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.ReadOnly {
+		if _, err := c.SQLiteConn.ExecContext(ctx, "BEGIN DEFERRED", nil); err != nil {
+			return nil, err
+		}
+		return &readTx{c: c}, nil
+	}
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	tx, err := c.SQLiteConn.BeginTx(ctx, opts)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+	return &gatedTx{Tx: tx, c: c}, nil
+}
+
+func (c *gatedConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	return c.ExecContext(context.Background(), query, namedValues(args))
+}
+
+func (c *gatedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	// Statements in a transaction are covered by its gate
+	if !c.AutoCommit() {
+		return c.SQLiteConn.ExecContext(ctx, query, args)
+	}
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.SQLiteConn.ExecContext(ctx, query, args)
+}
+
+func (c *gatedConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return c.QueryContext(context.Background(), query, namedValues(args))
+}
+
+// QueryContext takes the gate for statements that write, such as INSERT …
+// RETURNING, and holds it until the rows are closed
+func (c *gatedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !c.AutoCommit() || !c.writes(ctx, query) {
+		return c.SQLiteConn.QueryContext(ctx, query, args)
+	}
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+	return &gatedRows{SQLiteRows: rows.(*sqlite3.SQLiteRows), release: c.release}, nil
+}
+
+// writes reports whether SQLite considers the first statement of query a
+// write. Plain SELECTs skip the check.
+func (c *gatedConn) writes(ctx context.Context, query string) bool {
+	if q := strings.TrimSpace(query); len(q) >= 6 && strings.EqualFold(q[:6], "select") {
+		return false
+	}
+	s, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		// Let the query itself report the error
+		return false
+	}
+	defer s.Close()
+	return !s.(*sqlite3.SQLiteStmt).Readonly()
+}
+
+func (c *gatedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *gatedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	s, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &gatedStmt{SQLiteStmt: s.(*sqlite3.SQLiteStmt), c: c}, nil
+}
+
+// gatedTx releases the gate when a write transaction ends
+type gatedTx struct {
+	driver.Tx
+	c    *gatedConn
+	once sync.Once
+}
+
+func (t *gatedTx) Commit() error {
+	defer t.once.Do(t.c.release)
+	return t.Tx.Commit()
+}
+
+func (t *gatedTx) Rollback() error {
+	defer t.once.Do(t.c.release)
+	return t.Tx.Rollback()
+}
+
+// readTx is a deferred transaction, which only takes SQLite's write lock if
+// a statement in it writes
+type readTx struct {
+	c *gatedConn
+}
+
+func (t *readTx) Commit() error {
+	_, err := t.c.SQLiteConn.ExecContext(context.Background(), "COMMIT", nil)
+	return err
+}
+
+func (t *readTx) Rollback() error {
+	_, err := t.c.SQLiteConn.ExecContext(context.Background(), "ROLLBACK", nil)
+	return err
+}
+
+// gatedStmt takes the gate when a prepared statement that writes runs
+// outside a transaction
+type gatedStmt struct {
+	*sqlite3.SQLiteStmt
+	c *gatedConn
+}
+
+func (s *gatedStmt) gated() bool {
+	return s.c.AutoCommit() && !s.Readonly()
+}
+
+func (s *gatedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *gatedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if !s.gated() {
+		return s.SQLiteStmt.ExecContext(ctx, args)
+	}
+	if err := s.c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.c.release()
+	return s.SQLiteStmt.ExecContext(ctx, args)
+}
+
+func (s *gatedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *gatedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if !s.gated() {
+		return s.SQLiteStmt.QueryContext(ctx, args)
+	}
+	if err := s.c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.SQLiteStmt.QueryContext(ctx, args)
+	if err != nil {
+		s.c.release()
+		return nil, err
+	}
+	return &gatedRows{SQLiteRows: rows.(*sqlite3.SQLiteRows), release: s.c.release}, nil
+}
+
+// gatedRows releases the gate when the rows of a writing query are closed
+type gatedRows struct {
+	*sqlite3.SQLiteRows
+	release func()
+	once    sync.Once
+}
+
+func (r *gatedRows) Close() error {
+	defer r.once.Do(r.release)
+	return r.SQLiteRows.Close()
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
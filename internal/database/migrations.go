@@ -0,0 +1,484 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// migrations upgrade the schema one version at a time: migrations[i] moves a
+// database from version i to i+1. Append new steps and bump SchemaVersion;
+// never edit a released step.
+var migrations = []func(tx *sql.Tx) error{
+	createItems,
+	createChangeLog,
+	createImportKeys,
+	createJobs,
+	createWebhooks,
+	createOutbox,
+	addItemDetails,
+	convertValues,
+	addMetadata,
+	createItemSchemas,
+	createCollections,
+	createAttachments,
+	addChangeLogRetention,
+	reparentChildrenInGo,
+}
+
+// Migrate brings the database schema up to SchemaVersion. Open calls it;
+// tests with an in-memory database call it directly.
+func Migrate(db *sql.DB) error {
+	if len(migrations) != SchemaVersion {
+		return fmt.Errorf("schema version %d does not match %d migrations", SchemaVersion, len(migrations))
+	}
+
+	version, err := UserVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		err := WithTx(context.Background(), db, func(tx *sql.Tx) error {
+			if err := migrations[v](tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", v+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migrating schema to version %d: %w", v+1, err)
+		}
+	}
+	return nil
+}
+
+// createItems creates the items table
+func createItems(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS items (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		value REAL NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`)
+	return err
+}
+
+// createChangeLog records every change to items in item_changes, in commit
+// order, for replicas to follow. Existing items are recorded as upserts so
+// a new replica can start from sequence zero.
+func createChangeLog(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE item_changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		item_id TEXT NOT NULL,
+		op TEXT NOT NULL CHECK (op IN ('upsert', 'delete')),
+		changed_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+	);
+
+	CREATE TRIGGER items_insert_change AFTER INSERT ON items BEGIN
+		INSERT INTO item_changes (item_id, op) VALUES (NEW.id, 'upsert');
+	END;
+
+	CREATE TRIGGER items_update_change AFTER UPDATE ON items BEGIN
+		INSERT INTO item_changes (item_id, op) VALUES (NEW.id, 'upsert');
+	END;
+
+	CREATE TRIGGER items_delete_change AFTER DELETE ON items BEGIN
+		INSERT INTO item_changes (item_id, op) VALUES (OLD.id, 'delete');
+	END;
+
+	INSERT INTO item_changes (item_id, op) SELECT id, 'upsert' FROM items;`)
+	return err
+}
+
+// createImportKeys maps the keys of rows in imported files to the items they
+// created, per source, so a later import of the same file updates them. The
+// mapping goes away with the item.
+func createImportKeys(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE import_keys (
+		source TEXT NOT NULL,
+		external_key TEXT NOT NULL,
+		item_id TEXT NOT NULL,
+		PRIMARY KEY (source, external_key)
+	);
+
+	CREATE INDEX import_keys_item ON import_keys (item_id);
+
+	CREATE TRIGGER items_delete_import_key AFTER DELETE ON items BEGIN
+		DELETE FROM import_keys WHERE item_id = OLD.id;
+	END;`)
+	return err
+}
+
+// createJobs holds the background job queue. run_after and lease_expires are
+// Unix milliseconds so workers can compare them in SQL; version is bumped on
+// every change so watchers can tell when a job moved.
+func createJobs(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE jobs (
+		id TEXT PRIMARY KEY,
+		kind TEXT NOT NULL,
+		state TEXT NOT NULL CHECK (state IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')),
+		payload TEXT NOT NULL,
+		result TEXT,
+		error TEXT NOT NULL DEFAULT '',
+		progress_done INTEGER NOT NULL DEFAULT 0,
+		progress_total INTEGER NOT NULL DEFAULT 0,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL,
+		run_after INTEGER NOT NULL,
+		lease_owner TEXT NOT NULL DEFAULT '',
+		lease_expires INTEGER NOT NULL DEFAULT 0,
+		cancel_requested INTEGER NOT NULL DEFAULT 0,
+		version INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		started_at DATETIME,
+		finished_at DATETIME
+	);
+
+	CREATE INDEX jobs_runnable ON jobs (state, run_after);`)
+	return err
+}
+
+// createWebhooks holds webhook subscriptions and their outbox. Deliveries are
+// written in the transaction that changes the item and removed with their
+// subscription. events is a comma-separated list; empty means every event.
+func createWebhooks(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE webhook_subscriptions (
+		id TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		events TEXT NOT NULL DEFAULT '',
+		secret TEXT NOT NULL,
+		active INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subscription_id TEXT NOT NULL,
+		event_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		state TEXT NOT NULL CHECK (state IN ('pending', 'delivered', 'dead')),
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at INTEGER NOT NULL,
+		last_status INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		delivered_at DATETIME
+	);
+
+	CREATE INDEX webhook_deliveries_due ON webhook_deliveries (state, next_attempt_at);
+	CREATE INDEX webhook_deliveries_subscription ON webhook_deliveries (subscription_id, id);
+
+	CREATE TRIGGER webhook_subscriptions_delete AFTER DELETE ON webhook_subscriptions BEGIN
+		DELETE FROM webhook_deliveries WHERE subscription_id = OLD.id;
+	END;`)
+	return err
+}
+
+// createOutbox holds item events waiting for the message broker relay, in
+// commit order. Rows are written in the transaction that changes the item and
+// pruned once published.
+func createOutbox(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE outbox (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		item_id TEXT NOT NULL,
+		data TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		published_at INTEGER
+	);
+
+	CREATE INDEX outbox_pending ON outbox (item_id, seq) WHERE published_at IS NULL;
+	CREATE INDEX outbox_published ON outbox (published_at) WHERE published_at IS NOT NULL;`)
+	return err
+}
+
+// addItemDetails adds the descriptive columns of items and their tags. Tags
+// are shared between items through item_tags and removed once no item uses
+// them. updated_at starts out as created_at, also for rows inserted without
+// it.
+func addItemDetails(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE items ADD COLUMN description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE items ADD COLUMN category TEXT NOT NULL DEFAULT '';
+	ALTER TABLE items ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+	ALTER TABLE items ADD COLUMN updated_at DATETIME;
+	UPDATE items SET updated_at = created_at;
+
+	CREATE INDEX items_category ON items (category);
+
+	CREATE TRIGGER items_default_updated_at AFTER INSERT ON items WHEN NEW.updated_at IS NULL BEGIN
+		UPDATE items SET updated_at = NEW.created_at WHERE rowid = NEW.rowid;
+	END;
+
+	CREATE TABLE tags (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE
+	);
+
+	CREATE TABLE item_tags (
+		item_id TEXT NOT NULL,
+		tag_id INTEGER NOT NULL REFERENCES tags (id),
+		PRIMARY KEY (item_id, tag_id)
+	) WITHOUT ROWID;
+
+	CREATE INDEX item_tags_tag ON item_tags (tag_id, item_id);
+
+	CREATE TRIGGER items_delete_tags AFTER DELETE ON items BEGIN
+		DELETE FROM item_tags WHERE item_id = OLD.id;
+	END;
+
+	CREATE TRIGGER item_tags_delete AFTER DELETE ON item_tags BEGIN
+		DELETE FROM tags WHERE id = OLD.tag_id AND NOT EXISTS (SELECT 1 FROM item_tags WHERE tag_id = OLD.tag_id);
+	END;`)
+	return err
+}
+
+// convertValues replaces the REAL value of items with value_units, an exact
+// count of 10^-money.Scale units. Each value is read as the shortest decimal
+// that round-trips its float64, so 0.1 stays 0.1. The few with more places
+// than money.Scale are rounded half to even and listed in value_conversions;
+// values too large to store fail the migration.
+func convertValues(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE items ADD COLUMN value_units INTEGER NOT NULL DEFAULT 0;
+
+	CREATE TABLE value_conversions (
+		item_id TEXT NOT NULL,
+		original REAL NOT NULL,
+		stored TEXT NOT NULL,
+		converted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		id    string
+		value float64
+	}
+	var rows []row
+	result, err := tx.Query("SELECT id, value FROM items")
+	if err != nil {
+		return err
+	}
+	for result.Next() {
+		var r row
+		if err := result.Scan(&r.id, &r.value); err != nil {
+			result.Close()
+			return err
+		}
+		rows = append(rows, r)
+	}
+	result.Close()
+	if err := result.Err(); err != nil {
+		return err
+	}
+
+	changed := 0
+	for _, r := range rows {
+		exact, err := money.Parse(strconv.FormatFloat(r.value, 'g', -1, 64))
+		if err != nil {
+			return fmt.Errorf("item %s: value %v cannot be stored as a decimal: %w", r.id, r.value, err)
+		}
+		stored, err := exact.Round(money.Scale, money.HalfEven)
+		if err != nil {
+			return fmt.Errorf("item %s: value %v cannot be stored as a decimal: %w", r.id, r.value, err)
+		}
+		units, err := stored.Units()
+		if err != nil {
+			return fmt.Errorf("item %s: value %v cannot be stored as a decimal: %w", r.id, r.value, err)
+		}
+		if !stored.Equal(exact) {
+			changed++
+			_, err := tx.Exec("INSERT INTO value_conversions (item_id, original, stored) VALUES (?, ?, ?)",
+				r.id, r.value, stored.Trim(0).String())
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("UPDATE items SET value_units = ? WHERE id = ?", units, r.id); err != nil {
+			return err
+		}
+	}
+	if changed > 0 {
+		log.Printf("Rounded %d of %d item values to %d decimal places; see the value_conversions table",
+			changed, len(rows), money.Scale)
+	}
+
+	_, err = tx.Exec("ALTER TABLE items DROP COLUMN value")
+	return err
+}
+
+// addMetadata adds a JSON object of free-form attributes to items, and
+// metadata_indexes to record the keys an administrator has indexed. Each
+// indexed key is a generated column of items named after its id.
+func addMetadata(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE items ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(metadata));
+
+	CREATE TABLE metadata_indexes (
+		id INTEGER PRIMARY KEY,
+		key TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	return err
+}
+
+// createItemSchemas stores the versions of the JSON Schema items must match.
+// At most one version is active at a time.
+func createItemSchemas(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE item_schemas (
+		version INTEGER PRIMARY KEY,
+		schema TEXT NOT NULL CHECK (json_valid(schema)),
+		active INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		activated_at DATETIME
+	);
+
+	CREATE UNIQUE INDEX item_schemas_active ON item_schemas (active) WHERE active;`)
+	return err
+}
+
+// createCollections adds collections of items, which an item can belong to
+// any number of, and an optional parent_id to items. Deleting an item takes
+// it out of its collections and makes its children roots; deleting a
+// collection leaves its items. A collection can name a schema version its
+// items must match as well as the active one.
+func createCollections(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE items ADD COLUMN parent_id TEXT;
+	CREATE INDEX items_parent_id ON items (parent_id);
+
+	CREATE TABLE collections (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		schema_version INTEGER,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE collection_items (
+		collection_id TEXT NOT NULL,
+		item_id TEXT NOT NULL,
+		added_at DATETIME NOT NULL,
+		PRIMARY KEY (collection_id, item_id)
+	) WITHOUT ROWID;
+
+	CREATE INDEX collection_items_item_id ON collection_items (item_id);
+
+	CREATE TRIGGER items_delete_relations AFTER DELETE ON items BEGIN
+		DELETE FROM collection_items WHERE item_id = OLD.id;
+		UPDATE items SET parent_id = NULL WHERE parent_id = OLD.id;
+	END;
+
+	CREATE TRIGGER collections_delete AFTER DELETE ON collections BEGIN
+		DELETE FROM collection_items WHERE collection_id = OLD.id;
+	END;`)
+	return err
+}
+
+// createAttachments adds files attached to items, whose content lives in a
+// blob store keyed by SHA-256, and resumable uploads in progress. Deleting an
+// item deletes its attachments and uploads; a blob no attachment refers to
+// any more is queued in blob_garbage for the store to remove.
+func createAttachments(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE attachments (
+		id TEXT PRIMARY KEY,
+		item_id TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		sha256 TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX attachments_item_id ON attachments (item_id, created_at);
+	CREATE INDEX attachments_sha256 ON attachments (sha256);
+
+	CREATE TABLE attachment_uploads (
+		id TEXT PRIMARY KEY,
+		item_id TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		received INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX attachment_uploads_item_id ON attachment_uploads (item_id);
+
+	CREATE TABLE blob_garbage (
+		sha256 TEXT PRIMARY KEY,
+		queued_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	) WITHOUT ROWID;
+
+	CREATE TRIGGER attachments_delete AFTER DELETE ON attachments
+	WHEN NOT EXISTS (SELECT 1 FROM attachments WHERE sha256 = OLD.sha256) BEGIN
+		INSERT OR IGNORE INTO blob_garbage (sha256) VALUES (OLD.sha256);
+	END;
+
+	CREATE TRIGGER items_delete_attachments AFTER DELETE ON items BEGIN
+		DELETE FROM attachments WHERE item_id = OLD.id;
+		DELETE FROM attachment_uploads WHERE item_id = OLD.id;
+	END;`)
+	return err
+}
+
+// addChangeLogRetention records the position each replica has applied, so
+// item_changes can be pruned below the lowest, and the highest pruned
+// sequence number, so a replica that fell behind it can be told.
+func addChangeLogRetention(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE replica_cursors (
+		replica_id TEXT PRIMARY KEY,
+		applied_seq INTEGER NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE item_changes_pruned (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		seq INTEGER NOT NULL
+	);
+
+	INSERT INTO item_changes_pruned (id, seq) VALUES (1, 0);
+
+	CREATE INDEX item_changes_changed_at ON item_changes (changed_at);`)
+	return err
+}
+
+// reparentChildrenInGo stops the delete trigger from making children roots.
+// items.Delete does it instead, so the children get a new updated_at and
+// item.updated events.
+func reparentChildrenInGo(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	DROP TRIGGER items_delete_relations;
+
+	CREATE TRIGGER items_delete_relations AFTER DELETE ON items BEGIN
+		DELETE FROM collection_items WHERE item_id = OLD.id;
+	END;`)
+	return err
+}
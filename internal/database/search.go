@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// ErrSearchUnavailable is returned when SQLite was built without FTS5. Build
+// with -tags sqlite_fts5 to enable full-text search.
+var ErrSearchUnavailable = errors.New("full-text search requires a build with -tags sqlite_fts5")
+
+// searchTriggers keep items_fts in sync with items. The index uses items as
+// external content, so only the tokens are stored and rows are joined back
+// to items by rowid.
+var searchTriggers = []struct{ name, ddl string }{
+	{"items_fts_insert", `
+	CREATE TRIGGER items_fts_insert AFTER INSERT ON items BEGIN
+		INSERT INTO items_fts (rowid, name) VALUES (NEW.rowid, NEW.name);
+	END;`},
+	{"items_fts_update", `
+	CREATE TRIGGER items_fts_update AFTER UPDATE ON items BEGIN
+		INSERT INTO items_fts (items_fts, rowid, name) VALUES ('delete', OLD.rowid, OLD.name);
+		INSERT INTO items_fts (rowid, name) VALUES (NEW.rowid, NEW.name);
+	END;`},
+	{"items_fts_delete", `
+	CREATE TRIGGER items_fts_delete AFTER DELETE ON items BEGIN
+		INSERT INTO items_fts (items_fts, rowid, name) VALUES ('delete', OLD.rowid, OLD.name);
+	END;`},
+}
+
+// SearchAvailable reports whether the SQLite library was compiled with FTS5
+func SearchAvailable(db *sql.DB) bool {
+	var used bool
+	err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used)
+	return err == nil && used
+}
+
+// ensureSearchIndex creates the items_fts index and its triggers when FTS5 is
+// available, indexing existing rows. The index is derived data outside the
+// versioned schema: a build without FTS5 drops the triggers so writes keep
+// working, and the next build with FTS5 recreates them and rebuilds.
+func ensureSearchIndex(db *sql.DB) error {
+	available := SearchAvailable(db)
+	return WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		if !available {
+			for _, t := range searchTriggers {
+				exists, err := hasTrigger(tx, t.name)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					continue
+				}
+				log.Printf("FTS5 is not available, dropping search trigger %s", t.name)
+				if _, err := tx.Exec("DROP TRIGGER " + t.name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		_, err := tx.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS items_fts USING fts5(
+			name,
+			content = 'items',
+			content_rowid = 'rowid',
+			tokenize = 'unicode61 remove_diacritics 2',
+			prefix = '2 3'
+		);`)
+		if err != nil {
+			return err
+		}
+
+		missing := false
+		for _, t := range searchTriggers {
+			exists, err := hasTrigger(tx, t.name)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			missing = true
+			if _, err := tx.Exec(t.ddl); err != nil {
+				return err
+			}
+		}
+		if missing {
+			return rebuildSearchIndex(tx)
+		}
+		return nil
+	})
+}
+
+// Reindex rebuilds the full-text index from the items table and returns the
+// number of items indexed. Use it after upgrading a database created by a
+// build without FTS5, or after a VACUUM, which may renumber item rowids.
+func Reindex(ctx context.Context, db *sql.DB) (int64, error) {
+	if !SearchAvailable(db) {
+		return 0, ErrSearchUnavailable
+	}
+	if err := ensureSearchIndex(db); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err := WithTx(ctx, db, func(tx *sql.Tx) error {
+		if err := rebuildSearchIndex(tx); err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM items").Scan(&count)
+	})
+	return count, err
+}
+
+// rebuildSearchIndex discards the index contents and reads every item again
+func rebuildSearchIndex(tx *sql.Tx) error {
+	_, err := tx.Exec("INSERT INTO items_fts (items_fts) VALUES ('rebuild')")
+	return err
+}
+
+// hasTrigger reports whether a trigger with the given name exists
+func hasTrigger(tx *sql.Tx, name string) (bool, error) {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?", name).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateConvertsValuesToUnits(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	// A version 1 database, from before values were decimals
+	_, err = db.Exec(`
+		CREATE TABLE items (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			value REAL NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO items (id, name, value) VALUES
+			('a', 'Exact', 0.1), ('b', 'Whole', -42), ('c', 'Rounded', 1.23456), ('d', 'Tiny', 1e-9);
+		PRAGMA user_version = 1;`)
+	require.NoError(t, err)
+
+	require.NoError(t, database.Migrate(db))
+
+	units := make(map[string]int64)
+	rows, err := db.Query("SELECT id, value_units FROM items")
+	require.NoError(t, err)
+	for rows.Next() {
+		var id string
+		var u int64
+		require.NoError(t, rows.Scan(&id, &u))
+		units[id] = u
+	}
+	require.NoError(t, rows.Err())
+	rows.Close()
+	assert.Equal(t, map[string]int64{"a": 1000, "b": -420000, "c": 12346, "d": 0}, units)
+
+	// Only values that did not fit four decimal places are reported
+	report := make(map[string]string)
+	rows, err = db.Query("SELECT item_id, original, stored FROM value_conversions")
+	require.NoError(t, err)
+	for rows.Next() {
+		var id, stored string
+		var original float64
+		require.NoError(t, rows.Scan(&id, &original, &stored))
+		report[id] = stored
+	}
+	require.NoError(t, rows.Err())
+	rows.Close()
+	assert.Equal(t, map[string]string{"c": "1.2346", "d": "0"}, report)
+}
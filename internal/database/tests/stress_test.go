@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/money"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestConcurrentWritesAcrossTransports hammers one file database with writes
+// from REST and gRPC clients at once. Every write must succeed.
+func TestConcurrentWritesAcrossTransports(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+
+	const workers, writesPerWorker = 8, 25
+	db := openTestDB(t)
+
+	// REST server
+	h := handlers.NewHandler(db, money.Rules{})
+	router := mux.NewRouter()
+	router.HandleFunc("/api/items", h.CreateItem).Methods("POST")
+	router.HandleFunc("/api/items/{id}", h.UpdateItem).Methods("PUT")
+	router.HandleFunc("/api/items/{id}", h.DeleteItem).Methods("DELETE")
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// gRPC server
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpclib.NewServer()
+	pb.RegisterItemServiceServer(s, grpcserver.NewItemServer(db, money.Rules{}))
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpclib.NewClient("passthrough:///bufnet",
+		grpclib.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewItemServiceClient(conn)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers*writesPerWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writesPerWorker; i++ {
+				body, _ := json.Marshal(map[string]any{"name": fmt.Sprintf("rest-%d-%d", w, i), "value": i})
+				resp, err := http.Post(ts.URL+"/api/items", "application/json", bytes.NewReader(body))
+				if err != nil {
+					errs <- err
+					continue
+				}
+				var item struct{ ID string }
+				json.NewDecoder(resp.Body).Decode(&item)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusCreated {
+					errs <- fmt.Errorf("REST create returned %d", resp.StatusCode)
+					continue
+				}
+
+				req, _ := http.NewRequest("PUT", ts.URL+"/api/items/"+item.ID, bytes.NewReader(body))
+				resp, err = http.DefaultClient.Do(req)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					errs <- fmt.Errorf("REST update returned %d", resp.StatusCode)
+				}
+			}
+		}(w)
+
+		go func(w int) {
+			defer wg.Done()
+			ctx := context.Background()
+			for i := 0; i < writesPerWorker; i++ {
+				item, err := client.CreateItem(ctx, &pb.CreateItemRequest{Name: fmt.Sprintf("grpc-%d-%d", w, i), Value: &decimal.Decimal{Value: strconv.Itoa(i)}})
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if _, err := client.UpdateItem(ctx, &pb.UpdateItemRequest{Id: item.Id, Name: item.Name, Value: &decimal.Decimal{Value: "1"}}); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM items").Scan(&count))
+	assert.Equal(t, 2*workers*writesPerWorker, count)
+}
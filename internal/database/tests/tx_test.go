@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastRetry keeps backoff short in tests
+var fastRetry = database.Retry{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// openTestDB opens a file database configured like production
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func TestOpenConfiguresSQLite(t *testing.T) {
+	db := openTestDB(t)
+
+	var mode string
+	require.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+
+	var timeout int
+	require.NoError(t, db.QueryRow("PRAGMA busy_timeout").Scan(&timeout))
+	assert.Equal(t, database.BusyTimeout, timeout)
+}
+
+func TestWithTxRetriesBusyErrors(t *testing.T) {
+	db := openTestDB(t)
+	before := database.Stats()
+
+	attempts := 0
+	err := fastRetry.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		attempts++
+		if attempts < 3 {
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		}
+		_, err := tx.Exec("INSERT INTO items (id, name, value_units) VALUES ('a', 'Test Item', 10000)")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	after := database.Stats()
+	assert.Equal(t, before.Transactions+1, after.Transactions)
+	assert.Equal(t, before.Retries+2, after.Retries)
+}
+
+func TestWithTxGivesUp(t *testing.T) {
+	db := openTestDB(t)
+	before := database.Stats()
+
+	attempts := 0
+	err := fastRetry.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		attempts++
+		return sqlite3.Error{Code: sqlite3.ErrLocked}
+	})
+	assert.True(t, database.IsBusy(err))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, before.BusyFailures+1, database.Stats().BusyFailures)
+
+	// Exhausted retries surface as a retryable API error
+	apiErr := apierror.From(err, "test")
+	assert.Equal(t, apierror.ReasonDatabaseBusy, apiErr.Reason)
+}
+
+func TestWithTxRollsBackOtherErrors(t *testing.T) {
+	db := openTestDB(t)
+
+	attempts := 0
+	notFound := apierror.NotFound("item", "a")
+	err := fastRetry.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		attempts++
+		if _, err := tx.Exec("INSERT INTO items (id, name, value_units) VALUES ('a', 'Test Item', 10000)"); err != nil {
+			return err
+		}
+		return notFound
+	})
+	assert.True(t, errors.Is(err, notFound))
+	assert.Equal(t, 1, attempts)
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM items").Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestWritesOutsideTransactionsWaitForTheWriter(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO items (id, name, value_units) VALUES ('a', 'Test Item', 10000)")
+	require.NoError(t, err)
+
+	// Reads, including read-only transactions, do not wait
+	ro, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, ro.QueryRow("SELECT COUNT(*) FROM items").Scan(&n))
+	require.NoError(t, ro.Commit())
+	assert.Equal(t, 0, n)
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := db.Exec("UPDATE items SET name = 'Renamed' WHERE id = 'a'")
+		done <- err
+	}()
+	go func() {
+		var name string
+		done <- db.QueryRow("UPDATE items SET name = name || '!' WHERE id = 'a' RETURNING name").Scan(&name)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("write ran while a transaction held the writer: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, tx.Commit())
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+	var name string
+	require.NoError(t, db.QueryRow("SELECT name FROM items WHERE id = 'a'").Scan(&name))
+	assert.Contains(t, name, "Renamed")
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Retry controls how transactions are retried on lock contention
+type Retry struct {
+	// MaxAttempts is the total number of attempts, including the first
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles on each
+	// attempt up to MaxDelay, with full jitter
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetry is the policy used by WithTx
+var DefaultRetry = Retry{
+	MaxAttempts: 5,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    500 * time.Millisecond,
+}
+
+// TxStats counts transactions run through WithTx
+type TxStats struct {
+	Transactions int64 `json:"transactions"`
+	Retries      int64 `json:"retries"`
+	BusyFailures int64 `json:"busy_failures"`
+}
+
+var (
+	transactions atomic.Int64
+	retries      atomic.Int64
+	busyFailures atomic.Int64
+)
+
+func init() {
+	expvar.Publish("database", expvar.Func(func() any { return Stats() }))
+}
+
+// Stats returns the transaction counters, also published as the "database"
+// expvar
+func Stats() TxStats {
+	return TxStats{
+		Transactions: transactions.Load(),
+		Retries:      retries.Load(),
+		BusyFailures: busyFailures.Load(),
+	}
+}
+
+// WithTx runs fn in a write transaction using DefaultRetry. The transaction
+// is committed when fn returns nil and rolled back otherwise. Busy and locked
+// errors are retried with jittered backoff, so fn must be safe to run more
+// than once. Calls must not be nested.
+func WithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return DefaultRetry.WithTx(ctx, db, fn)
+}
+
+// WithTx runs fn in a write transaction using the policy r
+func (r Retry) WithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	transactions.Add(1)
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, fn)
+		if err == nil || !IsBusy(err) {
+			return err
+		}
+		if attempt >= r.MaxAttempts {
+			busyFailures.Add(1)
+			return err
+		}
+
+		retries.Add(1)
+		select {
+		case <-time.After(r.backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// backoff returns a random delay up to BaseDelay*2^(attempt-1), capped at
+// MaxDelay
+func (r Retry) backoff(attempt int) time.Duration {
+	d := r.BaseDelay << (attempt - 1)
+	if d <= 0 || d > r.MaxDelay {
+		d = r.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + 1
+}
+
+func runTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsBusy reports whether err is a transient SQLite lock error
+func IsBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
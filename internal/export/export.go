@@ -0,0 +1,214 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/filter"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// Format is an export file format
+type Format string
+
+// Supported export formats
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+	XLSX   Format = "xlsx"
+)
+
+// contentTypes maps formats to their media types
+var contentTypes = map[Format]string{
+	CSV:    "text/csv; charset=utf-8",
+	NDJSON: "application/x-ndjson",
+	XLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// acceptTypes maps media types accepted in an Accept header to formats
+var acceptTypes = map[string]Format{
+	"text/csv":             CSV,
+	"application/x-ndjson": NDJSON,
+	"application/ndjson":   NDJSON,
+	"application/jsonl":    NDJSON,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": XLSX,
+}
+
+// ContentType returns the media type of the format
+func (f Format) ContentType() string {
+	return contentTypes[f]
+}
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, bool) {
+	f := Format(strings.ToLower(name))
+	_, ok := contentTypes[f]
+	return f, ok
+}
+
+// Negotiate picks the format from an explicit format name or, failing that,
+// the first supported media type in an Accept header. CSV is the default.
+func Negotiate(name, accept string) (Format, error) {
+	if name != "" {
+		f, ok := ParseFormat(name)
+		if !ok {
+			return "", apierror.InvalidArgument("invalid export request",
+				apierror.FieldViolation{Field: "format", Description: "format must be csv, ndjson or xlsx"})
+		}
+		return f, nil
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if f, ok := acceptTypes[mediaType]; ok {
+			return f, nil
+		}
+	}
+	return CSV, nil
+}
+
+// Request selects the items and columns to export. The items are selected
+// as items.List selects them.
+type Request struct {
+	Format Format
+	items.ListRequest
+	// Columns to write, in order; every item field when empty
+	Columns []string
+	// ExcelSafe prefixes CSV text that a spreadsheet would evaluate as a
+	// formula with a single quote. Imports keep the quote, so such a file
+	// does not round-trip.
+	ExcelSafe bool
+}
+
+// Export is a validated export ready to run
+type Export struct {
+	format    Format
+	columns   []string
+	fields    []filter.Field
+	items     items.ListRequest
+	excelSafe bool
+}
+
+// Prepare validates a request. Errors are *apierror.Error values.
+func Prepare(req Request) (*Export, error) {
+	if _, ok := contentTypes[req.Format]; !ok {
+		return nil, apierror.InvalidArgument("invalid export request",
+			apierror.FieldViolation{Field: "format", Description: "format must be csv, ndjson or xlsx"})
+	}
+	// The rest of the selection needs the database and is checked by Write
+	if _, err := filter.ForItems(req.Filter); err != nil {
+		return nil, err
+	}
+
+	columns := req.Columns
+	if len(columns) == 0 {
+		columns = filter.ItemFields.Names()
+	}
+	e := &Export{format: req.Format, columns: columns, items: req.ListRequest, excelSafe: req.ExcelSafe}
+	seen := make(map[string]bool)
+	for _, name := range columns {
+		field, ok := filter.ItemFields[name]
+		if !ok || seen[name] {
+			return nil, apierror.InvalidArgument("invalid export request", apierror.FieldViolation{
+				Field:       "columns",
+				Description: "columns must be distinct names from " + strings.Join(filter.ItemFields.Names(), ", "),
+			})
+		}
+		seen[name] = true
+		e.fields = append(e.fields, field)
+	}
+	return e, nil
+}
+
+// Format returns the format of the export
+func (e *Export) Format() Format {
+	return e.format
+}
+
+// Filename suggests a file name for an export taken at t
+func (e *Export) Filename(t time.Time) string {
+	return "items-" + t.UTC().Format("20060102T150405Z") + "." + string(e.format)
+}
+
+// Write streams the matching items to w one row at a time and returns the
+// number of rows written. Nothing is written to w until the query has
+// started, so a failed query leaves w untouched. An invalid metadata path is
+// an *apierror.Error.
+func (e *Export) Write(ctx context.Context, db *sql.DB, w io.Writer) (int64, error) {
+	cols := make([]string, len(e.fields))
+	for i, f := range e.fields {
+		cols[i] = f.Column
+	}
+	where, args, err := e.items.Where(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+strings.Join(cols, ", ")+" FROM items WHERE "+where+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	out := newWriter(e.format, w, e.columns, e.excelSafe)
+	if err := out.WriteHeader(); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	values := make([]any, len(e.fields))
+	dest := make([]any, len(e.fields))
+	for rows.Next() {
+		for i, f := range e.fields {
+			switch f.Type {
+			case filter.NumberField:
+				dest[i] = new(sql.NullFloat64)
+			case filter.DecimalField:
+				dest[i] = new(sql.NullInt64)
+			case filter.TimeField:
+				dest[i] = new(sql.NullTime)
+			default:
+				dest[i] = new(sql.NullString)
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return count, err
+		}
+		for i, d := range dest {
+			switch d := d.(type) {
+			case *sql.NullFloat64:
+				values[i] = nullable(d.Valid, d.Float64)
+			case *sql.NullInt64:
+				values[i] = nullable(d.Valid, money.FromUnits(d.Int64).Trim(0))
+			case *sql.NullTime:
+				values[i] = nullable(d.Valid, d.Time)
+			case *sql.NullString:
+				values[i] = nullable(d.Valid, d.String)
+			}
+		}
+		if err := out.WriteRow(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, out.Close()
+}
+
+// nullable returns v, or nil for a NULL column
+func nullable[T any](valid bool, v T) any {
+	if !valid {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/export"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/money"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+// setupTestDB creates an in-memory database with three items
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, database.Migrate(db))
+
+	base := time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)
+	for i, item := range []struct {
+		name  string
+		value float64
+	}{{"Widget", 9.5}, {"=SUM(A1)", 20}, {`Gadget, "large"`, 30.25}} {
+		_, err := db.Exec("INSERT INTO items (id, name, value_units, created_at) VALUES (?, ?, ?, ?)",
+			string(rune('a'+i)), item.name, int64(item.value*10000), base.Add(time.Duration(i)*time.Hour))
+		require.NoError(t, err)
+	}
+	_, err = db.Exec(`INSERT INTO tags (name) VALUES ('blue'), ('small');
+		INSERT INTO item_tags (item_id, tag_id) SELECT 'a', id FROM tags`)
+	require.NoError(t, err)
+	return db
+}
+
+func run(t *testing.T, db *sql.DB, req export.Request) []byte {
+	t.Helper()
+	e, err := export.Prepare(req)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = e.Write(context.Background(), db, &buf)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	db := setupTestDB(t)
+
+	records, err := csv.NewReader(bytes.NewReader(run(t, db, export.Request{Format: export.CSV}))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"id", "name", "value", "description", "tags", "category", "currency", "parent_id", "created_at", "updated_at"},
+		{"a", "Widget", "9.5", "", "blue,small", "", "", "", "2026-03-30T12:00:00Z", "2026-03-30T12:00:00Z"},
+		{"b", "=SUM(A1)", "20", "", "", "", "", "", "2026-03-30T13:00:00Z", "2026-03-30T13:00:00Z"},
+		{"c", `Gadget, "large"`, "30.25", "", "", "", "", "", "2026-03-30T14:00:00Z", "2026-03-30T14:00:00Z"},
+	}, records)
+
+	// Formulas are neutralized on request
+	out := run(t, db, export.Request{Format: export.CSV, Columns: []string{"name"}, ExcelSafe: true})
+	assert.Equal(t, "name\nWidget\n'=SUM(A1)\n\"Gadget, \"\"large\"\"\"\n", string(out))
+}
+
+func TestNDJSONWithFilterAndColumns(t *testing.T) {
+	db := setupTestDB(t)
+
+	out := run(t, db, export.Request{Format: export.NDJSON, ListRequest: items.ListRequest{Filter: "value >= 20"}, Columns: []string{"value", "name"}})
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	assert.Equal(t, []string{
+		`{"value":"20","name":"=SUM(A1)"}`,
+		`{"value":"30.25","name":"Gadget, \"large\""}`,
+	}, lines)
+}
+
+func TestXLSX(t *testing.T) {
+	db := setupTestDB(t)
+
+	out := run(t, db, export.Request{Format: export.XLSX, Columns: []string{"name", "value", "created_at"}})
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	require.NoError(t, err)
+
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			body, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			sheet = string(body)
+		}
+	}
+	require.NotEmpty(t, sheet, "workbook has no sheet")
+	assert.Equal(t, 4, strings.Count(sheet, "<row "))
+	assert.Contains(t, sheet, `<c r="A1" t="inlineStr"><is><t xml:space="preserve">name</t></is></c>`)
+	assert.Contains(t, sheet, `<t xml:space="preserve">Gadget, &#34;large&#34;</t>`)
+	assert.Contains(t, sheet, `<c r="B4"><v>30.25</v></c>`)
+	// 2026-03-30 12:00 UTC
+	assert.Contains(t, sheet, `<c r="C2" s="1"><v>46111.5</v></c>`)
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name, accept string
+		want         export.Format
+	}{
+		{"", "", export.CSV},
+		{"", "application/json, application/x-ndjson;q=0.9", export.NDJSON},
+		{"", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", export.XLSX},
+		{"XLSX", "text/csv", export.XLSX},
+	}
+	for _, tt := range tests {
+		f, err := export.Negotiate(tt.name, tt.accept)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, f)
+	}
+
+	_, err := export.Negotiate("pdf", "")
+	assert.Error(t, err)
+}
+
+func TestInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   export.Request
+		field string
+	}{
+		{"format", export.Request{Format: "pdf"}, "format"},
+		{"unknown column", export.Request{Format: export.CSV, Columns: []string{"price"}}, "columns"},
+		{"duplicate column", export.Request{Format: export.CSV, Columns: []string{"name", "name"}}, "columns"},
+		{"filter", export.Request{Format: export.CSV, ListRequest: items.ListRequest{Filter: "value >"}}, "filter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := export.Prepare(tt.req)
+			var apiErr *apierror.Error
+			require.True(t, errors.As(err, &apiErr), "got %v", err)
+			assert.Equal(t, codes.InvalidArgument, apiErr.Code)
+			require.Len(t, apiErr.Violations, 1)
+			assert.Equal(t, tt.field, apiErr.Violations[0].Field)
+		})
+	}
+}
+
+func TestExportEndpoint(t *testing.T) {
+	db := setupTestDB(t)
+	h := handlers.NewHandler(db, money.Rules{})
+
+	req := httptest.NewRequest("GET", "/api/items/export?filter=value%3C10&columns=id,value", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+	h.ExportItems(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename="items-\d{8}T\d{6}Z\.ndjson"$`, rr.Header().Get("Content-Disposition"))
+	var row map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &row))
+	assert.Equal(t, map[string]any{"id": "a", "value": "9.5"}, row)
+
+	rr = httptest.NewRecorder()
+	h.ExportItems(rr, httptest.NewRequest("GET", "/api/items/export?columns=price", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, apierror.ProblemContentType, rr.Header().Get("Content-Type"))
+
+	rr = httptest.NewRecorder()
+	h.ExportItems(rr, httptest.NewRequest("GET", "/api/items/export?format=csv&columns=name&excel_safe=true", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "\n'=SUM(A1)\n")
+	rr = httptest.NewRecorder()
+	h.ExportItems(rr, httptest.NewRequest("GET", "/api/items/export?excel_safe=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Items are selected by the same parameters as the item list
+	_, err := db.Exec(`UPDATE items SET category = 'tools', metadata = '{"size":"large"}' WHERE id IN ('b', 'c');
+		UPDATE items SET metadata = '{"size":"small"}' WHERE id = 'c'`)
+	require.NoError(t, err)
+	for query, want := range map[string]string{
+		"tag=blue&tag=small":                   "id\na\n",
+		"category=tools":                       "id\nb\nc\n",
+		"category=tools&meta.size=large":       "id\nb\n",
+		"filter=value%3E25&category=tools":     "id\nc\n",
+		"meta.size=small&tag=blue":             "id\n",
+		"category=tools&filter=name%3D%22x%22": "id\n",
+	} {
+		rr = httptest.NewRecorder()
+		h.ExportItems(rr, httptest.NewRequest("GET", "/api/items/export?format=csv&columns=id&"+query, nil))
+		require.Equal(t, http.StatusOK, rr.Code, query)
+		assert.Equal(t, want, strings.ReplaceAll(rr.Body.String(), "\r\n", "\n"), query)
+	}
+	rr = httptest.NewRecorder()
+	h.ExportItems(rr, httptest.NewRequest("GET", "/api/items/export?format=csv&meta.bad..path=x", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Disposition"))
+
+	// A failed query is still answered with a problem, not a download
+	_, err = db.Exec("DROP TABLE items")
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	h.ExportItems(rr, httptest.NewRequest("GET", "/api/items/export?format=csv", nil))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Disposition"))
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// rowWriter encodes rows in one format. Values are nil, float64,
+// money.Decimal, time.Time or string.
+type rowWriter interface {
+	WriteHeader() error
+	WriteRow(values []any) error
+	Close() error
+}
+
+// flushEvery is how many rows are buffered before they are flushed
+const flushEvery = 100
+
+// newWriter returns the writer for format. excelSafe neutralizes formulas in
+// CSV text.
+func newWriter(format Format, w io.Writer, columns []string, excelSafe bool) rowWriter {
+	switch format {
+	case NDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), flusher: flusherOf(w), columns: columns}
+	case XLSX:
+		return newXLSXWriter(w, columns)
+	default:
+		return &csvWriter{w: csv.NewWriter(w), flusher: flusherOf(w), columns: columns, excelSafe: excelSafe}
+	}
+}
+
+// flusher is implemented by http.ResponseWriter
+type flusher interface {
+	Flush()
+}
+
+// flusherOf returns a Flush for w, or a no-op
+func flusherOf(w io.Writer) func() {
+	if f, ok := w.(flusher); ok {
+		return f.Flush
+	}
+	return func() {}
+}
+
+// csvWriter writes RFC 4180 CSV with a header row
+type csvWriter struct {
+	w         *csv.Writer
+	flusher   func()
+	columns   []string
+	excelSafe bool
+	rows      int
+}
+
+func (c *csvWriter) WriteHeader() error {
+	return c.w.Write(c.columns)
+}
+
+func (c *csvWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case money.Decimal:
+			record[i] = v.String()
+		case time.Time:
+			record[i] = v.UTC().Format(time.RFC3339Nano)
+		case string:
+			if c.excelSafe {
+				v = neutralizeFormula(v)
+			}
+			record[i] = v
+		}
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	c.rows++
+	if c.rows%flushEvery == 0 {
+		c.w.Flush()
+		c.flusher()
+	}
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// neutralizeFormula prefixes text that a spreadsheet would evaluate as a
+// formula with a single quote, so a cell like =HYPERLINK(...) is shown as
+// text when the CSV is opened
+func neutralizeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// ndjsonWriter writes one JSON object per line with keys in column order
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	flusher func()
+	columns []string
+	rows    int
+}
+
+func (n *ndjsonWriter) WriteHeader() error {
+	return nil
+}
+
+func (n *ndjsonWriter) WriteRow(values []any) error {
+	n.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(n.columns[i])
+		n.w.Write(key)
+		n.w.WriteByte(':')
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339Nano)
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		n.w.Write(value)
+	}
+	if _, err := n.w.WriteString("}\n"); err != nil {
+		return err
+	}
+	n.rows++
+	if n.rows%flushEvery == 0 {
+		if err := n.w.Flush(); err != nil {
+			return err
+		}
+		n.flusher()
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
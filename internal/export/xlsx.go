@@ -0,0 +1,157 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// MaxXLSXRows is the number of data rows that fit on a sheet below the
+// header
+const MaxXLSXRows = 1<<20 - 1
+
+// ErrTooManyRows is returned when an export does not fit on one sheet
+var ErrTooManyRows = errors.New("xlsx export is limited to 1048575 rows, use csv or ndjson")
+
+// xlsxParts are the fixed parts of a workbook with a single sheet. Style 1
+// formats numbers as date and time.
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Items" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts><fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs></styleSheet>`},
+}
+
+// xlsxWriter streams a workbook. The fixed parts are written first and the
+// sheet last, so rows go to the zip stream as they are read instead of
+// being assembled in memory.
+type xlsxWriter struct {
+	zip     *zip.Writer
+	sheet   *bufio.Writer
+	flusher func()
+	columns []string
+	refs    []string
+	row     int
+}
+
+func newXLSXWriter(w io.Writer, columns []string) *xlsxWriter {
+	x := &xlsxWriter{zip: zip.NewWriter(w), flusher: flusherOf(w), columns: columns}
+	for i := range columns {
+		x.refs = append(x.refs, columnRef(i))
+	}
+	return x
+}
+
+func (x *xlsxWriter) WriteHeader() error {
+	for _, part := range xlsxParts {
+		f, err := x.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(sheet)
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]any, len(x.columns))
+	for i, c := range x.columns {
+		header[i] = c
+	}
+	return x.writeRow(header)
+}
+
+func (x *xlsxWriter) WriteRow(values []any) error {
+	if x.row > MaxXLSXRows {
+		return ErrTooManyRows
+	}
+	if err := x.writeRow(values); err != nil {
+		return err
+	}
+	if x.row%flushEvery == 0 {
+		if err := x.sheet.Flush(); err != nil {
+			return err
+		}
+		if err := x.zip.Flush(); err != nil {
+			return err
+		}
+		x.flusher()
+	}
+	return nil
+}
+
+// writeRow writes one sheet row. Text uses inline strings so no shared
+// string table has to be built up front.
+func (x *xlsxWriter) writeRow(values []any) error {
+	x.row++
+	r := strconv.Itoa(x.row)
+	w := x.sheet
+	w.WriteString(`<row r="` + r + `">`)
+	for i, v := range values {
+		ref := x.refs[i] + r
+		switch v := v.(type) {
+		case float64:
+			w.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatFloat(v, 'f', -1, 64) + `</v></c>`)
+		case money.Decimal:
+			w.WriteString(`<c r="` + ref + `"><v>` + v.String() + `</v></c>`)
+		case time.Time:
+			w.WriteString(`<c r="` + ref + `" s="1"><v>` + strconv.FormatFloat(excelSerial(v), 'f', -1, 64) + `</v></c>`)
+		case string:
+			w.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(w, []byte(v)); err != nil {
+				return err
+			}
+			w.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := w.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if x.sheet == nil {
+		return x.zip.Close()
+	}
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+// unixEpochSerial is the spreadsheet serial date of 1970-01-01
+const unixEpochSerial = 25569
+
+// excelSerial converts t to a spreadsheet serial date in UTC
+func excelSerial(t time.Time) float64 {
+	return unixEpochSerial + float64(t.Unix())/86400 + float64(t.Nanosecond())/(86400*1e9)
+}
+
+// columnRef returns the spreadsheet column name for a 0-based index: A, B,
+// ..., Z, AA and so on
+func columnRef(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,223 @@
+package filter
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// Clause is a compiled filter: a boolean SQL expression with ? placeholders
+// and the arguments to bind. SQL only ever contains column names from Fields
+// and fixed operators; every value from the filter is an argument.
+type Clause struct {
+	SQL  string
+	Args []any
+}
+
+// sqlOps maps comparison operators to SQL
+var sqlOps = map[string]string{
+	"=":  "=",
+	"!=": "!=",
+	"<":  "<",
+	"<=": "<=",
+	">":  ">",
+	">=": ">=",
+}
+
+// timeLayouts are the accepted formats for time values
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// Compile validates expr against the fields and compiles it to SQL. A nil
+// expression matches every row.
+func (f Fields) Compile(expr Expr) (*Clause, error) {
+	c := &Clause{}
+	if expr == nil {
+		c.SQL = "1"
+		return c, nil
+	}
+	var b strings.Builder
+	if err := f.compile(expr, &b, c); err != nil {
+		return nil, err
+	}
+	c.SQL = b.String()
+	return c, nil
+}
+
+func (f Fields) compile(expr Expr, b *strings.Builder, c *Clause) error {
+	switch e := expr.(type) {
+	case *Logical:
+		b.WriteString("(")
+		if err := f.compile(e.Left, b, c); err != nil {
+			return err
+		}
+		b.WriteString(" " + e.Op + " ")
+		if err := f.compile(e.Right, b, c); err != nil {
+			return err
+		}
+		b.WriteString(")")
+	case *Not:
+		b.WriteString("(NOT ")
+		if err := f.compile(e.Expr, b, c); err != nil {
+			return err
+		}
+		b.WriteString(")")
+	case *Comparison:
+		return f.comparison(e, b, c)
+	default:
+		return errorf(expr.Position(), "unsupported expression")
+	}
+	return nil
+}
+
+func (f Fields) comparison(e *Comparison, b *strings.Builder, c *Clause) error {
+	field, ok := f[e.Field]
+	if !ok {
+		return errorf(e.Pos, "unknown field %q, expected one of %s", e.Field, f.names())
+	}
+
+	switch field.Type {
+	case NumberField:
+		op, ok := sqlOps[e.Op]
+		if !ok {
+			return errorf(e.Pos, "operator %q is not supported for number field %q", e.Op, e.Field)
+		}
+		if e.Value.Kind != NumberLiteral {
+			return errorf(e.Value.Pos, "field %q expects a number", e.Field)
+		}
+		n, err := strconv.ParseFloat(e.Value.Text, 64)
+		if err != nil {
+			return errorf(e.Value.Pos, "invalid number %q", e.Value.Text)
+		}
+		b.WriteString(field.Column + " " + op + " ?")
+		c.Args = append(c.Args, n)
+
+	case DecimalField:
+		op, ok := sqlOps[e.Op]
+		if !ok {
+			return errorf(e.Pos, "operator %q is not supported for number field %q", e.Op, e.Field)
+		}
+		if e.Value.Kind != NumberLiteral {
+			return errorf(e.Value.Pos, "field %q expects a number", e.Field)
+		}
+		d, err := money.Parse(e.Value.Text)
+		if err != nil {
+			return errorf(e.Value.Pos, "invalid number %q", e.Value.Text)
+		}
+		units, err := d.Trim(money.Scale).Units()
+		if err != nil {
+			return errorf(e.Value.Pos, "field %q has at most %d decimal places", e.Field, money.Scale)
+		}
+		b.WriteString(field.Column + " " + op + " ?")
+		c.Args = append(c.Args, units)
+
+	case TimeField:
+		op, ok := sqlOps[e.Op]
+		if !ok {
+			return errorf(e.Pos, "operator %q is not supported for time field %q", e.Op, e.Field)
+		}
+		t, err := parseTime(e.Value.Text)
+		if err != nil || e.Value.Kind == NumberLiteral {
+			return errorf(e.Value.Pos, "field %q expects a time such as \"2026-01-01\" or \"2026-01-01T15:04:05Z\"", e.Field)
+		}
+		// julianday normalizes stored timestamps with or without a zone
+		b.WriteString("julianday(" + field.Column + ") " + op + " julianday(?)")
+		c.Args = append(c.Args, t.UTC().Format("2006-01-02T15:04:05.000Z"))
+
+	case ListField:
+		if e.Op != ":" || field.Has == "" {
+			return errorf(e.Pos, "operator %q is not supported for list field %q, use %s:\"value\"", e.Op, e.Field, e.Field)
+		}
+		b.WriteString(field.Has)
+		c.Args = append(c.Args, e.Value.Text)
+
+	case TextField:
+		switch e.Op {
+		case "~":
+			b.WriteString(field.Column + ` LIKE ? ESCAPE '\'`)
+			c.Args = append(c.Args, globToLike(e.Value.Text))
+		case ":":
+			b.WriteString(field.Column + ` LIKE ? ESCAPE '\'`)
+			c.Args = append(c.Args, "%"+escapeLike(e.Value.Text)+"%")
+		default:
+			b.WriteString(field.Column + " " + sqlOps[e.Op] + " ?")
+			c.Args = append(c.Args, e.Value.Text)
+		}
+	}
+	return nil
+}
+
+// names lists the field names for error messages
+func (f Fields) names() string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parseTime accepts an RFC 3339 time, a local time without zone (read as
+// UTC) or a date
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid time")
+}
+
+// globToLike converts a * and ? wildcard pattern to a LIKE pattern
+func globToLike(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteRune('%')
+		case '?':
+			b.WriteRune('_')
+		default:
+			b.WriteString(escapeLike(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// ForItems parses and compiles a filter over items. Errors are
+// *apierror.Error values with a violation on the filter field, ready to be
+// returned by either transport.
+func ForItems(filter string) (*Clause, error) {
+	expr, err := Parse(filter)
+	if err != nil {
+		return nil, invalidFilter(err)
+	}
+	clause, err := ItemFields.Compile(expr)
+	if err != nil {
+		return nil, invalidFilter(err)
+	}
+	return clause, nil
+}
+
+// invalidFilter reports a filter error, with its position, as a field
+// violation
+func invalidFilter(err error) error {
+	var filterErr *Error
+	if !errors.As(err, &filterErr) {
+		return apierror.Internal(err, "compiling filter")
+	}
+	apiErr := apierror.InvalidArgument("invalid filter",
+		apierror.FieldViolation{Field: "filter", Description: filterErr.Error()})
+	apiErr.Metadata = map[string]string{"position": strconv.Itoa(filterErr.Pos)}
+	return apiErr
+}
@@ -0,0 +1,200 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxLength is the longest filter accepted, in characters
+const MaxLength = 2048
+
+// Error reports a syntax or validation error at a 1-based character
+// position in the filter
+type Error struct {
+	Pos int
+	Msg string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+func errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// describe names a token for error messages
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of filter"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// operators are listed longest first so "<=" wins over "<"
+var operators = []string{"!=", "<=", ">=", "=", "<", ">", "~", ":"}
+
+// lex splits a filter into tokens
+func lex(input string) ([]token, error) {
+	src := []rune(input)
+	if len(src) > MaxLength {
+		return nil, errorf(MaxLength+1, "filter is longer than %d characters", MaxLength)
+	}
+
+	var tokens []token
+	for i := 0; i < len(src); {
+		r := src[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", pos})
+			i++
+		case r == '"' || r == '\'':
+			text, n, err := lexString(src[i:], pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokString, text, pos})
+			i += n
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(src) && unicode.IsDigit(src[i+1])):
+			n := lexNumber(src[i:])
+			tokens = append(tokens, token{tokNumber, string(src[i : i+n]), pos})
+			i += n
+		case r == '-':
+			tokens = append(tokens, token{tokNot, "-", pos})
+			i++
+		case isIdentRune(r, true):
+			n := 1
+			for i+n < len(src) && isIdentRune(src[i+n], false) {
+				n++
+			}
+			text := string(src[i : i+n])
+			kind := tokIdent
+			switch text {
+			case "AND":
+				kind = tokAnd
+			case "OR":
+				kind = tokOr
+			case "NOT":
+				kind = tokNot
+			}
+			tokens = append(tokens, token{kind, text, pos})
+			i += n
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(string(src[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errorf(pos, "unexpected character %q", r)
+			}
+			tokens = append(tokens, token{tokOp, op, pos})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(src) + 1}), nil
+}
+
+// lexString reads a quoted string starting at src[0] and returns its value
+// and the number of characters consumed
+func lexString(src []rune, pos int) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i == len(src) {
+				break
+			}
+			switch src[i] {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case '\\', '"', '\'':
+				b.WriteRune(src[i])
+			default:
+				return "", 0, errorf(pos+i-1, "unknown escape sequence \\%c", src[i])
+			}
+		default:
+			b.WriteRune(src[i])
+		}
+	}
+	return "", 0, errorf(pos, "unterminated string")
+}
+
+// lexNumber returns the length of the number at the start of src
+func lexNumber(src []rune) int {
+	n := 0
+	if src[0] == '-' {
+		n++
+	}
+	digits := func() {
+		for n < len(src) && unicode.IsDigit(src[n]) {
+			n++
+		}
+	}
+	digits()
+	if n+1 < len(src) && src[n] == '.' && unicode.IsDigit(src[n+1]) {
+		n++
+		digits()
+	}
+	if n < len(src) && (src[n] == 'e' || src[n] == 'E') {
+		m := n + 1
+		if m < len(src) && (src[m] == '+' || src[m] == '-') {
+			m++
+		}
+		if m < len(src) && unicode.IsDigit(src[m]) {
+			n = m
+			digits()
+		}
+	}
+	return n
+}
+
+// isIdentRune reports whether r may appear in a field name or bare value.
+// Dots allow nested field paths.
+func isIdentRune(r rune, first bool) bool {
+	if unicode.IsLetter(r) || r == '_' {
+		return true
+	}
+	return !first && (unicode.IsDigit(r) || r == '.' || r == '*')
+}
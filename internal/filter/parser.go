@@ -0,0 +1,218 @@
+package filter
+
+// MaxDepth bounds how deeply parentheses and NOT may nest
+const MaxDepth = 32
+
+// Expr is a node of a parsed filter
+type Expr interface {
+	// Position returns the 1-based character position of the node
+	Position() int
+}
+
+// Logical combines two expressions with AND or OR
+type Logical struct {
+	Op          string
+	Left, Right Expr
+	Pos         int
+}
+
+// Not negates an expression
+type Not struct {
+	Expr Expr
+	Pos  int
+}
+
+// Comparison tests a field against a literal, e.g. value > 10
+type Comparison struct {
+	Field string
+	Op    string
+	Value Literal
+	Pos   int
+}
+
+// LiteralKind distinguishes quoted strings, numbers and bare words
+type LiteralKind int
+
+const (
+	StringLiteral LiteralKind = iota
+	NumberLiteral
+	BareLiteral
+)
+
+// Literal is the right-hand side of a comparison
+type Literal struct {
+	Kind LiteralKind
+	Text string
+	Pos  int
+}
+
+func (e *Logical) Position() int    { return e.Pos }
+func (e *Not) Position() int        { return e.Pos }
+func (e *Comparison) Position() int { return e.Pos }
+
+// Parse parses a filter into an AST. The grammar follows AIP-160:
+//
+//	expression = sequence { "AND" sequence }
+//	sequence   = factor { factor }            (implicit AND)
+//	factor     = term { "OR" term }
+//	term       = [ "NOT" | "-" ] simple
+//	simple     = "(" expression ")" | field operator value
+//
+// so OR binds tighter than AND. An empty filter parses to nil.
+func Parse(input string) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, nil
+	}
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorf(t.pos, "unexpected %s", t.describe())
+	}
+	return expr, nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) expression() (Expr, error) {
+	left, err := p.sequence()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		op := p.advance()
+		right, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "AND", Left: left, Right: right, Pos: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) sequence() (Expr, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for startsTerm(p.peek()) {
+		pos := p.peek().pos
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "AND", Left: left, Right: right, Pos: pos}
+	}
+	return left, nil
+}
+
+func (p *parser) factor() (Expr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		op := p.advance()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "OR", Left: left, Right: right, Pos: op.pos}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (Expr, error) {
+	if p.peek().kind != tokNot {
+		return p.simple()
+	}
+	not := p.advance()
+	if err := p.enter(not); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	expr, err := p.simple()
+	if err != nil {
+		return nil, err
+	}
+	return &Not{Expr: expr, Pos: not.pos}, nil
+}
+
+func (p *parser) simple() (Expr, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokLParen:
+		if err := p.enter(t); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokRParen {
+			return nil, errorf(closing.pos, "expected \")\" to close \"(\" at position %d, got %s", t.pos, closing.describe())
+		}
+		return expr, nil
+	case tokIdent:
+		op := p.advance()
+		if op.kind != tokOp {
+			return nil, errorf(op.pos, "expected an operator after %q, got %s", t.text, op.describe())
+		}
+		value := p.advance()
+		var lit Literal
+		switch value.kind {
+		case tokString:
+			lit = Literal{Kind: StringLiteral, Text: value.text, Pos: value.pos}
+		case tokNumber:
+			lit = Literal{Kind: NumberLiteral, Text: value.text, Pos: value.pos}
+		case tokIdent:
+			lit = Literal{Kind: BareLiteral, Text: value.text, Pos: value.pos}
+		default:
+			return nil, errorf(value.pos, "expected a value after %q, got %s", op.text, value.describe())
+		}
+		return &Comparison{Field: t.text, Op: op.text, Value: lit, Pos: t.pos}, nil
+	default:
+		return nil, errorf(t.pos, "expected a field name or \"(\", got %s", t.describe())
+	}
+}
+
+// enter tracks nesting so deeply nested filters cannot exhaust the stack
+func (p *parser) enter(t token) error {
+	p.depth++
+	if p.depth > MaxDepth {
+		return errorf(t.pos, "filter is nested more than %d levels deep", MaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+// startsTerm reports whether t can begin another term of an implicit AND
+func startsTerm(t token) bool {
+	return t.kind == tokIdent || t.kind == tokLParen || t.kind == tokNot
+}
@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// FieldType decides which operators and values a field accepts
+type FieldType int
+
+const (
+	TextField FieldType = iota
+	NumberField
+	TimeField
+	// ListField is a list of strings, matched with the has operator (:)
+	ListField
+	// DecimalField is an exact money.Decimal stored as 10^-money.Scale units
+	DecimalField
+)
+
+// Field is a filterable column. Order is the position of the field in its
+// model. Column may be an SQL expression; list fields also need Has, a
+// condition with one ? for the value an element must equal.
+type Field struct {
+	Column string
+	Type   FieldType
+	Order  int
+	Has    string
+}
+
+// Fields maps the names a filter may use to columns
+type Fields map[string]Field
+
+// ItemFields are the filterable fields of models.Item
+var ItemFields = itemFields()
+
+// Tags live in their own table. They read as one comma-separated string and
+// match case-insensitively, as they are stored in lowercase. Root items have
+// a NULL parent, which reads as empty like in models.Item.
+const (
+	itemTags   = "(SELECT group_concat(t.name, ',' ORDER BY t.name) FROM item_tags it JOIN tags t ON t.id = it.tag_id WHERE it.item_id = items.id)"
+	itemHasTag = "EXISTS (SELECT 1 FROM item_tags it JOIN tags t ON t.id = it.tag_id WHERE it.item_id = items.id AND t.name = lower(trim(?)))"
+	itemParent = "COALESCE(items.parent_id, '')"
+)
+
+func itemFields() Fields {
+	fields := FieldsOf(models.Item{})
+	tags := fields["tags"]
+	tags.Column, tags.Has = itemTags, itemHasTag
+	fields["tags"] = tags
+	parent := fields["parent_id"]
+	parent.Column = itemParent
+	fields["parent_id"] = parent
+	return fields
+}
+
+// FieldsOf derives filterable fields from a model struct. Each exported field
+// with a json tag becomes a field of that name backed by the column of the
+// same name, or of the name with _units for a money.Decimal; fields of other
+// types than string, string slices, numbers, decimals and time.Time are
+// skipped.
+func FieldsOf(model any) Fields {
+	fields := make(Fields)
+	t := reflect.TypeOf(model)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		field := Field{Column: name, Order: i}
+		switch {
+		case f.Type == reflect.TypeOf(time.Time{}):
+			field.Type = TimeField
+		case f.Type == reflect.TypeOf(money.Decimal{}):
+			field.Type = DecimalField
+			field.Column = name + "_units"
+		case f.Type.Kind() == reflect.String:
+			field.Type = TextField
+		case f.Type.Kind() >= reflect.Int && f.Type.Kind() <= reflect.Float64:
+			field.Type = NumberField
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.String:
+			field.Type = ListField
+		default:
+			continue
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// Names returns the field names in model order
+func (f Fields) Names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return f[names[i]].Order < f[names[j]].Order })
+	return names
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/filter"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		filter string
+		sql    string
+		args   []any
+	}{
+		{"", "1", nil},
+		{"value > 10", "value_units > ?", []any{int64(100000)}},
+		{"value = 9.50000", "value_units = ?", []any{int64(95000)}},
+		{"value >= -1.5e2", "value_units >= ?", []any{int64(-1500000)}},
+		{`name = "a \"b\""`, "name = ?", []any{`a "b"`}},
+		{"name = widget", "name = ?", []any{"widget"}},
+		{`name ~ "wid*_?"`, `name LIKE ? ESCAPE '\'`, []any{`wid%\__`}},
+		{`name : "50%"`, `name LIKE ? ESCAPE '\'`, []any{`%50\%%`}},
+		{`created_at < "2026-01-01"`, "julianday(created_at) < julianday(?)", []any{"2026-01-01T00:00:00.000Z"}},
+		{`created_at >= "2026-01-01T12:00:00+02:00"`, "julianday(created_at) >= julianday(?)", []any{"2026-01-01T10:00:00.000Z"}},
+		{`tags : "blue"`, "EXISTS (SELECT 1 FROM item_tags it JOIN tags t ON t.id = it.tag_id WHERE it.item_id = items.id AND t.name = lower(trim(?)))", []any{"blue"}},
+		{"NOT value = 1", "(NOT value_units = ?)", []any{int64(10000)}},
+		{"-value = 1", "(NOT value_units = ?)", []any{int64(10000)}},
+		{"value > 1 value < 5", "(value_units > ? AND value_units < ?)", []any{int64(10000), int64(50000)}},
+		// OR binds tighter than AND, as in AIP-160
+		{"value = 1 AND value = 2 OR value = 3", "(value_units = ? AND (value_units = ? OR value_units = ?))", []any{int64(10000), int64(20000), int64(30000)}},
+		{
+			`value > 10 AND (name ~ "widget*" OR created_at < "2026-01-01")`,
+			`(value_units > ? AND (name LIKE ? ESCAPE '\' OR julianday(created_at) < julianday(?)))`,
+			[]any{int64(100000), "widget%", "2026-01-01T00:00:00.000Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			clause, err := filter.ForItems(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.sql, clause.SQL)
+			assert.Equal(t, tt.args, clause.Args)
+		})
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		filter string
+		pos    int
+		msg    string
+	}{
+		{"value >", 8, `expected a value after ">", got end of filter`},
+		{"value 10", 7, `expected an operator after "value", got "10"`},
+		{"(value > 1", 11, `expected ")" to close "(" at position 1, got end of filter`},
+		{"value > 1)", 10, `unexpected ")"`},
+		{`name = "open`, 8, "unterminated string"},
+		{"value # 1", 7, `unexpected character '#'`},
+		{"AND value > 1", 1, `expected a field name or "(", got "AND"`},
+		{"price > 1", 1, `unknown field "price", expected one of category, created_at, currency, description, id, name, parent_id, tags, updated_at, value`},
+		{`value > "ten"`, 9, `field "value" expects a number`},
+		{"value ~ 1", 1, `operator "~" is not supported for number field "value"`},
+		{"value = 0.00001", 9, `field "value" has at most 4 decimal places`},
+		{"tags = blue", 1, `operator "=" is not supported for list field "tags", use tags:"value"`},
+		{`created_at < "yesterday"`, 14, `field "created_at" expects a time such as "2026-01-01" or "2026-01-01T15:04:05Z"`},
+		{"name = 'é' AND valu = 1", 16, `unknown field "valu", expected one of category, created_at, currency, description, id, name, parent_id, tags, updated_at, value`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			_, err := filter.ForItems(tt.filter)
+			var apiErr *apierror.Error
+			require.True(t, errors.As(err, &apiErr), "got %v", err)
+			assert.Equal(t, codes.InvalidArgument, apiErr.Code)
+			require.Len(t, apiErr.Violations, 1)
+			assert.Equal(t, "filter", apiErr.Violations[0].Field)
+			assert.Equal(t, (&filter.Error{Pos: tt.pos, Msg: tt.msg}).Error(), apiErr.Violations[0].Description)
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	deep := ""
+	for i := 0; i <= filter.MaxDepth; i++ {
+		deep += "("
+	}
+	_, err := filter.Parse(deep + "value = 1")
+	var filterErr *filter.Error
+	require.True(t, errors.As(err, &filterErr))
+	assert.Contains(t, filterErr.Msg, "nested")
+
+	long := make([]byte, filter.MaxLength+1)
+	for i := range long {
+		long[i] = ' '
+	}
+	_, err = filter.Parse(string(long))
+	require.True(t, errors.As(err, &filterErr))
+	assert.Contains(t, filterErr.Msg, "longer")
+}
+
+// TestFiltersAgainstSQLite runs compiled filters on a real items table,
+// including timestamps written by the driver with a zone offset
+func TestFiltersAgainstSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE items (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		value_units INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	require.NoError(t, err)
+
+	est := time.FixedZone("EST", -5*60*60)
+	rows := []struct {
+		id, name  string
+		value     float64
+		createdAt time.Time
+	}{
+		{"1", "Red Widget", 5, time.Date(2025, 12, 31, 20, 0, 0, 0, est)}, // 2026-01-01T01:00Z
+		{"2", "blue widget", 15, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"3", "gadget", 25, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"4", "100% gadget", 35, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, r := range rows {
+		_, err := db.Exec("INSERT INTO items (id, name, value_units, created_at) VALUES (?, ?, ?, ?)", r.id, r.name, int64(r.value*10000), r.createdAt)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"", []string{"1", "2", "3", "4"}},
+		{`value > 10 AND (name ~ "*widget*" OR created_at < "2026-01-01")`, []string{"2", "4"}},
+		{`name ~ "red*"`, []string{"1"}},
+		{`name : "%"`, []string{"4"}},
+		{`created_at >= "2026-01-01"`, []string{"1", "3"}},
+		{`created_at < "2026-01-01T01:00:00Z"`, []string{"2", "4"}},
+		{`NOT name ~ "*widget"`, []string{"3", "4"}},
+		{`id = "1" OR id = "3"`, []string{"1", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			clause, err := filter.ForItems(tt.filter)
+			require.NoError(t, err)
+			result, err := db.Query("SELECT id FROM items WHERE "+clause.SQL+" ORDER BY id", clause.Args...)
+			require.NoError(t, err)
+			defer result.Close()
+			var ids []string
+			for result.Next() {
+				var id string
+				require.NoError(t, result.Scan(&id))
+				ids = append(ids, id)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
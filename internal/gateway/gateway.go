@@ -0,0 +1,45 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// NewHandler returns an HTTP/JSON handler that transcodes requests to the
+// ItemService implementation according to the google.api.http annotations in
+// proto/item.proto. Calls are dispatched in-process, without a network hop.
+func NewHandler(ctx context.Context, srv pb.ItemServiceServer) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		// Use the proto field names so responses match the hand-written REST API
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+		runtime.WithErrorHandler(errorHandler),
+	)
+	if err := pb.RegisterItemServiceHandlerServer(ctx, mux, srv); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// errorHandler renders gateway errors as problem+json, like the REST handlers
+func errorHandler(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		apierror.Write(w, r, err)
+		return
+	}
+	apierror.Write(w, r, apierror.FromStatus(st))
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/gateway"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/money"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupGateway creates an in-memory database and a gateway handler backed by it
+func setupGateway(t *testing.T) http.Handler {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	err = database.Migrate(db)
+	require.NoError(t, err)
+
+	h, err := gateway.NewHandler(context.Background(), grpcserver.NewItemServer(db, money.Rules{}))
+	require.NoError(t, err)
+	return h
+}
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func TestGatewayCRUD(t *testing.T) {
+	h := setupGateway(t)
+
+	// Create
+	req := httptest.NewRequest("POST", "/v1/items", bytes.NewBufferString(`{"name": "Test Item", "value": {"value": "29.99"}}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var created map[string]any
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	id, _ := created["id"].(string)
+	require.NotEmpty(t, id)
+	assert.Equal(t, "Test Item", created["name"])
+	assert.Equal(t, map[string]any{"value": "29.99"}, created["value"])
+	assert.Contains(t, created, "created_at")
+
+	// Get
+	req = httptest.NewRequest("GET", "/v1/items/"+id, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// List
+	req = httptest.NewRequest("GET", "/v1/items", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Items []map[string]any `json:"items"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	assert.Len(t, list.Items, 1)
+
+	// Update
+	req = httptest.NewRequest("PUT", "/v1/items/"+id, bytes.NewBufferString(`{"name": "Updated Item", "value": {"value": "39.99"}}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var updated map[string]any
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&updated))
+	assert.Equal(t, "Updated Item", updated["name"])
+
+	// Delete
+	req = httptest.NewRequest("DELETE", "/v1/items/"+id, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGatewayErrors(t *testing.T) {
+	h := setupGateway(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "Missing name",
+			method:     "POST",
+			path:       "/v1/items",
+			body:       `{"value": {"value": "1"}}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.ReasonValidationFailed,
+		},
+		{
+			name:       "Non-existent item",
+			method:     "GET",
+			path:       "/v1/items/non-existent-id",
+			wantStatus: http.StatusNotFound,
+			wantCode:   apierror.ReasonNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, apierror.ProblemContentType, w.Header().Get("Content-Type"))
+
+			var problem apierror.Problem
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+			assert.Equal(t, tt.wantCode, problem.Code)
+		})
+	}
+}
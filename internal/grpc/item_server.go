@@ -5,141 +5,227 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
 	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/outbox"
+	"github.com/angel/go-api-sqlite/internal/search"
+	"github.com/angel/go-api-sqlite/internal/stats"
+	"github.com/angel/go-api-sqlite/internal/webhook"
 	pb "github.com/angel/go-api-sqlite/proto"
 	"github.com/google/uuid"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type ItemServer struct {
 	pb.UnimplementedItemServiceServer
-	db *sql.DB
+	db    *sql.DB
+	rules money.Rules
 }
 
-func NewItemServer(db *sql.DB) *ItemServer {
-	return &ItemServer{db: db}
+func NewItemServer(db *sql.DB, rules money.Rules) *ItemServer {
+	return &ItemServer{db: db, rules: rules}
 }
 
 func (s *ItemServer) CreateItem(ctx context.Context, req *pb.CreateItemRequest) (*pb.Item, error) {
-	if req.Name == "" {
-		return nil, status.Error(codes.InvalidArgument, "name is required")
+	value, err := items.ParseValue(req.GetValue().GetValue(), req.LegacyValue)
+	if err != nil {
+		return nil, err
 	}
-
 	item := models.Item{
-		ID:        uuid.New().String(),
-		Name:      req.Name,
-		Value:     req.Value,
-		CreatedAt: time.Now(),
+		Name:        req.Name,
+		Value:       value,
+		Description: req.Description,
+		Tags:        req.Tags,
+		Category:    req.Category,
+		Currency:    req.Currency,
+		Metadata:    req.Metadata.AsMap(),
+		ParentID:    req.ParentId,
+	}
+	item.ID = uuid.New().String()
+	if err := items.Check(ctx, s.db, s.rules, &item); err != nil {
+		return nil, err
 	}
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = item.CreatedAt
 
-	_, err := s.db.ExecContext(ctx,
-		"INSERT INTO items (id, name, value, created_at) VALUES (?, ?, ?, ?)",
-		item.ID, item.Name, item.Value, item.CreatedAt)
+	err = database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := items.Insert(ctx, tx, &item); err != nil {
+			return err
+		}
+		if err := webhook.Record(ctx, tx, webhook.ItemCreated, item); err != nil {
+			return err
+		}
+		return outbox.Record(ctx, tx, webhook.ItemCreated, item)
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, apierror.From(err, "inserting item")
 	}
 
-	return &pb.Item{
-		Id:        item.ID,
-		Name:      item.Name,
-		Value:     item.Value,
-		CreatedAt: timestamppb.New(item.CreatedAt),
-	}, nil
+	return items.Proto(&item), nil
 }
 
 func (s *ItemServer) GetItem(ctx context.Context, req *pb.GetItemRequest) (*pb.Item, error) {
-	var item models.Item
-	err := s.db.QueryRowContext(ctx,
-		"SELECT id, name, value, created_at FROM items WHERE id = ?",
-		req.Id).Scan(&item.ID, &item.Name, &item.Value, &item.CreatedAt)
-
-	if err == sql.ErrNoRows {
-		return nil, status.Error(codes.NotFound, "item not found")
+	item, err := items.Get(ctx, s.db, s.rules, req.Id)
+	if err != nil {
+		return nil, apierror.From(err, "retrieving item "+req.Id)
 	}
+	return items.Proto(item), nil
+}
+
+func (s *ItemServer) ListItems(ctx context.Context, req *pb.ListItemsRequest) (*pb.ListItemsResponse, error) {
+	list, err := items.List(ctx, s.db, s.rules, items.ListRequest{
+		Filter:   req.Filter,
+		Tags:     req.Tags,
+		Category: req.Category,
+		Metadata: req.Metadata,
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
 	}
 
-	return &pb.Item{
-		Id:        item.ID,
-		Name:      item.Name,
-		Value:     item.Value,
-		CreatedAt: timestamppb.New(item.CreatedAt),
-	}, nil
+	resp := &pb.ListItemsResponse{}
+	for i := range list {
+		resp.Items = append(resp.Items, items.Proto(&list[i]))
+	}
+	return resp, nil
 }
 
-func (s *ItemServer) ListItems(ctx context.Context, req *pb.ListItemsRequest) (*pb.ListItemsResponse, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT id, name, value, created_at FROM items")
+func (s *ItemServer) SearchItems(ctx context.Context, req *pb.SearchItemsRequest) (*pb.SearchItemsResponse, error) {
+	page, err := search.Items(ctx, s.db, s.rules, search.Request{
+		Text:      req.Query,
+		PageSize:  int(req.PageSize),
+		PageToken: req.PageToken,
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
 	}
-	defer rows.Close()
 
-	var items []*pb.Item
-	for rows.Next() {
-		var item models.Item
-		if err := rows.Scan(&item.ID, &item.Name, &item.Value, &item.CreatedAt); err != nil {
-			return nil, status.Error(codes.Internal, err.Error())
-		}
-		items = append(items, &pb.Item{
-			Id:        item.ID,
-			Name:      item.Name,
-			Value:     item.Value,
-			CreatedAt: timestamppb.New(item.CreatedAt),
+	resp := &pb.SearchItemsResponse{NextPageToken: page.NextPageToken}
+	for _, r := range page.Results {
+		resp.Results = append(resp.Results, &pb.SearchResult{
+			Item:    items.Proto(&r.Item),
+			Score:   r.Score,
+			Snippet: r.Snippet,
 		})
 	}
-
-	return &pb.ListItemsResponse{Items: items}, nil
+	return resp, nil
 }
 
-func (s *ItemServer) UpdateItem(ctx context.Context, req *pb.UpdateItemRequest) (*pb.Item, error) {
-	result, err := s.db.ExecContext(ctx,
-		"UPDATE items SET name = ?, value = ? WHERE id = ?",
-		req.Name, req.Value, req.Id)
+func (s *ItemServer) GetItemStats(ctx context.Context, req *pb.GetItemStatsRequest) (*pb.ItemStats, error) {
+	result, err := stats.Items(ctx, s.db, stats.Request{
+		Filter:       req.Filter,
+		GroupBy:      req.GroupBy,
+		PrefixLength: int(req.PrefixLength),
+		Percentiles:  req.Percentiles,
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
+	}
+
+	resp := &pb.ItemStats{Summary: statsSummary(result.Summary), GroupBy: result.GroupBy}
+	for _, b := range result.Buckets {
+		resp.Buckets = append(resp.Buckets, &pb.StatsBucket{Key: b.Key, Summary: statsSummary(b.Summary)})
+	}
+	return resp, nil
+}
+
+// statsSummary converts a stats summary to its protobuf form
+func statsSummary(s stats.Summary) *pb.StatsSummary {
+	msg := &pb.StatsSummary{
+		Count:           s.Count,
+		Currency:        s.Currency,
+		MixedCurrencies: s.MixedCurrencies,
+		Percentiles:     s.Percentiles,
+	}
+	if s.Sum != nil {
+		msg.Sum, msg.LegacySum = items.DecimalProto(*s.Sum), s.Sum.Float64()
+	}
+	if s.Min != nil {
+		msg.Min, msg.LegacyMin = items.DecimalProto(*s.Min), s.Min.Float64()
+	}
+	if s.Max != nil {
+		msg.Max, msg.LegacyMax = items.DecimalProto(*s.Max), s.Max.Float64()
+	}
+	if s.Mean != nil {
+		msg.Mean = *s.Mean
 	}
+	return msg
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *ItemServer) UpdateItem(ctx context.Context, req *pb.UpdateItemRequest) (*pb.Item, error) {
+	value, err := items.ParseValue(req.GetValue().GetValue(), req.LegacyValue)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
+	}
+	request := models.Item{
+		ID:          req.Id,
+		Name:        req.Name,
+		Value:       value,
+		Description: req.Description,
+		Tags:        req.Tags,
+		Category:    req.Category,
+		Currency:    req.Currency,
+		Metadata:    req.Metadata.AsMap(),
+		ParentID:    req.ParentId,
 	}
-	if rowsAffected == 0 {
-		return nil, status.Error(codes.NotFound, "item not found")
+	if err := items.Check(ctx, s.db, s.rules, &request); err != nil {
+		return nil, err
 	}
 
-	// Get the updated item
 	var item models.Item
-	err = s.db.QueryRowContext(ctx,
-		"SELECT id, name, value, created_at FROM items WHERE id = ?",
-		req.Id).Scan(&item.ID, &item.Name, &item.Value, &item.CreatedAt)
+	err = database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		item = request
+		if err := items.Update(ctx, tx, s.rules, &item); err != nil {
+			return err
+		}
+		if err := webhook.Record(ctx, tx, webhook.ItemUpdated, item); err != nil {
+			return err
+		}
+		return outbox.Record(ctx, tx, webhook.ItemUpdated, item)
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, apierror.From(err, "updating item "+req.Id)
 	}
 
-	return &pb.Item{
-		Id:        item.ID,
-		Name:      item.Name,
-		Value:     item.Value,
-		CreatedAt: timestamppb.New(item.CreatedAt),
-	}, nil
+	return items.Proto(&item), nil
 }
 
 func (s *ItemServer) DeleteItem(ctx context.Context, req *pb.DeleteItemRequest) (*pb.DeleteItemResponse, error) {
-	result, err := s.db.ExecContext(ctx, "DELETE FROM items WHERE id = ?", req.Id)
+	err := database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		deleted, children, err := items.Delete(ctx, tx, s.rules, req.Id)
+		if err != nil {
+			return err
+		}
+		if err := webhook.Record(ctx, tx, webhook.ItemDeleted, *deleted); err != nil {
+			return err
+		}
+		if err := outbox.Record(ctx, tx, webhook.ItemDeleted, *deleted); err != nil {
+			return err
+		}
+		// The children are now roots
+		for _, child := range children {
+			if err := webhook.Record(ctx, tx, webhook.ItemUpdated, *child); err != nil {
+				return err
+			}
+			if err := outbox.Record(ctx, tx, webhook.ItemUpdated, *child); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, apierror.From(err, "deleting item "+req.Id)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return &pb.DeleteItemResponse{Success: true}, nil
+}
+
+func (s *ItemServer) GetItemTree(ctx context.Context, req *pb.GetItemTreeRequest) (*pb.ItemTree, error) {
+	tree, err := items.GetTree(ctx, s.db, s.rules, req.Id, int(req.Depth))
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-	if rowsAffected == 0 {
-		return nil, status.Error(codes.NotFound, "item not found")
+		return nil, err
 	}
-
-	return &pb.DeleteItemResponse{Success: true}, nil
+	return items.TreeProto(tree), nil
 }
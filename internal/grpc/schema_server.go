@@ -0,0 +1,127 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/money"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SchemaServer implements the SchemaService gRPC service
+type SchemaServer struct {
+	pb.UnimplementedSchemaServiceServer
+	db    *sql.DB
+	rules money.Rules
+}
+
+// NewSchemaServer creates a schema server for the item schemas in db. Dry
+// runs read items the way rules round them.
+func NewSchemaServer(db *sql.DB, rules money.Rules) *SchemaServer {
+	return &SchemaServer{db: db, rules: rules}
+}
+
+func (s *SchemaServer) CreateSchema(ctx context.Context, req *pb.CreateSchemaRequest) (*pb.Schema, error) {
+	if req.Schema == nil {
+		return nil, apierror.InvalidArgument("invalid schema",
+			apierror.FieldViolation{Field: "schema", Description: "schema is required"})
+	}
+	data, err := json.Marshal(req.Schema.AsMap())
+	if err != nil {
+		return nil, apierror.Malformed(err)
+	}
+	schema, err := items.CreateSchema(ctx, s.db, data)
+	if err != nil {
+		return nil, err
+	}
+	return schemaProto(schema)
+}
+
+func (s *SchemaServer) GetSchema(ctx context.Context, req *pb.GetSchemaRequest) (*pb.Schema, error) {
+	schema, err := items.GetSchema(ctx, s.db, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	return schemaProto(schema)
+}
+
+func (s *SchemaServer) ListSchemas(ctx context.Context, req *pb.ListSchemasRequest) (*pb.ListSchemasResponse, error) {
+	schemas, err := items.ListSchemas(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListSchemasResponse{}
+	for _, schema := range schemas {
+		msg, err := schemaProto(schema)
+		if err != nil {
+			return nil, err
+		}
+		resp.Schemas = append(resp.Schemas, msg)
+	}
+	return resp, nil
+}
+
+func (s *SchemaServer) DryRunSchema(ctx context.Context, req *pb.DryRunSchemaRequest) (*pb.DryRunSchemaResponse, error) {
+	run, err := items.DryRunSchema(ctx, s.db, s.rules, req.Version, req.CollectionId)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.DryRunSchemaResponse{Version: run.Version, Checked: int64(run.Checked), Failed: int64(run.Failed)}
+	for _, f := range run.Failures {
+		failure := &pb.DryRunSchemaResponse_Failure{Id: f.ID}
+		for _, v := range f.Violations {
+			failure.Violations = append(failure.Violations,
+				&pb.DryRunSchemaResponse_Violation{Field: v.Field, Description: v.Description})
+		}
+		resp.Failures = append(resp.Failures, failure)
+	}
+	return resp, nil
+}
+
+func (s *SchemaServer) ActivateSchema(ctx context.Context, req *pb.ActivateSchemaRequest) (*pb.Schema, error) {
+	var schema *items.Schema
+	err := database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		schema, err = items.ActivateSchema(ctx, tx, req.Version)
+		return err
+	})
+	if err != nil {
+		return nil, apierror.From(err, "activating schema")
+	}
+	return schemaProto(schema)
+}
+
+func (s *SchemaServer) DeactivateSchema(ctx context.Context, req *pb.DeactivateSchemaRequest) (*pb.DeactivateSchemaResponse, error) {
+	if err := items.DeactivateSchema(ctx, s.db); err != nil {
+		return nil, err
+	}
+	return &pb.DeactivateSchemaResponse{Success: true}, nil
+}
+
+// schemaProto converts a schema version to its message
+func schemaProto(schema *items.Schema) (*pb.Schema, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(schema.Schema, &doc); err != nil {
+		return nil, apierror.Internal(err, "reading schema")
+	}
+	body, err := structpb.NewStruct(doc)
+	if err != nil {
+		return nil, apierror.Internal(err, "reading schema")
+	}
+	msg := &pb.Schema{
+		Version:   schema.Version,
+		Schema:    body,
+		Active:    schema.Active,
+		CreatedAt: timestamppb.New(schema.CreatedAt),
+	}
+	if schema.ActivatedAt != nil {
+		msg.ActivatedAt = timestamppb.New(*schema.ActivatedAt)
+	}
+	return msg, nil
+}
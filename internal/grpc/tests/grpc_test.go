@@ -1,27 +1,45 @@
 package tests
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"io"
 	"log"
 	"net"
 	"os"
 	"testing"
 
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/attachments"
+	"github.com/angel/go-api-sqlite/internal/collections"
+	"github.com/angel/go-api-sqlite/internal/database"
 	"github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/money"
 	pb "github.com/angel/go-api-sqlite/proto"
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/genproto/googleapis/type/decimal"
 	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 const bufSize = 1024 * 1024
 
 var lis *bufconn.Listener
 var client pb.ItemServiceClient
+var schemaClient pb.SchemaServiceClient
+var collectionClient pb.CollectionServiceClient
+var attachmentClient pb.AttachmentServiceClient
+var reflectionClient reflectionpb.ServerReflectionClient
 
 func bufDialer(context.Context, string) (net.Conn, error) {
 	return lis.Dial()
@@ -35,15 +53,8 @@ func setupTestServer() (*sql.DB, error) {
 		return nil, err
 	}
 
-	// Create the items table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS items (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			value REAL NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-	`)
+	// Create the schema
+	err = database.Migrate(db)
 	if err != nil {
 		db.Close()
 		return nil, err
@@ -64,7 +75,19 @@ func TestMain(m *testing.M) {
 		log.Fatalf("Failed to setup test database: %v", err)
 	}
 
-	pb.RegisterItemServiceServer(s, grpc.NewItemServer(db))
+	pb.RegisterItemServiceServer(s, grpc.NewItemServer(db, money.Rules{}))
+	pb.RegisterSchemaServiceServer(s, grpc.NewSchemaServer(db, money.Rules{}))
+	pb.RegisterCollectionServiceServer(s, collections.NewServer(db, money.Rules{}))
+	attachmentDir, err := os.MkdirTemp("", "attachments-*")
+	if err != nil {
+		log.Fatalf("Failed to create attachment directory: %v", err)
+	}
+	attachmentSvc, err := attachments.New(db, attachments.Config{Dir: attachmentDir})
+	if err != nil {
+		log.Fatalf("Failed to open attachment store: %v", err)
+	}
+	pb.RegisterAttachmentServiceServer(s, attachments.NewServer(attachmentSvc))
+	reflection.Register(s)
 	go func() {
 		if err := s.Serve(lis); err != nil {
 			log.Fatalf("Failed to serve test server: %v", err)
@@ -79,6 +102,10 @@ func TestMain(m *testing.M) {
 	defer conn.Close()
 
 	client = pb.NewItemServiceClient(conn)
+	schemaClient = pb.NewSchemaServiceClient(conn)
+	collectionClient = pb.NewCollectionServiceClient(conn)
+	attachmentClient = pb.NewAttachmentServiceClient(conn)
+	reflectionClient = reflectionpb.NewServerReflectionClient(conn)
 
 	// Run the tests
 	exitCode := m.Run()
@@ -86,6 +113,7 @@ func TestMain(m *testing.M) {
 	// Clean up
 	db.Close()
 	s.Stop()
+	os.RemoveAll(attachmentDir)
 	os.Exit(exitCode)
 }
 
@@ -101,14 +129,14 @@ func TestCreateItem(t *testing.T) {
 			name: "Valid item",
 			request: &pb.CreateItemRequest{
 				Name:  "Test Item",
-				Value: 29.99,
+				Value: &decimal.Decimal{Value: "29.99"},
 			},
 			wantErr: false,
 		},
 		{
 			name: "Missing name",
 			request: &pb.CreateItemRequest{
-				Value: 29.99,
+				Value: &decimal.Decimal{Value: "29.99"},
 			},
 			wantErr: true,
 		},
@@ -126,7 +154,7 @@ func TestCreateItem(t *testing.T) {
 			require.NoError(t, err)
 			assert.NotEmpty(t, response.Id)
 			assert.Equal(t, tt.request.Name, response.Name)
-			assert.Equal(t, tt.request.Value, response.Value)
+			assert.Equal(t, tt.request.GetValue().GetValue(), response.GetValue().GetValue())
 			assert.NotNil(t, response.CreatedAt)
 		})
 	}
@@ -138,7 +166,7 @@ func TestGetItem(t *testing.T) {
 	// First create an item
 	createResp, err := client.CreateItem(ctx, &pb.CreateItemRequest{
 		Name:  "Test Item",
-		Value: 29.99,
+		Value: &decimal.Decimal{Value: "29.99"},
 	})
 	require.NoError(t, err)
 
@@ -175,7 +203,7 @@ func TestGetItem(t *testing.T) {
 			require.NoError(t, err)
 			assert.Equal(t, createResp.Id, response.Id)
 			assert.Equal(t, createResp.Name, response.Name)
-			assert.Equal(t, createResp.Value, response.Value)
+			assert.Equal(t, createResp.GetValue().GetValue(), response.GetValue().GetValue())
 		})
 	}
 }
@@ -186,17 +214,17 @@ func TestListItems(t *testing.T) {
 	// Create a few items
 	items := []struct {
 		name  string
-		value float64
+		value string
 	}{
-		{"Item 1", 29.99},
-		{"Item 2", 39.99},
-		{"Item 3", 49.99},
+		{"Item 1", "29.99"},
+		{"Item 2", "39.99"},
+		{"Item 3", "49.99"},
 	}
 
 	for _, item := range items {
 		_, err := client.CreateItem(ctx, &pb.CreateItemRequest{
 			Name:  item.name,
-			Value: item.value,
+			Value: &decimal.Decimal{Value: item.value},
 		})
 		require.NoError(t, err)
 	}
@@ -206,13 +234,41 @@ func TestListItems(t *testing.T) {
 	assert.GreaterOrEqual(t, len(response.Items), len(items))
 }
 
+func TestListItemsFilter(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range []string{"Filter widget", "Filter gadget"} {
+		_, err := client.CreateItem(ctx, &pb.CreateItemRequest{Name: name, Value: &decimal.Decimal{Value: "1000"}})
+		require.NoError(t, err)
+	}
+
+	response, err := client.ListItems(ctx, &pb.ListItemsRequest{Filter: `value >= 1000 AND name ~ "*widget"`})
+	require.NoError(t, err)
+	require.Len(t, response.Items, 1)
+	assert.Equal(t, "Filter widget", response.Items[0].Name)
+
+	_, err = client.ListItems(ctx, &pb.ListItemsRequest{Filter: "value >= AND"})
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			assert.Equal(t, "10", d.Metadata["position"])
+		case *errdetails.BadRequest:
+			require.Len(t, d.FieldViolations, 1)
+			assert.Equal(t, "filter", d.FieldViolations[0].Field)
+		}
+	}
+}
+
 func TestUpdateItem(t *testing.T) {
 	ctx := context.Background()
 
 	// First create an item
 	createResp, err := client.CreateItem(ctx, &pb.CreateItemRequest{
 		Name:  "Test Item",
-		Value: 29.99,
+		Value: &decimal.Decimal{Value: "29.99"},
 	})
 	require.NoError(t, err)
 
@@ -226,7 +282,7 @@ func TestUpdateItem(t *testing.T) {
 			request: &pb.UpdateItemRequest{
 				Id:    createResp.Id,
 				Name:  "Updated Item",
-				Value: 39.99,
+				Value: &decimal.Decimal{Value: "39.99"},
 			},
 			wantErr: false,
 		},
@@ -235,7 +291,7 @@ func TestUpdateItem(t *testing.T) {
 			request: &pb.UpdateItemRequest{
 				Id:    "non-existent-id",
 				Name:  "Updated Item",
-				Value: 39.99,
+				Value: &decimal.Decimal{Value: "39.99"},
 			},
 			wantErr: true,
 		},
@@ -253,18 +309,251 @@ func TestUpdateItem(t *testing.T) {
 			require.NoError(t, err)
 			assert.Equal(t, tt.request.Id, response.Id)
 			assert.Equal(t, tt.request.Name, response.Name)
-			assert.Equal(t, tt.request.Value, response.Value)
+			assert.Equal(t, tt.request.GetValue().GetValue(), response.GetValue().GetValue())
 		})
 	}
 }
 
+func TestItemDetails(t *testing.T) {
+	ctx := context.Background()
+
+	created, err := client.CreateItem(ctx, &pb.CreateItemRequest{
+		Name:        "Detailed item",
+		Description: "Has every field",
+		Tags:        []string{"Details", "grpc"},
+		Category:    "details",
+		Currency:    "gbp",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"details", "grpc"}, created.Tags)
+	assert.Equal(t, "GBP", created.Currency)
+	assert.Equal(t, created.CreatedAt.AsTime(), created.UpdatedAt.AsTime())
+
+	response, err := client.ListItems(ctx, &pb.ListItemsRequest{Tags: []string{"details"}, Category: "details"})
+	require.NoError(t, err)
+	require.Len(t, response.Items, 1)
+	assert.Equal(t, "Has every field", response.Items[0].Description)
+
+	// Fields left out of an update are cleared
+	updated, err := client.UpdateItem(ctx, &pb.UpdateItemRequest{Id: created.Id, Name: "Detailed item", Tags: []string{"grpc"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"grpc"}, updated.Tags)
+	assert.Empty(t, updated.Category)
+	assert.True(t, updated.UpdatedAt.AsTime().After(created.UpdatedAt.AsTime()))
+
+	_, err = client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Bad", Currency: "ZZZ"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestItemMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	metadata, err := structpb.NewStruct(map[string]any{"team": "grpc", "limits": map[string]any{"max": 5}})
+	require.NoError(t, err)
+	created, err := client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Metadata item", Metadata: metadata})
+	require.NoError(t, err)
+	assert.Equal(t, metadata.AsMap(), created.Metadata.AsMap())
+
+	response, err := client.ListItems(ctx, &pb.ListItemsRequest{Metadata: map[string]string{"team": "grpc", "limits.max": "5"}})
+	require.NoError(t, err)
+	require.Len(t, response.Items, 1)
+	assert.Equal(t, created.Id, response.Items[0].Id)
+
+	_, err = client.ListItems(ctx, &pb.ListItemsRequest{Metadata: map[string]string{"no such key": "x"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	bad, err := structpb.NewStruct(map[string]any{"has space": 1})
+	require.NoError(t, err)
+	_, err = client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Bad", Metadata: bad})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestItemSchema(t *testing.T) {
+	ctx := context.Background()
+
+	body, err := structpb.NewStruct(map[string]any{
+		"properties": map[string]any{
+			"name":     map[string]any{"pattern": "^[A-Z]"},
+			"metadata": map[string]any{"required": []any{"team"}},
+		},
+	})
+	require.NoError(t, err)
+	schema, err := schemaClient.CreateSchema(ctx, &pb.CreateSchemaRequest{Schema: body})
+	require.NoError(t, err)
+	assert.False(t, schema.Active)
+
+	run, err := schemaClient.DryRunSchema(ctx, &pb.DryRunSchemaRequest{Version: schema.Version})
+	require.NoError(t, err)
+	assert.Equal(t, schema.Version, run.Version)
+
+	schema, err = schemaClient.ActivateSchema(ctx, &pb.ActivateSchemaRequest{Version: schema.Version})
+	require.NoError(t, err)
+	assert.True(t, schema.Active)
+	assert.NotNil(t, schema.ActivatedAt)
+	defer schemaClient.DeactivateSchema(ctx, &pb.DeactivateSchemaRequest{})
+
+	_, err = client.CreateItem(ctx, &pb.CreateItemRequest{Name: "lower case"})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	var fields []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	assert.Equal(t, []string{"metadata.team", "name"}, fields)
+
+	metadata, err := structpb.NewStruct(map[string]any{"team": "grpc"})
+	require.NoError(t, err)
+	created, err := client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Upper case", Metadata: metadata})
+	require.NoError(t, err)
+	_, err = client.UpdateItem(ctx, &pb.UpdateItemRequest{Id: created.Id, Name: "lower case", Metadata: metadata})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	list, err := schemaClient.ListSchemas(ctx, &pb.ListSchemasRequest{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, list.Schemas)
+	_, err = schemaClient.GetSchema(ctx, &pb.GetSchemaRequest{Version: 999})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestItemTreeAndCollections(t *testing.T) {
+	ctx := context.Background()
+
+	root, err := client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Catalog"})
+	require.NoError(t, err)
+	child, err := client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Bundle", ParentId: root.Id})
+	require.NoError(t, err)
+	assert.Equal(t, root.Id, child.ParentId)
+	_, err = client.UpdateItem(ctx, &pb.UpdateItemRequest{Id: root.Id, Name: "Catalog", ParentId: child.Id})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	tree, err := client.GetItemTree(ctx, &pb.GetItemTreeRequest{Id: child.Id})
+	require.NoError(t, err)
+	require.Len(t, tree.Ancestors, 1)
+	assert.Equal(t, root.Id, tree.Ancestors[0].Id)
+	assert.Equal(t, child.Id, tree.Tree.Item.Id)
+	assert.Empty(t, tree.Tree.Children)
+	tree, err = client.GetItemTree(ctx, &pb.GetItemTreeRequest{Id: root.Id})
+	require.NoError(t, err)
+	require.Len(t, tree.Tree.Children, 1)
+	assert.Equal(t, child.Id, tree.Tree.Children[0].Item.Id)
+	_, err = client.GetItemTree(ctx, &pb.GetItemTreeRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	c, err := collectionClient.CreateCollection(ctx, &pb.CreateCollectionRequest{Name: "Bundles"})
+	require.NoError(t, err)
+	assert.Nil(t, c.SchemaVersion)
+	_, err = collectionClient.CreateCollection(ctx, &pb.CreateCollectionRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	c, err = collectionClient.UpdateCollection(ctx, &pb.UpdateCollectionRequest{Id: c.Id, Name: "All bundles"})
+	require.NoError(t, err)
+	assert.Equal(t, "All bundles", c.Name)
+
+	_, err = collectionClient.AddCollectionItem(ctx, &pb.AddCollectionItemRequest{CollectionId: c.Id, ItemId: child.Id})
+	require.NoError(t, err)
+	_, err = collectionClient.AddCollectionItem(ctx, &pb.AddCollectionItemRequest{CollectionId: c.Id, ItemId: child.Id})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	page, err := collectionClient.ListCollectionItems(ctx, &pb.ListCollectionItemsRequest{CollectionId: c.Id})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "Bundle", page.Items[0].Name)
+	_, err = collectionClient.RemoveCollectionItem(ctx, &pb.RemoveCollectionItemRequest{CollectionId: c.Id, ItemId: child.Id})
+	require.NoError(t, err)
+
+	list, err := collectionClient.ListCollections(ctx, &pb.ListCollectionsRequest{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, list.Collections)
+	_, err = collectionClient.DeleteCollection(ctx, &pb.DeleteCollectionRequest{Id: c.Id})
+	require.NoError(t, err)
+	_, err = collectionClient.GetCollection(ctx, &pb.GetCollectionRequest{Id: c.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	item, err := client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Manual"})
+	require.NoError(t, err)
+
+	// Upload a PDF larger than one download chunk, in several messages
+	pdf := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("0123456789abcdef"), 5000)...)
+	upload, err := attachmentClient.UploadAttachment(ctx)
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadAttachmentRequest{Data: &pb.UploadAttachmentRequest_Info_{
+		Info: &pb.UploadAttachmentRequest_Info{ItemId: item.Id, Filename: "manual.pdf"}}}))
+	for rest := pdf; len(rest) > 0; {
+		n := min(len(rest), 30000)
+		require.NoError(t, upload.Send(&pb.UploadAttachmentRequest{Data: &pb.UploadAttachmentRequest_Chunk{Chunk: rest[:n]}}))
+		rest = rest[n:]
+	}
+	a, err := upload.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", a.ContentType)
+	assert.Equal(t, int64(len(pdf)), a.Size)
+
+	download := func(req *pb.DownloadAttachmentRequest) ([]byte, error) {
+		stream, err := attachmentClient.DownloadAttachment(ctx, req)
+		require.NoError(t, err)
+		first, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		assert.Equal(t, a.Id, first.GetAttachment().GetId())
+		var content []byte
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				return content, nil
+			}
+			require.NoError(t, err)
+			content = append(content, resp.GetChunk()...)
+		}
+	}
+	content, err := download(&pb.DownloadAttachmentRequest{ItemId: item.Id, Id: a.Id})
+	require.NoError(t, err)
+	assert.Equal(t, pdf, content)
+	content, err = download(&pb.DownloadAttachmentRequest{ItemId: item.Id, Id: a.Id, Offset: 9, Length: 16})
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", string(content))
+	_, err = download(&pb.DownloadAttachmentRequest{ItemId: item.Id, Id: a.Id, Offset: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Content of a type not accepted is refused
+	upload, err = attachmentClient.UploadAttachment(ctx)
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadAttachmentRequest{Data: &pb.UploadAttachmentRequest_Info_{
+		Info: &pb.UploadAttachmentRequest_Info{ItemId: item.Id, Filename: "notes.pdf"}}}))
+	require.NoError(t, upload.Send(&pb.UploadAttachmentRequest{Data: &pb.UploadAttachmentRequest_Chunk{Chunk: []byte("plain text")}}))
+	_, err = upload.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	upload, err = attachmentClient.UploadAttachment(ctx)
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadAttachmentRequest{Data: &pb.UploadAttachmentRequest_Chunk{Chunk: pdf[:10]}}))
+	_, err = upload.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	list, err := attachmentClient.ListAttachments(ctx, &pb.ListAttachmentsRequest{ItemId: item.Id})
+	require.NoError(t, err)
+	require.Len(t, list.Attachments, 1)
+	got, err := attachmentClient.GetAttachment(ctx, &pb.GetAttachmentRequest{ItemId: item.Id, Id: a.Id})
+	require.NoError(t, err)
+	assert.Equal(t, a.Sha256, got.Sha256)
+	_, err = attachmentClient.DeleteAttachment(ctx, &pb.DeleteAttachmentRequest{ItemId: item.Id, Id: a.Id})
+	require.NoError(t, err)
+	_, err = attachmentClient.GetAttachment(ctx, &pb.GetAttachmentRequest{ItemId: item.Id, Id: a.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
 func TestDeleteItem(t *testing.T) {
 	ctx := context.Background()
 
 	// First create an item
 	createResp, err := client.CreateItem(ctx, &pb.CreateItemRequest{
 		Name:  "Test Item",
-		Value: 29.99,
+		Value: &decimal.Decimal{Value: "29.99"},
 	})
 	require.NoError(t, err)
 
@@ -307,3 +596,62 @@ func TestDeleteItem(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorDetails(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Validation error", func(t *testing.T) {
+		_, err := client.CreateItem(ctx, &pb.CreateItemRequest{Value: &decimal.Decimal{Value: "1"}})
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, codes.InvalidArgument, st.Code())
+
+		var info *errdetails.ErrorInfo
+		var badRequest *errdetails.BadRequest
+		for _, d := range st.Details() {
+			switch d := d.(type) {
+			case *errdetails.ErrorInfo:
+				info = d
+			case *errdetails.BadRequest:
+				badRequest = d
+			}
+		}
+		require.NotNil(t, info)
+		assert.Equal(t, apierror.ReasonValidationFailed, info.Reason)
+		assert.Equal(t, apierror.Domain, info.Domain)
+		require.NotNil(t, badRequest)
+		require.Len(t, badRequest.FieldViolations, 1)
+		assert.Equal(t, "name", badRequest.FieldViolations[0].Field)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := client.GetItem(ctx, &pb.GetItemRequest{Id: "missing"})
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, codes.NotFound, st.Code())
+		require.NotEmpty(t, st.Details())
+		info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+		require.True(t, ok)
+		assert.Equal(t, apierror.ReasonNotFound, info.Reason)
+		assert.Equal(t, "missing", info.Metadata["id"])
+	})
+}
+
+func TestServerReflection(t *testing.T) {
+	stream, err := reflectionClient.ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	require.NoError(t, err)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+
+	var services []string
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		services = append(services, svc.Name)
+	}
+	assert.Contains(t, services, "proto.ItemService")
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/attachments"
+	"github.com/gorilla/mux"
+)
+
+// AttachmentHandler serves the files attached to items
+type AttachmentHandler struct {
+	svc *attachments.Service
+}
+
+// NewAttachmentHandler creates a handler for the attachments of svc
+func NewAttachmentHandler(svc *attachments.Service) *AttachmentHandler {
+	return &AttachmentHandler{svc: svc}
+}
+
+// startUploadRequest starts a resumable upload
+type startUploadRequest struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+// CreateAttachment handles POST requests to attach a file to an item. A
+// multipart form with a part named file is stored at once; a JSON body with
+// filename and size starts a resumable upload instead.
+func (h *AttachmentHandler) CreateAttachment(w http.ResponseWriter, r *http.Request) {
+	itemID := mux.Vars(r)["id"]
+	log.Printf("Handling CreateAttachment request for item %s from %s", itemID, r.RemoteAddr)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		var req startUploadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Write(w, r, apierror.Malformed(err))
+			return
+		}
+		u, err := h.svc.StartUpload(r.Context(), itemID, req.Filename, req.Size)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		log.Printf("Started upload %s for item %s", u.ID, itemID)
+		w.Header().Set("Location", uploadURL(u))
+		writeJSON(w, http.StatusCreated, u)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		apierror.Write(w, r, apierror.Malformed(err))
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			apierror.Write(w, r, apierror.InvalidArgument("invalid attachment",
+				apierror.FieldViolation{Field: "file", Description: "the form has no file part"}))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, apierror.Malformed(err))
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		a, err := h.svc.Create(r.Context(), itemID, part.FileName(), bodyReader{part})
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		log.Printf("Successfully created attachment %s for item %s", a.ID, itemID)
+		w.Header().Set("Location", attachmentURL(a))
+		writeJSON(w, http.StatusCreated, a)
+		return
+	}
+}
+
+// ListAttachments handles GET requests for the attachments of an item
+func (h *AttachmentHandler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	list, err := h.svc.List(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GetAttachment handles GET requests for an attachment without its content
+func (h *AttachmentHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	a, err := h.svc.Get(r.Context(), vars["id"], vars["attachment_id"])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// GetAttachmentContent handles GET requests for the content of an
+// attachment, including range and conditional requests. The content is
+// served with its sniffed type and never sniffed again by browsers.
+func (h *AttachmentHandler) GetAttachmentContent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	a, f, err := h.svc.Open(r.Context(), vars["id"], vars["attachment_id"])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": a.Filename}))
+	w.Header().Set("Cache-Control", "private, max-age=0, must-revalidate")
+	http.ServeContent(w, r, a.Filename, a.CreatedAt, f)
+}
+
+// DeleteAttachment handles DELETE requests to remove an attachment
+func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	log.Printf("Handling DeleteAttachment request for ID: %s from %s", vars["attachment_id"], r.RemoteAddr)
+	if err := h.svc.Delete(r.Context(), vars["id"], vars["attachment_id"]); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUpload handles GET requests for a resumable upload, whose received
+// field is where the next chunk starts
+func (h *AttachmentHandler) GetUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	u, err := h.svc.GetUpload(r.Context(), vars["id"], vars["upload_id"])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// WriteUploadChunk handles PUT requests with the next chunk of a resumable
+// upload, placed by a Content-Range header. It responds with the upload, or
+// with the attachment once the last chunk is in.
+func (h *AttachmentHandler) WriteUploadChunk(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	start, end, total, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidArgument("invalid chunk",
+			apierror.FieldViolation{Field: "Content-Range", Description: err.Error()}))
+		return
+	}
+	u, err := h.svc.GetUpload(r.Context(), vars["id"], vars["upload_id"])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if total != u.Size {
+		apierror.Write(w, r, apierror.InvalidArgument("invalid chunk", apierror.FieldViolation{Field: "Content-Range",
+			Description: fmt.Sprintf("total must be the upload size of %d bytes", u.Size)}))
+		return
+	}
+
+	body := bodyReader{io.LimitReader(r.Body, end-start+1)}
+	u, a, err := h.svc.WriteChunk(r.Context(), vars["id"], vars["upload_id"], start, body)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if a == nil {
+		writeJSON(w, http.StatusOK, u)
+		return
+	}
+	log.Printf("Completed upload %s as attachment %s", u.ID, a.ID)
+	w.Header().Set("Location", attachmentURL(a))
+	writeJSON(w, http.StatusCreated, a)
+}
+
+// CancelUpload handles DELETE requests to discard a resumable upload
+func (h *AttachmentHandler) CancelUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.svc.CancelUpload(r.Context(), vars["id"], vars["upload_id"]); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseContentRange parses a "bytes start-end/total" header
+func parseContentRange(header string) (start, end, total int64, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("Content-Range must have the form bytes start-end/total")
+	}
+	rng, size, ok := strings.Cut(spec, "/")
+	first, last, ok2 := strings.Cut(rng, "-")
+	if !ok || !ok2 {
+		return 0, 0, 0, fmt.Errorf("Content-Range must have the form bytes start-end/total")
+	}
+	start, err1 := strconv.ParseInt(first, 10, 64)
+	end, err2 := strconv.ParseInt(last, 10, 64)
+	total, err3 := strconv.ParseInt(size, 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || start < 0 || end < start || end >= total {
+		return 0, 0, 0, fmt.Errorf("Content-Range must have the form bytes start-end/total with start <= end < total")
+	}
+	return start, end, total, nil
+}
+
+// bodyReader reports failures reading a request body as malformed requests
+// rather than internal errors
+type bodyReader struct {
+	r io.Reader
+}
+
+func (b bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		return n, apierror.Malformed(err)
+	}
+	return n, err
+}
+
+func attachmentURL(a *attachments.Attachment) string {
+	return "/api/items/" + a.ItemID + "/attachments/" + a.ID
+}
+
+func uploadURL(u *attachments.Upload) string {
+	return "/api/items/" + u.ItemID + "/attachments/uploads/" + u.ID
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/backup"
+)
+
+// BackupHandler serves the admin endpoints for database snapshots
+type BackupHandler struct {
+	db        *sql.DB
+	dir       string
+	retention backup.Retention
+}
+
+// NewBackupHandler creates a handler writing snapshots of db into dir
+func NewBackupHandler(db *sql.DB, dir string, retention backup.Retention) *BackupHandler {
+	return &BackupHandler{db: db, dir: dir, retention: retention}
+}
+
+// CreateBackup handles POST requests to take a snapshot and apply retention
+func (h *BackupHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling CreateBackup request from %s", r.RemoteAddr)
+	m, err := backup.Snapshot(r.Context(), h.db, h.dir)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal(err, "creating backup"))
+		return
+	}
+	log.Printf("Successfully created backup %s", m.File)
+
+	if _, err := backup.Prune(h.dir, h.retention); err != nil {
+		log.Printf("Error pruning backups: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+// ListBackups handles GET requests to list snapshots, newest first
+func (h *BackupHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	manifests, err := backup.List(h.dir)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal(err, "listing backups"))
+		return
+	}
+	if manifests == nil {
+		manifests = []*backup.Manifest{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifests)
+}
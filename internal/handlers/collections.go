@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/collections"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
+)
+
+// CollectionHandler serves collections and their items
+type CollectionHandler struct {
+	db    *sql.DB
+	rules money.Rules
+}
+
+// NewCollectionHandler creates a handler for the collections in db, whose
+// items are written the way rules round them
+func NewCollectionHandler(db *sql.DB, rules money.Rules) *CollectionHandler {
+	return &CollectionHandler{db: db, rules: rules}
+}
+
+// CreateCollection handles POST requests to add a collection
+func (h *CollectionHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling CreateCollection request from %s", r.RemoteAddr)
+	var p collections.Params
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		apierror.Write(w, r, apierror.Malformed(err))
+		return
+	}
+	c, err := collections.Create(r.Context(), h.db, p)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	log.Printf("Successfully created collection %s", c.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/collections/"+c.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// ListCollections handles GET requests to list collections
+func (h *CollectionHandler) ListCollections(w http.ResponseWriter, r *http.Request) {
+	list, err := collections.List(r.Context(), h.db)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetCollection handles GET requests for one collection
+func (h *CollectionHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := collections.Get(r.Context(), h.db, mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// UpdateCollection handles PUT requests to change a collection
+func (h *CollectionHandler) UpdateCollection(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	log.Printf("Handling UpdateCollection request for ID: %s from %s", id, r.RemoteAddr)
+	var p collections.Params
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		apierror.Write(w, r, apierror.Malformed(err))
+		return
+	}
+	c, err := collections.Update(r.Context(), h.db, id, p)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// DeleteCollection handles DELETE requests to remove a collection, keeping
+// its items
+func (h *CollectionHandler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	log.Printf("Handling DeleteCollection request for ID: %s from %s", id, r.RemoteAddr)
+	if err := collections.Delete(r.Context(), h.db, id); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListCollectionItems handles GET requests for a page of the items in a
+// collection. page_size and page_token page through them by id.
+func (h *CollectionHandler) ListCollectionItems(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	req := collections.ItemsRequest{PageToken: params.Get("page_token")}
+	if v := params.Get("page_size"); v != "" {
+		var err error
+		if req.PageSize, err = strconv.Atoi(v); err != nil {
+			apierror.Write(w, r, apierror.InvalidArgument("invalid collection items request",
+				apierror.FieldViolation{Field: "page_size", Description: "page_size must be an integer"}))
+			return
+		}
+	}
+	page, err := collections.ListItems(r.Context(), h.db, h.rules, mux.Vars(r)["id"], req)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// AddCollectionItem handles POST requests to put the item given by item_id
+// in a collection
+func (h *CollectionHandler) AddCollectionItem(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	log.Printf("Handling AddCollectionItem request for ID: %s from %s", id, r.RemoteAddr)
+	var req struct {
+		ItemID string `json:"item_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.Malformed(err))
+		return
+	}
+	if req.ItemID == "" {
+		apierror.Write(w, r, apierror.InvalidArgument("invalid collection item",
+			apierror.FieldViolation{Field: "item_id", Description: "item_id is required"}))
+		return
+	}
+	if err := collections.AddItem(r.Context(), h.db, h.rules, id, req.ItemID); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveCollectionItem handles DELETE requests to take an item out of a
+// collection
+func (h *CollectionHandler) RemoveCollectionItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	log.Printf("Handling RemoveCollectionItem request for ID: %s from %s", vars["id"], r.RemoteAddr)
+	if err := collections.RemoveItem(r.Context(), h.db, vars["id"], vars["item_id"]); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -5,21 +5,35 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/export"
+	"github.com/angel/go-api-sqlite/internal/items"
 	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/outbox"
+	"github.com/angel/go-api-sqlite/internal/search"
+	"github.com/angel/go-api-sqlite/internal/stats"
+	"github.com/angel/go-api-sqlite/internal/webhook"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 // Handler holds the database connection
 type Handler struct {
-	db *sql.DB
+	db    *sql.DB
+	rules money.Rules
 }
 
-// NewHandler creates a new handler with the database connection
-func NewHandler(db *sql.DB) *Handler {
-	return &Handler{db: db}
+// NewHandler creates a new handler with the database connection and the
+// rules item values are rounded by
+func NewHandler(db *sql.DB, rules money.Rules) *Handler {
+	return &Handler{db: db, rules: rules}
 }
 
 // HealthCheck handles the health check endpoint
@@ -34,27 +48,34 @@ func (h *Handler) CreateItem(w http.ResponseWriter, r *http.Request) {
 	var item models.Item
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
 		log.Printf("Error decoding request body: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Validate required fields
-	if item.Name == "" {
-		log.Printf("Invalid request: name is required")
-		http.Error(w, "name is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Malformed(err))
 		return
 	}
 
 	// Generate UUID for new item
 	item.ID = uuid.New().String()
+
+	// Validate fields
+	if err := items.Check(r.Context(), h.db, h.rules, &item); err != nil {
+		log.Printf("Invalid request: %v", err)
+		apierror.Write(w, r, err)
+		return
+	}
 	item.CreatedAt = time.Now()
+	item.UpdatedAt = item.CreatedAt
 
 	// Insert into database
-	_, err := h.db.Exec("INSERT INTO items (id, name, value, created_at) VALUES (?, ?, ?, ?)",
-		item.ID, item.Name, item.Value, item.CreatedAt)
+	err := database.WithTx(r.Context(), h.db, func(tx *sql.Tx) error {
+		if err := items.Insert(r.Context(), tx, &item); err != nil {
+			return err
+		}
+		if err := webhook.Record(r.Context(), tx, webhook.ItemCreated, item); err != nil {
+			return err
+		}
+		return outbox.Record(r.Context(), tx, webhook.ItemCreated, item)
+	})
 	if err != nil {
-		log.Printf("Error inserting item into database: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.From(err, "inserting item"))
 		return
 	}
 	log.Printf("Successfully created item with ID: %s", item.ID)
@@ -64,31 +85,180 @@ func (h *Handler) CreateItem(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(item)
 }
 
-// GetItems handles GET requests to retrieve all items
+// GetItems handles GET requests to retrieve all items, optionally narrowed
+// by a filter expression in the filter query parameter, by tag parameters
+// the items must all have, by category and by meta.<path> parameters the
+// metadata of the items must match
 func (h *Handler) GetItems(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Handling GetItems request from %s", r.RemoteAddr)
-	rows, err := h.db.Query("SELECT id, name, value, created_at FROM items")
+	list, err := items.List(r.Context(), h.db, h.rules, listRequest(r.URL.Query()))
 	if err != nil {
-		log.Printf("Error querying items: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
-	defer rows.Close()
+	log.Printf("Successfully retrieved %d items", len(list))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// SearchItems handles GET requests for full-text search. The q parameter is
+// an FTS5 query; page_size and page_token page through the ranked results.
+func (h *Handler) SearchItems(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling SearchItems request from %s", r.RemoteAddr)
+	params := r.URL.Query()
 
-	items := make([]models.Item, 0)
-	for rows.Next() {
-		var item models.Item
-		if err := rows.Scan(&item.ID, &item.Name, &item.Value, &item.CreatedAt); err != nil {
-			log.Printf("Error scanning item row: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	req := search.Request{Text: params.Get("q"), PageToken: params.Get("page_token")}
+	if v := params.Get("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidArgument("invalid search request",
+				apierror.FieldViolation{Field: "page_size", Description: "page_size must be an integer"}))
 			return
 		}
-		items = append(items, item)
+		req.PageSize = size
 	}
-	log.Printf("Successfully retrieved %d items", len(items))
+
+	page, err := search.Items(r.Context(), h.db, h.rules, req)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	log.Printf("Search returned %d items", len(page.Results))
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(items)
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetItemStats handles GET requests for aggregate statistics over item
+// values. It accepts filter, group_by, prefix_length and a comma-separated
+// list of percentiles.
+func (h *Handler) GetItemStats(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling GetItemStats request from %s", r.RemoteAddr)
+	params := r.URL.Query()
+
+	req := stats.Request{Filter: params.Get("filter"), GroupBy: params.Get("group_by")}
+	var violations []apierror.FieldViolation
+	if v := params.Get("prefix_length"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			violations = append(violations, apierror.FieldViolation{Field: "prefix_length", Description: "prefix_length must be an integer"})
+		}
+		req.PrefixLength = n
+	}
+	if v := params.Get("percentiles"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			p, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				violations = append(violations, apierror.FieldViolation{Field: "percentiles", Description: "percentiles must be a comma-separated list of numbers"})
+				break
+			}
+			req.Percentiles = append(req.Percentiles, p)
+		}
+	}
+	if len(violations) > 0 {
+		apierror.Write(w, r, apierror.InvalidArgument("invalid stats request", violations...))
+		return
+	}
+
+	result, err := stats.Items(r.Context(), h.db, req)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	log.Printf("Computed stats over %d items", result.Summary.Count)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// listRequest reads the filter, tag, category and meta.<path> parameters
+// that select items
+func listRequest(params url.Values) items.ListRequest {
+	metadata := make(map[string]string)
+	for name, values := range params {
+		if path, ok := strings.CutPrefix(name, "meta."); ok {
+			metadata[path] = values[0]
+		}
+	}
+	return items.ListRequest{
+		Filter:   params.Get("filter"),
+		Tags:     params["tag"],
+		Category: params.Get("category"),
+		Metadata: metadata,
+	}
+}
+
+// ExportItems handles GET requests for a file export of items. The format
+// comes from the format parameter or the Accept header, the items are
+// selected by the same parameters as GetItems, and columns is a
+// comma-separated list of fields to include. excel_safe=true neutralizes
+// formulas in CSV text. Rows are streamed from the database as they are read.
+func (h *Handler) ExportItems(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling ExportItems request from %s", r.RemoteAddr)
+	params := r.URL.Query()
+
+	format, err := export.Negotiate(params.Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	req := export.Request{Format: format, ListRequest: listRequest(params)}
+	if v := params.Get("columns"); v != "" {
+		for _, c := range strings.Split(v, ",") {
+			req.Columns = append(req.Columns, strings.TrimSpace(c))
+		}
+	}
+	if v := params.Get("excel_safe"); v != "" {
+		if req.ExcelSafe, err = strconv.ParseBool(v); err != nil {
+			apierror.Write(w, r, apierror.InvalidArgument("invalid export request",
+				apierror.FieldViolation{Field: "excel_safe", Description: "excel_safe must be true or false"}))
+			return
+		}
+	}
+	e, err := export.Prepare(req)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	out := &exportWriter{ResponseWriter: w, contentType: format.ContentType(), filename: e.Filename(time.Now())}
+	count, err := e.Write(r.Context(), h.db, out)
+	if err != nil {
+		if !out.started {
+			apierror.Write(w, r, apierror.From(err, "querying items"))
+			return
+		}
+		// The status line is gone, so the best we can do is cut the
+		// response short
+		log.Printf("Export failed after %d items: %v", count, err)
+		panic(http.ErrAbortHandler)
+	}
+	log.Printf("Exported %d items as %s", count, format)
+}
+
+// exportWriter sets the download headers on the first write, so an export
+// that fails before any output can still be answered with a problem
+type exportWriter struct {
+	http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.Header().Set("Content-Type", w.contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+w.filename+`"`)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *exportWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
 
 // GetItem handles GET requests to retrieve a specific item
@@ -97,17 +267,10 @@ func (h *Handler) GetItem(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 	log.Printf("Handling GetItem request for ID: %s from %s", id, r.RemoteAddr)
 
-	var item models.Item
-	err := h.db.QueryRow("SELECT id, name, value, created_at FROM items WHERE id = ?", id).
-		Scan(&item.ID, &item.Name, &item.Value, &item.CreatedAt)
-
-	if err == sql.ErrNoRows {
-		log.Printf("Item not found with ID: %s", id)
-		http.Error(w, "Item not found", http.StatusNotFound)
-		return
-	} else if err != nil {
+	item, err := items.Get(r.Context(), h.db, h.rules, id)
+	if err != nil {
 		log.Printf("Error retrieving item with ID %s: %v", id, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.From(err, "retrieving item "+id))
 		return
 	}
 	log.Printf("Successfully retrieved item with ID: %s", id)
@@ -116,6 +279,29 @@ func (h *Handler) GetItem(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(item)
 }
 
+// GetItemTree handles GET requests for an item with its ancestors and its
+// descendants, ?depth= levels down
+func (h *Handler) GetItemTree(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	depth := 0
+	if v := r.URL.Query().Get("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidArgument("invalid tree request",
+				apierror.FieldViolation{Field: "depth", Description: "depth must be an integer"}))
+			return
+		}
+		depth = n
+	}
+	tree, err := items.GetTree(r.Context(), h.db, h.rules, id, depth)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
 // UpdateItem handles PUT requests to update an existing item
 func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -124,30 +310,33 @@ func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 
 	var item models.Item
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Malformed(err))
 		return
 	}
 
-	result, err := h.db.Exec("UPDATE items SET name = ?, value = ? WHERE id = ?",
-		item.Name, item.Value, id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	item.ID = id
+	if err := items.Check(r.Context(), h.db, h.rules, &item); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	request := item
+	err := database.WithTx(r.Context(), h.db, func(tx *sql.Tx) error {
+		item = request
+		if err := items.Update(r.Context(), tx, h.rules, &item); err != nil {
+			return err
+		}
+		if err := webhook.Record(r.Context(), tx, webhook.ItemUpdated, item); err != nil {
+			return err
+		}
+		return outbox.Record(r.Context(), tx, webhook.ItemUpdated, item)
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if rowsAffected == 0 {
-		log.Printf("No item found to update with ID: %s", id)
-		http.Error(w, "Item not found", http.StatusNotFound)
+		apierror.Write(w, r, apierror.From(err, "updating item "+id))
 		return
 	}
 
 	log.Printf("Successfully updated item with ID: %s", id)
-	item.ID = id
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(item)
 }
@@ -158,20 +347,30 @@ func (h *Handler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 	log.Printf("Handling DeleteItem request for ID: %s from %s", id, r.RemoteAddr)
 
-	result, err := h.db.Exec("DELETE FROM items WHERE id = ?", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, err := result.RowsAffected()
+	err := database.WithTx(r.Context(), h.db, func(tx *sql.Tx) error {
+		deleted, children, err := items.Delete(r.Context(), tx, h.rules, id)
+		if err != nil {
+			return err
+		}
+		if err := webhook.Record(r.Context(), tx, webhook.ItemDeleted, *deleted); err != nil {
+			return err
+		}
+		if err := outbox.Record(r.Context(), tx, webhook.ItemDeleted, *deleted); err != nil {
+			return err
+		}
+		// The children are now roots
+		for _, child := range children {
+			if err := webhook.Record(r.Context(), tx, webhook.ItemUpdated, *child); err != nil {
+				return err
+			}
+			if err := outbox.Record(r.Context(), tx, webhook.ItemUpdated, *child); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if rowsAffected == 0 {
-		log.Printf("No item found to delete with ID: %s", id)
-		http.Error(w, "Item not found", http.StatusNotFound)
+		apierror.Write(w, r, apierror.From(err, "deleting item "+id))
 		return
 	}
 
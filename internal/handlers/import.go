@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/export"
+	"github.com/angel/go-api-sqlite/internal/importer"
+	"github.com/angel/go-api-sqlite/internal/jobs"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
+)
+
+// ImportHandler serves bulk imports of items from CSV and NDJSON files.
+// Imports run as jobs, so their status is served by the job endpoints.
+type ImportHandler struct {
+	db    *sql.DB
+	rules money.Rules
+	queue *jobs.Queue
+	// spoolDir holds the uploads of background imports until their job runs
+	spoolDir string
+	// asyncThreshold is the request size above which imports run in the
+	// background
+	asyncThreshold int64
+}
+
+// NewImportHandler creates a handler importing into db items rounded by
+// rules. Requests larger than asyncThreshold bytes are spooled to spoolDir
+// and imported by a job of q, which must have the import kind registered.
+func NewImportHandler(db *sql.DB, rules money.Rules, q *jobs.Queue, spoolDir string, asyncThreshold int64) *ImportHandler {
+	return &ImportHandler{db: db, rules: rules, queue: q, spoolDir: spoolDir, asyncThreshold: asyncThreshold}
+}
+
+// ImportItems handles POST requests with a file to import, either as the
+// request body or as the file part of a multipart form. Options are query
+// parameters: format, map, key, source, dry_run, batch_size and async.
+func (h *ImportHandler) ImportItems(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling ImportItems request from %s", r.RemoteAddr)
+	params := r.URL.Query()
+
+	body, filename, contentType, err := importBody(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	req, async, err := importRequest(params, filename, contentType)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	async = async || (h.asyncThreshold > 0 && r.ContentLength > h.asyncThreshold)
+	im, err := importer.Prepare(req, h.rules)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if !async {
+		job, res, err := im.RunJob(r.Context(), h.queue, h.db, body)
+		var apiErr *apierror.Error
+		if job == nil || (err != nil && errors.As(err, &apiErr) && res.Rows == 0) {
+			// Nothing was imported, so report the problem with the file
+			apierror.Write(w, r, apierror.From(err, "importing items"))
+			return
+		}
+		log.Printf("Import %s %s: %d rows, %d failed", job.ID, job.State, res.Rows, res.Failed)
+		writeImport(w, http.StatusOK, job)
+		return
+	}
+
+	// The body is gone once the handler returns, so spool it to disk first
+	spool, err := os.CreateTemp(h.spoolDir, "import-*")
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal(err, "spooling import"))
+		return
+	}
+	if _, err := io.Copy(spool, body); err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		apierror.Write(w, r, apierror.Malformed(err))
+		return
+	}
+	if err := spool.Close(); err != nil {
+		os.Remove(spool.Name())
+		apierror.Write(w, r, apierror.Internal(err, "spooling import"))
+		return
+	}
+	job, err := im.Enqueue(r.Context(), h.queue, spool.Name())
+	if err != nil {
+		os.Remove(spool.Name())
+		apierror.Write(w, r, apierror.Internal(err, "queueing import"))
+		return
+	}
+	log.Printf("Queued import %s", job.ID)
+	w.Header().Set("Location", jobURL(job.ID))
+	writeImport(w, http.StatusAccepted, job)
+}
+
+// GetImportReport handles GET requests for the row errors of an import as a
+// CSV download. The report is empty until the import has finished.
+func (h *ImportHandler) GetImportReport(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	job, err := h.queue.Get(r.Context(), id)
+	if err == nil && job.Kind != importer.JobKind {
+		err = apierror.NotFound("import", id)
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	res, err := importer.JobResult(job)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal(err, "reading import result"))
+		return
+	}
+	w.Header().Set("Content-Type", export.CSV.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="import-`+id+`-errors.csv"`)
+	if err := res.WriteReport(w); err != nil {
+		log.Printf("Error writing import report: %v", err)
+	}
+}
+
+// importStatus is an import job with links to its status and error report
+type importStatus struct {
+	*jobs.Job
+	StatusURL string `json:"status_url"`
+	ReportURL string `json:"report_url"`
+}
+
+func writeImport(w http.ResponseWriter, code int, job *jobs.Job) {
+	status := importStatus{Job: job, StatusURL: jobURL(job.ID), ReportURL: "/api/items/import/" + job.ID + "/report"}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+func jobURL(id string) string {
+	return "/api/jobs/" + id
+}
+
+// importBody returns the file to import and, when known, its name and media
+// type. A multipart form must carry the file in a part named file.
+func importBody(r *http.Request) (io.Reader, string, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, "", mediaType, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", "", apierror.Malformed(err)
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, "", "", apierror.InvalidArgument("invalid import request",
+				apierror.FieldViolation{Field: "file", Description: "the form has no file part"})
+		}
+		if err != nil {
+			return nil, "", "", apierror.Malformed(err)
+		}
+		if part.FormName() == "file" {
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			return part, part.FileName(), partType, nil
+		}
+	}
+}
+
+// importRequest builds an import request from query parameters. The format
+// defaults to the file extension, then the media type, then CSV.
+func importRequest(params url.Values, filename, contentType string) (importer.Request, bool, error) {
+	get := params.Get
+	req := importer.Request{Key: get("key"), Source: get("source")}
+	var violations []apierror.FieldViolation
+
+	format := get("format")
+	if format == "" && filename != "" {
+		format = strings.TrimPrefix(filepath.Ext(filename), ".")
+	}
+	if f, err := export.Negotiate(format, contentType); err != nil {
+		violations = append(violations, apierror.FieldViolation{Field: "format", Description: "format must be csv or ndjson"})
+	} else {
+		req.Format = f
+	}
+
+	var async bool
+	var err error
+	if req.Mapping, err = importer.ParseMapping(params["map"]...); err != nil {
+		violations = append(violations, apierror.FieldViolation{Field: "map", Description: err.Error()})
+	}
+	if v := get("dry_run"); v != "" {
+		if req.DryRun, err = strconv.ParseBool(v); err != nil {
+			violations = append(violations, apierror.FieldViolation{Field: "dry_run", Description: "dry_run must be true or false"})
+		}
+	}
+	if v := get("async"); v != "" {
+		if async, err = strconv.ParseBool(v); err != nil {
+			violations = append(violations, apierror.FieldViolation{Field: "async", Description: "async must be true or false"})
+		}
+	}
+	if v := get("batch_size"); v != "" {
+		if req.BatchSize, err = strconv.Atoi(v); err != nil {
+			violations = append(violations, apierror.FieldViolation{Field: "batch_size", Description: "batch_size must be an integer"})
+		}
+	}
+	if len(violations) > 0 {
+		return req, false, apierror.InvalidArgument("invalid import request", violations...)
+	}
+	return req, async, nil
+}
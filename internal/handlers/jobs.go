@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/jobs"
+	"github.com/gorilla/mux"
+)
+
+// JobHandler serves the status of background jobs
+type JobHandler struct {
+	queue *jobs.Queue
+}
+
+// NewJobHandler creates a handler for the jobs of q
+func NewJobHandler(q *jobs.Queue) *JobHandler {
+	return &JobHandler{queue: q}
+}
+
+// GetJob handles GET requests for the state, progress and result of a job
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.queue.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelJob handles POST requests to cancel a job. A running job may still
+// be running in the response; it becomes cancelled once it has stopped.
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling CancelJob request from %s", r.RemoteAddr)
+	job, err := h.queue.Cancel(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
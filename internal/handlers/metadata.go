@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/gorilla/mux"
+)
+
+// MetadataIndexHandler serves the admin endpoints that index metadata keys
+type MetadataIndexHandler struct {
+	db *sql.DB
+}
+
+// NewMetadataIndexHandler creates a handler for the metadata indexes in db
+func NewMetadataIndexHandler(db *sql.DB) *MetadataIndexHandler {
+	return &MetadataIndexHandler{db: db}
+}
+
+// ListMetadataIndexes handles GET requests to list the indexed keys
+func (h *MetadataIndexHandler) ListMetadataIndexes(w http.ResponseWriter, r *http.Request) {
+	indexes, err := items.ListMetadataIndexes(r.Context(), h.db)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal(err, "listing metadata indexes"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(indexes)
+}
+
+// CreateMetadataIndex handles POST requests to index a metadata key, given
+// as a dotted path such as size.width
+func (h *MetadataIndexHandler) CreateMetadataIndex(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling CreateMetadataIndex request from %s", r.RemoteAddr)
+	var req struct {
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.Malformed(err))
+		return
+	}
+	var index *items.MetadataIndex
+	err := database.WithTx(r.Context(), h.db, func(tx *sql.Tx) error {
+		var err error
+		index, err = items.CreateMetadataIndex(r.Context(), tx, req.Key)
+		return err
+	})
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "creating metadata index"))
+		return
+	}
+	log.Printf("Successfully indexed metadata key %s", index.Key)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(index)
+}
+
+// DeleteMetadataIndex handles DELETE requests to drop the index of a key
+func (h *MetadataIndexHandler) DeleteMetadataIndex(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	log.Printf("Handling DeleteMetadataIndex request for key %s from %s", key, r.RemoteAddr)
+	err := database.WithTx(r.Context(), h.db, func(tx *sql.Tx) error {
+		return items.DropMetadataIndex(r.Context(), tx, key)
+	})
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "dropping metadata index"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
+)
+
+// SchemaHandler serves the admin endpoints that manage item schemas
+type SchemaHandler struct {
+	db    *sql.DB
+	rules money.Rules
+}
+
+// NewSchemaHandler creates a handler for the item schemas in db. Dry runs
+// read items the way rules round them.
+func NewSchemaHandler(db *sql.DB, rules money.Rules) *SchemaHandler {
+	return &SchemaHandler{db: db, rules: rules}
+}
+
+// ListSchemas handles GET requests to list every schema version
+func (h *SchemaHandler) ListSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas, err := items.ListSchemas(r.Context(), h.db)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schemas)
+}
+
+// CreateSchema handles POST requests to store a new, inactive schema version
+func (h *SchemaHandler) CreateSchema(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling CreateSchema request from %s", r.RemoteAddr)
+	var req struct {
+		Schema json.RawMessage `json:"schema"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.Malformed(err))
+		return
+	}
+	if len(req.Schema) == 0 {
+		apierror.Write(w, r, apierror.InvalidArgument("invalid schema",
+			apierror.FieldViolation{Field: "schema", Description: "schema is required"}))
+		return
+	}
+	schema, err := items.CreateSchema(r.Context(), h.db, req.Schema)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	log.Printf("Successfully created schema version %d", schema.Version)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schema)
+}
+
+// GetSchema handles GET requests for one schema version
+func (h *SchemaHandler) GetSchema(w http.ResponseWriter, r *http.Request) {
+	version, err := schemaVersion(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	schema, err := items.GetSchema(r.Context(), h.db, version)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schema)
+}
+
+// DryRunSchema handles POST requests to check the stored items, or those in
+// the collection given by ?collection=, against a schema version without
+// activating it
+func (h *SchemaHandler) DryRunSchema(w http.ResponseWriter, r *http.Request) {
+	version, err := schemaVersion(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	run, err := items.DryRunSchema(r.Context(), h.db, h.rules, version, r.URL.Query().Get("collection"))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// ActivateSchema handles POST requests to make a schema version the one
+// items are validated against
+func (h *SchemaHandler) ActivateSchema(w http.ResponseWriter, r *http.Request) {
+	version, err := schemaVersion(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	log.Printf("Handling ActivateSchema request for version %d from %s", version, r.RemoteAddr)
+	var schema *items.Schema
+	err = database.WithTx(r.Context(), h.db, func(tx *sql.Tx) error {
+		var err error
+		schema, err = items.ActivateSchema(r.Context(), tx, version)
+		return err
+	})
+	if err != nil {
+		apierror.Write(w, r, apierror.From(err, "activating schema"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schema)
+}
+
+// DeactivateSchema handles POST requests to stop validating items against
+// a schema
+func (h *SchemaHandler) DeactivateSchema(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling DeactivateSchema request from %s", r.RemoteAddr)
+	if err := items.DeactivateSchema(r.Context(), h.db); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// schemaVersion reads the version in the path
+func schemaVersion(r *http.Request) (int64, error) {
+	s := mux.Vars(r)["version"]
+	version, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || version < 1 {
+		return 0, apierror.NotFound("schema", s)
+	}
+	return version, nil
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/attachments"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	svc, err := attachments.New(db, attachments.Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	h := handlers.NewHandler(db, money.Rules{})
+	router.HandleFunc("/api/items", h.CreateItem).Methods("POST")
+	ah := handlers.NewAttachmentHandler(svc)
+	router.HandleFunc("/api/items/{id}/attachments", ah.ListAttachments).Methods("GET")
+	router.HandleFunc("/api/items/{id}/attachments", ah.CreateAttachment).Methods("POST")
+	router.HandleFunc("/api/items/{id}/attachments/uploads/{upload_id}", ah.GetUpload).Methods("GET")
+	router.HandleFunc("/api/items/{id}/attachments/uploads/{upload_id}", ah.WriteUploadChunk).Methods("PUT")
+	router.HandleFunc("/api/items/{id}/attachments/uploads/{upload_id}", ah.CancelUpload).Methods("DELETE")
+	router.HandleFunc("/api/items/{id}/attachments/{attachment_id}", ah.GetAttachment).Methods("GET")
+	router.HandleFunc("/api/items/{id}/attachments/{attachment_id}", ah.DeleteAttachment).Methods("DELETE")
+	router.HandleFunc("/api/items/{id}/attachments/{attachment_id}/content", ah.GetAttachmentContent).Methods("GET")
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(httptest.NewRequest("POST", "/api/items", strings.NewReader(`{"name": "Poster"}`)))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var item struct{ ID string }
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&item))
+	base := "/api/items/" + item.ID + "/attachments"
+
+	upload := func(filename string, content []byte) *httptest.ResponseRecorder {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		require.NoError(t, mw.WriteField("note", "ignored"))
+		part, err := mw.CreateFormFile("file", filename)
+		require.NoError(t, err)
+		part.Write(content)
+		require.NoError(t, mw.Close())
+		req := httptest.NewRequest("POST", base, &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return do(req)
+	}
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte("0123456789"), 10)...)
+
+	w = upload("poster.png", png)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var a attachments.Attachment
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&a))
+	assert.Equal(t, base+"/"+a.ID, w.Header().Get("Location"))
+	assert.Equal(t, "image/png", a.ContentType)
+	assert.Equal(t, http.StatusBadRequest, upload("page.png", []byte("<html></html>")).Code)
+	assert.Contains(t, do(httptest.NewRequest("GET", base, nil)).Body.String(), `"filename":"poster.png"`)
+	assert.Equal(t, http.StatusOK, do(httptest.NewRequest("GET", base+"/"+a.ID, nil)).Code)
+
+	// Content is served with range and conditional request support
+	w = do(httptest.NewRequest("GET", base+"/"+a.ID+"/content", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, png, w.Body.Bytes())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, `inline; filename=poster.png`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, `"`+a.SHA256+`"`, w.Header().Get("ETag"))
+
+	req := httptest.NewRequest("GET", base+"/"+a.ID+"/content", nil)
+	req.Header.Set("Range", "bytes=8-17")
+	w = do(req)
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+	assert.Equal(t, "bytes 8-17/108", w.Header().Get("Content-Range"))
+
+	req = httptest.NewRequest("GET", base+"/"+a.ID+"/content", nil)
+	req.Header.Set("If-None-Match", `"`+a.SHA256+`"`)
+	assert.Equal(t, http.StatusNotModified, do(req).Code)
+
+	// A resumable upload takes chunks placed by Content-Range
+	w = do(httptest.NewRequest("POST", base, strings.NewReader(`{"filename": "chunked.png", "size": 108}`)))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var u attachments.Upload
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&u))
+	location := w.Header().Get("Location")
+	assert.Equal(t, base+"/uploads/"+u.ID, location)
+
+	chunk := func(first, last int, total string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("PUT", location, bytes.NewReader(png[first:last+1]))
+		req.Header.Set("Content-Range", "bytes "+strconv.Itoa(first)+"-"+strconv.Itoa(last)+"/"+total)
+		return do(req)
+	}
+	w = chunk(0, 49, "108")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"received":50`)
+	w = chunk(0, 49, "108")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), attachments.ReasonUploadOffsetMismatch)
+	assert.Equal(t, http.StatusBadRequest, chunk(50, 107, "200").Code)
+	req = httptest.NewRequest("PUT", location, strings.NewReader("x"))
+	assert.Equal(t, http.StatusBadRequest, do(req).Code)
+	assert.Contains(t, do(httptest.NewRequest("GET", location, nil)).Body.String(), `"received":50`)
+
+	w = chunk(50, 107, "108")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var chunked attachments.Attachment
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&chunked))
+	assert.Equal(t, a.SHA256, chunked.SHA256)
+	assert.Equal(t, "chunked.png", chunked.Filename)
+	assert.Equal(t, http.StatusNotFound, do(httptest.NewRequest("GET", location, nil)).Code)
+
+	w = do(httptest.NewRequest("POST", base, strings.NewReader(`{"filename": "big.png", "size": 1000000000}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(httptest.NewRequest("POST", base, strings.NewReader(`{"filename": "later.png", "size": 10}`)))
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusNoContent, do(httptest.NewRequest("DELETE", w.Header().Get("Location"), nil)).Code)
+
+	assert.Equal(t, http.StatusNoContent, do(httptest.NewRequest("DELETE", base+"/"+a.ID, nil)).Code)
+	assert.Equal(t, http.StatusNotFound, do(httptest.NewRequest("GET", base+"/"+a.ID+"/content", nil)).Code)
+	w = do(httptest.NewRequest("GET", base+"/"+chunked.ID+"/content", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, png, body)
+	assert.Equal(t, http.StatusNotFound, do(httptest.NewRequest("GET", "/api/items/missing/attachments", nil)).Code)
+}
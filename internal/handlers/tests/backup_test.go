@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/backup"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupEndpoints(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "data.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	dir := t.TempDir()
+	h := handlers.NewBackupHandler(db, dir, backup.Retention{Daily: 7, Weekly: 4})
+
+	// Create a snapshot
+	w := httptest.NewRecorder()
+	h.CreateBackup(w, httptest.NewRequest("POST", "/api/admin/backups", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created backup.Manifest
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.FileExists(t, filepath.Join(dir, created.File))
+	assert.Equal(t, database.SchemaVersion, created.SchemaVersion)
+
+	// List snapshots
+	w = httptest.NewRecorder()
+	h.ListBackups(w, httptest.NewRequest("GET", "/api/admin/backups", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var listed []backup.Manifest
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&listed))
+	require.Len(t, listed, 1)
+	assert.Equal(t, created.SHA256, listed[0].SHA256)
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/collections"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionsAndTree(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	router := mux.NewRouter()
+	h := handlers.NewHandler(db, money.Rules{})
+	router.HandleFunc("/api/items", h.CreateItem).Methods("POST")
+	router.HandleFunc("/api/items/{id}", h.UpdateItem).Methods("PUT")
+	router.HandleFunc("/api/items/{id}/tree", h.GetItemTree).Methods("GET")
+	ch := handlers.NewCollectionHandler(db, money.Rules{})
+	router.HandleFunc("/api/collections", ch.ListCollections).Methods("GET")
+	router.HandleFunc("/api/collections", ch.CreateCollection).Methods("POST")
+	router.HandleFunc("/api/collections/{id:[^/:]+}", ch.GetCollection).Methods("GET")
+	router.HandleFunc("/api/collections/{id:[^/:]+}", ch.UpdateCollection).Methods("PUT")
+	router.HandleFunc("/api/collections/{id:[^/:]+}", ch.DeleteCollection).Methods("DELETE")
+	router.HandleFunc("/api/collections/{id}/items", ch.ListCollectionItems).Methods("GET")
+	router.HandleFunc("/api/collections/{id}/items", ch.AddCollectionItem).Methods("POST")
+	router.HandleFunc("/api/collections/{id}/items/{item_id}", ch.RemoveCollectionItem).Methods("DELETE")
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	create := func(body string) string {
+		t.Helper()
+		w := do("POST", "/api/items", body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var item struct{ ID string }
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&item))
+		return item.ID
+	}
+
+	root := create(`{"name": "Catalog"}`)
+	child := create(`{"name": "Bundle", "parent_id": "` + root + `"}`)
+	create(`{"name": "Part", "parent_id": "` + child + `"}`)
+
+	w := do("POST", "/api/items", `{"name": "Orphan", "parent_id": "missing"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "parent item missing does not exist")
+	w = do("PUT", "/api/items/"+root, `{"name": "Catalog", "parent_id": "`+child+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "is this item or one of its descendants")
+
+	w = do("GET", "/api/items/"+child+"/tree?depth=2", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tree items.Tree
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tree))
+	require.Len(t, tree.Ancestors, 1)
+	assert.Equal(t, root, tree.Ancestors[0].ID)
+	assert.Equal(t, root, tree.Tree.Item.ParentID)
+	require.Len(t, tree.Tree.Children, 1)
+	assert.Equal(t, "Part", tree.Tree.Children[0].Item.Name)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/api/items/"+child+"/tree?depth=x", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/api/items/"+child+"/tree?depth=11", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/items/missing/tree", "").Code)
+
+	w = do("POST", "/api/collections", `{"name": "Summer", "description": "Seasonal"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var c collections.Collection
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&c))
+	assert.Equal(t, "/api/collections/"+c.ID, w.Header().Get("Location"))
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/collections", `{"name": ""}`).Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/api/collections/"+c.ID, "").Code)
+	assert.Contains(t, do("GET", "/api/collections", "").Body.String(), `"name":"Summer"`)
+	w = do("PUT", "/api/collections/"+c.ID, `{"name": "Winter"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"name":"Winter"`)
+
+	assert.Equal(t, http.StatusNoContent, do("POST", "/api/collections/"+c.ID+"/items", `{"item_id": "`+root+`"}`).Code)
+	assert.Equal(t, http.StatusNoContent, do("POST", "/api/collections/"+c.ID+"/items", `{"item_id": "`+child+`"}`).Code)
+	assert.Equal(t, http.StatusConflict, do("POST", "/api/collections/"+c.ID+"/items", `{"item_id": "`+root+`"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/collections/"+c.ID+"/items", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/api/collections/missing/items", `{"item_id": "`+root+`"}`).Code)
+
+	w = do("GET", "/api/collections/"+c.ID+"/items?page_size=1", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page collections.ItemsPage
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+	assert.Len(t, page.Items, 1)
+	require.NotEmpty(t, page.NextPageToken)
+	w = do("GET", "/api/collections/"+c.ID+"/items?page_size=1&page_token="+page.NextPageToken, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	page = collections.ItemsPage{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+	assert.Len(t, page.Items, 1)
+	assert.Empty(t, page.NextPageToken)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/api/collections/"+c.ID+"/items?page_size=x", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/api/collections/"+c.ID+"/items/"+root, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/api/collections/"+c.ID+"/items/"+root, "").Code)
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/api/collections/"+c.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/collections/"+c.ID, "").Code)
+}
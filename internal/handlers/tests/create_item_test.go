@@ -9,14 +9,17 @@ import (
 
 	"github.com/angel/go-api-sqlite/internal/handlers"
 	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateItem(t *testing.T) {
 	// Setup
 	db := setupTestDB(t)
 	defer db.Close()
-	h := handlers.NewHandler(db)
+	h := handlers.NewHandler(db, money.Rules{})
 
 	tests := []struct {
 		name       string
@@ -28,15 +31,37 @@ func TestCreateItem(t *testing.T) {
 			name: "Valid item",
 			input: models.Item{
 				Name:  "Test Item",
-				Value: 29.99,
+				Value: money.MustParse("29.99"),
 			},
 			wantStatus: http.StatusCreated,
 			wantErr:    false,
 		},
+		{
+			name: "Details",
+			input: models.Item{
+				Name:        "Detailed Item",
+				Value:       money.MustParse("5.00"),
+				Description: "With every field",
+				Tags:        []string{"sale", "new"},
+				Category:    "tools",
+				Currency:    "EUR",
+			},
+			wantStatus: http.StatusCreated,
+			wantErr:    false,
+		},
+		{
+			name: "Unknown currency",
+			input: models.Item{
+				Name:     "Test Item",
+				Currency: "XYZ",
+			},
+			wantStatus: http.StatusBadRequest,
+			wantErr:    true,
+		},
 		{
 			name: "Missing name",
 			input: models.Item{
-				Value: 29.99,
+				Value: money.MustParse("29.99"),
 			},
 			wantStatus: http.StatusBadRequest,
 			wantErr:    true,
@@ -67,8 +92,37 @@ func TestCreateItem(t *testing.T) {
 				assert.NotEmpty(t, response.ID)
 				assert.Equal(t, tt.input.Name, response.Name)
 				assert.Equal(t, tt.input.Value, response.Value)
+				assert.Equal(t, tt.input.Description, response.Description)
+				assert.ElementsMatch(t, tt.input.Tags, response.Tags)
+				assert.Equal(t, tt.input.Category, response.Category)
+				assert.Equal(t, tt.input.Currency, response.Currency)
 				assert.NotZero(t, response.CreatedAt)
+				assert.Equal(t, response.CreatedAt, response.UpdatedAt)
 			}
 		})
 	}
 }
+
+func TestCreateItemRoundsByRules(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	rules, err := money.ParseRules("USD=0:down")
+	require.NoError(t, err)
+	h := handlers.NewHandler(db, rules)
+
+	req := httptest.NewRequest("POST", "/api/items", bytes.NewBufferString(`{"name": "Widget", "value": "29.99", "currency": "USD"}`))
+	w := httptest.NewRecorder()
+	h.CreateItem(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created models.Item
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "29", created.Value.String())
+
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/api/items/"+created.ID, nil), map[string]string{"id": created.ID})
+	w = httptest.NewRecorder()
+	h.GetItem(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var got models.Item
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, "29", got.Value.String())
+}
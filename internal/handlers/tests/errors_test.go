@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemResponses(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	defer db.Close()
+	h := handlers.NewHandler(db, money.Rules{})
+
+	t.Run("Validation error lists field violations", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/items", bytes.NewBufferString(`{"value": 1}`))
+		w := httptest.NewRecorder()
+
+		h.CreateItem(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, apierror.ProblemContentType, w.Header().Get("Content-Type"))
+
+		var problem apierror.Problem
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+		assert.Equal(t, apierror.ReasonValidationFailed, problem.Code)
+		assert.Equal(t, http.StatusBadRequest, problem.Status)
+		assert.Equal(t, "/api/items", problem.Instance)
+		require.Len(t, problem.Errors, 1)
+		assert.Equal(t, "name", problem.Errors[0].Field)
+	})
+
+	t.Run("Malformed body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/items", bytes.NewBufferString(`{`))
+		w := httptest.NewRecorder()
+
+		h.CreateItem(w, req)
+
+		var problem apierror.Problem
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, apierror.ReasonMalformedRequest, problem.Code)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/items/missing", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "missing"})
+		w := httptest.NewRecorder()
+
+		h.GetItem(w, req)
+
+		var problem apierror.Problem
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, apierror.ReasonNotFound, problem.Code)
+	})
+
+	t.Run("Database errors are sanitized", func(t *testing.T) {
+		broken := setupTestDB(t)
+		_, err := broken.Exec("DROP TABLE items")
+		require.NoError(t, err)
+		defer broken.Close()
+
+		req := httptest.NewRequest("GET", "/api/items", nil)
+		w := httptest.NewRecorder()
+
+		handlers.NewHandler(broken, money.Rules{}).GetItems(w, req)
+
+		var problem apierror.Problem
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, apierror.ReasonInternal, problem.Code)
+		assert.NotContains(t, problem.Detail, "no such table")
+		assert.NotEmpty(t, problem.CorrelationID)
+		assert.Equal(t, problem.CorrelationID, w.Header().Get("X-Correlation-ID"))
+	})
+}
@@ -4,11 +4,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/angel/go-api-sqlite/internal/apierror"
 	"github.com/angel/go-api-sqlite/internal/handlers"
 	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -17,28 +21,28 @@ func TestGetItems(t *testing.T) {
 	// Setup
 	db := setupTestDB(t)
 	defer db.Close()
-	h := handlers.NewHandler(db)
+	h := handlers.NewHandler(db, money.Rules{})
 
 	// Insert test items
 	testItems := []models.Item{
 		{
 			ID:        uuid.New().String(),
 			Name:      "Test Item 1",
-			Value:     29.99,
+			Value:     money.MustParse("29.99"),
 			CreatedAt: time.Now(),
 		},
 		{
 			ID:        uuid.New().String(),
 			Name:      "Test Item 2",
-			Value:     39.99,
+			Value:     money.MustParse("39.99"),
 			CreatedAt: time.Now(),
 		},
 	}
 
 	for _, item := range testItems {
 		_, err := db.Exec(
-			"INSERT INTO items (id, name, value, created_at) VALUES (?, ?, ?, ?)",
-			item.ID, item.Name, item.Value, item.CreatedAt,
+			"INSERT INTO items (id, name, value_units, created_at) VALUES (?, ?, ?, ?)",
+			item.ID, item.Name, units(item.Value), item.CreatedAt,
 		)
 		assert.NoError(t, err)
 	}
@@ -73,3 +77,77 @@ func TestGetItems(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, response, 0)
 }
+
+func TestGetItemsFilter(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	defer db.Close()
+	h := handlers.NewHandler(db, money.Rules{})
+
+	for i, name := range []string{"Red Widget", "Blue Widget", "Gadget"} {
+		_, err := db.Exec(
+			"INSERT INTO items (id, name, value_units, created_at) VALUES (?, ?, ?, ?)",
+			uuid.New().String(), name, i*100000, time.Now(),
+		)
+		assert.NoError(t, err)
+	}
+
+	// Matching items
+	req := httptest.NewRequest("GET", "/api/items?filter="+url.QueryEscape(`value > 5 AND name ~ "*widget"`), nil)
+	w := httptest.NewRecorder()
+	h.GetItems(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response []models.Item
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	if assert.Len(t, response, 1) {
+		assert.Equal(t, "Blue Widget", response[0].Name)
+	}
+
+	// Syntax errors are reported with their position
+	req = httptest.NewRequest("GET", "/api/items?filter="+url.QueryEscape("(value > 5"), nil)
+	w = httptest.NewRecorder()
+	h.GetItems(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem apierror.Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	if assert.Len(t, problem.Errors, 1) {
+		assert.Equal(t, "filter", problem.Errors[0].Field)
+		assert.Contains(t, problem.Errors[0].Description, "at position 11")
+	}
+}
+
+func TestGetItemsByTagAndCategory(t *testing.T) {
+	// Setup
+	db := setupTestDB(t)
+	defer db.Close()
+	h := handlers.NewHandler(db, money.Rules{})
+
+	for _, body := range []string{
+		`{"name": "Red Widget", "tags": ["red", "sale"], "category": "tools"}`,
+		`{"name": "Red Gadget", "tags": ["red"], "category": "toys"}`,
+		`{"name": "Blue Widget", "tags": ["Blue", "sale"], "category": "tools"}`,
+	} {
+		w := httptest.NewRecorder()
+		h.CreateItem(w, httptest.NewRequest("POST", "/api/items", strings.NewReader(body)))
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	names := func(query string) []string {
+		w := httptest.NewRecorder()
+		h.GetItems(w, httptest.NewRequest("GET", "/api/items?"+query, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []models.Item
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		var names []string
+		for _, item := range response {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+	assert.ElementsMatch(t, []string{"Red Widget", "Red Gadget"}, names("tag=red"))
+	assert.ElementsMatch(t, []string{"Red Widget"}, names("tag=red&tag=sale"))
+	assert.ElementsMatch(t, []string{"Red Widget", "Blue Widget"}, names("category=tools"))
+	assert.ElementsMatch(t, []string{"Blue Widget"}, names("filter="+url.QueryEscape(`tags:blue AND category = "tools"`)))
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,7 +15,7 @@ func TestHealthCheck(t *testing.T) {
 	// Setup
 	db := setupTestDB(t)
 	defer db.Close()
-	h := handlers.NewHandler(db)
+	h := handlers.NewHandler(db, money.Rules{})
 
 	// Create a new HTTP request
 	req := httptest.NewRequest("GET", "/api/health", nil)
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemMetadata(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	router := mux.NewRouter()
+	h := handlers.NewHandler(db, money.Rules{})
+	router.HandleFunc("/api/items", h.GetItems).Methods("GET")
+	router.HandleFunc("/api/items", h.CreateItem).Methods("POST")
+	mh := handlers.NewMetadataIndexHandler(db)
+	router.HandleFunc("/api/admin/metadata-indexes", mh.ListMetadataIndexes).Methods("GET")
+	router.HandleFunc("/api/admin/metadata-indexes", mh.CreateMetadataIndex).Methods("POST")
+	router.HandleFunc("/api/admin/metadata-indexes/{key}", mh.DeleteMetadataIndex).Methods("DELETE")
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	w := do("POST", "/api/items", `{"name": "Red", "metadata": {"color": "red", "size": {"width": 3}}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created models.Item
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, map[string]any{"color": "red", "size": map[string]any{"width": 3.0}}, created.Metadata)
+	require.Equal(t, http.StatusCreated, do("POST", "/api/items", `{"name": "Plain"}`).Code)
+
+	w = do("POST", "/api/items", `{"name": "Bad", "metadata": {"not a key": 1}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do("POST", "/api/items", `{"name": "Bad", "metadata": [1]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	list := func(query string) []models.Item {
+		t.Helper()
+		w := do("GET", "/api/items?"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var list []models.Item
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+		return list
+	}
+	assert.Len(t, list("meta.color=red&meta.size.width=3"), 1)
+	assert.Empty(t, list("meta.color=blue"))
+	assert.Len(t, list(""), 2)
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/api/items?meta.a..b=1", "").Code)
+
+	// Index a key; queries return the same items
+	w = do("POST", "/api/admin/metadata-indexes", `{"key": "color"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusConflict, do("POST", "/api/admin/metadata-indexes", `{"key": "color"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/admin/metadata-indexes", `{"key": "$.color"}`).Code)
+	assert.Len(t, list("meta.color=red"), 1)
+
+	w = do("GET", "/api/admin/metadata-indexes", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"key":"color"`)
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/api/admin/metadata-indexes/color", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/api/admin/metadata-indexes/color", "").Code)
+	assert.Len(t, list("meta.color=red"), 1)
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemSchemas(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	router := mux.NewRouter()
+	h := handlers.NewHandler(db, money.Rules{})
+	router.HandleFunc("/api/items", h.CreateItem).Methods("POST")
+	router.HandleFunc("/api/items/{id}", h.UpdateItem).Methods("PUT")
+	sh := handlers.NewSchemaHandler(db, money.Rules{})
+	router.HandleFunc("/api/admin/schemas", sh.ListSchemas).Methods("GET")
+	router.HandleFunc("/api/admin/schemas", sh.CreateSchema).Methods("POST")
+	router.HandleFunc("/api/admin/schemas:deactivate", sh.DeactivateSchema).Methods("POST")
+	router.HandleFunc("/api/admin/schemas/{version:[^/:]+}", sh.GetSchema).Methods("GET")
+	router.HandleFunc("/api/admin/schemas/{version:[^/:]+}:dry-run", sh.DryRunSchema).Methods("POST")
+	router.HandleFunc("/api/admin/schemas/{version:[^/:]+}:activate", sh.ActivateSchema).Methods("POST")
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	w := do("POST", "/api/items", `{"name": "Existing item", "value": "2000"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var existing struct{ ID string }
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&existing))
+
+	w = do("POST", "/api/admin/schemas", `{"schema": {"properties": {"name": {"maxLength": 8}, "value": {"maximum": 1000}}}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created items.Schema
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, int64(1), created.Version)
+	assert.JSONEq(t, `{"properties": {"name": {"maxLength": 8}, "value": {"maximum": 1000}}}`, string(created.Schema))
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/admin/schemas", `{"schema": {"type": "text"}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/admin/schemas", `{}`).Code)
+
+	// The dry run finds the stored item the schema would reject
+	w = do("POST", "/api/admin/schemas/1:dry-run", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var run items.DryRun
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&run))
+	assert.Equal(t, 1, run.Checked)
+	assert.Equal(t, 1, run.Failed)
+	require.Len(t, run.Failures, 1)
+	assert.Equal(t, existing.ID, run.Failures[0].ID)
+	assert.Len(t, run.Failures[0].Violations, 2)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/api/admin/schemas/7:dry-run", "").Code)
+
+	// Nothing is enforced until the schema is activated
+	assert.Equal(t, http.StatusCreated, do("POST", "/api/items", `{"name": "Long name accepted"}`).Code)
+	w = do("POST", "/api/admin/schemas/1:activate", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"active":true`)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/api/admin/schemas/x:activate", "").Code)
+
+	// Every violation is reported at once, on create and update
+	w = do("POST", "/api/items", `{"name": "Long name rejected", "value": "1000.01", "currency": "ZZZ"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var problem struct {
+		Errors []struct{ Field string } `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Len(t, problem.Errors, 3)
+	assert.Equal(t, http.StatusBadRequest, do("PUT", "/api/items/"+existing.ID, `{"name": "Short", "value": "2000"}`).Code)
+	assert.Equal(t, http.StatusOK, do("PUT", "/api/items/"+existing.ID, `{"name": "Short", "value": "20"}`).Code)
+
+	// A second version replaces the first
+	require.Equal(t, http.StatusCreated, do("POST", "/api/admin/schemas", `{"schema": {}}`).Code)
+	require.Equal(t, http.StatusOK, do("POST", "/api/admin/schemas/2:activate", "").Code)
+	w = do("GET", "/api/admin/schemas", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var schemas []items.Schema
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&schemas))
+	require.Len(t, schemas, 2)
+	assert.False(t, schemas[0].Active)
+	assert.True(t, schemas[1].Active)
+
+	assert.Equal(t, http.StatusNoContent, do("POST", "/api/admin/schemas:deactivate", "").Code)
+	w = do("GET", "/api/admin/schemas/2", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"active":false`)
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"testing"
 
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/money"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -19,15 +21,8 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("Error opening test database: %v", err)
 	}
 
-	// Create the items table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS items (
-			id TEXT PRIMARY KEY,
-			name TEXT NOT NULL,
-			value REAL NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-	`)
+	// Create the schema
+	err = database.Migrate(db)
 	if err != nil {
 		t.Fatalf("Error creating test table: %v", err)
 	}
@@ -42,3 +37,12 @@ func TestMain(m *testing.M) {
 	// Run tests
 	os.Exit(m.Run())
 }
+
+// units returns a value as it is stored in the value_units column
+func units(value money.Decimal) int64 {
+	u, err := value.Units()
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/angel/go-api-sqlite/internal/handlers"
 	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
@@ -20,19 +21,19 @@ func TestUpdateItem(t *testing.T) {
 	// Setup
 	db := setupTestDB(t)
 	defer db.Close()
-	h := handlers.NewHandler(db)
+	h := handlers.NewHandler(db, money.Rules{})
 
 	// Insert a test item
 	testItem := models.Item{
 		ID:        uuid.New().String(),
 		Name:      "Test Item",
-		Value:     29.99,
+		Value:     money.MustParse("29.99"),
 		CreatedAt: time.Now(),
 	}
 
 	_, err := db.Exec(
-		"INSERT INTO items (id, name, value, created_at) VALUES (?, ?, ?, ?)",
-		testItem.ID, testItem.Name, testItem.Value, testItem.CreatedAt,
+		"INSERT INTO items (id, name, value_units, created_at) VALUES (?, ?, ?, ?)",
+		testItem.ID, testItem.Name, units(testItem.Value), testItem.CreatedAt,
 	)
 	assert.NoError(t, err)
 
@@ -48,7 +49,7 @@ func TestUpdateItem(t *testing.T) {
 			itemID: testItem.ID,
 			updates: models.Item{
 				Name:  "Updated Item",
-				Value: 39.99,
+				Value: money.MustParse("39.99"),
 			},
 			wantStatus: http.StatusOK,
 			wantErr:    false,
@@ -58,7 +59,7 @@ func TestUpdateItem(t *testing.T) {
 			itemID: uuid.New().String(),
 			updates: models.Item{
 				Name:  "Updated Item",
-				Value: 39.99,
+				Value: money.MustParse("39.99"),
 			},
 			wantStatus: http.StatusNotFound,
 			wantErr:    true,
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/webhook"
+	"github.com/gorilla/mux"
+)
+
+// WebhookHandler serves webhook subscriptions and their deliveries
+type WebhookHandler struct {
+	db     *sql.DB
+	policy webhook.Policy
+}
+
+// NewWebhookHandler creates a handler for the subscriptions in db whose
+// receivers must have addresses policy allows
+func NewWebhookHandler(db *sql.DB, policy webhook.Policy) *WebhookHandler {
+	return &WebhookHandler{db: db, policy: policy}
+}
+
+// CreateWebhook handles POST requests to subscribe a URL to item events.
+// The response is the only one that includes the secret.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Handling CreateWebhook request from %s", r.RemoteAddr)
+	var p webhook.Params
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		apierror.Write(w, r, apierror.Malformed(err))
+		return
+	}
+	sub, err := webhook.Create(r.Context(), h.db, h.policy, p)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	log.Printf("Successfully created webhook %s", sub.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/webhooks/"+sub.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// ListWebhooks handles GET requests to list subscriptions
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs, err := webhook.List(r.Context(), h.db)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subs)
+}
+
+// GetWebhook handles GET requests for one subscription
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	sub, err := webhook.Get(r.Context(), h.db, mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// UpdateWebhook handles PUT requests to change a subscription
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	log.Printf("Handling UpdateWebhook request for ID: %s from %s", id, r.RemoteAddr)
+	var p webhook.Params
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		apierror.Write(w, r, apierror.Malformed(err))
+		return
+	}
+	sub, err := webhook.Update(r.Context(), h.db, h.policy, id, p)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// DeleteWebhook handles DELETE requests to remove a subscription and its
+// deliveries
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	log.Printf("Handling DeleteWebhook request for ID: %s from %s", id, r.RemoteAddr)
+	if err := webhook.Delete(r.Context(), h.db, id); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries handles GET requests for the deliveries of a subscription,
+// newest first. The state, before and page_size query parameters narrow
+// and page them.
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := webhook.DeliveryQuery{State: params.Get("state")}
+	var violations []apierror.FieldViolation
+	if v := params.Get("before"); v != "" {
+		var err error
+		if q.Before, err = strconv.ParseInt(v, 10, 64); err != nil {
+			violations = append(violations, apierror.FieldViolation{Field: "before", Description: "before must be a delivery ID"})
+		}
+	}
+	if v := params.Get("page_size"); v != "" {
+		var err error
+		if q.PageSize, err = strconv.Atoi(v); err != nil {
+			violations = append(violations, apierror.FieldViolation{Field: "page_size", Description: "page_size must be an integer"})
+		}
+	}
+	if len(violations) > 0 {
+		apierror.Write(w, r, apierror.InvalidArgument("invalid delivery query", violations...))
+		return
+	}
+
+	deliveries, err := webhook.ListDeliveries(r.Context(), h.db, mux.Vars(r)["id"], q)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// ReplayDelivery handles POST requests to send a delivery again
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	log.Printf("Handling ReplayDelivery request for delivery %s from %s", vars["delivery_id"], r.RemoteAddr)
+	id, err := strconv.ParseInt(vars["delivery_id"], 10, 64)
+	if err != nil {
+		apierror.Write(w, r, apierror.NotFound("delivery", vars["delivery_id"]))
+		return
+	}
+	d, err := webhook.Replay(r.Context(), h.db, vars["id"], id)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// ReplayDeadDeliveries handles POST requests to send the dead-lettered
+// deliveries of a subscription again
+func (h *WebhookHandler) ReplayDeadDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	log.Printf("Handling ReplayDeadDeliveries request for webhook %s from %s", id, r.RemoteAddr)
+	n, err := webhook.ReplayDead(r.Context(), h.db, id)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"replayed": n})
+}
@@ -0,0 +1,553 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/export"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/outbox"
+	"github.com/angel/go-api-sqlite/internal/webhook"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+// Limits and defaults of an import
+const (
+	DefaultBatchSize = 500
+	MaxBatchSize     = 10000
+	DefaultSource    = "default"
+	// MaxReportedRows caps the errors and dry-run changes kept in a result
+	MaxReportedRows = 10000
+)
+
+// ItemFields are the item fields a file column can be mapped to. tags is a
+// comma-separated list.
+var ItemFields = []string{"id", "name", "value", "description", "tags", "category", "currency", "created_at"}
+
+// keptFields keep their stored value on update when the file has no column
+// for them
+var keptFields = []string{"description", "tags", "category", "currency"}
+
+// Actions taken for a row
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+)
+
+// Request describes how to read a file into items
+type Request struct {
+	// Format is export.CSV or export.NDJSON
+	Format export.Format `json:"format"`
+	// Mapping maps item fields to file columns. Fields not mapped are read
+	// from the column of the same name, if any.
+	Mapping map[string]string `json:"mapping,omitempty"`
+	// Key is the column holding the supplier's key for a row. Rows are
+	// upserted by key within Source; without a key they are upserted by id
+	// when the file has one and inserted otherwise.
+	Key    string `json:"key,omitempty"`
+	Source string `json:"source,omitempty"`
+	// DryRun validates every row and reports what would change without
+	// committing anything
+	DryRun bool `json:"dry_run,omitempty"`
+	// BatchSize is the number of rows committed per transaction
+	BatchSize int `json:"batch_size,omitempty"`
+}
+
+// RowError reports why a row was skipped. Rows are numbered from 1, not
+// counting a CSV header.
+type RowError struct {
+	Row     int64  `json:"row"`
+	Key     string `json:"key,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// RowChange reports what a dry run would do with a row
+type RowChange struct {
+	Row    int64  `json:"row"`
+	Key    string `json:"key,omitempty"`
+	ID     string `json:"id"`
+	Action string `json:"action"`
+}
+
+// Result counts the rows of an import. Errors and Changes hold at most
+// MaxReportedRows entries each; Truncated is set when some were dropped.
+type Result struct {
+	DryRun    bool        `json:"dry_run"`
+	Rows      int64       `json:"rows"`
+	Created   int64       `json:"created"`
+	Updated   int64       `json:"updated"`
+	Unchanged int64       `json:"unchanged"`
+	Failed    int64       `json:"failed"`
+	Errors    []RowError  `json:"errors,omitempty"`
+	Changes   []RowChange `json:"changes,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// Import is a validated import ready to run
+type Import struct {
+	req     Request
+	rules   money.Rules
+	columns map[string]string
+}
+
+// Prepare validates a request whose items are rounded by rules. Errors are *apierror.Error values.
+func Prepare(req Request, rules money.Rules) (*Import, error) {
+	var violations []apierror.FieldViolation
+	if req.Format != export.CSV && req.Format != export.NDJSON {
+		violations = append(violations, apierror.FieldViolation{Field: "format", Description: "format must be csv or ndjson"})
+	}
+	if req.BatchSize == 0 {
+		req.BatchSize = DefaultBatchSize
+	}
+	if req.BatchSize < 1 || req.BatchSize > MaxBatchSize {
+		violations = append(violations, apierror.FieldViolation{
+			Field: "batch_size", Description: fmt.Sprintf("batch_size must be between 1 and %d", MaxBatchSize)})
+	}
+	if req.Source == "" {
+		req.Source = DefaultSource
+	}
+
+	columns := make(map[string]string, len(ItemFields))
+	for _, f := range ItemFields {
+		columns[f] = f
+	}
+	for field, column := range req.Mapping {
+		if !slices.Contains(ItemFields, field) {
+			violations = append(violations, apierror.FieldViolation{
+				Field: "map", Description: fmt.Sprintf("%q is not one of %s", field, strings.Join(ItemFields, ", "))})
+			continue
+		}
+		if column == "" {
+			violations = append(violations, apierror.FieldViolation{Field: "map", Description: field + " is mapped to an empty column name"})
+			continue
+		}
+		columns[field] = column
+	}
+	if len(violations) > 0 {
+		return nil, apierror.InvalidArgument("invalid import request", violations...)
+	}
+	return &Import{req: req, rules: rules, columns: columns}, nil
+}
+
+// ParseMapping parses comma-separated field=column pairs, such as
+// "name=Product,value=Unit Price", into a Request.Mapping. Empty specs and
+// pairs are skipped, so an unset flag or parameter maps nothing.
+func ParseMapping(specs ...string) (map[string]string, error) {
+	var mapping map[string]string
+	for _, spec := range specs {
+		for _, pair := range strings.Split(spec, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			field, column, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, errors.New("map must be a comma-separated list of field=column")
+			}
+			if mapping == nil {
+				mapping = make(map[string]string)
+			}
+			mapping[strings.TrimSpace(field)] = strings.TrimSpace(column)
+		}
+	}
+	return mapping, nil
+}
+
+// row is a validated row waiting to be written. present holds the
+// keptFields the file has a column for.
+type row struct {
+	num       int64
+	key       string
+	item      models.Item
+	present   map[string]bool
+	createdAt *time.Time
+}
+
+// errDryRun rolls back the batches of a dry run
+var errDryRun = errors.New("dry run")
+
+// Run reads r and writes its rows in batches, calling progress after each
+// batch. Invalid rows are reported in the result and skipped. A file that
+// cannot be read past some point, a failed batch or a cancelled context stop
+// the import with an error; batches committed before it stay committed, and
+// the result counts them.
+func (im *Import) Run(ctx context.Context, db *sql.DB, r io.Reader, progress func(Result)) (*Result, error) {
+	res := &Result{DryRun: im.req.DryRun}
+	rows := newReader(im.req.Format, r)
+
+	if c, ok := rows.(*csvReader); ok {
+		header, err := c.readHeader()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, invalidFile(err)
+		}
+		if err := im.checkHeader(header); err != nil {
+			return res, err
+		}
+	}
+
+	seen := make(map[string]int64)
+	batch := make([]row, 0, im.req.BatchSize)
+	for {
+		rec, err := rows.next()
+		if err != nil && err != io.EOF {
+			return res, invalidFile(fmt.Errorf("after row %d: %w", res.Rows, err))
+		}
+		if err == nil {
+			res.Rows++
+			if rw, rowErrs := im.parse(res.Rows, rec, seen); rowErrs != nil {
+				res.fail(rowErrs)
+			} else {
+				batch = append(batch, rw)
+			}
+		}
+		if len(batch) == im.req.BatchSize || (err == io.EOF && len(batch) > 0) {
+			if err := im.write(ctx, db, batch, res); err != nil {
+				return res, err
+			}
+			batch = batch[:0]
+			if progress != nil {
+				progress(*res)
+			}
+		}
+		if err == io.EOF {
+			return res, nil
+		}
+	}
+}
+
+// checkHeader rejects a CSV file missing a mapped column
+func (im *Import) checkHeader(header []string) error {
+	var violations []apierror.FieldViolation
+	if im.req.Key != "" && !slices.Contains(header, im.req.Key) {
+		violations = append(violations, apierror.FieldViolation{Field: "key", Description: "the file has no column " + strconv.Quote(im.req.Key)})
+	}
+	for _, field := range ItemFields {
+		column, ok := im.req.Mapping[field]
+		if ok && !slices.Contains(header, column) {
+			violations = append(violations, apierror.FieldViolation{
+				Field: "map", Description: fmt.Sprintf("the file has no column %q for %s", column, field)})
+		}
+	}
+	if _, ok := im.req.Mapping["name"]; !ok && !slices.Contains(header, "name") {
+		violations = append(violations, apierror.FieldViolation{Field: "map", Description: "the file has no column for name"})
+	}
+	if len(violations) > 0 {
+		return apierror.InvalidArgument("invalid import file", violations...)
+	}
+	return nil
+}
+
+// parse validates one record, reporting every invalid field. seen holds the
+// row of each key or id so far, so a file cannot write the same item twice.
+func (im *Import) parse(num int64, rec record, seen map[string]int64) (row, []RowError) {
+	rw := row{num: num}
+	if rec.err != nil {
+		return rw, []RowError{{Row: num, Message: rec.err.Error()}}
+	}
+	get := func(field string) string {
+		return strings.TrimSpace(rec.values[im.columns[field]])
+	}
+	rw.present = make(map[string]bool)
+	for _, field := range keptFields {
+		_, rw.present[field] = rec.values[im.columns[field]]
+	}
+
+	var rowErrs []RowError
+	if im.req.Key != "" {
+		rw.key = strings.TrimSpace(rec.values[im.req.Key])
+		if rw.key == "" {
+			rowErrs = append(rowErrs, RowError{Row: num, Field: "key", Message: "key is required"})
+		}
+	}
+	rw.item = models.Item{
+		ID:          get("id"),
+		Name:        get("name"),
+		Description: get("description"),
+		Tags:        items.ParseTags(get("tags")),
+		Category:    get("category"),
+		Currency:    get("currency"),
+	}
+	value := get("value")
+	if value != "" {
+		v, err := money.Parse(value)
+		if err != nil {
+			rowErrs = append(rowErrs, RowError{Row: num, Key: rw.key, Field: "value", Message: "value must be a number"})
+		} else {
+			rw.item.Value = v
+		}
+	}
+	if err := items.Validate(&rw.item, im.rules); err != nil {
+		rowErrs = append(rowErrs, violationErrors(rw, err)...)
+	}
+	if s := get("created_at"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			rowErrs = append(rowErrs, RowError{Row: num, Key: rw.key, Field: "created_at",
+				Message: "created_at must be an RFC 3339 timestamp or a date"})
+		} else {
+			rw.createdAt = &t
+		}
+	}
+	if len(rowErrs) > 0 {
+		return rw, rowErrs
+	}
+
+	identity := ""
+	switch {
+	case rw.key != "":
+		identity = "key " + rw.key
+	case rw.item.ID != "":
+		identity = "id " + rw.item.ID
+	}
+	if identity != "" {
+		if first, ok := seen[identity]; ok {
+			return rw, []RowError{{Row: num, Key: rw.key, Message: fmt.Sprintf("duplicate %s, first seen on row %d", identity, first)}}
+		}
+		seen[identity] = num
+	}
+	return rw, nil
+}
+
+// parseTime accepts RFC 3339 timestamps and plain dates in UTC
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// write applies a batch in one transaction. Counts are only added to res
+// once the transaction has committed, since WithTx may run fn again.
+func (im *Import) write(ctx context.Context, db *sql.DB, batch []row, res *Result) error {
+	var changes []RowChange
+	var failed [][]RowError
+	err := database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		changes, failed = changes[:0], failed[:0]
+		for _, rw := range batch {
+			change, rowErrs, err := im.apply(ctx, tx, rw)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", rw.num, err)
+			}
+			if rowErrs != nil {
+				failed = append(failed, rowErrs)
+			} else {
+				changes = append(changes, change)
+			}
+		}
+		if im.req.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return apierror.Internal(err, "importing items")
+	}
+
+	for _, rowErrs := range failed {
+		res.fail(rowErrs)
+	}
+	for _, c := range changes {
+		switch c.Action {
+		case ActionCreate:
+			res.Created++
+		case ActionUpdate:
+			res.Updated++
+		default:
+			res.Unchanged++
+		}
+		if im.req.DryRun {
+			if len(res.Changes) < MaxReportedRows {
+				res.Changes = append(res.Changes, c)
+			} else {
+				res.Truncated = true
+			}
+		}
+	}
+	return nil
+}
+
+// apply creates or updates the item of one row
+func (im *Import) apply(ctx context.Context, tx *sql.Tx, rw row) (RowChange, []RowError, error) {
+	change := RowChange{Row: rw.num, Key: rw.key, ID: rw.item.ID}
+
+	// Find the item the row refers to
+	id := rw.item.ID
+	mapped := false
+	if rw.key != "" {
+		var itemID string
+		err := tx.QueryRowContext(ctx, "SELECT item_id FROM import_keys WHERE source = ? AND external_key = ?",
+			im.req.Source, rw.key).Scan(&itemID)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return change, nil, err
+		case rw.item.ID != "" && rw.item.ID != itemID:
+			return change, []RowError{{Row: rw.num, Key: rw.key, Field: "id",
+				Message: fmt.Sprintf("key was imported as item %s, not %s", itemID, rw.item.ID)}}, nil
+		default:
+			id, mapped = itemID, true
+		}
+	}
+
+	item := rw.item
+	item.ID = id
+	var stored *models.Item
+	if id != "" {
+		var err error
+		stored, err = items.Get(ctx, tx, im.rules, id)
+		var apiErr *apierror.Error
+		if errors.As(err, &apiErr) && apiErr.Code == codes.NotFound {
+			stored, err = nil, nil
+		}
+		if err != nil {
+			return change, nil, err
+		}
+	}
+
+	if stored == nil {
+		if item.ID == "" {
+			item.ID = uuid.New().String()
+		}
+		item.CreatedAt = time.Now()
+		if rw.createdAt != nil {
+			item.CreatedAt = *rw.createdAt
+		}
+		item.UpdatedAt = time.Now()
+		if rowErrs, err := checkSchema(ctx, tx, rw, &item); rowErrs != nil || err != nil {
+			return change, rowErrs, err
+		}
+		if err := items.Insert(ctx, tx, &item); err != nil {
+			return change, nil, err
+		}
+		if err := im.mapKey(ctx, tx, rw.key, item.ID); err != nil {
+			return change, nil, err
+		}
+		if err := webhook.Record(ctx, tx, webhook.ItemCreated, item); err != nil {
+			return change, nil, err
+		}
+		if err := outbox.Record(ctx, tx, webhook.ItemCreated, item); err != nil {
+			return change, nil, err
+		}
+		change.ID, change.Action = item.ID, ActionCreate
+		return change, nil, nil
+	}
+
+	// The row names an existing item by id; remember its key for next time
+	if rw.key != "" && !mapped {
+		if err := im.mapKey(ctx, tx, rw.key, id); err != nil {
+			return change, nil, err
+		}
+	}
+	change.ID = id
+	if !rw.present["description"] {
+		item.Description = stored.Description
+	}
+	if !rw.present["tags"] {
+		item.Tags = stored.Tags
+	}
+	if !rw.present["category"] {
+		item.Category = stored.Category
+	}
+	if !rw.present["currency"] {
+		item.Currency = stored.Currency
+	}
+	// Files have no metadata or parent column
+	item.Metadata = stored.Metadata
+	item.ParentID = stored.ParentID
+	item.CreatedAt = stored.CreatedAt
+	if rw.createdAt != nil {
+		item.CreatedAt = *rw.createdAt
+	}
+	if item.Name == stored.Name && item.Value.Equal(stored.Value) && item.Description == stored.Description &&
+		slices.Equal(item.Tags, stored.Tags) && item.Category == stored.Category &&
+		item.Currency == stored.Currency && item.CreatedAt.Equal(stored.CreatedAt) {
+		change.Action = ActionUnchanged
+		return change, nil, nil
+	}
+	item.UpdatedAt = time.Now()
+	if rowErrs, err := checkSchema(ctx, tx, rw, &item); rowErrs != nil || err != nil {
+		return change, rowErrs, err
+	}
+	if err := items.Replace(ctx, tx, &item); err != nil {
+		return change, nil, err
+	}
+	if err := webhook.Record(ctx, tx, webhook.ItemUpdated, item); err != nil {
+		return change, nil, err
+	}
+	if err := outbox.Record(ctx, tx, webhook.ItemUpdated, item); err != nil {
+		return change, nil, err
+	}
+	change.Action = ActionUpdate
+	return change, nil, nil
+}
+
+// checkSchema checks the item a row writes against the active schema,
+// reporting every violation
+func checkSchema(ctx context.Context, tx *sql.Tx, rw row, item *models.Item) ([]RowError, error) {
+	err := items.CheckSchema(ctx, tx, item)
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) && apiErr.Code == codes.InvalidArgument && len(apiErr.Violations) > 0 {
+		return violationErrors(rw, err), nil
+	}
+	return nil, err
+}
+
+// violationErrors reports each field violation of an apierror as an error
+// of the row
+func violationErrors(rw row, err error) []RowError {
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) || len(apiErr.Violations) == 0 {
+		return []RowError{{Row: rw.num, Key: rw.key, Message: err.Error()}}
+	}
+	rowErrs := make([]RowError, 0, len(apiErr.Violations))
+	for _, v := range apiErr.Violations {
+		rowErrs = append(rowErrs, RowError{Row: rw.num, Key: rw.key, Field: v.Field, Message: v.Description})
+	}
+	return rowErrs
+}
+
+// mapKey records the item imported for a key
+func (im *Import) mapKey(ctx context.Context, tx *sql.Tx, key, id string) error {
+	if key == "" {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO import_keys (source, external_key, item_id) VALUES (?, ?, ?)",
+		im.req.Source, key, id)
+	return err
+}
+
+// fail records a skipped row and its errors
+func (res *Result) fail(rowErrs []RowError) {
+	res.Failed++
+	for _, e := range rowErrs {
+		if len(res.Errors) < MaxReportedRows {
+			res.Errors = append(res.Errors, e)
+		} else {
+			res.Truncated = true
+		}
+	}
+}
+
+// invalidFile reports a file that could not be read
+func invalidFile(err error) error {
+	return apierror.InvalidArgument("invalid import file", apierror.FieldViolation{Field: "file", Description: err.Error()})
+}
@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/angel/go-api-sqlite/internal/jobs"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// JobKind is the kind of the jobs that run imports
+const JobKind = "import"
+
+// jobPayload is stored with an import job. File is the spooled upload of a
+// background import; it belongs to the job, which removes it when done.
+type jobPayload struct {
+	Request Request `json:"request"`
+	File    string  `json:"file,omitempty"`
+}
+
+// RegisterJobs lets q run background imports into db. Imports are attempted
+// once, since a retry would repeat the batches committed before a failure.
+func RegisterJobs(q *jobs.Queue, db *sql.DB, rules money.Rules) {
+	q.Register(JobKind, jobs.Kind{
+		MaxAttempts: 1,
+		Handler: func(ctx context.Context, t *jobs.Task) (any, error) {
+			var p jobPayload
+			if err := t.Decode(&p); err != nil {
+				return nil, jobs.Permanent(err)
+			}
+			defer os.Remove(p.File)
+			im, err := Prepare(p.Request, rules)
+			if err != nil {
+				return nil, jobs.Permanent(err)
+			}
+			f, err := os.Open(p.File)
+			if err != nil {
+				return nil, jobs.Permanent(err)
+			}
+			defer f.Close()
+			return im.runTask(ctx, t, db, f)
+		},
+		Discard: func(job *jobs.Job) {
+			var p jobPayload
+			if json.Unmarshal(job.Payload(), &p) == nil && p.File != "" {
+				os.Remove(p.File)
+			}
+		},
+	})
+}
+
+// Enqueue queues a background import of the file at path, which the job
+// takes over. q must have the import kind registered.
+func (im *Import) Enqueue(ctx context.Context, q *jobs.Queue, path string) (*jobs.Job, error) {
+	return q.Enqueue(ctx, JobKind, jobPayload{Request: im.req, File: path})
+}
+
+// RunJob imports r in the calling goroutine as a job of q, so its status
+// and report can be fetched like those of a background import. It returns
+// the final job, the result and the error that stopped the import, if any.
+func (im *Import) RunJob(ctx context.Context, q *jobs.Queue, db *sql.DB, r io.Reader) (*jobs.Job, *Result, error) {
+	res := &Result{DryRun: im.req.DryRun}
+	job, err := q.Do(ctx, JobKind, jobPayload{Request: im.req}, func(ctx context.Context, t *jobs.Task) (any, error) {
+		var err error
+		res, err = im.runTask(ctx, t, db, r)
+		return res, err
+	})
+	return job, res, err
+}
+
+// runTask runs the import, reporting the rows read so far as progress
+func (im *Import) runTask(ctx context.Context, t *jobs.Task, db *sql.DB, r io.Reader) (*Result, error) {
+	return im.Run(ctx, db, r, func(res Result) { t.Progress(res.Rows, 0) })
+}
+
+// JobResult returns the result stored with an import job, which is empty
+// until the job has finished
+func JobResult(job *jobs.Job) (*Result, error) {
+	var res Result
+	if len(job.Result) == 0 {
+		return &res, nil
+	}
+	if err := json.Unmarshal(job.Result, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// WriteReport writes the row errors of a result as CSV
+func (res *Result) WriteReport(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"row", "key", "field", "message"})
+	for _, e := range res.Errors {
+		c.Write([]string{strconv.FormatInt(e.Row, 10), e.Key, e.Field, e.Message})
+	}
+	c.Flush()
+	return c.Error()
+}
@@ -0,0 +1,148 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/angel/go-api-sqlite/internal/export"
+)
+
+// MaxLineBytes is the longest NDJSON line accepted
+const MaxLineBytes = 1 << 20
+
+// record is one row of a file keyed by column name. err is set when the row
+// could not be parsed; the reader moves on to the next row.
+type record struct {
+	values map[string]string
+	err    error
+}
+
+// rowReader reads the rows of a file one at a time. next returns io.EOF
+// after the last row.
+type rowReader interface {
+	next() (record, error)
+}
+
+// newReader returns the reader for format
+func newReader(format export.Format, r io.Reader) rowReader {
+	if format == export.NDJSON {
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 64*1024), MaxLineBytes)
+		return &ndjsonReader{s: s}
+	}
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = -1
+	c.ReuseRecord = true
+	return &csvReader{r: c}
+}
+
+// csvReader reads CSV with a header row naming the columns
+type csvReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+// readHeader reads the header row once. It returns io.EOF for an empty file.
+func (c *csvReader) readHeader() ([]string, error) {
+	if c.header != nil {
+		return c.header, nil
+	}
+	header, err := c.r.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	c.header = append([]string(nil), header...)
+	// Tolerate a UTF-8 byte order mark written by spreadsheets
+	c.header[0] = strings.TrimPrefix(c.header[0], "\ufeff")
+	return c.header, nil
+}
+
+func (c *csvReader) next() (record, error) {
+	if _, err := c.readHeader(); err != nil {
+		return record{}, err
+	}
+
+	fields, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			// The reader cannot resynchronize after a quoting error
+			return record{}, fmt.Errorf("line %d: %w", parseErr.Line, parseErr.Err)
+		}
+		return record{}, err
+	}
+	if len(fields) != len(c.header) {
+		return record{err: fmt.Errorf("row has %d columns, header has %d", len(fields), len(c.header))}, nil
+	}
+	values := make(map[string]string, len(fields))
+	for i, f := range fields {
+		values[c.header[i]] = f
+	}
+	return record{values: values}, nil
+}
+
+// ndjsonReader reads one JSON object per line. Blank lines are skipped.
+type ndjsonReader struct {
+	s *bufio.Scanner
+}
+
+func (n *ndjsonReader) next() (record, error) {
+	for n.s.Scan() {
+		line := bytes.TrimSpace(n.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return parseObject(line), nil
+	}
+	if err := n.s.Err(); err != nil {
+		return record{}, err
+	}
+	return record{}, io.EOF
+}
+
+// parseObject converts a JSON object with string, number or null members
+// into a record. An array of strings, such as tags, is joined with commas.
+func parseObject(line []byte) record {
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+	var obj map[string]any
+	if err := d.Decode(&obj); err != nil {
+		return record{err: fmt.Errorf("invalid JSON: %v", err)}
+	}
+	if obj == nil {
+		return record{err: errors.New("row must be a JSON object")}
+	}
+	values := make(map[string]string, len(obj))
+	for k, v := range obj {
+		switch v := v.(type) {
+		case nil:
+			values[k] = ""
+		case string:
+			values[k] = v
+		case json.Number:
+			values[k] = v.String()
+		case []any:
+			list := make([]string, len(v))
+			for i, e := range v {
+				s, ok := e.(string)
+				if !ok {
+					return record{err: fmt.Errorf("%s must be an array of strings", k)}
+				}
+				list[i] = s
+			}
+			values[k] = strings.Join(list, ",")
+		default:
+			return record{err: fmt.Errorf("%s must be a string or a number", k)}
+		}
+	}
+	return record{values: values}
+}
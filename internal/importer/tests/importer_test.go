@@ -0,0 +1,396 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/export"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/importer"
+	"github.com/angel/go-api-sqlite/internal/jobs"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "data.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func run(t *testing.T, db *sql.DB, req importer.Request, file string) *importer.Result {
+	t.Helper()
+	im, err := importer.Prepare(req, money.Rules{})
+	require.NoError(t, err)
+	res, err := im.Run(context.Background(), db, strings.NewReader(file), nil)
+	require.NoError(t, err)
+	return res
+}
+
+func items(t *testing.T, db *sql.DB) map[string]float64 {
+	t.Helper()
+	rows, err := db.Query("SELECT name, value_units / 10000.0 FROM items")
+	require.NoError(t, err)
+	defer rows.Close()
+	values := make(map[string]float64)
+	for rows.Next() {
+		var name string
+		var value float64
+		require.NoError(t, rows.Scan(&name, &value))
+		values[name] = value
+	}
+	return values
+}
+
+const supplierCSV = `SKU,Product,Price
+A-1,Widget,9.5
+A-2,Gadget,not a number
+A-3,,3
+A-1,Widget again,1
+A-4,Gizmo,4
+`
+
+func TestUpsertByKeyWithMapping(t *testing.T) {
+	db := openTestDB(t)
+	req := importer.Request{
+		Format:    export.CSV,
+		Mapping:   map[string]string{"name": "Product", "value": "Price"},
+		Key:       "SKU",
+		Source:    "acme",
+		BatchSize: 2,
+	}
+
+	res := run(t, db, req, supplierCSV)
+	assert.Equal(t, int64(5), res.Rows)
+	assert.Equal(t, int64(2), res.Created)
+	assert.Equal(t, int64(3), res.Failed)
+	require.Len(t, res.Errors, 3)
+	assert.Equal(t, importer.RowError{Row: 2, Key: "A-2", Field: "value", Message: "value must be a number"}, res.Errors[0])
+	assert.Equal(t, importer.RowError{Row: 3, Key: "A-3", Field: "name", Message: "name is required"}, res.Errors[1])
+	assert.Equal(t, "duplicate key A-1, first seen on row 1", res.Errors[2].Message)
+	assert.Equal(t, map[string]float64{"Widget": 9.5, "Gizmo": 4}, items(t, db))
+
+	// A second import of the same keys updates the items it created
+	res = run(t, db, req, "SKU,Product,Price\nA-1,Widget,12\nA-4,Gizmo,4\nA-5,Doohickey,5\n")
+	assert.Equal(t, int64(1), res.Created)
+	assert.Equal(t, int64(1), res.Updated)
+	assert.Equal(t, int64(1), res.Unchanged)
+	assert.Equal(t, map[string]float64{"Widget": 12, "Gizmo": 4, "Doohickey": 5}, items(t, db))
+
+	// Keys are scoped to their source
+	res = run(t, db, importer.Request{Format: export.CSV, Mapping: req.Mapping, Key: "SKU", Source: "other"},
+		"SKU,Product,Price\nA-1,Widget,1\n")
+	assert.Equal(t, int64(1), res.Created)
+}
+
+func TestDryRun(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec("INSERT INTO items (id, name, value_units) VALUES ('existing', 'Old', 10000)")
+	require.NoError(t, err)
+
+	res := run(t, db, importer.Request{Format: export.NDJSON, DryRun: true},
+		`{"id": "existing", "name": "New", "value": 2}
+{"name": "Fresh", "value": 3.5, "created_at": "2026-03-30"}
+
+{"name": "Broken", "value": true}
+not json
+`)
+	assert.True(t, res.DryRun)
+	assert.Equal(t, int64(4), res.Rows)
+	assert.Equal(t, int64(1), res.Updated)
+	assert.Equal(t, int64(1), res.Created)
+	assert.Equal(t, int64(2), res.Failed)
+	require.Len(t, res.Changes, 2)
+	assert.Equal(t, importer.RowChange{Row: 1, ID: "existing", Action: importer.ActionUpdate}, res.Changes[0])
+	assert.Equal(t, importer.ActionCreate, res.Changes[1].Action)
+	assert.Equal(t, "value must be a string or a number", res.Errors[0].Message)
+
+	// Nothing was written
+	assert.Equal(t, map[string]float64{"Old": 1}, items(t, db))
+}
+
+func TestItemDetails(t *testing.T) {
+	db := openTestDB(t)
+	details := func(name string) []string {
+		t.Helper()
+		var description, tags, category, currency string
+		require.NoError(t, db.QueryRow(`SELECT description, category, currency,
+			coalesce((SELECT group_concat(t.name, ',' ORDER BY t.name) FROM item_tags it JOIN tags t ON t.id = it.tag_id
+				WHERE it.item_id = items.id), '')
+			FROM items WHERE name = ?`, name).Scan(&description, &category, &currency, &tags))
+		return []string{description, tags, category, currency}
+	}
+
+	res := run(t, db, importer.Request{Format: export.CSV, Key: "sku"},
+		"sku,name,description,tags,category,currency\n"+
+			"A-1,Widget,Small widget,\"Sale, blue\",tools,eur\n"+
+			"A-2,Gadget,,,,dollars\n")
+	assert.Equal(t, int64(1), res.Created)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "currency", res.Errors[0].Field)
+	assert.Equal(t, []string{"Small widget", "blue,sale", "tools", "EUR"}, details("Widget"))
+
+	// Tags may be a JSON array, and fields without a column keep their value
+	res = run(t, db, importer.Request{Format: export.NDJSON, Key: "sku"},
+		`{"sku": "A-1", "name": "Widget", "tags": ["blue", "new"]}`+"\n")
+	assert.Equal(t, int64(1), res.Updated)
+	assert.Equal(t, []string{"Small widget", "blue,new", "tools", "EUR"}, details("Widget"))
+
+	res = run(t, db, importer.Request{Format: export.NDJSON, Key: "sku"},
+		`{"sku": "A-1", "name": "Widget", "tags": ["New", "blue"]}`+"\n"+
+			`{"sku": "A-3", "name": "Gizmo", "tags": [1]}`+"\n")
+	assert.Equal(t, int64(1), res.Unchanged)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "tags must be an array of strings", res.Errors[0].Message)
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	src, dst := openTestDB(t), openTestDB(t)
+	_, err := src.Exec(`INSERT INTO items (id, name, value_units, description) VALUES
+		('a', '-Widget', 95000, '=1+1'), ('b', '@home', 10000, '+note');
+		INSERT INTO tags (name) VALUES ('-sale');
+		INSERT INTO item_tags (item_id, tag_id) SELECT 'a', id FROM tags`)
+	require.NoError(t, err)
+
+	e, err := export.Prepare(export.Request{Format: export.CSV, Columns: []string{"id", "name", "value", "description", "tags"}})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = e.Write(context.Background(), src, &buf)
+	require.NoError(t, err)
+
+	res := run(t, dst, importer.Request{Format: export.CSV, Key: "id"}, buf.String())
+	assert.Equal(t, int64(2), res.Created)
+	assert.Empty(t, res.Errors)
+	assert.Equal(t, map[string]float64{"-Widget": 9.5, "@home": 1}, items(t, dst))
+	var description, tag string
+	require.NoError(t, dst.QueryRow(`SELECT i.description, t.name FROM items i
+		JOIN item_tags it ON it.item_id = i.id JOIN tags t ON t.id = it.tag_id`).Scan(&description, &tag))
+	assert.Equal(t, "=1+1", description)
+	assert.Equal(t, "-sale", tag)
+}
+
+func TestActiveSchema(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec(`INSERT INTO item_schemas (schema, active, activated_at)
+		VALUES ('{"properties": {"value": {"maximum": 100}}}', 1, CURRENT_TIMESTAMP)`)
+	require.NoError(t, err)
+
+	res := run(t, db, importer.Request{Format: export.CSV, Key: "sku"},
+		"sku,name,value\nA-1,Widget,99\nA-2,Gadget,250\n")
+	assert.Equal(t, int64(1), res.Created)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, importer.RowError{Row: 2, Key: "A-2", Field: "value", Message: "value must be at most 100"}, res.Errors[0])
+
+	// Updates are checked too
+	res = run(t, db, importer.Request{Format: export.CSV, Key: "sku"}, "sku,name,value\nA-1,Widget,101\n")
+	assert.Equal(t, int64(1), res.Failed)
+	assert.Equal(t, map[string]float64{"Widget": 99}, items(t, db))
+}
+
+func TestEveryViolationIsReported(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec(`INSERT INTO item_schemas (schema, active, activated_at)
+		VALUES ('{"properties": {"value": {"maximum": 100}, "category": {"enum": ["tools"]}}}', 1, CURRENT_TIMESTAMP)`)
+	require.NoError(t, err)
+
+	res := run(t, db, importer.Request{Format: export.CSV, Key: "sku"},
+		"sku,name,value,currency,created_at,category\nA-1,,abc,XYZ,yesterday,tools\nA-2,Gadget,250,,,toys\n")
+	assert.Equal(t, int64(2), res.Failed)
+	assert.Equal(t, []importer.RowError{
+		{Row: 1, Key: "A-1", Field: "value", Message: "value must be a number"},
+		{Row: 1, Key: "A-1", Field: "name", Message: "name is required"},
+		{Row: 1, Key: "A-1", Field: "currency", Message: "currency must be an ISO 4217 code such as USD or EUR"},
+		{Row: 1, Key: "A-1", Field: "created_at", Message: "created_at must be an RFC 3339 timestamp or a date"},
+		{Row: 2, Key: "A-2", Field: "category", Message: `category must be one of "tools"`},
+		{Row: 2, Key: "A-2", Field: "value", Message: "value must be at most 100"},
+	}, res.Errors)
+}
+
+func TestInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   importer.Request
+		field string
+	}{
+		{"format", importer.Request{Format: export.XLSX}, "format"},
+		{"batch size", importer.Request{Format: export.CSV, BatchSize: importer.MaxBatchSize + 1}, "batch_size"},
+		{"mapping", importer.Request{Format: export.CSV, Mapping: map[string]string{"price": "Price"}}, "map"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := importer.Prepare(tt.req, money.Rules{})
+			var apiErr *apierror.Error
+			require.True(t, errors.As(err, &apiErr), "got %v", err)
+			assert.Equal(t, codes.InvalidArgument, apiErr.Code)
+			require.Len(t, apiErr.Violations, 1)
+			assert.Equal(t, tt.field, apiErr.Violations[0].Field)
+		})
+	}
+
+	// Mapped columns must exist in the header
+	db := openTestDB(t)
+	im, err := importer.Prepare(importer.Request{Format: export.CSV, Key: "SKU"}, money.Rules{})
+	require.NoError(t, err)
+	_, err = im.Run(context.Background(), db, strings.NewReader("Product,Price\n"), nil)
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr), "got %v", err)
+	assert.Len(t, apiErr.Violations, 2)
+}
+
+func TestParseMapping(t *testing.T) {
+	for _, specs := range [][]string{nil, {""}, {" "}, {"", ","}} {
+		m, err := importer.ParseMapping(specs...)
+		require.NoError(t, err, specs)
+		assert.Nil(t, m, specs)
+	}
+	m, err := importer.ParseMapping("name=Product,", " value = Unit Price", "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "Product", "value": "Unit Price"}, m)
+	_, err = importer.ParseMapping("name")
+	assert.Error(t, err)
+}
+
+func newRouter(t *testing.T, db *sql.DB, asyncThreshold int64) *mux.Router {
+	q := jobs.New(db, jobs.Config{PollInterval: 10 * time.Millisecond})
+	importer.RegisterJobs(q, db, money.Rules{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	ih := handlers.NewImportHandler(db, money.Rules{}, q, t.TempDir(), asyncThreshold)
+	jh := handlers.NewJobHandler(q)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/items/import", ih.ImportItems).Methods("POST")
+	router.HandleFunc("/api/items/import/{id}/report", ih.GetImportReport).Methods("GET")
+	router.HandleFunc("/api/jobs/{id}", jh.GetJob).Methods("GET")
+	return router
+}
+
+type status struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	State     jobs.State      `json:"state"`
+	Result    importer.Result `json:"result"`
+	StatusURL string          `json:"status_url"`
+	ReportURL string          `json:"report_url"`
+}
+
+func TestImportEndpoint(t *testing.T) {
+	db := openTestDB(t)
+	router := newRouter(t, db, 0)
+
+	// Multipart upload with the format taken from the file name
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", "supplier.csv")
+	require.NoError(t, err)
+	part.Write([]byte(supplierCSV))
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest("POST", "/api/items/import?key=SKU&map=name=Product,value=Price", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var st status
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&st))
+	assert.Equal(t, jobs.Succeeded, st.State)
+	assert.Equal(t, importer.JobKind, st.Kind)
+	assert.Equal(t, int64(2), st.Result.Created)
+	assert.Equal(t, int64(3), st.Result.Failed)
+	assert.Equal(t, "/api/items/import/"+st.ID+"/report", st.ReportURL)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", st.ReportURL, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	report, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, report, 4)
+	assert.Equal(t, []string{"row", "key", "field", "message"}, report[0])
+	assert.Equal(t, []string{"2", "A-2", "value", "value must be a number"}, report[1])
+
+	// An empty map parameter maps nothing
+	req = httptest.NewRequest("POST", "/api/items/import?map=", strings.NewReader("name,value\nPlain,1\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&st))
+	assert.Equal(t, int64(1), st.Result.Created)
+
+	// A file that cannot be read is a problem, not a job
+	req = httptest.NewRequest("POST", "/api/items/import?map=name=Product", strings.NewReader("Name\nx\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/items/import/missing/report", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestBackgroundImport(t *testing.T) {
+	db := openTestDB(t)
+	router := newRouter(t, db, 16)
+
+	// Larger than the threshold, so it runs in the background
+	req := httptest.NewRequest("POST", "/api/items/import", strings.NewReader(`{"name":"a","value":1}
+{"name":"b","value":2}
+`))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+
+	var st status
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&st))
+	assert.Equal(t, st.StatusURL, rr.Header().Get("Location"))
+
+	require.Eventually(t, func() bool {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", st.StatusURL, nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&st))
+		return st.State.Final()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, jobs.Succeeded, st.State)
+	assert.Equal(t, int64(2), st.Result.Created)
+	assert.Equal(t, map[string]float64{"a": 1, "b": 2}, items(t, db))
+}
@@ -0,0 +1,300 @@
+// Package items reads and writes items and their tags. Writes take a
+// transaction so callers can record events in the same one.
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/filter"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// Columns are the columns of items read by Scan, in order
+var Columns = "items.id, items.name, items.value_units, items.description, " + filter.ItemFields["tags"].Column +
+	", items.category, items.currency, items.metadata, items.parent_id, items.created_at, items.updated_at"
+
+// Scanner is a *sql.Row or *sql.Rows
+type Scanner interface {
+	Scan(dest ...any) error
+}
+
+// Scan reads an item selected with Columns, writing its value the way rules
+// round it, and any columns selected after them into extra
+func Scan(row Scanner, rules money.Rules, item *models.Item, extra ...any) error {
+	var units int64
+	var tags sql.NullString
+	var metadata string
+	var parentID sql.NullString
+	dest := []any{&item.ID, &item.Name, &units, &item.Description, &tags,
+		&item.Category, &item.Currency, &metadata, &parentID, &item.CreatedAt, &item.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	item.Metadata = map[string]any{}
+	if err := json.Unmarshal([]byte(metadata), &item.Metadata); err != nil {
+		return err
+	}
+	item.ParentID = parentID.String
+	item.Value = rules.Trim(money.FromUnits(units), item.Currency)
+	item.Tags = []string{}
+	if tags.String != "" {
+		item.Tags = strings.Split(tags.String, ",")
+	}
+	return nil
+}
+
+// Querier is a *sql.DB or *sql.Tx
+type Querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Get reads one item. A missing item is an apierror NotFound.
+func Get(ctx context.Context, q Querier, rules money.Rules, id string) (*models.Item, error) {
+	var item models.Item
+	err := Scan(q.QueryRowContext(ctx, "SELECT "+Columns+" FROM items WHERE items.id = ?", id), rules, &item)
+	if err == sql.ErrNoRows {
+		return nil, apierror.NotFound("item", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ListRequest selects the items to list
+type ListRequest struct {
+	// Filter is an AIP-160 style filter, see package filter
+	Filter string
+	// Tags the items must all have
+	Tags []string
+	// Category the items must be in, when not empty
+	Category string
+	// Metadata values the items must have, keyed by dotted path
+	Metadata map[string]string
+}
+
+// Where returns the condition on the items table selecting the items that
+// match req, and its arguments. Errors are *apierror.Error values.
+func (req ListRequest) Where(ctx context.Context, q Querier) (string, []any, error) {
+	where, err := filter.ForItems(req.Filter)
+	if err != nil {
+		return "", nil, err
+	}
+	cond := where.SQL
+	args := where.Args
+	for _, tag := range req.Tags {
+		cond += " AND " + filter.ItemFields["tags"].Has
+		args = append(args, tag)
+	}
+	if req.Category != "" {
+		cond += " AND items.category = ?"
+		args = append(args, req.Category)
+	}
+	meta, metaArgs, err := metadataConditions(ctx, q, req.Metadata)
+	if err != nil {
+		return "", nil, err
+	}
+	return cond + meta, append(args, metaArgs...), nil
+}
+
+// List reads the items matching req. Errors are *apierror.Error values.
+func List(ctx context.Context, db *sql.DB, rules money.Rules, req ListRequest) ([]models.Item, error) {
+	where, args, err := req.Where(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT "+Columns+" FROM items WHERE "+where, args...)
+	if err != nil {
+		return nil, apierror.Internal(err, "querying items")
+	}
+	defer rows.Close()
+
+	list := make([]models.Item, 0)
+	for rows.Next() {
+		var item models.Item
+		if err := Scan(rows, rules, &item); err != nil {
+			return nil, apierror.Internal(err, "scanning item row")
+		}
+		list = append(list, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "querying items")
+	}
+	return list, nil
+}
+
+// Insert adds a validated item. The caller sets ID and CreatedAt; UpdatedAt
+// defaults to CreatedAt. A parent that does not exist is an apierror
+// InvalidArgument.
+func Insert(ctx context.Context, tx *sql.Tx, item *models.Item) error {
+	if item.UpdatedAt.IsZero() {
+		item.UpdatedAt = item.CreatedAt
+	}
+	if err := CheckParent(ctx, tx, item); err != nil {
+		return err
+	}
+	units, metadata, err := columnValues(item)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO items
+		(id, name, value_units, description, category, currency, metadata, parent_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		item.ID, item.Name, units, item.Description, item.Category, item.Currency, metadata,
+		nullString(item.ParentID), item.CreatedAt, item.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return setTags(ctx, tx, item.ID, item.Tags)
+}
+
+// Update replaces the fields of a validated item other than its timestamps,
+// sets UpdatedAt to now and reads the stored item back into item. A missing
+// item is an apierror NotFound; a missing parent or one that would make a
+// cycle is an apierror InvalidArgument.
+func Update(ctx context.Context, tx *sql.Tx, rules money.Rules, item *models.Item) error {
+	if err := CheckParent(ctx, tx, item); err != nil {
+		return err
+	}
+	units, metadata, err := columnValues(item)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `UPDATE items
+		SET name = ?, value_units = ?, description = ?, category = ?, currency = ?, metadata = ?, parent_id = ?,
+			updated_at = ?
+		WHERE id = ?`,
+		item.Name, units, item.Description, item.Category, item.Currency, metadata, nullString(item.ParentID),
+		time.Now(), item.ID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return apierror.NotFound("item", item.ID)
+	}
+	if err := setTags(ctx, tx, item.ID, item.Tags); err != nil {
+		return err
+	}
+	stored, err := Get(ctx, tx, rules, item.ID)
+	if err != nil {
+		return err
+	}
+	*item = *stored
+	return nil
+}
+
+// Replace writes an item exactly as given, timestamps included, whether or
+// not it exists. Imports and replicas use it; the parent is not checked, as
+// replicas may apply a child before its parent's changes.
+func Replace(ctx context.Context, tx *sql.Tx, item *models.Item) error {
+	units, metadata, err := columnValues(item)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO items
+		(id, name, value_units, description, category, currency, metadata, parent_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, value_units = excluded.value_units,
+			description = excluded.description, category = excluded.category, currency = excluded.currency,
+			metadata = excluded.metadata, parent_id = excluded.parent_id, created_at = excluded.created_at,
+			updated_at = excluded.updated_at`,
+		item.ID, item.Name, units, item.Description, item.Category, item.Currency, metadata,
+		nullString(item.ParentID), item.CreatedAt, item.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return setTags(ctx, tx, item.ID, item.Tags)
+}
+
+// Delete removes an item and returns it as it was. Its children become roots
+// and are returned as they are now, so callers can record their update. A
+// missing item is an apierror NotFound.
+func Delete(ctx context.Context, tx *sql.Tx, rules money.Rules, id string) (*models.Item, []*models.Item, error) {
+	item, err := Get(ctx, tx, rules, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM items WHERE parent_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, nil, err
+	}
+	var childIDs []string
+	for rows.Next() {
+		var childID string
+		if err := rows.Scan(&childID); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		childIDs = append(childIDs, childID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(childIDs) > 0 {
+		_, err := tx.ExecContext(ctx, "UPDATE items SET parent_id = NULL, updated_at = ? WHERE parent_id = ?", time.Now(), id)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM items WHERE id = ?", id); err != nil {
+		return nil, nil, err
+	}
+	children := make([]*models.Item, 0, len(childIDs))
+	for _, childID := range childIDs {
+		child, err := Get(ctx, tx, rules, childID)
+		if err != nil {
+			return nil, nil, err
+		}
+		children = append(children, child)
+	}
+	return item, children, nil
+}
+
+// columnValues returns the value and metadata of an item as they are stored
+func columnValues(item *models.Item) (int64, string, error) {
+	units, err := item.Value.Units()
+	if err != nil {
+		return 0, "", err
+	}
+	if item.Metadata == nil {
+		return units, "{}", nil
+	}
+	metadata, err := json.Marshal(item.Metadata)
+	return units, string(metadata), err
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// setTags replaces the tags of an item
+func setTags(ctx context.Context, tx *sql.Tx, id string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM item_tags WHERE item_id = ?", id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING", tag); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO item_tags (item_id, tag_id) SELECT ?, id FROM tags WHERE name = ?", id, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Limits on item metadata
+const (
+	// MaxMetadataSize is the largest metadata object in bytes of JSON
+	MaxMetadataSize = 16 << 10
+	// MaxMetadataDepth is how deeply objects may nest within metadata
+	MaxMetadataDepth = 8
+	// MaxMetadataIndexes is how many metadata keys may be indexed at once
+	MaxMetadataIndexes = 32
+)
+
+// metadataKey is one key of a metadata object, and one segment of a path.
+// Keys are safe to write into JSON paths and SQL unquoted.
+var metadataKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]{0,63}$`)
+
+// MetadataIndex is a metadata path stored in a generated column of items
+type MetadataIndex struct {
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+	column    string
+}
+
+// validateMetadata returns why metadata is invalid, or "" if it is not
+func validateMetadata(metadata map[string]any) string {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "metadata must be a JSON object"
+	}
+	if len(data) > MaxMetadataSize {
+		return fmt.Sprintf("metadata must be at most %d bytes of JSON", MaxMetadataSize)
+	}
+	return validateObject(metadata, 1)
+}
+
+func validateObject(object map[string]any, depth int) string {
+	if depth > MaxMetadataDepth {
+		return fmt.Sprintf("metadata must nest at most %d objects deep", MaxMetadataDepth)
+	}
+	for key, value := range object {
+		if !metadataKey.MatchString(key) {
+			return fmt.Sprintf("metadata key %q must start with a letter or _ and have at most 64 letters, digits, _ or -", key)
+		}
+		if err := validateValue(value, depth); err != "" {
+			return err
+		}
+	}
+	return ""
+}
+
+func validateValue(value any, depth int) string {
+	switch v := value.(type) {
+	case map[string]any:
+		return validateObject(v, depth+1)
+	case []any:
+		for _, e := range v {
+			if err := validateValue(e, depth); err != "" {
+				return err
+			}
+		}
+	}
+	return ""
+}
+
+// ParseMetadataPath checks a dotted path into metadata, such as size.width,
+// and returns its JSON path. Errors are apierror InvalidArgument values.
+func ParseMetadataPath(field, path string) (string, error) {
+	segments := strings.Split(path, ".")
+	for _, s := range segments {
+		if !metadataKey.MatchString(s) {
+			return "", apierror.InvalidArgument("invalid metadata path", apierror.FieldViolation{
+				Field:       field,
+				Description: fmt.Sprintf("%q is not a dotted path of metadata keys such as size.width", path),
+			})
+		}
+	}
+	if len(segments) > MaxMetadataDepth {
+		return "", apierror.InvalidArgument("invalid metadata path", apierror.FieldViolation{
+			Field:       field,
+			Description: fmt.Sprintf("metadata paths have at most %d keys", MaxMetadataDepth),
+		})
+	}
+	return "$." + path, nil
+}
+
+// metadataValue is how metadata values compare to query parameters: as
+// text, with true and false as 1 and 0 and objects and arrays as JSON.
+// column is the metadata column, unqualified in generated columns.
+func metadataValue(column, jsonPath string) string {
+	return "CAST(json_extract(" + column + ", '" + jsonPath + "') AS TEXT)"
+}
+
+// metadataConditions returns a condition for each path and value in match,
+// in path order, using the generated column of indexed paths
+func metadataConditions(ctx context.Context, q Querier, match map[string]string) (string, []any, error) {
+	if len(match) == 0 {
+		return "", nil, nil
+	}
+	indexes, err := ListMetadataIndexes(ctx, q)
+	if err != nil {
+		return "", nil, apierror.Internal(err, "listing metadata indexes")
+	}
+	columns := make(map[string]string, len(indexes))
+	for _, index := range indexes {
+		columns[index.Key] = index.column
+	}
+
+	paths := make([]string, 0, len(match))
+	for path := range match {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var sql strings.Builder
+	args := make([]any, 0, len(paths))
+	for _, path := range paths {
+		jsonPath, err := ParseMetadataPath("meta."+path, path)
+		if err != nil {
+			return "", nil, err
+		}
+		if column, ok := columns[path]; ok {
+			sql.WriteString(" AND items." + column + " = ?")
+		} else {
+			sql.WriteString(" AND " + metadataValue("items.metadata", jsonPath) + " = ?")
+		}
+		args = append(args, match[path])
+	}
+	return sql.String(), args, nil
+}
+
+// ListMetadataIndexes returns the indexed metadata paths, oldest first
+func ListMetadataIndexes(ctx context.Context, q Querier) ([]MetadataIndex, error) {
+	rows, err := q.QueryContext(ctx, "SELECT id, key, created_at FROM metadata_indexes ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := make([]MetadataIndex, 0)
+	for rows.Next() {
+		var index MetadataIndex
+		var id int64
+		if err := rows.Scan(&id, &index.Key, &index.CreatedAt); err != nil {
+			return nil, err
+		}
+		index.column = metadataColumn(id)
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
+// CreateMetadataIndex adds an indexed generated column of items holding the
+// value at path, so queries on it no longer read every item's metadata.
+// Errors are *apierror.Error values.
+func CreateMetadataIndex(ctx context.Context, tx *sql.Tx, path string) (*MetadataIndex, error) {
+	jsonPath, err := ParseMetadataPath("key", path)
+	if err != nil {
+		return nil, err
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM metadata_indexes").Scan(&count); err != nil {
+		return nil, apierror.Internal(err, "counting metadata indexes")
+	}
+	if count >= MaxMetadataIndexes {
+		return nil, apierror.InvalidArgument("too many metadata indexes", apierror.FieldViolation{
+			Field:       "key",
+			Description: fmt.Sprintf("at most %d metadata keys can be indexed; drop one first", MaxMetadataIndexes),
+		})
+	}
+
+	index := MetadataIndex{Key: path, CreatedAt: time.Now().UTC()}
+	result, err := tx.ExecContext(ctx, "INSERT INTO metadata_indexes (key, created_at) VALUES (?, ?)", path, index.CreatedAt)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return nil, apierror.AlreadyExists("metadata index", path)
+	}
+	if err != nil {
+		return nil, apierror.Internal(err, "recording metadata index")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, apierror.Internal(err, "recording metadata index")
+	}
+	index.column = metadataColumn(id)
+
+	// Paths are checked against metadataKey, so they can be written into
+	// the statements, which cannot take parameters
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		ALTER TABLE items ADD COLUMN %[1]s TEXT GENERATED ALWAYS AS (%[2]s) VIRTUAL;
+		CREATE INDEX items_%[1]s ON items (%[1]s);`, index.column, metadataValue("metadata", jsonPath)))
+	if err != nil {
+		return nil, apierror.Internal(err, "creating metadata index")
+	}
+	return &index, nil
+}
+
+// DropMetadataIndex removes the generated column and index of path. A path
+// that is not indexed is an apierror NotFound.
+func DropMetadataIndex(ctx context.Context, tx *sql.Tx, path string) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, "DELETE FROM metadata_indexes WHERE key = ? RETURNING id", path).Scan(&id)
+	if err == sql.ErrNoRows {
+		return apierror.NotFound("metadata index", path)
+	}
+	if err != nil {
+		return apierror.Internal(err, "removing metadata index")
+	}
+	column := metadataColumn(id)
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		DROP INDEX items_%[1]s;
+		ALTER TABLE items DROP COLUMN %[1]s;`, column))
+	if err != nil {
+		return apierror.Internal(err, "dropping metadata index")
+	}
+	return nil
+}
+
+// metadataColumn names the generated column of a metadata index by its id,
+// as keys differing only in case would clash in SQLite
+func metadataColumn(id int64) string {
+	return "meta_" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,79 @@
+package items
+
+import (
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Proto converts an item to its protobuf form
+func Proto(item *models.Item) *pb.Item {
+	// Validated metadata is decoded JSON, which every Struct can hold
+	metadata, _ := structpb.NewStruct(item.Metadata)
+	return &pb.Item{
+		Id:          item.ID,
+		Name:        item.Name,
+		Value:       DecimalProto(item.Value),
+		LegacyValue: item.Value.Float64(),
+		Description: item.Description,
+		Tags:        item.Tags,
+		Category:    item.Category,
+		Currency:    item.Currency,
+		Metadata:    metadata,
+		ParentId:    item.ParentID,
+		CreatedAt:   timestamppb.New(item.CreatedAt),
+		UpdatedAt:   timestamppb.New(item.UpdatedAt),
+	}
+}
+
+// DecimalProto converts a decimal to a google.type.Decimal
+func DecimalProto(d money.Decimal) *decimal.Decimal {
+	return &decimal.Decimal{Value: d.String()}
+}
+
+// FromProto converts a protobuf item. An item without updated_at, from an
+// older server, was last updated when it was created; one without value
+// has its legacy_value.
+func FromProto(msg *pb.Item) (*models.Item, error) {
+	value, err := ParseValue(msg.GetValue().GetValue(), msg.LegacyValue)
+	if err != nil {
+		return nil, err
+	}
+	item := &models.Item{
+		ID:          msg.Id,
+		Name:        msg.Name,
+		Value:       value,
+		Description: msg.Description,
+		Tags:        msg.Tags,
+		Category:    msg.Category,
+		Currency:    msg.Currency,
+		Metadata:    msg.Metadata.AsMap(),
+		ParentID:    msg.ParentId,
+		CreatedAt:   msg.CreatedAt.AsTime(),
+		UpdatedAt:   msg.UpdatedAt.AsTime(),
+	}
+	if msg.UpdatedAt == nil {
+		item.UpdatedAt = item.CreatedAt
+	}
+	return item, nil
+}
+
+// TreeProto converts an item tree to its protobuf form
+func TreeProto(tree *Tree) *pb.ItemTree {
+	msg := &pb.ItemTree{Tree: treeNodeProto(tree.Tree), Truncated: tree.Truncated}
+	for i := range tree.Ancestors {
+		msg.Ancestors = append(msg.Ancestors, Proto(&tree.Ancestors[i]))
+	}
+	return msg
+}
+
+func treeNodeProto(node *TreeNode) *pb.ItemTreeNode {
+	msg := &pb.ItemTreeNode{Item: Proto(&node.Item)}
+	for _, child := range node.Children {
+		msg.Children = append(msg.Children, treeNodeProto(child))
+	}
+	return msg
+}
@@ -0,0 +1,335 @@
+package items
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/jsonschema"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// MaxDryRunFailures is how many failing items a dry run reports
+const MaxDryRunFailures = 100
+
+// Schema is a version of the JSON Schema items must match while it is
+// active. Versions are numbered from 1 and never change once created.
+type Schema struct {
+	Version     int64           `json:"version"`
+	Schema      json.RawMessage `json:"schema"`
+	Active      bool            `json:"active"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ActivatedAt *time.Time      `json:"activated_at,omitempty"`
+}
+
+// DryRun reports how the stored items fare against a schema version
+type DryRun struct {
+	Version int64 `json:"version"`
+	Checked int   `json:"checked"`
+	Failed  int   `json:"failed"`
+	// Failures lists the first MaxDryRunFailures failing items by id
+	Failures []DryRunFailure `json:"failures"`
+}
+
+// DryRunFailure is an item that does not match a schema
+type DryRunFailure struct {
+	ID         string                    `json:"id"`
+	Violations []apierror.FieldViolation `json:"violations"`
+}
+
+// compiled caches schemas by their text, which versions never change
+var compiled struct {
+	sync.Mutex
+	schemas map[string]*jsonschema.Schema
+}
+
+func compile(text string) (*jsonschema.Schema, error) {
+	compiled.Lock()
+	defer compiled.Unlock()
+	if s, ok := compiled.schemas[text]; ok {
+		return s, nil
+	}
+	s, err := jsonschema.Compile([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	if compiled.schemas == nil {
+		compiled.schemas = map[string]*jsonschema.Schema{}
+	}
+	compiled.schemas[text] = s
+	return s, nil
+}
+
+// Check validates an item with Validate, its parent with CheckParent and
+// then the item against its schemas, reporting every violation in one
+// apierror InvalidArgument
+func Check(ctx context.Context, q Querier, rules money.Rules, item *models.Item) error {
+	var violations []apierror.FieldViolation
+	for _, check := range []func() error{
+		func() error { return Validate(item, rules) },
+		func() error { return CheckParent(ctx, q, item) },
+		func() error { return CheckSchema(ctx, q, item) },
+	} {
+		if err := check(); err != nil {
+			var apiErr *apierror.Error
+			if !errors.As(err, &apiErr) || apiErr.Violations == nil {
+				return err
+			}
+			violations = append(violations, apiErr.Violations...)
+		}
+	}
+	if len(violations) > 0 {
+		return apierror.InvalidArgument("invalid item", violations...)
+	}
+	return nil
+}
+
+// CheckSchema validates a normalized item against the active schema and the
+// schemas of the collections it is in. Errors are *apierror.Error values.
+func CheckSchema(ctx context.Context, q Querier, item *models.Item) error {
+	rows, err := q.QueryContext(ctx, `SELECT schema FROM item_schemas
+		WHERE active OR version IN (
+			SELECT collections.schema_version FROM collection_items
+			JOIN collections ON collections.id = collection_items.collection_id
+			WHERE collection_items.item_id = ?)
+		ORDER BY version`, item.ID)
+	if err != nil {
+		return apierror.Internal(err, "reading item schemas")
+	}
+	var texts []string
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			rows.Close()
+			return apierror.Internal(err, "reading item schemas")
+		}
+		texts = append(texts, text)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return apierror.Internal(err, "reading item schemas")
+	}
+
+	var violations []apierror.FieldViolation
+	for _, text := range texts {
+		schema, err := compile(text)
+		if err != nil {
+			return apierror.Internal(err, "compiling item schema")
+		}
+		for _, v := range schemaViolations(schema, item) {
+			if !slices.Contains(violations, v) {
+				violations = append(violations, v)
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return apierror.InvalidArgument("invalid item", violations...)
+	}
+	return nil
+}
+
+// schemaViolations validates the fields a client sets, with value as a JSON
+// number, against schema
+func schemaViolations(schema *jsonschema.Schema, item *models.Item) []apierror.FieldViolation {
+	data, err := json.Marshal(map[string]any{
+		"name":        item.Name,
+		"value":       json.Number(item.Value.String()),
+		"description": item.Description,
+		"tags":        item.Tags,
+		"category":    item.Category,
+		"currency":    item.Currency,
+		"metadata":    item.Metadata,
+	})
+	if err != nil {
+		return []apierror.FieldViolation{{Field: "item", Description: "item cannot be written as JSON"}}
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var doc any
+	if err := d.Decode(&doc); err != nil {
+		return []apierror.FieldViolation{{Field: "item", Description: "item cannot be written as JSON"}}
+	}
+
+	var violations []apierror.FieldViolation
+	for _, v := range schema.Validate(doc) {
+		field := v.Path
+		if field == "" {
+			field = "item"
+		}
+		violations = append(violations, apierror.FieldViolation{Field: field, Description: field + " " + v.Message})
+	}
+	return violations
+}
+
+// CreateSchema stores a new inactive schema version. A schema that is not
+// an object or does not compile is an apierror InvalidArgument listing its
+// problems.
+func CreateSchema(ctx context.Context, db *sql.DB, schema json.RawMessage) (*Schema, error) {
+	if trimmed := bytes.TrimSpace(schema); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, apierror.InvalidArgument("invalid schema",
+			apierror.FieldViolation{Field: "schema", Description: "schema must be a JSON object"})
+	}
+	if _, err := jsonschema.Compile(schema); err != nil {
+		var compileErr *jsonschema.CompileError
+		if !errors.As(err, &compileErr) {
+			return nil, apierror.Internal(err, "compiling schema")
+		}
+		violations := make([]apierror.FieldViolation, len(compileErr.Problems))
+		for i, p := range compileErr.Problems {
+			violations[i] = apierror.FieldViolation{Field: "schema", Description: p}
+		}
+		return nil, apierror.InvalidArgument("invalid schema", violations...)
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, schema); err != nil {
+		return nil, apierror.Malformed(err)
+	}
+
+	s := &Schema{Schema: buf.Bytes(), CreatedAt: time.Now().UTC()}
+	err := db.QueryRowContext(ctx, "INSERT INTO item_schemas (schema, created_at) VALUES (?, ?) RETURNING version",
+		buf.String(), s.CreatedAt).Scan(&s.Version)
+	if err != nil {
+		return nil, apierror.Internal(err, "storing schema")
+	}
+	return s, nil
+}
+
+const schemaColumns = "version, schema, active, created_at, activated_at"
+
+func scanSchema(row Scanner) (*Schema, error) {
+	var s Schema
+	var text string
+	var activatedAt sql.NullTime
+	if err := row.Scan(&s.Version, &text, &s.Active, &s.CreatedAt, &activatedAt); err != nil {
+		return nil, err
+	}
+	s.Schema = json.RawMessage(text)
+	if activatedAt.Valid {
+		s.ActivatedAt = &activatedAt.Time
+	}
+	return &s, nil
+}
+
+// GetSchema reads one schema version. A missing version is an apierror
+// NotFound.
+func GetSchema(ctx context.Context, q Querier, version int64) (*Schema, error) {
+	s, err := scanSchema(q.QueryRowContext(ctx, "SELECT "+schemaColumns+" FROM item_schemas WHERE version = ?", version))
+	if err == sql.ErrNoRows {
+		return nil, apierror.NotFound("schema", strconv.FormatInt(version, 10))
+	}
+	if err != nil {
+		return nil, apierror.Internal(err, "reading schema")
+	}
+	return s, nil
+}
+
+// ListSchemas returns every schema version, oldest first
+func ListSchemas(ctx context.Context, q Querier) ([]*Schema, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+schemaColumns+" FROM item_schemas ORDER BY version")
+	if err != nil {
+		return nil, apierror.Internal(err, "listing schemas")
+	}
+	defer rows.Close()
+
+	schemas := make([]*Schema, 0)
+	for rows.Next() {
+		s, err := scanSchema(rows)
+		if err != nil {
+			return nil, apierror.Internal(err, "listing schemas")
+		}
+		schemas = append(schemas, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "listing schemas")
+	}
+	return schemas, nil
+}
+
+// ActivateSchema makes a version the one items must match, replacing the
+// active one. Stored items are not checked; run DryRunSchema first.
+func ActivateSchema(ctx context.Context, tx *sql.Tx, version int64) (*Schema, error) {
+	if _, err := tx.ExecContext(ctx, "UPDATE item_schemas SET active = 0, activated_at = NULL WHERE active"); err != nil {
+		return nil, apierror.Internal(err, "deactivating schema")
+	}
+	result, err := tx.ExecContext(ctx, "UPDATE item_schemas SET active = 1, activated_at = ? WHERE version = ?",
+		time.Now().UTC(), version)
+	if err != nil {
+		return nil, apierror.Internal(err, "activating schema")
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, apierror.Internal(err, "activating schema")
+	} else if n == 0 {
+		return nil, apierror.NotFound("schema", strconv.FormatInt(version, 10))
+	}
+	return GetSchema(ctx, tx, version)
+}
+
+// DeactivateSchema stops validating items against any schema
+func DeactivateSchema(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, "UPDATE item_schemas SET active = 0, activated_at = NULL WHERE active"); err != nil {
+		return apierror.Internal(err, "deactivating schema")
+	}
+	return nil
+}
+
+// DryRunSchema checks every stored item, or every item in a collection when
+// collectionID is not empty, against a schema version without changing
+// anything. A missing collection is an apierror NotFound.
+func DryRunSchema(ctx context.Context, q Querier, rules money.Rules, version int64, collectionID string) (*DryRun, error) {
+	s, err := GetSchema(ctx, q, version)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := compile(string(s.Schema))
+	if err != nil {
+		return nil, apierror.Internal(err, "compiling schema")
+	}
+
+	query, args := "SELECT "+Columns+" FROM items ORDER BY items.id", []any(nil)
+	if collectionID != "" {
+		var exists bool
+		err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM collections WHERE id = ?)", collectionID).Scan(&exists)
+		if err != nil {
+			return nil, apierror.Internal(err, "reading collection")
+		}
+		if !exists {
+			return nil, apierror.NotFound("collection", collectionID)
+		}
+		query = "SELECT " + Columns + ` FROM collection_items
+			JOIN items ON items.id = collection_items.item_id
+			WHERE collection_items.collection_id = ? ORDER BY items.id`
+		args = []any{collectionID}
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apierror.Internal(err, "reading items")
+	}
+	defer rows.Close()
+
+	run := &DryRun{Version: version, Failures: make([]DryRunFailure, 0)}
+	for rows.Next() {
+		var item models.Item
+		if err := Scan(rows, rules, &item); err != nil {
+			return nil, apierror.Internal(err, "reading items")
+		}
+		run.Checked++
+		if violations := schemaViolations(schema, &item); len(violations) > 0 {
+			run.Failed++
+			if len(run.Failures) < MaxDryRunFailures {
+				run.Failures = append(run.Failures, DryRunFailure{ID: item.ID, Violations: violations})
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "reading items")
+	}
+	return run, nil
+}
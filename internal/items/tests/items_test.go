@@ -0,0 +1,445 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, database.Migrate(db))
+	return db
+}
+
+func insert(t *testing.T, db *sql.DB, item models.Item) {
+	t.Helper()
+	require.NoError(t, items.Validate(&item, money.Rules{}))
+	require.NoError(t, database.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		return items.Insert(context.Background(), tx, &item)
+	}))
+}
+
+func TestValidate(t *testing.T) {
+	item := models.Item{
+		Name:     "Widget",
+		Tags:     []string{" Blue", "small", "blue "},
+		Category: "  Tools ",
+		Currency: "eur",
+	}
+	require.NoError(t, items.Validate(&item, money.Rules{}))
+	assert.Equal(t, []string{"blue", "small"}, item.Tags)
+	assert.Equal(t, "Tools", item.Category)
+	assert.Equal(t, "EUR", item.Currency)
+
+	tests := []struct {
+		name  string
+		item  models.Item
+		field string
+	}{
+		{"missing name", models.Item{}, "name"},
+		{"unknown currency", models.Item{Name: "a", Currency: "EURO"}, "currency"},
+		{"empty tag", models.Item{Name: "a", Tags: []string{"ok", " "}}, "tags"},
+		{"tag with comma", models.Item{Name: "a", Tags: []string{"a,b"}}, "tags"},
+		{"long category", models.Item{Name: "a", Category: string(make([]byte, items.MaxCategoryLength+1))}, "category"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := items.Validate(&tt.item, money.Rules{})
+			var apiErr *apierror.Error
+			require.True(t, errors.As(err, &apiErr), "got %v", err)
+			assert.Equal(t, codes.InvalidArgument, apiErr.Code)
+			require.Len(t, apiErr.Violations, 1)
+			assert.Equal(t, tt.field, apiErr.Violations[0].Field)
+		})
+	}
+}
+
+func TestInsertUpdateAndGet(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	created := time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC)
+	insert(t, db, models.Item{ID: "a", Name: "Widget", Value: money.MustParse("9.5"), Description: "A widget",
+		Tags: []string{"small", "blue"}, Category: "tools", Currency: "usd", CreatedAt: created})
+
+	item, err := items.Get(ctx, db, money.Rules{}, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "A widget", item.Description)
+	assert.Equal(t, []string{"blue", "small"}, item.Tags)
+	assert.Equal(t, "USD", item.Currency)
+	assert.Equal(t, "9.50", item.Value.String())
+	assert.True(t, item.UpdatedAt.Equal(created))
+
+	// Update replaces the tags and moves updated_at, but not created_at
+	update := models.Item{ID: "a", Name: "Widget", Value: money.MustParse("10"), Tags: []string{"red"}}
+	require.NoError(t, items.Validate(&update, money.Rules{}))
+	require.NoError(t, database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		return items.Update(ctx, tx, money.Rules{}, &update)
+	}))
+	assert.Equal(t, []string{"red"}, update.Tags)
+	assert.Empty(t, update.Description)
+	assert.True(t, update.CreatedAt.Equal(created))
+	assert.True(t, update.UpdatedAt.After(created))
+
+	// Tags no item uses any more are removed
+	var tags int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM tags").Scan(&tags))
+	assert.Equal(t, 1, tags)
+
+	_, err = items.Get(ctx, db, money.Rules{}, "missing")
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, codes.NotFound, apiErr.Code)
+
+	// An item inserted without the items package still gets updated_at
+	_, err = db.Exec("INSERT INTO items (id, name, value_units, created_at) VALUES ('b', 'Raw', 10000, ?)", created)
+	require.NoError(t, err)
+	item, err = items.Get(ctx, db, money.Rules{}, "b")
+	require.NoError(t, err)
+	assert.True(t, item.UpdatedAt.Equal(created))
+	assert.Equal(t, []string{}, item.Tags)
+}
+
+func TestListByTagsAndCategory(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	insert(t, db, models.Item{ID: "a", Name: "Red widget", Tags: []string{"red", "small"}, Category: "tools"})
+	insert(t, db, models.Item{ID: "b", Name: "Red gadget", Tags: []string{"red"}, Category: "toys"})
+	insert(t, db, models.Item{ID: "c", Name: "Blue widget", Tags: []string{"blue", "small"}, Category: "tools"})
+
+	ids := func(req items.ListRequest) []string {
+		t.Helper()
+		list, err := items.List(ctx, db, money.Rules{}, req)
+		require.NoError(t, err)
+		var ids []string
+		for _, item := range list {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []string{"a", "b"}, ids(items.ListRequest{Tags: []string{"red"}}))
+	assert.ElementsMatch(t, []string{"a"}, ids(items.ListRequest{Tags: []string{"red", "small"}}))
+	assert.ElementsMatch(t, []string{"a", "c"}, ids(items.ListRequest{Category: "tools"}))
+	assert.ElementsMatch(t, []string{"c"}, ids(items.ListRequest{Filter: `tags:"Blue"`}))
+	assert.ElementsMatch(t, []string{"b"}, ids(items.ListRequest{Filter: `category = "toys" AND tags:red`}))
+	assert.Empty(t, ids(items.ListRequest{Tags: []string{"green"}}))
+}
+
+func TestDeleteRemovesTags(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	insert(t, db, models.Item{ID: "a", Name: "Widget", Tags: []string{"red"}})
+
+	var deleted *models.Item
+	require.NoError(t, database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		var err error
+		deleted, _, err = items.Delete(ctx, tx, money.Rules{}, "a")
+		return err
+	}))
+	assert.Equal(t, []string{"red"}, deleted.Tags)
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT (SELECT COUNT(*) FROM item_tags) + (SELECT COUNT(*) FROM tags)").Scan(&n))
+	assert.Zero(t, n)
+}
+
+func TestValidateMetadata(t *testing.T) {
+	item := models.Item{Name: "a"}
+	require.NoError(t, items.Validate(&item, money.Rules{}))
+	assert.Equal(t, map[string]any{}, item.Metadata)
+
+	deep := map[string]any{"leaf": true}
+	for i := 0; i < items.MaxMetadataDepth; i++ {
+		deep = map[string]any{"n": deep}
+	}
+	tests := []struct {
+		name     string
+		metadata map[string]any
+	}{
+		{"empty key", map[string]any{"": 1}},
+		{"key with dot", map[string]any{"a.b": 1}},
+		{"nested key with space", map[string]any{"a": []any{map[string]any{"b c": 1}}}},
+		{"too deep", deep},
+		{"too large", map[string]any{"a": strings.Repeat("x", items.MaxMetadataSize)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := items.Validate(&models.Item{Name: "a", Metadata: tt.metadata}, money.Rules{})
+			var apiErr *apierror.Error
+			require.True(t, errors.As(err, &apiErr), "got %v", err)
+			require.Len(t, apiErr.Violations, 1)
+			assert.Equal(t, "metadata", apiErr.Violations[0].Field)
+		})
+	}
+}
+
+func TestListByMetadata(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	insert(t, db, models.Item{ID: "a", Name: "Red", Metadata: map[string]any{"color": "red", "size": map[string]any{"width": 3}}})
+	insert(t, db, models.Item{ID: "b", Name: "Blue", Metadata: map[string]any{"color": "blue", "in_stock": true}})
+	insert(t, db, models.Item{ID: "c", Name: "Plain"})
+
+	item, err := items.Get(ctx, db, money.Rules{}, "a")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"color": "red", "size": map[string]any{"width": 3.0}}, item.Metadata)
+
+	ids := func(match map[string]string) []string {
+		t.Helper()
+		list, err := items.List(ctx, db, money.Rules{}, items.ListRequest{Metadata: match})
+		require.NoError(t, err)
+		var ids []string
+		for _, item := range list {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+	check := func() {
+		t.Helper()
+		assert.Equal(t, []string{"a"}, ids(map[string]string{"color": "red"}))
+		assert.Equal(t, []string{"a"}, ids(map[string]string{"size.width": "3"}))
+		assert.Equal(t, []string{"b"}, ids(map[string]string{"in_stock": "1", "color": "blue"}))
+		assert.Empty(t, ids(map[string]string{"color": "green"}))
+	}
+	check()
+
+	// Indexed keys are read from their generated column, with the same results
+	create := func(key string) error {
+		return database.WithTx(ctx, db, func(tx *sql.Tx) error {
+			_, err := items.CreateMetadataIndex(ctx, tx, key)
+			return err
+		})
+	}
+	require.NoError(t, create("color"))
+	require.NoError(t, create("size.width"))
+	check()
+	indexes, err := items.ListMetadataIndexes(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, indexes, 2)
+	assert.Equal(t, "color", indexes[0].Key)
+
+	var id, parent, unused int
+	var plan string
+	require.NoError(t, db.QueryRow("EXPLAIN QUERY PLAN SELECT id FROM items WHERE meta_1 = 'red'").
+		Scan(&id, &parent, &unused, &plan))
+	assert.Contains(t, plan, "USING INDEX items_meta_1")
+
+	var apiErr *apierror.Error
+	require.True(t, errors.As(create("color"), &apiErr))
+	assert.Equal(t, codes.AlreadyExists, apiErr.Code)
+	require.True(t, errors.As(create("bad key"), &apiErr))
+	assert.Equal(t, codes.InvalidArgument, apiErr.Code)
+
+	drop := func(key string) error {
+		return database.WithTx(ctx, db, func(tx *sql.Tx) error {
+			return items.DropMetadataIndex(ctx, tx, key)
+		})
+	}
+	require.NoError(t, drop("color"))
+	require.True(t, errors.As(drop("color"), &apiErr))
+	assert.Equal(t, codes.NotFound, apiErr.Code)
+	check()
+
+	_, err = items.List(ctx, db, money.Rules{}, items.ListRequest{Metadata: map[string]string{"a..b": "x"}})
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "meta.a..b", apiErr.Violations[0].Field)
+}
+
+func TestCheckSchema(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	schema, err := items.CreateSchema(ctx, db, []byte(`{
+		"properties": {
+			"name": {"maxLength": 10},
+			"value": {"minimum": 0, "maximum": 1000},
+			"metadata": {"required": ["owner"], "properties": {"owner": {"type": "string"}}}
+		}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), schema.Version)
+	assert.False(t, schema.Active)
+
+	// Inactive schemas are not enforced
+	item := models.Item{Name: "A very long name", Value: money.MustParse("-1")}
+	require.NoError(t, items.Check(ctx, db, money.Rules{}, &item))
+
+	require.NoError(t, database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		_, err := items.ActivateSchema(ctx, tx, schema.Version)
+		return err
+	}))
+
+	// Violations of Validate and the schema are reported together
+	item = models.Item{Name: "A very long name", Value: money.MustParse("-1"), Currency: "ZZZ"}
+	err = items.Check(ctx, db, money.Rules{}, &item)
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, codes.InvalidArgument, apiErr.Code)
+	assert.Equal(t, []apierror.FieldViolation{
+		{Field: "currency", Description: "currency must be an ISO 4217 code such as USD or EUR"},
+		{Field: "metadata.owner", Description: "metadata.owner is required"},
+		{Field: "name", Description: "name must be at most 10 characters"},
+		{Field: "value", Description: "value must be at least 0"},
+	}, apiErr.Violations)
+
+	item = models.Item{Name: "Widget", Value: money.MustParse("9.5"), Metadata: map[string]any{"owner": "ops"}}
+	require.NoError(t, items.Check(ctx, db, money.Rules{}, &item))
+
+	_, err = items.CreateSchema(ctx, db, []byte(`{"properties": {"name": {"$ref": "#/x"}}}`))
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, []apierror.FieldViolation{{Field: "schema", Description: "properties.name.$ref: keyword is not supported"}},
+		apiErr.Violations)
+	_, err = items.CreateSchema(ctx, db, []byte(`true`))
+	assert.Error(t, err)
+
+	require.NoError(t, items.DeactivateSchema(ctx, db))
+	item = models.Item{Name: "A very long name"}
+	require.NoError(t, items.Check(ctx, db, money.Rules{}, &item))
+}
+
+func TestDryRunSchema(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	insert(t, db, models.Item{ID: "a", Name: "Cheap", Value: money.MustParse("5")})
+	insert(t, db, models.Item{ID: "b", Name: "Dear", Value: money.MustParse("5000")})
+	insert(t, db, models.Item{ID: "c", Name: "Dearer", Value: money.MustParse("9000")})
+
+	schema, err := items.CreateSchema(ctx, db, []byte(`{"properties": {"value": {"maximum": 1000}}}`))
+	require.NoError(t, err)
+	run, err := items.DryRunSchema(ctx, db, money.Rules{}, schema.Version, "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, run.Checked)
+	assert.Equal(t, 2, run.Failed)
+	require.Len(t, run.Failures, 2)
+	assert.Equal(t, "b", run.Failures[0].ID)
+	assert.Equal(t, []apierror.FieldViolation{{Field: "value", Description: "value must be at most 1000"}},
+		run.Failures[0].Violations)
+
+	// A dry run activates nothing
+	stored, err := items.GetSchema(ctx, db, schema.Version)
+	require.NoError(t, err)
+	assert.False(t, stored.Active)
+
+	_, err = items.DryRunSchema(ctx, db, money.Rules{}, 99, "")
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, codes.NotFound, apiErr.Code)
+}
+
+func TestItemTree(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	insert(t, db, models.Item{ID: "a", Name: "Catalog"})
+	insert(t, db, models.Item{ID: "b", Name: "Bundle", ParentID: "a"})
+	insert(t, db, models.Item{ID: "c", Name: "Accessory", ParentID: "a"})
+	insert(t, db, models.Item{ID: "d", Name: "Part", ParentID: "b"})
+
+	names := func(nodes []*items.TreeNode) []string {
+		var out []string
+		for _, n := range nodes {
+			out = append(out, n.Item.Name)
+		}
+		return out
+	}
+
+	// One level by default, children ordered by name
+	tree, err := items.GetTree(ctx, db, money.Rules{}, "a", 0)
+	require.NoError(t, err)
+	assert.Empty(t, tree.Ancestors)
+	assert.Equal(t, "Catalog", tree.Tree.Item.Name)
+	assert.Equal(t, []string{"Accessory", "Bundle"}, names(tree.Tree.Children))
+	assert.Empty(t, tree.Tree.Children[1].Children)
+	assert.False(t, tree.Truncated)
+
+	tree, err = items.GetTree(ctx, db, money.Rules{}, "a", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Part"}, names(tree.Tree.Children[1].Children))
+
+	tree, err = items.GetTree(ctx, db, money.Rules{}, "d", 1)
+	require.NoError(t, err)
+	require.Len(t, tree.Ancestors, 2)
+	assert.Equal(t, "b", tree.Ancestors[0].ID)
+	assert.Equal(t, "a", tree.Ancestors[1].ID)
+
+	var apiErr *apierror.Error
+	_, err = items.GetTree(ctx, db, money.Rules{}, "missing", 1)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, codes.NotFound, apiErr.Code)
+	_, err = items.GetTree(ctx, db, money.Rules{}, "a", items.MaxTreeDepth+1)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, codes.InvalidArgument, apiErr.Code)
+
+	// Deleting a parent makes its children roots and returns them
+	before, err := items.Get(ctx, db, money.Rules{}, "d")
+	require.NoError(t, err)
+	var children []*models.Item
+	require.NoError(t, database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		_, children, err = items.Delete(ctx, tx, money.Rules{}, "b")
+		return err
+	}))
+	require.Len(t, children, 1)
+	assert.Equal(t, "d", children[0].ID)
+	assert.Empty(t, children[0].ParentID)
+	assert.True(t, children[0].UpdatedAt.After(before.UpdatedAt))
+	part, err := items.Get(ctx, db, money.Rules{}, "d")
+	require.NoError(t, err)
+	assert.Empty(t, part.ParentID)
+}
+
+func TestCheckParent(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	insert(t, db, models.Item{ID: "a", Name: "Root"})
+	insert(t, db, models.Item{ID: "b", Name: "Child", ParentID: "a"})
+	insert(t, db, models.Item{ID: "c", Name: "Grandchild", ParentID: "b"})
+
+	for _, tc := range []struct {
+		name, id, parent, want string
+	}{
+		{"missing parent", "a", "zzz", "parent item zzz does not exist"},
+		{"own parent", "a", "a", "parent item a is this item or one of its descendants"},
+		{"descendant", "a", "c", "parent item c is this item or one of its descendants"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := database.WithTx(ctx, db, func(tx *sql.Tx) error {
+				item, err := items.Get(ctx, tx, money.Rules{}, tc.id)
+				require.NoError(t, err)
+				item.ParentID = tc.parent
+				return items.Update(ctx, tx, money.Rules{}, item)
+			})
+			var apiErr *apierror.Error
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, []apierror.FieldViolation{{Field: "parent_id", Description: tc.want}}, apiErr.Violations)
+		})
+	}
+
+	// Moving an item under a sibling branch is fine
+	item := models.Item{ID: "c", Name: "Grandchild", ParentID: "a"}
+	require.NoError(t, items.Check(ctx, db, money.Rules{}, &item))
+}
@@ -0,0 +1,146 @@
+package items
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// Limits on item trees
+const (
+	// DefaultTreeDepth is how many levels of descendants GetTree returns
+	// when no depth is given
+	DefaultTreeDepth = 1
+	// MaxTreeDepth is the most levels of descendants GetTree returns
+	MaxTreeDepth = 10
+	// MaxTreeItems is the most descendants, and ancestors, GetTree returns
+	MaxTreeItems = 1000
+)
+
+// Tree is an item with its ancestors and descendants
+type Tree struct {
+	// Ancestors lists the parent first and the root last
+	Ancestors []models.Item `json:"ancestors"`
+	Tree      *TreeNode     `json:"tree"`
+	// Truncated is set when descendants past MaxTreeItems were left out
+	Truncated bool `json:"truncated"`
+}
+
+// TreeNode is an item and its children, ordered by name
+type TreeNode struct {
+	Item     models.Item `json:"item"`
+	Children []*TreeNode `json:"children"`
+}
+
+// CheckParent checks that the parent of an item exists and is neither the
+// item nor one of its descendants. Errors are *apierror.Error values.
+func CheckParent(ctx context.Context, q Querier, item *models.Item) error {
+	if item.ParentID == "" {
+		return nil
+	}
+	// UNION drops repeated ids, so the walk ends even on a cycle
+	var exists, cycle bool
+	err := q.QueryRowContext(ctx, `
+		WITH RECURSIVE ancestors(id) AS (
+			SELECT ?
+			UNION
+			SELECT items.parent_id FROM items JOIN ancestors ON items.id = ancestors.id
+			WHERE items.parent_id IS NOT NULL
+		)
+		SELECT EXISTS (SELECT 1 FROM items WHERE id = ?), EXISTS (SELECT 1 FROM ancestors WHERE id = ?)`,
+		item.ParentID, item.ParentID, item.ID).Scan(&exists, &cycle)
+	if err != nil {
+		return apierror.Internal(err, "checking parent item")
+	}
+	switch {
+	case !exists:
+		return apierror.InvalidArgument("invalid item", apierror.FieldViolation{Field: "parent_id",
+			Description: fmt.Sprintf("parent item %s does not exist", item.ParentID)})
+	case cycle:
+		return apierror.InvalidArgument("invalid item", apierror.FieldViolation{Field: "parent_id",
+			Description: fmt.Sprintf("parent item %s is this item or one of its descendants", item.ParentID)})
+	}
+	return nil
+}
+
+// GetTree reads an item, its ancestors and its descendants up to depth
+// levels below it, or DefaultTreeDepth when depth is 0. A missing item is an
+// apierror NotFound.
+func GetTree(ctx context.Context, q Querier, rules money.Rules, id string, depth int) (*Tree, error) {
+	if depth == 0 {
+		depth = DefaultTreeDepth
+	}
+	if depth < 1 || depth > MaxTreeDepth {
+		return nil, apierror.InvalidArgument("invalid tree request", apierror.FieldViolation{Field: "depth",
+			Description: fmt.Sprintf("depth must be between 1 and %d", MaxTreeDepth)})
+	}
+
+	// Breadth first, so every parent is read before its children
+	rows, err := q.QueryContext(ctx, `
+		WITH RECURSIVE tree(id, depth) AS (
+			SELECT ?, 0
+			UNION ALL
+			SELECT items.id, tree.depth + 1 FROM items JOIN tree ON items.parent_id = tree.id
+			WHERE tree.depth < ?
+		)
+		SELECT `+Columns+` FROM tree JOIN items ON items.id = tree.id
+		ORDER BY tree.depth, items.name, items.id
+		LIMIT ?`, id, depth, MaxTreeItems+2)
+	if err != nil {
+		return nil, apierror.Internal(err, "reading item tree")
+	}
+	defer rows.Close()
+
+	tree := &Tree{Ancestors: make([]models.Item, 0)}
+	nodes := make(map[string]*TreeNode)
+	for rows.Next() {
+		node := &TreeNode{Children: make([]*TreeNode, 0)}
+		if err := Scan(rows, rules, &node.Item); err != nil {
+			return nil, apierror.Internal(err, "reading item tree")
+		}
+		if tree.Tree == nil {
+			tree.Tree = node
+		} else if len(nodes) > MaxTreeItems {
+			tree.Truncated = true
+			break
+		} else {
+			parent := nodes[node.Item.ParentID]
+			parent.Children = append(parent.Children, node)
+		}
+		nodes[node.Item.ID] = node
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "reading item tree")
+	}
+	if tree.Tree == nil {
+		return nil, apierror.NotFound("item", id)
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		WITH RECURSIVE ancestors(id, depth) AS (
+			SELECT parent_id, 1 FROM items WHERE id = ? AND parent_id IS NOT NULL
+			UNION ALL
+			SELECT items.parent_id, ancestors.depth + 1 FROM items JOIN ancestors ON items.id = ancestors.id
+			WHERE items.parent_id IS NOT NULL AND ancestors.depth < ?
+		)
+		SELECT `+Columns+` FROM ancestors JOIN items ON items.id = ancestors.id
+		ORDER BY ancestors.depth`, id, MaxTreeItems)
+	if err != nil {
+		return nil, apierror.Internal(err, "reading item ancestors")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.Item
+		if err := Scan(rows, rules, &item); err != nil {
+			return nil, apierror.Internal(err, "reading item ancestors")
+		}
+		tree.Ancestors = append(tree.Ancestors, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "reading item ancestors")
+	}
+	return tree, nil
+}
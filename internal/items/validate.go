@@ -0,0 +1,113 @@
+package items
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// Limits on item fields
+const (
+	MaxDescriptionLength = 10000
+	MaxCategoryLength    = 100
+	MaxTags              = 50
+	MaxTagLength         = 64
+)
+
+// Validate checks the fields a client sets and normalizes them: category is
+// trimmed, currency upper-cased, value rounded by the rules of its
+// currency, and tags trimmed, lower-cased, sorted and made unique.
+// Metadata is limited in size and depth and its keys are checked. Errors are
+// apierror InvalidArgument values with one violation per bad field.
+func Validate(item *models.Item, rules money.Rules) error {
+	var violations []apierror.FieldViolation
+	if item.Name == "" {
+		violations = append(violations, apierror.FieldViolation{Field: "name", Description: "name is required"})
+	}
+	if utf8.RuneCountInString(item.Description) > MaxDescriptionLength {
+		violations = append(violations, apierror.FieldViolation{Field: "description",
+			Description: fmt.Sprintf("description must be at most %d characters", MaxDescriptionLength)})
+	}
+	item.Category = strings.TrimSpace(item.Category)
+	if utf8.RuneCountInString(item.Category) > MaxCategoryLength {
+		violations = append(violations, apierror.FieldViolation{Field: "category",
+			Description: fmt.Sprintf("category must be at most %d characters", MaxCategoryLength)})
+	}
+	item.Currency = strings.ToUpper(strings.TrimSpace(item.Currency))
+	if item.Currency != "" && !money.IsCurrency(item.Currency) {
+		violations = append(violations, apierror.FieldViolation{Field: "currency",
+			Description: "currency must be an ISO 4217 code such as USD or EUR"})
+	} else if value, err := rules.Round(item.Value, item.Currency); err != nil {
+		violations = append(violations, apierror.FieldViolation{Field: "value", Description: "value is out of range"})
+	} else {
+		item.Value = value
+	}
+	tags, err := normalizeTags(item.Tags)
+	if err != "" {
+		violations = append(violations, apierror.FieldViolation{Field: "tags", Description: err})
+	}
+	item.Tags = tags
+	if item.Metadata == nil {
+		item.Metadata = map[string]any{}
+	}
+	if err := validateMetadata(item.Metadata); err != "" {
+		violations = append(violations, apierror.FieldViolation{Field: "metadata", Description: err})
+	}
+
+	if len(violations) > 0 {
+		return apierror.InvalidArgument("invalid item", violations...)
+	}
+	return nil
+}
+
+// normalizeTags returns the tags in stored form, or why they are invalid
+func normalizeTags(tags []string) ([]string, string) {
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		switch {
+		case tag == "":
+			return nil, "tags must not be empty"
+		case utf8.RuneCountInString(tag) > MaxTagLength:
+			return nil, fmt.Sprintf("tags must be at most %d characters", MaxTagLength)
+		case strings.ContainsFunc(tag, func(r rune) bool { return r == ',' || unicode.IsControl(r) }):
+			return nil, "tags must not contain commas or control characters"
+		}
+		out = append(out, tag)
+	}
+	slices.Sort(out)
+	out = slices.Compact(out)
+	if len(out) > MaxTags {
+		return nil, fmt.Sprintf("an item can have at most %d tags", MaxTags)
+	}
+	return out, ""
+}
+
+// ParseValue reads a value sent as a decimal string, falling back to the
+// double older clients send. Errors are apierror InvalidArgument values.
+func ParseValue(value string, legacy float64) (money.Decimal, error) {
+	if value == "" {
+		value = strconv.FormatFloat(legacy, 'g', -1, 64)
+	}
+	d, err := money.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return d, apierror.InvalidArgument("invalid item",
+			apierror.FieldViolation{Field: "value", Description: "value must be a decimal number such as 9.50"})
+	}
+	return d, nil
+}
+
+// ParseTags splits a comma-separated list of tags, as exports write them
+func ParseTags(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// State is the lifecycle stage of a job
+type State string
+
+// Job states. Succeeded, failed and cancelled are final.
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Succeeded State = "succeeded"
+	Failed    State = "failed"
+	Cancelled State = "cancelled"
+)
+
+// Final reports whether a job in state s will not run again
+func (s State) Final() bool {
+	return s == Succeeded || s == Failed || s == Cancelled
+}
+
+// Progress is how far a running job has got. Total is zero when unknown.
+type Progress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// Job is the stored state of a background job. Result is the JSON returned
+// by the job's handler, kept also when the job failed part way. Error is the
+// last failure, including ones that were retried.
+type Job struct {
+	ID              string          `json:"id"`
+	Kind            string          `json:"kind"`
+	State           State           `json:"state"`
+	Progress        Progress        `json:"progress"`
+	Attempts        int             `json:"attempts"`
+	MaxAttempts     int             `json:"max_attempts"`
+	Result          json.RawMessage `json:"result,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	CancelRequested bool            `json:"cancel_requested,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+
+	payload json.RawMessage
+	version int64
+}
+
+// jobColumns are read by scanJob, in order
+const jobColumns = `id, kind, state, payload, result, error, progress_done, progress_total,
+	attempts, max_attempts, cancel_requested, version, created_at, updated_at, started_at, finished_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row scanner) (*Job, error) {
+	var j Job
+	var payload string
+	var result sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(&j.ID, &j.Kind, &j.State, &payload, &result, &j.Error, &j.Progress.Done, &j.Progress.Total,
+		&j.Attempts, &j.MaxAttempts, &j.CancelRequested, &j.version, &j.CreatedAt, &j.UpdatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	j.payload = json.RawMessage(payload)
+	if result.Valid {
+		j.Result = json.RawMessage(result.String)
+	}
+	if startedAt.Valid {
+		j.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return &j, nil
+}
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails without further attempts
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Payload returns the JSON payload the job was enqueued with
+func (j *Job) Payload() json.RawMessage {
+	return j.payload
+}
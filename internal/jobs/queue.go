@@ -0,0 +1,651 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/google/uuid"
+)
+
+// Handler runs one attempt of a job. ctx is cancelled when the job is
+// cancelled, its lease is lost or the queue shuts down. The returned value
+// is stored as the job's JSON result, also when err is not nil.
+type Handler func(ctx context.Context, t *Task) (any, error)
+
+// Task is a job being run by a handler
+type Task struct {
+	Job   *Job
+	queue *Queue
+}
+
+// Decode unmarshals the job's payload into v
+func (t *Task) Decode(v any) error {
+	return json.Unmarshal(t.Job.payload, v)
+}
+
+// Progress records how far the job has got. Failures are logged, not
+// returned, so a busy database does not fail the job itself.
+func (t *Task) Progress(done, total int64) {
+	err := database.WithTx(context.Background(), t.queue.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE jobs SET progress_done = ?, progress_total = ?, version = version + 1, updated_at = ?
+			WHERE id = ? AND lease_owner = ? AND state = 'running'`,
+			done, total, time.Now().UTC(), t.Job.ID, t.queue.owner)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error recording progress of job %s: %v", t.Job.ID, err)
+	}
+}
+
+// Config tunes the workers of a queue
+type Config struct {
+	// Workers is the number of jobs run at once
+	Workers int
+	// LeaseDuration is how long a claimed job stays with a worker without a
+	// heartbeat. Heartbeats are sent every third of it; a job whose lease
+	// expires is picked up again, for example after a crash.
+	LeaseDuration time.Duration
+	// PollInterval is how often idle workers and watchers check the table
+	PollInterval time.Duration
+	// RetryBaseDelay is the backoff before the second attempt; it doubles on
+	// each attempt up to RetryMaxDelay
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// Retention is how long succeeded, failed and cancelled jobs are kept
+	Retention time.Duration
+}
+
+// DefaultConfig returns a configuration for a handful of long jobs
+func DefaultConfig() Config {
+	return Config{
+		Workers:        2,
+		LeaseDuration:  30 * time.Second,
+		PollInterval:   500 * time.Millisecond,
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  5 * time.Minute,
+		Retention:      7 * 24 * time.Hour,
+	}
+}
+
+// Causes of a cancelled job context
+var (
+	errCancelled = errors.New("job cancelled")
+	errLeaseLost = errors.New("job lease lost")
+)
+
+// Kind describes how to run one kind of job
+type Kind struct {
+	Handler Handler
+	// MaxAttempts is how many times a job is attempted before it fails
+	MaxAttempts int
+	// Discard, if set, is called for a job that ends without its handler
+	// returning: one cancelled while queued, or one abandoned by a stopped
+	// worker on its last attempt. It releases what the payload refers to.
+	Discard func(job *Job)
+}
+
+// Queue is a job queue stored in SQLite with workers in this process.
+// Several processes may share a database; each job runs on one of them at a
+// time.
+type Queue struct {
+	db    *sql.DB
+	cfg   Config
+	owner string
+	wake  chan struct{}
+
+	mu      sync.Mutex
+	kinds   map[string]Kind
+	running map[string]context.CancelCauseFunc
+}
+
+// New creates a queue, filling unset fields of cfg from DefaultConfig
+func New(db *sql.DB, cfg Config) *Queue {
+	def := DefaultConfig()
+	if cfg.Workers <= 0 {
+		cfg.Workers = def.Workers
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = def.LeaseDuration
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = def.RetryBaseDelay
+	}
+	if cfg.RetryMaxDelay < cfg.RetryBaseDelay {
+		cfg.RetryMaxDelay = max(def.RetryMaxDelay, cfg.RetryBaseDelay)
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = def.Retention
+	}
+	return &Queue{
+		db:      db,
+		cfg:     cfg,
+		owner:   uuid.New().String(),
+		wake:    make(chan struct{}, 1),
+		kinds:   make(map[string]Kind),
+		running: make(map[string]context.CancelCauseFunc),
+	}
+}
+
+// Register sets how jobs of a kind are run. Register kinds before calling
+// Run.
+func (q *Queue) Register(name string, k Kind) {
+	k.MaxAttempts = max(k.MaxAttempts, 1)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.kinds[name] = k
+}
+
+func (q *Queue) kind(name string) (Kind, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	k, ok := q.kinds[name]
+	return k, ok
+}
+
+// Enqueue stores a job for a worker to run. payload is stored as JSON.
+func (q *Queue) Enqueue(ctx context.Context, kindName string, payload any) (*Job, error) {
+	k, ok := q.kind(kindName)
+	if !ok {
+		return nil, fmt.Errorf("unknown job kind %q", kindName)
+	}
+	job, err := q.insert(ctx, kindName, payload, k.MaxAttempts, false)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Do runs h once as a job of the given kind in the calling goroutine and
+// returns the job's final state along with the handler's error. The kind
+// need not be registered. The job is stored like any other, so it can be
+// watched and cancelled while it runs; if ctx ends first it is cancelled.
+func (q *Queue) Do(ctx context.Context, kindName string, payload any, h Handler) (*Job, error) {
+	job, err := q.insert(ctx, kindName, payload, 1, true)
+	if err != nil {
+		return nil, err
+	}
+	runErr := q.execute(ctx, job, h, true)
+	final, err := q.Get(context.Background(), job.ID)
+	if err != nil {
+		return nil, err
+	}
+	return final, runErr
+}
+
+// insert stores a new job, already claimed by this queue when running
+func (q *Queue) insert(ctx context.Context, kindName string, payload any, maxAttempts int, running bool) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	now := time.Now().UTC()
+	state, owner, attempts, leaseExpires := Queued, "", 0, int64(0)
+	var startedAt *time.Time
+	if running {
+		state, owner, attempts = Running, q.owner, 1
+		leaseExpires = now.Add(q.cfg.LeaseDuration).UnixMilli()
+		startedAt = &now
+	}
+	err = database.WithTx(ctx, q.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO jobs (id, kind, state, payload, attempts, max_attempts, run_after,
+			lease_owner, lease_expires, created_at, updated_at, started_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, kindName, state, string(data), attempts, maxAttempts, now.UnixMilli(),
+			owner, leaseExpires, now, now, startedAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return q.Get(ctx, id)
+}
+
+// Get returns a job. Errors are *apierror.Error values.
+func (q *Queue) Get(ctx context.Context, id string) (*Job, error) {
+	job, err := scanJob(q.db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, apierror.NotFound("job", id)
+	}
+	if err != nil {
+		return nil, apierror.Internal(err, "reading job")
+	}
+	return job, nil
+}
+
+// Cancel stops a job. A queued job is cancelled at once; a running one is
+// asked to stop and becomes cancelled when its handler returns. Cancelling a
+// finished job changes nothing. Errors are *apierror.Error values.
+func (q *Queue) Cancel(ctx context.Context, id string) (*Job, error) {
+	var found, discarded bool
+	err := database.WithTx(ctx, q.db, func(tx *sql.Tx) error {
+		var state State
+		err := tx.QueryRow("SELECT state FROM jobs WHERE id = ?", id).Scan(&state)
+		found, discarded = err != sql.ErrNoRows, state == Queued
+		if err != nil {
+			if !found {
+				return nil
+			}
+			return err
+		}
+		now := time.Now().UTC()
+		switch state {
+		case Queued:
+			_, err = tx.Exec(`UPDATE jobs SET state = 'cancelled', cancel_requested = 1, error = ?, version = version + 1,
+				updated_at = ?, finished_at = ? WHERE id = ?`, errCancelled.Error(), now, now, id)
+		case Running:
+			_, err = tx.Exec("UPDATE jobs SET cancel_requested = 1, version = version + 1, updated_at = ? WHERE id = ?", now, id)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, apierror.Internal(err, "cancelling job")
+	}
+	if !found {
+		return nil, apierror.NotFound("job", id)
+	}
+
+	// Stop it now if it runs here; other processes notice on their next
+	// heartbeat
+	q.mu.Lock()
+	if cancel, ok := q.running[id]; ok {
+		cancel(errCancelled)
+	}
+	q.mu.Unlock()
+
+	job, err := q.Get(ctx, id)
+	if err == nil && discarded {
+		q.discard(job)
+	}
+	return job, err
+}
+
+// discard releases the payload of a job that ended without running
+func (q *Queue) discard(job *Job) {
+	if k, ok := q.kind(job.Kind); ok && k.Discard != nil {
+		k.Discard(job)
+	}
+}
+
+// Watch calls fn with the job now and again each time it changes, until it
+// reaches a final state, fn returns an error or ctx ends
+func (q *Queue) Watch(ctx context.Context, id string, fn func(*Job) error) error {
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+	var version int64
+	for {
+		job, err := q.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if job.version != version {
+			version = job.version
+			if err := fn(job); err != nil {
+				return err
+			}
+		}
+		if job.State.Final() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Wait returns the job once it reaches a final state
+func (q *Queue) Wait(ctx context.Context, id string) (*Job, error) {
+	var last *Job
+	err := q.Watch(ctx, id, func(job *Job) error {
+		last = job
+		return nil
+	})
+	return last, err
+}
+
+// pruneInterval is how often finished jobs are deleted, unless Retention is
+// shorter
+const pruneInterval = time.Minute
+
+// Run starts the workers and blocks until ctx ends and they have stopped.
+// Jobs interrupted by shutdown are retried by the next run, or fail once
+// they are out of attempts. Finished jobs are deleted after Retention.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(min(pruneInterval, q.cfg.Retention))
+		defer ticker.Stop()
+		for {
+			if _, err := q.Prune(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Error pruning jobs: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+// Prune deletes jobs that finished more than Retention ago and returns how
+// many were deleted
+func (q *Queue) Prune(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-q.cfg.Retention).UTC()
+	const finished = "state IN ('succeeded', 'failed', 'cancelled') AND finished_at < ?"
+
+	// Probe outside a transaction so an idle queue takes no write lock
+	var due bool
+	if err := q.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM jobs WHERE "+finished+")", cutoff).Scan(&due); err != nil || !due {
+		return 0, err
+	}
+	var n int64
+	err := database.WithTx(ctx, q.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM jobs WHERE "+finished, cutoff)
+		if err != nil {
+			return err
+		}
+		n, err = result.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+func (q *Queue) work(ctx context.Context) {
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		job, err := q.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error claiming job: %v", err)
+		}
+		if job != nil {
+			if k, ok := q.kind(job.Kind); ok {
+				q.execute(ctx, job, k.Handler, false)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim takes the next runnable job: a queued one that is due, or a running
+// one whose worker stopped sending heartbeats. Abandoned jobs that are out of
+// attempts or were cancelled are finished instead of claimed.
+func (q *Queue) claim(ctx context.Context) (*Job, error) {
+	q.mu.Lock()
+	kinds := make([]any, 0, len(q.kinds))
+	for name := range q.kinds {
+		kinds = append(kinds, name)
+	}
+	q.mu.Unlock()
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.Repeat("?, ", len(kinds)-1) + "?"
+	const runnable = "((state = 'queued' AND run_after <= ?) OR (state = 'running' AND lease_expires <= ?))"
+
+	// Probe outside a transaction so idle workers take no write lock
+	now := time.Now().UnixMilli()
+	var due bool
+	err := q.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM jobs WHERE kind IN (`+placeholders+`) AND `+runnable+`)`,
+		append(append([]any{}, kinds...), now, now)...).Scan(&due)
+	if err != nil || !due {
+		return nil, err
+	}
+
+	var claimed string
+	var abandoned []string
+	err = database.WithTx(ctx, q.db, func(tx *sql.Tx) error {
+		claimed, abandoned = "", nil
+		for {
+			now := time.Now().UTC()
+			var id string
+			var state State
+			var attempts, maxAttempts int
+			var cancelRequested bool
+			args := append(append([]any{}, kinds...), now.UnixMilli(), now.UnixMilli())
+			err := tx.QueryRow(`SELECT id, state, attempts, max_attempts, cancel_requested FROM jobs
+				WHERE kind IN (`+placeholders+`) AND `+runnable+`
+				ORDER BY run_after, created_at LIMIT 1`, args...).
+				Scan(&id, &state, &attempts, &maxAttempts, &cancelRequested)
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if state == Running && (cancelRequested || attempts >= maxAttempts) {
+				final, msg := Failed, "the worker running the job stopped before it finished"
+				if cancelRequested {
+					final, msg = Cancelled, errCancelled.Error()
+				}
+				_, err := tx.Exec(`UPDATE jobs SET state = ?, error = ?, lease_owner = '', version = version + 1,
+					updated_at = ?, finished_at = ? WHERE id = ?`, final, msg, now, now, id)
+				if err != nil {
+					return err
+				}
+				abandoned = append(abandoned, id)
+				continue
+			}
+
+			_, err = tx.Exec(`UPDATE jobs SET state = 'running', attempts = attempts + 1, lease_owner = ?, lease_expires = ?,
+				version = version + 1, updated_at = ?, started_at = COALESCE(started_at, ?) WHERE id = ?`,
+				q.owner, now.Add(q.cfg.LeaseDuration).UnixMilli(), now, now, id)
+			if err != nil {
+				return err
+			}
+			claimed = id
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range abandoned {
+		if job, err := q.Get(ctx, id); err == nil {
+			q.discard(job)
+		}
+	}
+	if claimed == "" {
+		return nil, nil
+	}
+	return q.Get(ctx, claimed)
+}
+
+// execute runs a claimed job and records the outcome. It returns the
+// handler's error.
+func (q *Queue) execute(parent context.Context, job *Job, h Handler, inline bool) error {
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+	}()
+	if job.CancelRequested {
+		cancel(errCancelled)
+	}
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		q.heartbeat(ctx, job.ID, cancel)
+	}()
+
+	var result any
+	var err error
+	if ctx.Err() == nil {
+		result, err = h(ctx, &Task{Job: job, queue: q})
+	} else {
+		err = context.Cause(ctx)
+	}
+	cause := context.Cause(ctx)
+	cancel(nil)
+	<-heartbeatDone
+
+	if cause == errLeaseLost {
+		log.Printf("Job %s lost its lease; another worker owns it now", job.ID)
+		return err
+	}
+	if finishErr := q.finish(job, result, err, cause, inline); finishErr != nil {
+		log.Printf("Error recording the outcome of job %s: %v", job.ID, finishErr)
+	}
+	return err
+}
+
+// heartbeat extends the lease of a running job until ctx ends. It cancels
+// the job when the lease was taken over or a cancel was requested by another
+// process.
+func (q *Queue) heartbeat(ctx context.Context, id string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(q.cfg.LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var owned, cancelRequested bool
+		err := database.WithTx(ctx, q.db, func(tx *sql.Tx) error {
+			res, err := tx.Exec("UPDATE jobs SET lease_expires = ? WHERE id = ? AND lease_owner = ? AND state = 'running'",
+				time.Now().Add(q.cfg.LeaseDuration).UnixMilli(), id, q.owner)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			owned = n > 0
+			return tx.QueryRow("SELECT cancel_requested FROM jobs WHERE id = ?", id).Scan(&cancelRequested)
+		})
+		switch {
+		case err != nil:
+			if ctx.Err() == nil {
+				log.Printf("Error extending the lease of job %s: %v", id, err)
+			}
+		case !owned:
+			cancel(errLeaseLost)
+		case cancelRequested:
+			cancel(errCancelled)
+		}
+	}
+}
+
+// finish stores the outcome of an attempt: success, cancellation, a retry
+// after a backoff, or failure once the job is out of attempts
+func (q *Queue) finish(job *Job, result any, runErr, cause error, inline bool) error {
+	var resultJSON *string
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		s := string(data)
+		resultJSON = &s
+	}
+
+	now := time.Now().UTC()
+	state, msg := Succeeded, ""
+	runAfter := now
+	switch {
+	case runErr == nil:
+	case cause == errCancelled || (inline && cause != nil):
+		state, msg = Cancelled, errCancelled.Error()
+	case cause != nil:
+		// The queue is shutting down
+		state, msg = Queued, "the worker running the job stopped before it finished"
+		if job.Attempts >= job.MaxAttempts {
+			state = Failed
+		}
+	default:
+		state, msg = Queued, errorMessage(runErr)
+		if IsPermanent(runErr) || job.Attempts >= job.MaxAttempts {
+			state = Failed
+		} else {
+			runAfter = now.Add(q.backoff(job.Attempts))
+		}
+	}
+
+	var finishedAt *time.Time
+	if state.Final() {
+		finishedAt = &now
+	}
+	return database.WithTx(context.Background(), q.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE jobs SET state = ?, result = COALESCE(?, result), error = ?, run_after = ?,
+			lease_owner = '', lease_expires = 0, version = version + 1, updated_at = ?, finished_at = ?
+			WHERE id = ? AND lease_owner = ?`,
+			state, resultJSON, msg, runAfter.UnixMilli(), now, finishedAt, job.ID, q.owner)
+		return err
+	})
+}
+
+// backoff returns the delay before the attempt after attempt, with up to
+// half of it added as jitter
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.cfg.RetryBaseDelay << (attempt - 1)
+	if d <= 0 || d > q.cfg.RetryMaxDelay {
+		d = q.cfg.RetryMaxDelay
+	}
+	return d + rand.N(d/2+1)
+}
+
+// errorMessage returns a message for err that is safe to show to clients.
+// Unexpected errors are logged and replaced by their correlation ID.
+func errorMessage(err error) string {
+	var p *permanentError
+	if errors.As(err, &p) {
+		err = p.err
+	}
+	apiErr := apierror.From(err, "running job")
+	msg := apiErr.Message
+	if apiErr.CorrelationID != "" {
+		msg += " (correlation_id " + apiErr.CorrelationID + ")"
+	}
+	for _, v := range apiErr.Violations {
+		msg += "; " + v.Field + ": " + v.Description
+	}
+	return msg
+}
@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the JobService gRPC service on a queue
+type Server struct {
+	pb.UnimplementedJobServiceServer
+	queue *Queue
+}
+
+// NewServer creates a job server for q
+func NewServer(q *Queue) *Server {
+	return &Server{queue: q}
+}
+
+// GetJob returns a job by ID
+func (s *Server) GetJob(ctx context.Context, req *pb.GetJobRequest) (*pb.Job, error) {
+	job, err := s.queue.Get(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return toProto(job)
+}
+
+// CancelJob cancels a job
+func (s *Server) CancelJob(ctx context.Context, req *pb.CancelJobRequest) (*pb.Job, error) {
+	job, err := s.queue.Cancel(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return toProto(job)
+}
+
+// WatchJob streams a job until it reaches a final state
+func (s *Server) WatchJob(req *pb.WatchJobRequest, stream pb.JobService_WatchJobServer) error {
+	err := s.queue.Watch(stream.Context(), req.Id, func(job *Job) error {
+		msg, err := toProto(job)
+		if err != nil {
+			return err
+		}
+		return stream.Send(msg)
+	})
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+var protoStates = map[State]pb.Job_State{
+	Queued:    pb.Job_STATE_QUEUED,
+	Running:   pb.Job_STATE_RUNNING,
+	Succeeded: pb.Job_STATE_SUCCEEDED,
+	Failed:    pb.Job_STATE_FAILED,
+	Cancelled: pb.Job_STATE_CANCELLED,
+}
+
+func toProto(job *Job) (*pb.Job, error) {
+	msg := &pb.Job{
+		Id:              job.ID,
+		Kind:            job.Kind,
+		State:           protoStates[job.State],
+		Progress:        &pb.Job_Progress{Done: job.Progress.Done, Total: job.Progress.Total},
+		Attempts:        int32(job.Attempts),
+		MaxAttempts:     int32(job.MaxAttempts),
+		Error:           job.Error,
+		CancelRequested: job.CancelRequested,
+		CreatedAt:       timestamppb.New(job.CreatedAt),
+		UpdatedAt:       timestamppb.New(job.UpdatedAt),
+	}
+	if job.StartedAt != nil {
+		msg.StartedAt = timestamppb.New(*job.StartedAt)
+	}
+	if job.FinishedAt != nil {
+		msg.FinishedAt = timestamppb.New(*job.FinishedAt)
+	}
+	if len(job.Result) > 0 {
+		var v any
+		if err := json.Unmarshal(job.Result, &v); err != nil {
+			return nil, apierror.Internal(err, "decoding job result")
+		}
+		result, err := structpb.NewValue(v)
+		if err != nil {
+			return nil, apierror.Internal(err, "converting job result")
+		}
+		msg.Result = result
+	}
+	return msg, nil
+}
@@ -0,0 +1,418 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/jobs"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+// testConfig polls often and retries at once so tests run quickly
+var testConfig = jobs.Config{
+	Workers:        2,
+	LeaseDuration:  300 * time.Millisecond,
+	PollInterval:   5 * time.Millisecond,
+	RetryBaseDelay: time.Millisecond,
+	RetryMaxDelay:  5 * time.Millisecond,
+}
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := database.Open(filepath.Join(t.TempDir(), "data.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// run runs the workers of q until the test ends
+func run(t *testing.T, q *jobs.Queue) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func wait(t *testing.T, q *jobs.Queue, id string) *jobs.Job {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := q.Wait(ctx, id)
+	require.NoError(t, err)
+	return job
+}
+
+type sumPayload struct {
+	Values []int `json:"values"`
+}
+
+func TestJobSucceeds(t *testing.T) {
+	q := jobs.New(openDB(t), testConfig)
+	q.Register("sum", jobs.Kind{MaxAttempts: 3, Handler: func(ctx context.Context, task *jobs.Task) (any, error) {
+		var p sumPayload
+		if err := task.Decode(&p); err != nil {
+			return nil, err
+		}
+		total := 0
+		for i, v := range p.Values {
+			total += v
+			task.Progress(int64(i+1), int64(len(p.Values)))
+		}
+		return map[string]int{"total": total}, nil
+	}})
+	run(t, q)
+
+	job, err := q.Enqueue(context.Background(), "sum", sumPayload{Values: []int{1, 2, 3}})
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Queued, job.State)
+
+	job = wait(t, q, job.ID)
+	assert.Equal(t, jobs.Succeeded, job.State)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, jobs.Progress{Done: 3, Total: 3}, job.Progress)
+	assert.JSONEq(t, `{"total":6}`, string(job.Result))
+	assert.NotNil(t, job.StartedAt)
+	assert.NotNil(t, job.FinishedAt)
+
+	_, err = q.Enqueue(context.Background(), "unknown", nil)
+	assert.Error(t, err)
+}
+
+func TestJobRetries(t *testing.T) {
+	q := jobs.New(openDB(t), testConfig)
+	var calls atomic.Int32
+	q.Register("flaky", jobs.Kind{MaxAttempts: 3, Handler: func(ctx context.Context, task *jobs.Task) (any, error) {
+		if calls.Add(1) < 3 {
+			return nil, errors.New("temporary failure")
+		}
+		return "ok", nil
+	}})
+	q.Register("broken", jobs.Kind{MaxAttempts: 2, Handler: func(ctx context.Context, task *jobs.Task) (any, error) {
+		return map[string]int{"done": 1}, apierror.InvalidArgument("bad payload")
+	}})
+	q.Register("invalid", jobs.Kind{MaxAttempts: 5, Handler: func(ctx context.Context, task *jobs.Task) (any, error) {
+		return nil, jobs.Permanent(apierror.InvalidArgument("bad payload"))
+	}})
+	run(t, q)
+
+	flaky, err := q.Enqueue(context.Background(), "flaky", nil)
+	require.NoError(t, err)
+	flaky = wait(t, q, flaky.ID)
+	assert.Equal(t, jobs.Succeeded, flaky.State)
+	assert.Equal(t, 3, flaky.Attempts)
+	assert.Equal(t, "", flaky.Error)
+
+	// Out of attempts; the result of the last one is kept
+	broken, err := q.Enqueue(context.Background(), "broken", nil)
+	require.NoError(t, err)
+	broken = wait(t, q, broken.ID)
+	assert.Equal(t, jobs.Failed, broken.State)
+	assert.Equal(t, 2, broken.Attempts)
+	assert.Equal(t, "bad payload", broken.Error)
+	assert.JSONEq(t, `{"done":1}`, string(broken.Result))
+
+	// Permanent errors are not retried
+	invalid, err := q.Enqueue(context.Background(), "invalid", nil)
+	require.NoError(t, err)
+	invalid = wait(t, q, invalid.ID)
+	assert.Equal(t, jobs.Failed, invalid.State)
+	assert.Equal(t, 1, invalid.Attempts)
+}
+
+func TestIdleWorkersDoNotWrite(t *testing.T) {
+	q := jobs.New(openDB(t), testConfig)
+	q.Register("noop", jobs.Kind{Handler: func(ctx context.Context, task *jobs.Task) (any, error) { return nil, nil }})
+	run(t, q)
+	time.Sleep(20 * time.Millisecond)
+
+	before := database.Stats().Transactions
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, before, database.Stats().Transactions)
+}
+
+func TestFinishedJobsArePruned(t *testing.T) {
+	db := openDB(t)
+	q := jobs.New(db, testConfig)
+	q.Register("noop", jobs.Kind{Handler: func(ctx context.Context, task *jobs.Task) (any, error) { return nil, nil }})
+	ctx := context.Background()
+
+	old, err := q.Do(ctx, "noop", nil, func(ctx context.Context, task *jobs.Task) (any, error) { return nil, nil })
+	require.NoError(t, err)
+	recent, err := q.Do(ctx, "noop", nil, func(ctx context.Context, task *jobs.Task) (any, error) { return nil, nil })
+	require.NoError(t, err)
+	queued, err := q.Enqueue(ctx, "noop", nil)
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE jobs SET finished_at = ? WHERE id = ?", time.Now().Add(-8*24*time.Hour).UTC(), old.ID)
+	require.NoError(t, err)
+
+	n, err := q.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = q.Get(ctx, old.ID)
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, codes.NotFound, apiErr.Code)
+	for _, id := range []string{recent.ID, queued.ID} {
+		_, err = q.Get(ctx, id)
+		assert.NoError(t, err)
+	}
+}
+
+func TestUnexpectedErrorsAreHidden(t *testing.T) {
+	q := jobs.New(openDB(t), testConfig)
+	q.Register("leaky", jobs.Kind{Handler: func(ctx context.Context, task *jobs.Task) (any, error) {
+		return nil, errors.New("open /secret/path: permission denied")
+	}})
+	run(t, q)
+
+	job, err := q.Enqueue(context.Background(), "leaky", nil)
+	require.NoError(t, err)
+	job = wait(t, q, job.ID)
+	assert.Equal(t, jobs.Failed, job.State)
+	assert.NotContains(t, job.Error, "secret")
+	assert.Contains(t, job.Error, "correlation_id")
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	q := jobs.New(openDB(t), testConfig)
+	var discarded atomic.Bool
+	q.Register("slow", jobs.Kind{
+		Handler: func(ctx context.Context, task *jobs.Task) (any, error) { return nil, nil },
+		Discard: func(job *jobs.Job) { discarded.Store(true) },
+	})
+
+	// No workers run, so the job stays queued
+	job, err := q.Enqueue(context.Background(), "slow", nil)
+	require.NoError(t, err)
+	job, err = q.Cancel(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Cancelled, job.State)
+	assert.True(t, discarded.Load())
+
+	// Cancelling again changes nothing
+	job, err = q.Cancel(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Cancelled, job.State)
+
+	_, err = q.Cancel(context.Background(), "missing")
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, codes.NotFound, apiErr.Code)
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	q := jobs.New(openDB(t), testConfig)
+	started := make(chan struct{})
+	q.Register("wait", jobs.Kind{MaxAttempts: 3, Handler: func(ctx context.Context, task *jobs.Task) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return "stopped", ctx.Err()
+	}})
+	run(t, q)
+
+	job, err := q.Enqueue(context.Background(), "wait", nil)
+	require.NoError(t, err)
+	<-started
+	job, err = q.Cancel(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.True(t, job.CancelRequested)
+
+	job = wait(t, q, job.ID)
+	assert.Equal(t, jobs.Cancelled, job.State)
+	assert.Equal(t, 1, job.Attempts)
+	assert.JSONEq(t, `"stopped"`, string(job.Result))
+}
+
+func TestCancelFromAnotherProcess(t *testing.T) {
+	db := openDB(t)
+	worker := jobs.New(db, testConfig)
+	started := make(chan struct{})
+	worker.Register("wait", jobs.Kind{Handler: func(ctx context.Context, task *jobs.Task) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}})
+	run(t, worker)
+
+	// A queue without workers, like a second server sharing the database,
+	// is noticed on the worker's next heartbeat
+	other := jobs.New(db, testConfig)
+	job, err := worker.Enqueue(context.Background(), "wait", nil)
+	require.NoError(t, err)
+	<-started
+	_, err = other.Cancel(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Cancelled, wait(t, other, job.ID).State)
+}
+
+func TestExpiredLeaseIsRecovered(t *testing.T) {
+	db := openDB(t)
+	q := jobs.New(db, testConfig)
+	var discarded atomic.Int32
+	q.Register("work", jobs.Kind{
+		MaxAttempts: 2,
+		Handler:     func(ctx context.Context, task *jobs.Task) (any, error) { return "recovered", nil },
+		Discard:     func(job *jobs.Job) { discarded.Add(1) },
+	})
+
+	retry, err := q.Enqueue(context.Background(), "work", nil)
+	require.NoError(t, err)
+	lastAttempt, err := q.Enqueue(context.Background(), "work", nil)
+	require.NoError(t, err)
+
+	// Pretend a worker that has since crashed claimed both jobs
+	_, err = db.Exec(`UPDATE jobs SET state = 'running', attempts = 1, lease_owner = 'crashed', lease_expires = 1 WHERE id = ?`, retry.ID)
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE jobs SET state = 'running', attempts = 2, lease_owner = 'crashed', lease_expires = 1 WHERE id = ?`, lastAttempt.ID)
+	require.NoError(t, err)
+	run(t, q)
+
+	retry = wait(t, q, retry.ID)
+	assert.Equal(t, jobs.Succeeded, retry.State)
+	assert.Equal(t, 2, retry.Attempts)
+
+	lastAttempt = wait(t, q, lastAttempt.ID)
+	assert.Equal(t, jobs.Failed, lastAttempt.State)
+	assert.Contains(t, lastAttempt.Error, "stopped")
+	assert.Equal(t, int32(1), discarded.Load())
+}
+
+func TestDo(t *testing.T) {
+	q := jobs.New(openDB(t), testConfig)
+	job, err := q.Do(context.Background(), "inline", nil, func(ctx context.Context, task *jobs.Task) (any, error) {
+		task.Progress(1, 1)
+		return []int{1}, errors.New("partial")
+	})
+	assert.EqualError(t, err, "partial")
+	assert.Equal(t, jobs.Failed, job.State)
+	assert.Equal(t, "inline", job.Kind)
+	assert.JSONEq(t, `[1]`, string(job.Result))
+
+	// The caller going away cancels the job
+	ctx, cancel := context.WithCancel(context.Background())
+	job, err = q.Do(ctx, "inline", nil, func(ctx context.Context, task *jobs.Task) (any, error) {
+		cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, jobs.Cancelled, job.State)
+}
+
+func TestJobEndpoints(t *testing.T) {
+	q := jobs.New(openDB(t), testConfig)
+	q.Register("noop", jobs.Kind{Handler: func(ctx context.Context, task *jobs.Task) (any, error) { return nil, nil }})
+	jh := handlers.NewJobHandler(q)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/jobs/{id:[^/:]+}", jh.GetJob).Methods("GET")
+	router.HandleFunc("/api/jobs/{id:[^/:]+}:cancel", jh.CancelJob).Methods("POST")
+
+	job, err := q.Enqueue(context.Background(), "noop", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/jobs/"+job.ID, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	assert.Equal(t, "queued", got["state"])
+	assert.Equal(t, "noop", got["kind"])
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/api/jobs/"+job.ID+":cancel", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	assert.Equal(t, "cancelled", got["state"])
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/jobs/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+}
+
+func TestJobService(t *testing.T) {
+	q := jobs.New(openDB(t), testConfig)
+	release := make(chan struct{})
+	q.Register("steps", jobs.Kind{Handler: func(ctx context.Context, task *jobs.Task) (any, error) {
+		task.Progress(1, 2)
+		<-release
+		return map[string]any{"steps": 2}, nil
+	}})
+	run(t, q)
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpclib.NewServer()
+	pb.RegisterJobServiceServer(s, jobs.NewServer(q))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpclib.NewClient("passthrough:///bufnet",
+		grpclib.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := pb.NewJobServiceClient(conn)
+
+	job, err := q.Enqueue(context.Background(), "steps", nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchJob(ctx, &pb.WatchJobRequest{Id: job.ID})
+	require.NoError(t, err)
+
+	var last *pb.Job
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if msg.Progress.GetDone() == 1 && msg.State == pb.Job_STATE_RUNNING {
+			close(release)
+		}
+		last = msg
+	}
+	assert.Equal(t, pb.Job_STATE_SUCCEEDED, last.State)
+	assert.Equal(t, float64(2), last.Result.GetStructValue().Fields["steps"].GetNumberValue())
+	assert.NotNil(t, last.FinishedAt)
+
+	got, err := client.GetJob(ctx, &pb.GetJobRequest{Id: job.ID})
+	require.NoError(t, err)
+	assert.Equal(t, pb.Job_STATE_SUCCEEDED, got.State)
+
+	_, err = client.CancelJob(ctx, &pb.CancelJobRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,530 @@
+// Package jsonschema validates JSON documents against a subset of JSON
+// Schema draft 2020-12: type, enum and const; the string, number, object
+// and array constraints; and allOf, anyOf, oneOf and not. Schemas using any
+// other keyword, such as $ref, are rejected when compiled, so a constraint
+// is never silently ignored. Numbers are compared exactly.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled schema
+type Schema struct {
+	// always is the result of the true and false schemas
+	always *bool
+
+	types    []string
+	enum     []any
+	constant []any
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	minimum, maximum                   *number
+	exclusiveMinimum, exclusiveMaximum *number
+	multipleOf                         *number
+
+	properties                   map[string]*Schema
+	required                     []string
+	additionalProperties         *Schema
+	minProperties, maxProperties *int
+
+	items              *Schema
+	minItems, maxItems *int
+	uniqueItems        bool
+
+	allOf, anyOf, oneOf []*Schema
+	not                 *Schema
+}
+
+// number is a number in a schema, kept as written for messages
+type number struct {
+	*big.Rat
+	text string
+}
+
+// CompileError lists everything wrong with a schema
+type CompileError struct {
+	Problems []string
+}
+
+func (e *CompileError) Error() string {
+	return "invalid schema: " + strings.Join(e.Problems, "; ")
+}
+
+// Violation is one way a document fails a schema. Path locates the value,
+// such as metadata.size or tags[2], and is empty for the document itself.
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// annotations are keywords that do not constrain documents
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true,
+	"description": true, "default": true, "examples": true,
+}
+
+var typeNames = []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+
+// Compile reads a schema. Errors are *CompileError values.
+func Compile(data []byte) (*Schema, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, &CompileError{Problems: []string{"schema is not valid JSON: " + err.Error()}}
+	}
+	c := &compiler{}
+	s := c.compile(v, "")
+	if len(c.problems) > 0 {
+		return nil, &CompileError{Problems: c.problems}
+	}
+	return s, nil
+}
+
+type compiler struct {
+	problems []string
+}
+
+func (c *compiler) fail(path, format string, args ...any) {
+	if path == "" {
+		path = "schema"
+	}
+	c.problems = append(c.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (c *compiler) compile(v any, path string) *Schema {
+	s := &Schema{}
+	if b, ok := v.(bool); ok {
+		s.always = &b
+		return s
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		c.fail(path, "must be an object or a boolean")
+		return s
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, at := obj[key], join(path, key)
+		switch key {
+		case "type":
+			s.types = c.types(value, at)
+		case "enum":
+			list, ok := value.([]any)
+			if !ok {
+				c.fail(at, "must be an array")
+			}
+			s.enum = list
+		case "const":
+			s.constant = []any{value}
+		case "minLength":
+			s.minLength = c.count(value, at)
+		case "maxLength":
+			s.maxLength = c.count(value, at)
+		case "pattern":
+			p, ok := value.(string)
+			if !ok {
+				c.fail(at, "must be a string")
+				continue
+			}
+			re, err := regexp.Compile(p)
+			if err != nil {
+				c.fail(at, "is not a valid RE2 regular expression: %v", err)
+				continue
+			}
+			s.pattern = re
+		case "minimum":
+			s.minimum = c.number(value, at)
+		case "maximum":
+			s.maximum = c.number(value, at)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum = c.number(value, at)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum = c.number(value, at)
+		case "multipleOf":
+			if s.multipleOf = c.number(value, at); s.multipleOf != nil && s.multipleOf.Sign() <= 0 {
+				c.fail(at, "must be greater than 0")
+			}
+		case "properties":
+			props, ok := value.(map[string]any)
+			if !ok {
+				c.fail(at, "must be an object")
+				continue
+			}
+			s.properties = make(map[string]*Schema, len(props))
+			for name, sub := range props {
+				s.properties[name] = c.compile(sub, join(at, name))
+			}
+		case "required":
+			list, ok := value.([]any)
+			if !ok {
+				c.fail(at, "must be an array of strings")
+				continue
+			}
+			for _, e := range list {
+				name, ok := e.(string)
+				if !ok {
+					c.fail(at, "must be an array of strings")
+					break
+				}
+				s.required = append(s.required, name)
+			}
+		case "additionalProperties":
+			s.additionalProperties = c.compile(value, at)
+		case "minProperties":
+			s.minProperties = c.count(value, at)
+		case "maxProperties":
+			s.maxProperties = c.count(value, at)
+		case "items":
+			s.items = c.compile(value, at)
+		case "minItems":
+			s.minItems = c.count(value, at)
+		case "maxItems":
+			s.maxItems = c.count(value, at)
+		case "uniqueItems":
+			b, ok := value.(bool)
+			if !ok {
+				c.fail(at, "must be a boolean")
+			}
+			s.uniqueItems = b
+		case "allOf":
+			s.allOf = c.list(value, at)
+		case "anyOf":
+			s.anyOf = c.list(value, at)
+		case "oneOf":
+			s.oneOf = c.list(value, at)
+		case "not":
+			s.not = c.compile(value, at)
+		default:
+			if !annotations[key] {
+				c.fail(at, "keyword is not supported")
+			}
+		}
+	}
+	return s
+}
+
+func (c *compiler) types(v any, path string) []string {
+	var names []string
+	switch v := v.(type) {
+	case string:
+		names = []string{v}
+	case []any:
+		for _, e := range v {
+			name, _ := e.(string)
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		c.fail(path, "must be a type name or an array of them")
+	}
+	for _, name := range names {
+		if i := sort.SearchStrings(typeNames, name); i == len(typeNames) || typeNames[i] != name {
+			c.fail(path, "%q is not one of %s", name, strings.Join(typeNames, ", "))
+		}
+	}
+	return names
+}
+
+func (c *compiler) count(v any, path string) *int {
+	r := rat(v)
+	if r == nil || !r.IsInt() || r.Sign() < 0 || !r.Num().IsInt64() || r.Num().Int64() > 1<<31 {
+		c.fail(path, "must be a non-negative integer")
+		return nil
+	}
+	n := int(r.Num().Int64())
+	return &n
+}
+
+func (c *compiler) number(v any, path string) *number {
+	r := rat(v)
+	if r == nil {
+		c.fail(path, "must be a number")
+		return nil
+	}
+	return &number{Rat: r, text: fmt.Sprint(v)}
+}
+
+func (c *compiler) list(v any, path string) []*Schema {
+	list, ok := v.([]any)
+	if !ok || len(list) == 0 {
+		c.fail(path, "must be a non-empty array of schemas")
+		return nil
+	}
+	schemas := make([]*Schema, len(list))
+	for i, e := range list {
+		schemas[i] = c.compile(e, path+"["+strconv.Itoa(i)+"]")
+	}
+	return schemas
+}
+
+// Validate returns every way doc fails the schema, or nil. doc is decoded
+// JSON, preferably with json.Decoder.UseNumber so numbers keep their digits.
+func (s *Schema) Validate(doc any) []Violation {
+	var out []Violation
+	s.validate(doc, "", &out)
+	return out
+}
+
+func (s *Schema) validate(v any, path string, out *[]Violation) {
+	fail := func(format string, args ...any) {
+		*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.always != nil {
+		if !*s.always {
+			fail("is not allowed")
+		}
+		return
+	}
+	if len(s.types) > 0 && !s.hasType(v) {
+		fail("must be of type %s", strings.Join(s.types, " or "))
+		return
+	}
+	if s.enum != nil && !contains(s.enum, v) {
+		fail("must be one of %s", describe(s.enum))
+	}
+	if s.constant != nil && !equal(s.constant[0], v) {
+		fail("must be %s", describe(s.constant))
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match the pattern %s", s.pattern)
+		}
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				*out = append(*out, Violation{Path: join(path, name), Message: "is required"})
+			}
+		}
+		if s.minProperties != nil && len(v) < *s.minProperties {
+			fail("must have at least %d properties", *s.minProperties)
+		}
+		if s.maxProperties != nil && len(v) > *s.maxProperties {
+			fail("must have at most %d properties", *s.maxProperties)
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if sub, ok := s.properties[name]; ok {
+				sub.validate(v[name], join(path, name), out)
+			} else if s.additionalProperties != nil {
+				s.additionalProperties.validate(v[name], join(path, name), out)
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.uniqueItems {
+			for i := range v {
+				if contains(v[:i], v[i]) {
+					fail("must not contain duplicates")
+					break
+				}
+			}
+		}
+		if s.items != nil {
+			for i, e := range v {
+				s.items.validate(e, path+"["+strconv.Itoa(i)+"]", out)
+			}
+		}
+	default:
+		if r := rat(v); r != nil {
+			s.validateNumber(r, fail)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(v, path, out)
+	}
+	if s.anyOf != nil && s.matches(s.anyOf, v) == 0 {
+		fail("must match at least one schema of anyOf")
+	}
+	if s.oneOf != nil {
+		if n := s.matches(s.oneOf, v); n != 1 {
+			fail("must match exactly one schema of oneOf, matched %d", n)
+		}
+	}
+	if s.not != nil && len(s.not.Validate(v)) == 0 {
+		fail("must not match the schema of not")
+	}
+}
+
+func (s *Schema) validateNumber(r *big.Rat, fail func(string, ...any)) {
+	if s.minimum != nil && r.Cmp(s.minimum.Rat) < 0 {
+		fail("must be at least %s", s.minimum.text)
+	}
+	if s.maximum != nil && r.Cmp(s.maximum.Rat) > 0 {
+		fail("must be at most %s", s.maximum.text)
+	}
+	if s.exclusiveMinimum != nil && r.Cmp(s.exclusiveMinimum.Rat) <= 0 {
+		fail("must be greater than %s", s.exclusiveMinimum.text)
+	}
+	if s.exclusiveMaximum != nil && r.Cmp(s.exclusiveMaximum.Rat) >= 0 {
+		fail("must be less than %s", s.exclusiveMaximum.text)
+	}
+	if s.multipleOf != nil && !new(big.Rat).Quo(r, s.multipleOf.Rat).IsInt() {
+		fail("must be a multiple of %s", s.multipleOf.text)
+	}
+}
+
+// matches counts the schemas v is valid against
+func (s *Schema) matches(schemas []*Schema, v any) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.Validate(v)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Schema) hasType(v any) bool {
+	for _, t := range s.types {
+		switch t {
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "object":
+			if _, ok := v.(map[string]any); ok {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]any); ok {
+				return true
+			}
+		case "number":
+			if rat(v) != nil {
+				return true
+			}
+		case "integer":
+			if r := rat(v); r != nil && r.IsInt() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rat returns a JSON number exactly, or nil for other values
+func rat(v any) *big.Rat {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = v.String()
+	case float64:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	case int:
+		s = strconv.Itoa(v)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	default:
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+// equal compares JSON values, numbers by value
+func equal(a, b any) bool {
+	if ra, rb := rat(a), rat(b); ra != nil || rb != nil {
+		return ra != nil && rb != nil && ra.Cmp(rb) == 0
+	}
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func contains(list []any, v any) bool {
+	for _, e := range list {
+		if equal(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe writes values for messages, e.g. "a", "b" or 3
+func describe(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		b, _ := json.Marshal(v)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// join appends a property name to a path
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
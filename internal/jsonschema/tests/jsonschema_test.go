@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+// decode reads JSON the way the items package does, keeping number digits
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	d := json.NewDecoder(bytes.NewReader([]byte(s)))
+	d.UseNumber()
+	var v any
+	require.NoError(t, d.Decode(&v))
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := jsonschema.Compile([]byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "Item",
+		"type": "object",
+		"required": ["name", "value"],
+		"properties": {
+			"name": {"type": "string", "minLength": 3, "maxLength": 5, "pattern": "^\\p{Lu}"},
+			"value": {"type": "number", "minimum": 0, "exclusiveMaximum": 100, "multipleOf": 0.01},
+			"tags": {"type": "array", "items": {"enum": ["a", "b"]}, "maxItems": 2, "uniqueItems": true},
+			"metadata": {
+				"type": "object",
+				"properties": {"size": {"type": "integer"}},
+				"additionalProperties": false
+			}
+		}
+	}`))
+	require.NoError(t, err)
+
+	assert.Empty(t, schema.Validate(decode(t, `{"name": "Ünï", "value": 99.99, "tags": ["a"], "metadata": {"size": 3.0}}`)))
+
+	got := schema.Validate(decode(t, `{
+		"name": "ab",
+		"value": 100.001,
+		"tags": ["a", "c", "a"],
+		"metadata": {"size": 1.5, "color": "red"}
+	}`))
+	assert.ElementsMatch(t, []jsonschema.Violation{
+		{Path: "name", Message: "must be at least 3 characters"},
+		{Path: "name", Message: `must match the pattern ^\p{Lu}`},
+		{Path: "value", Message: "must be less than 100"},
+		{Path: "value", Message: "must be a multiple of 0.01"},
+		{Path: "tags", Message: "must have at most 2 items"},
+		{Path: "tags", Message: "must not contain duplicates"},
+		{Path: "tags[1]", Message: `must be one of "a", "b"`},
+		{Path: "metadata.size", Message: "must be of type integer"},
+		{Path: "metadata.color", Message: "is not allowed"},
+	}, got)
+
+	assert.Equal(t, []jsonschema.Violation{{Path: "value", Message: "is required"}},
+		schema.Validate(decode(t, `{"name": "Abc"}`)))
+	assert.Equal(t, []jsonschema.Violation{{Path: "", Message: "must be of type object"}},
+		schema.Validate(decode(t, `[]`)))
+}
+
+func TestCombinators(t *testing.T) {
+	schema, err := jsonschema.Compile([]byte(`{
+		"anyOf": [{"type": "string"}, {"type": "null"}],
+		"not": {"const": "forbidden"},
+		"oneOf": [
+			{"type": "string", "maxLength": 4},
+			{"type": "string", "minLength": 2},
+			{"type": "null"}
+		]
+	}`))
+	require.NoError(t, err)
+
+	assert.Empty(t, schema.Validate("a"))
+	assert.Empty(t, schema.Validate(nil))
+	assert.Equal(t, []jsonschema.Violation{
+		{Message: "must match at least one schema of anyOf"},
+		{Message: "must match exactly one schema of oneOf, matched 0"},
+	}, schema.Validate(decode(t, `3`)))
+	assert.Equal(t, []jsonschema.Violation{{Message: "must match exactly one schema of oneOf, matched 2"}},
+		schema.Validate("abc"))
+	assert.Len(t, schema.Validate("forbidden"), 1)
+}
+
+func TestNumbersAreExact(t *testing.T) {
+	schema, err := jsonschema.Compile([]byte(`{"const": 1.10, "maximum": 9007199254740993}`))
+	require.NoError(t, err)
+
+	assert.Empty(t, schema.Validate(decode(t, `1.1`)))
+	assert.Empty(t, schema.Validate(decode(t, `11e-1`)))
+	assert.Len(t, schema.Validate(decode(t, `1.1000000000000001`)), 1)
+
+	schema, err = jsonschema.Compile([]byte(`{"maximum": 9007199254740992}`))
+	require.NoError(t, err)
+	assert.Len(t, schema.Validate(decode(t, `9007199254740993`)), 1)
+}
+
+func TestCompileErrors(t *testing.T) {
+	_, err := jsonschema.Compile([]byte(`{
+		"type": "text",
+		"minLength": -1,
+		"pattern": "(",
+		"$ref": "#/definitions/x",
+		"properties": {"a": 3},
+		"allOf": []
+	}`))
+	var compileErr *jsonschema.CompileError
+	require.True(t, errors.As(err, &compileErr))
+	assert.Equal(t, []string{
+		"$ref: keyword is not supported",
+		"allOf: must be a non-empty array of schemas",
+		"minLength: must be a non-negative integer",
+		"pattern: is not a valid RE2 regular expression: error parsing regexp: missing closing ): `(`",
+		"properties.a: must be an object or a boolean",
+		`type: "text" is not one of array, boolean, integer, null, number, object, string`,
+	}, compileErr.Problems)
+
+	_, err = jsonschema.Compile([]byte(`{`))
+	assert.Error(t, err)
+
+	schema, err := jsonschema.Compile([]byte(`false`))
+	require.NoError(t, err)
+	assert.Equal(t, []jsonschema.Violation{{Message: "is not allowed"}}, schema.Validate("x"))
+}
@@ -0,0 +1,189 @@
+package loadshed
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrOverloaded is returned when a request is shed instead of queued
+var ErrOverloaded = errors.New("server overloaded")
+
+// Config tunes the adaptive concurrency limit and the wait queue
+type Config struct {
+	// InitialLimit is the starting number of concurrent writes
+	InitialLimit int
+	// MinLimit and MaxLimit bound the adaptive limit
+	MinLimit int
+	MaxLimit int
+	// MaxQueue is how many requests may wait for a slot; extra requests are
+	// shed immediately
+	MaxQueue int
+	// QueueTimeout is the longest a request waits for a slot
+	QueueTimeout time.Duration
+	// LatencyTarget is the latency above which the limit is reduced
+	LatencyTarget time.Duration
+	// Backoff is the multiplicative decrease applied on slow or overloaded
+	// requests, between 0 and 1
+	Backoff float64
+	// Bypass lists HTTP path and gRPC method prefixes that skip the limiter,
+	// such as health checks and admin calls
+	Bypass []string
+}
+
+// DefaultConfig returns a configuration suited to a single SQLite writer
+func DefaultConfig() Config {
+	return Config{
+		InitialLimit:  4,
+		MinLimit:      1,
+		MaxLimit:      32,
+		MaxQueue:      128,
+		QueueTimeout:  time.Second,
+		LatencyTarget: 100 * time.Millisecond,
+		Backoff:       0.75,
+		Bypass:        []string{"/api/health", "/api/admin/", "/grpc.health.v1.Health/", "/grpc.reflection."},
+	}
+}
+
+// Stats is a snapshot of the limiter state
+type Stats struct {
+	Limit    int
+	InFlight int
+	Queued   int
+	Shed     int64
+}
+
+// Limiter is an AIMD concurrency limiter: the limit grows by one slot per
+// window of fast requests and shrinks multiplicatively when requests are slow
+// or report overload. Requests over the limit wait in a bounded FIFO queue.
+type Limiter struct {
+	cfg Config
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	queue    list.List
+	shed     int64
+}
+
+// NewLimiter creates a limiter, filling unset fields from DefaultConfig
+func NewLimiter(cfg Config) *Limiter {
+	def := DefaultConfig()
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = def.MinLimit
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = max(def.MaxLimit, cfg.MinLimit)
+	}
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = def.InitialLimit
+	}
+	cfg.InitialLimit = min(max(cfg.InitialLimit, cfg.MinLimit), cfg.MaxLimit)
+	if cfg.MaxQueue < 0 {
+		cfg.MaxQueue = 0
+	}
+	if cfg.LatencyTarget <= 0 {
+		cfg.LatencyTarget = def.LatencyTarget
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = def.Backoff
+	}
+	if cfg.Bypass == nil {
+		cfg.Bypass = def.Bypass
+	}
+	return &Limiter{cfg: cfg, limit: float64(cfg.InitialLimit)}
+}
+
+// Token is a granted slot. Done must be called exactly once.
+type Token struct {
+	l     *Limiter
+	start time.Time
+}
+
+// Acquire takes a slot, waiting up to QueueTimeout for one to free up. It
+// returns ErrOverloaded when the queue is full or the wait times out.
+func (l *Limiter) Acquire(ctx context.Context) (*Token, error) {
+	l.mu.Lock()
+	if l.inflight < l.capacity() && l.queue.Len() == 0 {
+		l.inflight++
+		l.mu.Unlock()
+		return &Token{l: l, start: time.Now()}, nil
+	}
+	if l.queue.Len() >= l.cfg.MaxQueue {
+		l.shed++
+		l.mu.Unlock()
+		return nil, ErrOverloaded
+	}
+	ready := make(chan struct{})
+	elem := l.queue.PushBack(ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		return &Token{l: l, start: time.Now()}, nil
+	case <-timer.C:
+		err = ErrOverloaded
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-ready:
+		// The slot was granted while we were giving up; hand it on
+		l.inflight--
+		l.grant()
+	default:
+		l.queue.Remove(elem)
+	}
+	if err == ErrOverloaded {
+		l.shed++
+	}
+	return nil, err
+}
+
+// Done releases the slot. overloaded reports that the request failed because
+// the backend was saturated, such as a SQLite busy error.
+func (t *Token) Done(overloaded bool) {
+	l := t.l
+	latency := time.Since(t.start)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	if overloaded || latency > l.cfg.LatencyTarget {
+		l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*l.cfg.Backoff)
+	} else {
+		l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+	}
+	l.grant()
+}
+
+// Stats returns the current limit, usage and number of shed requests
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{Limit: l.capacity(), InFlight: l.inflight, Queued: l.queue.Len(), Shed: l.shed}
+}
+
+// capacity is the whole number of slots allowed by the current limit
+func (l *Limiter) capacity() int {
+	return int(l.limit)
+}
+
+// grant hands free slots to queued requests in FIFO order
+func (l *Limiter) grant() {
+	for l.inflight < l.capacity() && l.queue.Len() > 0 {
+		ready := l.queue.Remove(l.queue.Front()).(chan struct{})
+		l.inflight++
+		close(ready)
+	}
+}
@@ -0,0 +1,121 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ReasonOverloaded is the error reason returned for shed requests
+const ReasonOverloaded = "OVERLOADED"
+
+// overloaded builds the error returned to shed clients
+func overloaded() *apierror.Error {
+	return &apierror.Error{
+		Code:       codes.Unavailable,
+		Reason:     ReasonOverloaded,
+		Message:    "server is overloaded, please retry",
+		RetryAfter: time.Second,
+	}
+}
+
+// bypass reports whether path skips the limiter
+func (l *Limiter) bypass(path string) bool {
+	for _, prefix := range l.cfg.Bypass {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware limits concurrent HTTP writes. Reads and bypassed paths are
+// passed through; shed writes get 503 with Retry-After.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.bypass(r.URL.Path) || !ratelimit.IsWriteRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := l.Acquire(r.Context())
+		if err != nil {
+			if errors.Is(err, ErrOverloaded) {
+				apierror.Write(w, r, overloaded())
+			}
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		token.Done(sw.status == http.StatusServiceUnavailable)
+	})
+}
+
+// UnaryInterceptor limits concurrent write RPCs, returning UNAVAILABLE with
+// RetryInfo when the request is shed
+func (l *Limiter) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if l.bypass(info.FullMethod) || !ratelimit.IsWriteRPC(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	token, err := l.Acquire(ctx)
+	if err != nil {
+		if errors.Is(err, ErrOverloaded) {
+			return nil, overloaded()
+		}
+		return nil, status.FromContextError(err).Err()
+	}
+	resp, err := handler(ctx, req)
+	token.Done(status.Code(err) == codes.Unavailable)
+	return resp, err
+}
+
+// StreamInterceptor limits concurrent streaming write RPCs, such as
+// uploads and streaming imports, for as long as the stream is open. Shed
+// streams fail like shed unary calls.
+func (l *Limiter) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if l.bypass(info.FullMethod) || !ratelimit.IsWriteRPC(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	token, err := l.Acquire(ss.Context())
+	if err != nil {
+		if errors.Is(err, ErrOverloaded) {
+			return overloaded()
+		}
+		return status.FromContextError(err).Err()
+	}
+	err = handler(srv, ss)
+	token.Done(status.Code(err) == codes.Unavailable)
+	return err
+}
+
+// statusWriter records the response status code
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/loadshed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+// newLimiter creates a limiter with a single slot
+func newLimiter(queue int, timeout time.Duration) *loadshed.Limiter {
+	return loadshed.NewLimiter(loadshed.Config{
+		InitialLimit:  1,
+		MinLimit:      1,
+		MaxLimit:      1,
+		MaxQueue:      queue,
+		QueueTimeout:  timeout,
+		LatencyTarget: time.Minute,
+	})
+}
+
+func TestQueueAndShed(t *testing.T) {
+	l := newLimiter(1, time.Second)
+	ctx := context.Background()
+
+	held, err := l.Acquire(ctx)
+	require.NoError(t, err)
+
+	// The next request waits in the queue for the held slot
+	acquired := make(chan error, 1)
+	go func() {
+		token, err := l.Acquire(ctx)
+		if err == nil {
+			token.Done(false)
+		}
+		acquired <- err
+	}()
+	require.Eventually(t, func() bool { return l.Stats().Queued == 1 }, time.Second, time.Millisecond)
+
+	// The queue is full, so further requests are shed immediately
+	_, err = l.Acquire(ctx)
+	assert.ErrorIs(t, err, loadshed.ErrOverloaded)
+
+	held.Done(false)
+	assert.NoError(t, <-acquired)
+
+	stats := l.Stats()
+	assert.Equal(t, 0, stats.InFlight)
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, int64(1), stats.Shed)
+}
+
+func TestQueueTimeout(t *testing.T) {
+	l := newLimiter(1, 20*time.Millisecond)
+	held, err := l.Acquire(context.Background())
+	require.NoError(t, err)
+	defer held.Done(false)
+
+	start := time.Now()
+	_, err = l.Acquire(context.Background())
+	assert.ErrorIs(t, err, loadshed.ErrOverloaded)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, 0, l.Stats().Queued)
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	l := loadshed.NewLimiter(loadshed.Config{
+		InitialLimit:  8,
+		MinLimit:      2,
+		MaxLimit:      10,
+		LatencyTarget: time.Minute,
+		Backoff:       0.5,
+	})
+	ctx := context.Background()
+
+	// Overload signals halve the limit down to the minimum
+	for i := 0; i < 5; i++ {
+		token, err := l.Acquire(ctx)
+		require.NoError(t, err)
+		token.Done(true)
+	}
+	assert.Equal(t, 2, l.Stats().Limit)
+
+	// Fast successful requests grow it additively up to the maximum
+	for i := 0; i < 100; i++ {
+		token, err := l.Acquire(ctx)
+		require.NoError(t, err)
+		token.Done(false)
+	}
+	assert.Equal(t, 10, l.Stats().Limit)
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	l := newLimiter(0, time.Second)
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Occupy the only write slot
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/items", nil))
+		done <- w.Code
+	}()
+	<-entered
+
+	// Writes are shed with a problem document and Retry-After
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/items", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	var problem apierror.Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, loadshed.ReasonOverloaded, problem.Code)
+
+	// Reads and health checks bypass the limiter
+	for _, path := range []string{"/api/items", "/api/health"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+}
+
+func TestGRPCInterceptor(t *testing.T) {
+	l := newLimiter(0, time.Second)
+	held, err := l.Acquire(context.Background())
+	require.NoError(t, err)
+	defer held.Done(false)
+
+	called := false
+	handler := func(ctx context.Context, req any) (any, error) {
+		called = true
+		return "ok", nil
+	}
+
+	_, err = l.UnaryInterceptor(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/proto.ItemService/CreateItem"}, handler)
+	st := status.Convert(err)
+	assert.Equal(t, codes.Unavailable, st.Code())
+	assert.False(t, called)
+
+	var retry *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	require.NotNil(t, retry)
+	assert.Equal(t, time.Second, retry.RetryDelay.AsDuration())
+
+	// Reads are not limited
+	resp, err := l.UnaryInterceptor(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/proto.ItemService/ListItems"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	// Streaming writes are limited too
+	called = false
+	stream := func(srv any, ss grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+	err = l.StreamInterceptor(nil, serverStream{}, &grpc.StreamServerInfo{FullMethod: "/proto.AttachmentService/UploadAttachment"}, stream)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.False(t, called)
+
+	err = l.StreamInterceptor(nil, serverStream{}, &grpc.StreamServerInfo{FullMethod: "/proto.JobService/WatchJob"}, stream)
+	require.NoError(t, err)
+	assert.True(t, called)
+}
+
+// serverStream is a grpc.ServerStream with a background context
+type serverStream struct {
+	grpc.ServerStream
+}
+
+func (serverStream) Context() context.Context {
+	return context.Background()
+}
@@ -1,11 +1,26 @@
 package models
 
-import "time"
+import (
+	"time"
 
-// Item represents a basic item in the database
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// Item represents a basic item in the database. Value is exact and rounded
+// to the minor units of Currency, an ISO 4217 code or empty. Tags are
+// lowercase, sorted and unique. Metadata holds free-form attributes as
+// decoded from a JSON object. ParentID is the item this one belongs under,
+// or empty for a root. UpdatedAt is maintained by the server.
 type Item struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Value     float64   `json:"value"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Value       money.Decimal  `json:"value"`
+	Description string         `json:"description"`
+	Tags        []string       `json:"tags"`
+	Category    string         `json:"category"`
+	Currency    string         `json:"currency"`
+	Metadata    map[string]any `json:"metadata"`
+	ParentID    string         `json:"parent_id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
 }
@@ -0,0 +1,59 @@
+package money
+
+// minorUnits are the decimal places of the active ISO 4217 currency codes
+var minorUnits = map[string]int{}
+
+func init() {
+	for _, group := range []struct {
+		places int
+		codes  []string
+	}{
+		{0, []string{
+			"BIF", "CLP", "DJF", "GNF", "ISK", "JPY", "KMF", "KRW", "PYG", "RWF",
+			"UGX", "UYI", "VND", "VUV", "XAF", "XOF", "XPF",
+		}},
+		{2, []string{
+			"AED", "AFN", "ALL", "AMD", "ANG", "AOA", "ARS", "AUD", "AWG", "AZN",
+			"BAM", "BBD", "BDT", "BGN", "BMD", "BND", "BOB", "BOV", "BRL", "BSD",
+			"BTN", "BWP", "BYN", "BZD", "CAD", "CDF", "CHE", "CHF", "CHW", "CNY",
+			"COP", "COU", "CRC", "CUP", "CVE", "CZK", "DKK", "DOP", "DZD", "EGP",
+			"ERN", "ETB", "EUR", "FJD", "FKP", "GBP", "GEL", "GHS", "GIP", "GMD",
+			"GTQ", "GYD", "HKD", "HNL", "HTG", "HUF", "IDR", "ILS", "INR", "IRR",
+			"JMD", "KES", "KGS", "KHR", "KPW", "KYD", "KZT", "LAK", "LBP", "LKR",
+			"LRD", "LSL", "MAD", "MDL", "MGA", "MKD", "MMK", "MNT", "MOP", "MRU",
+			"MUR", "MVR", "MWK", "MXN", "MXV", "MYR", "MZN", "NAD", "NGN", "NIO",
+			"NOK", "NPR", "NZD", "PAB", "PEN", "PGK", "PHP", "PKR", "PLN", "QAR",
+			"RON", "RSD", "RUB", "SAR", "SBD", "SCR", "SDG", "SEK", "SGD", "SHP",
+			"SLE", "SOS", "SRD", "SSP", "STN", "SVC", "SYP", "SZL", "THB", "TJS",
+			"TMT", "TOP", "TRY", "TTD", "TWD", "TZS", "UAH", "USD", "USN", "UYU",
+			"UZS", "VED", "VES", "WST", "XCD", "XCG", "YER", "ZAR", "ZMW", "ZWG",
+		}},
+		{3, []string{"BHD", "IQD", "JOD", "KWD", "LYD", "OMR", "TND"}},
+		{4, []string{"CLF", "UYW"}},
+		// Precious metals, units of account and codes for testing have no
+		// minor unit
+		{Scale, []string{
+			"XAG", "XAU", "XBA", "XBB", "XBC", "XBD", "XDR", "XPD", "XPT", "XSU",
+			"XTS", "XUA", "XXX",
+		}},
+	} {
+		for _, code := range group.codes {
+			minorUnits[code] = group.places
+		}
+	}
+}
+
+// IsCurrency reports whether code is an active ISO 4217 code, in upper case
+func IsCurrency(code string) bool {
+	_, ok := minorUnits[code]
+	return ok
+}
+
+// MinorUnits returns the decimal places of a currency, or Scale for a code
+// that is not a currency
+func MinorUnits(code string) int {
+	if places, ok := minorUnits[code]; ok {
+		return places
+	}
+	return Scale
+}
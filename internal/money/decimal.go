@@ -0,0 +1,245 @@
+// Package money represents item values exactly. A Decimal keeps the digits
+// it was written with; Rules round it to the places of its currency before
+// it is stored as an integer count of 10^-Scale units.
+package money
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places values are stored with. No ISO 4217
+// currency has more minor units.
+const Scale = 4
+
+// MaxPlaces bounds the decimal places a Decimal may be written with. Any
+// float64 written in its shortest form has fewer.
+const MaxPlaces = 400
+
+var (
+	ErrSyntax = errors.New("not a decimal number")
+	ErrRange  = errors.New("number out of range")
+)
+
+// pow10 are the powers of ten that fit an int64
+var pow10 [19]int64
+
+func init() {
+	pow10[0] = 1
+	for i := 1; i < len(pow10); i++ {
+		pow10[i] = pow10[i-1] * 10
+	}
+}
+
+// Decimal is an exact decimal number: coef × 10^-places. The zero value is 0.
+type Decimal struct {
+	coef   int64
+	places int
+}
+
+// Parse reads a decimal such as -12.50 or 1.5e3. More significant digits
+// than fit an int64, or more than MaxPlaces decimal places, are ErrRange.
+func Parse(s string) (Decimal, error) {
+	var d Decimal
+	mantissa, exponent, hasExp := strings.Cut(strings.ToLower(s), "e")
+	neg := false
+	switch {
+	case strings.HasPrefix(mantissa, "-"):
+		neg, mantissa = true, mantissa[1:]
+	case strings.HasPrefix(mantissa, "+"):
+		mantissa = mantissa[1:]
+	}
+	whole, frac, _ := strings.Cut(mantissa, ".")
+	if whole == "" && frac == "" {
+		return d, ErrSyntax
+	}
+	for _, c := range whole + frac {
+		if c < '0' || c > '9' {
+			return d, ErrSyntax
+		}
+		if d.coef > (math.MaxInt64-int64(c-'0'))/10 {
+			return d, ErrRange
+		}
+		d.coef = d.coef*10 + int64(c-'0')
+	}
+	d.places = len(frac)
+	if hasExp {
+		e, err := strconv.Atoi(exponent)
+		if err != nil {
+			return d, ErrSyntax
+		}
+		if e > MaxPlaces || e < -MaxPlaces {
+			return d, ErrRange
+		}
+		d.places -= e
+	}
+	if neg {
+		d.coef = -d.coef
+	}
+	if d.places < 0 {
+		return d.Round(0, Down)
+	}
+	d = d.Trim(MaxPlaces)
+	if d.places > MaxPlaces {
+		return d, ErrRange
+	}
+	return d, nil
+}
+
+// MustParse is Parse for constants; it panics on an invalid decimal
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic("money: " + strconv.Quote(s) + ": " + err.Error())
+	}
+	return d
+}
+
+// FromUnits returns the value of a count of 10^-Scale units
+func FromUnits(units int64) Decimal {
+	return Decimal{coef: units, places: Scale}
+}
+
+// Units returns the value as a count of 10^-Scale units. A value with more
+// than Scale places must be rounded first.
+func (d Decimal) Units() (int64, error) {
+	if d.places > Scale {
+		return 0, ErrRange
+	}
+	return d.rescale(Scale)
+}
+
+// Round returns the value with exactly places decimal places, rounded by
+// mode when it has more. Values that no longer fit are ErrRange.
+func (d Decimal) Round(places int, mode Mode) (Decimal, error) {
+	if places < 0 {
+		return d, ErrRange
+	}
+	if d.places <= places {
+		coef, err := d.rescale(places)
+		return Decimal{coef: coef, places: places}, err
+	}
+
+	// q is the value truncated to places and r the dropped digits; half
+	// compares |r| with half a unit of q
+	var q, r int64
+	var half int
+	if shift := d.places - places; shift < len(pow10) {
+		div := pow10[shift]
+		q, r = d.coef/div, d.coef%div
+		half = cmpUint(absUint(r)*2, uint64(div))
+	} else {
+		// A unit of q exceeds any int64, so q is 0 and r the whole value,
+		// which only reaches half a unit when shift is 19
+		r, half = d.coef, -1
+		if shift == len(pow10) {
+			half = cmpUint(absUint(r), 5*uint64(pow10[len(pow10)-1]))
+		}
+	}
+	if r != 0 && mode.awayFromZero(q, r, half) {
+		if d.coef < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Decimal{coef: q, places: places}, nil
+}
+
+// Trim drops trailing zero decimals, keeping at least places of them
+func (d Decimal) Trim(places int) Decimal {
+	for d.places > places && d.coef%10 == 0 {
+		d.coef /= 10
+		d.places--
+	}
+	return d
+}
+
+// Equal reports whether two decimals have the same value
+func (d Decimal) Equal(e Decimal) bool {
+	return d.Trim(0) == e.Trim(0)
+}
+
+// Float64 returns the nearest float64, for clients that cannot take decimals
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String formats the value with all of its decimal places, e.g. 9.50
+func (d Decimal) String() string {
+	digits := strconv.FormatUint(absUint(d.coef), 10)
+	if len(digits) <= d.places {
+		digits = strings.Repeat("0", d.places-len(digits)+1) + digits
+	}
+	if d.places > 0 {
+		i := len(digits) - d.places
+		digits = digits[:i] + "." + digits[i:]
+	}
+	if d.coef < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalJSON writes the value as a string, so no precision is lost to
+// clients that read JSON numbers as floats
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON reads a string or a number. Numbers are read from their
+// digits, so 0.1 is exactly 0.1.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// rescale returns the coefficient of the value written with places decimal
+// places, which must be at least d.places
+func (d Decimal) rescale(places int) (int64, error) {
+	shift := places - d.places
+	if shift >= len(pow10) {
+		if d.coef == 0 {
+			return 0, nil
+		}
+		return 0, ErrRange
+	}
+	p := pow10[shift]
+	if d.coef > math.MaxInt64/p || d.coef < math.MinInt64/p {
+		return 0, ErrRange
+	}
+	return d.coef * p, nil
+}
+
+// cmpUint returns -1, 0 or 1 as a is less than, equal to or greater than b
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// absUint returns |v|, also for math.MinInt64
+func absUint(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
@@ -0,0 +1,170 @@
+package money
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Mode decides which way a value between two representable ones is rounded
+type Mode int
+
+const (
+	// HalfEven rounds to the nearest value, ties to the even digit
+	HalfEven Mode = iota
+	// HalfUp rounds to the nearest value, ties away from zero
+	HalfUp
+	// HalfDown rounds to the nearest value, ties towards zero
+	HalfDown
+	// Up rounds away from zero
+	Up
+	// Down truncates towards zero
+	Down
+	// Ceiling rounds towards positive infinity
+	Ceiling
+	// Floor rounds towards negative infinity
+	Floor
+)
+
+var modeNames = []string{"half-even", "half-up", "half-down", "up", "down", "ceiling", "floor"}
+
+// ParseMode reads a mode by name, e.g. half-even
+func ParseMode(s string) (Mode, error) {
+	for i, name := range modeNames {
+		if s == name {
+			return Mode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rounding mode %q, expected one of %s", s, strings.Join(modeNames, ", "))
+}
+
+// String returns the name of the mode
+func (m Mode) String() string {
+	return modeNames[m]
+}
+
+// awayFromZero reports whether the quotient q is rounded away from zero. r
+// is the non-zero remainder, with the sign of the dividend, and half
+// compares |r| with half a unit of q.
+func (m Mode) awayFromZero(q, r int64, half int) bool {
+	switch m {
+	case HalfUp:
+		return half >= 0
+	case HalfDown:
+		return half > 0
+	case Up:
+		return true
+	case Down:
+		return false
+	case Ceiling:
+		return r > 0
+	case Floor:
+		return r < 0
+	default:
+		return half > 0 || half == 0 && q%2 != 0
+	}
+}
+
+// Rule is how values in one currency are rounded
+type Rule struct {
+	Places int
+	Mode   Mode
+}
+
+// Rules round values to the minor units of their currency. Values without a
+// currency keep up to Scale places. The zero Rules round half-even.
+type Rules struct {
+	// Mode rounds every currency without a rule of its own
+	Mode Mode
+	// Currencies overrides the rule of single currencies
+	Currencies map[string]Rule
+}
+
+// Rule returns the rule for a currency, or for values without one when
+// currency is empty
+func (r Rules) Rule(currency string) Rule {
+	if rule, ok := r.Currencies[currency]; ok {
+		return rule
+	}
+	if currency == "" {
+		return Rule{Places: Scale, Mode: r.Mode}
+	}
+	return Rule{Places: MinorUnits(currency), Mode: r.Mode}
+}
+
+// Round rounds a value by the rule of its currency. Values in a currency
+// are written with its places, e.g. 9.50 in USD; values without a currency
+// drop trailing zeros.
+func (r Rules) Round(d Decimal, currency string) (Decimal, error) {
+	rule := r.Rule(currency)
+	d, err := d.Round(rule.Places, rule.Mode)
+	if err != nil {
+		return d, err
+	}
+	return r.Trim(d, currency), nil
+}
+
+// Trim writes a stored value the way Round does, without rounding it, so a
+// value stored before the rules changed reads back unchanged
+func (r Rules) Trim(d Decimal, currency string) Decimal {
+	if currency == "" {
+		return d.Trim(0)
+	}
+	return d.Trim(r.Rule(currency).Places)
+}
+
+// ParseRules reads comma-separated CODE=PLACES:MODE rules, where either part
+// may be left out, e.g. JPY=0,CHF=half-up,BHD=2:down. *=MODE sets the mode
+// of every other currency.
+func ParseRules(spec string) (Rules, error) {
+	rules := Rules{Mode: HalfEven, Currencies: make(map[string]Rule)}
+	if strings.TrimSpace(spec) == "" {
+		return rules, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		code, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return rules, fmt.Errorf("rounding rule %q is not CODE=PLACES:MODE", entry)
+		}
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "*" {
+			mode, err := ParseMode(strings.TrimSpace(value))
+			if err != nil {
+				return rules, err
+			}
+			rules.Mode = mode
+			continue
+		}
+		if !IsCurrency(code) {
+			return rules, fmt.Errorf("rounding rule for %q: not an ISO 4217 currency code", code)
+		}
+		rule := Rule{Places: -1, Mode: -1}
+		for _, part := range strings.Split(value, ":") {
+			part = strings.TrimSpace(part)
+			if places, err := strconv.Atoi(part); err == nil {
+				if places < 0 || places > Scale {
+					return rules, fmt.Errorf("rounding rule for %s: places must be between 0 and %d", code, Scale)
+				}
+				rule.Places = places
+				continue
+			}
+			mode, err := ParseMode(part)
+			if err != nil {
+				return rules, fmt.Errorf("rounding rule for %s: %w", code, err)
+			}
+			rule.Mode = mode
+		}
+		if rule.Places < 0 {
+			rule.Places = MinorUnits(code)
+		}
+		rules.Currencies[code] = rule
+	}
+	// Currencies without a mode of their own follow *, wherever it appears
+	for code, rule := range rules.Currencies {
+		if rule.Mode < 0 {
+			rule.Mode = rules.Mode
+			rules.Currencies[code] = rule
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0", "0"},
+		{"9.50", "9.50"},
+		{"-12.345", "-12.345"},
+		{"+.5", "0.5"},
+		{"1.5e3", "1500"},
+		{"125E-2", "1.25"},
+		{"0.1", "0.1"},
+		{"9223372036854775807", "9223372036854775807"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := money.Parse(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, d.String())
+		})
+	}
+
+	for _, in := range []string{"", "-", ".", "1.2.3", "ten", "1e", "0x10", "1,5"} {
+		_, err := money.Parse(in)
+		assert.ErrorIs(t, err, money.ErrSyntax, in)
+	}
+	for _, in := range []string{"9223372036854775808", "1e401"} {
+		_, err := money.Parse(in)
+		assert.ErrorIs(t, err, money.ErrRange, in)
+	}
+}
+
+func TestUnits(t *testing.T) {
+	units, err := money.MustParse("-9.5").Units()
+	require.NoError(t, err)
+	assert.Equal(t, int64(-95000), units)
+	assert.Equal(t, "-9.5000", money.FromUnits(units).String())
+
+	_, err = money.MustParse("0.00001").Units()
+	assert.ErrorIs(t, err, money.ErrRange)
+	_, err = money.MustParse("922337203685478").Units()
+	assert.ErrorIs(t, err, money.ErrRange)
+
+	assert.True(t, money.MustParse("1.50").Equal(money.MustParse("1.5")))
+	assert.False(t, money.MustParse("1.5").Equal(money.MustParse("1.05")))
+}
+
+func TestRoundModes(t *testing.T) {
+	values := []string{"2.5", "-2.5", "1.5", "2.51", "-2.49", "3"}
+	tests := []struct {
+		mode money.Mode
+		want []string
+	}{
+		{money.HalfEven, []string{"2", "-2", "2", "3", "-2", "3"}},
+		{money.HalfUp, []string{"3", "-3", "2", "3", "-2", "3"}},
+		{money.HalfDown, []string{"2", "-2", "1", "3", "-2", "3"}},
+		{money.Up, []string{"3", "-3", "2", "3", "-3", "3"}},
+		{money.Down, []string{"2", "-2", "1", "2", "-2", "3"}},
+		{money.Ceiling, []string{"3", "-2", "2", "3", "-2", "3"}},
+		{money.Floor, []string{"2", "-3", "1", "2", "-3", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			for i, v := range values {
+				d, err := money.MustParse(v).Round(0, tt.mode)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want[i], d.String(), v)
+			}
+		})
+	}
+
+	// Digits far below the rounding place still decide the direction
+	d, err := money.MustParse("1e-30").Round(2, money.Up)
+	require.NoError(t, err)
+	assert.Equal(t, "0.01", d.String())
+	d, err = money.MustParse("1e-30").Round(2, money.HalfUp)
+	require.NoError(t, err)
+	assert.Equal(t, "0.00", d.String())
+}
+
+func TestRules(t *testing.T) {
+	rules, err := money.ParseRules("*=half-up, JPY=down, BHD=2, chf=1:floor")
+	require.NoError(t, err)
+	assert.Equal(t, money.HalfUp, rules.Mode)
+	assert.Equal(t, money.Rule{Places: 0, Mode: money.Down}, rules.Rule("JPY"))
+	assert.Equal(t, money.Rule{Places: 2, Mode: money.HalfUp}, rules.Rule("BHD"))
+	assert.Equal(t, money.Rule{Places: 1, Mode: money.Floor}, rules.Rule("CHF"))
+	assert.Equal(t, money.Rule{Places: 2, Mode: money.HalfUp}, rules.Rule("USD"))
+	assert.Equal(t, money.Rule{Places: money.Scale, Mode: money.HalfUp}, rules.Rule(""))
+
+	round := func(v, currency string) string {
+		t.Helper()
+		d, err := rules.Round(money.MustParse(v), currency)
+		require.NoError(t, err)
+		return d.String()
+	}
+	assert.Equal(t, "9.50", round("9.5", "USD"))
+	assert.Equal(t, "0.13", round("0.125", "EUR"))
+	assert.Equal(t, "1999", round("1999.99", "JPY"))
+	assert.Equal(t, "0.13", round("0.125", "BHD"))
+	assert.Equal(t, "1.2", round("1.29", "CHF"))
+	assert.Equal(t, "9.5", round("9.50000", ""))
+	assert.Equal(t, "0.1235", round("0.12345", ""))
+
+	// Stored values are written like rounded ones, without rounding again
+	assert.Equal(t, "1999.99", rules.Trim(money.MustParse("1999.9900"), "JPY").String())
+
+	for _, spec := range []string{"USD", "ABC=2", "USD=5", "USD=2:sideways", "*=9"} {
+		_, err := money.ParseRules(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var item struct {
+		Value money.Decimal `json:"value"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"value": 0.1}`), &item))
+	assert.Equal(t, "0.1", item.Value.String())
+	require.NoError(t, json.Unmarshal([]byte(`{"value": "19.990"}`), &item))
+	assert.Equal(t, "19.990", item.Value.String())
+	assert.Error(t, json.Unmarshal([]byte(`{"value": "abc"}`), &item))
+
+	out, err := json.Marshal(item)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"value": "19.990"}`, string(out))
+}
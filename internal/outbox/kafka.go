@@ -0,0 +1,89 @@
+package outbox
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+)
+
+// kafkaTimeout bounds producing one record, retries included
+const kafkaTimeout = 10 * time.Second
+
+// KafkaSink produces events to a Kafka topic with an idempotent producer
+// that waits for every in-sync replica, so a retried record is written once
+// and records of one partition keep their order. Records are keyed by item
+// ID, so the events of one item land on one partition.
+type KafkaSink struct {
+	topic  string
+	client *kgo.Client
+}
+
+// NewKafkaSink creates a sink for a kafka:// or kafka+tls:// URL listing the
+// seed brokers, separated by commas, and naming the topic as its path. A
+// user and password in the URL authenticate with SASL/PLAIN. Brokers are
+// contacted on the first publish.
+func NewKafkaSink(u *url.URL) (*KafkaSink, error) {
+	topic := strings.Trim(u.Path, "/")
+	if u.Host == "" || topic == "" || strings.Contains(topic, "/") {
+		return nil, fmt.Errorf("kafka outbox sink needs a URL like kafka://host:9092/topic")
+	}
+	var brokers []string
+	for _, host := range strings.Split(u.Host, ",") {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "9092")
+		}
+		brokers = append(brokers, host)
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.RecordDeliveryTimeout(kafkaTimeout),
+		kgo.ProducerLinger(0),
+		kgo.ClientID("go-api-sqlite-outbox"),
+	}
+	if u.Scheme == "kafka+tls" {
+		opts = append(opts, kgo.DialTLSConfig(&tls.Config{}))
+	}
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		opts = append(opts, kgo.SASL(plain.Auth{User: u.User.Username(), Pass: pass}.AsMechanism()))
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka outbox sink: %w", err)
+	}
+	return &KafkaSink{topic: topic, client: client}, nil
+}
+
+// Publish produces e and waits for the brokers to acknowledge it
+func (s *KafkaSink) Publish(ctx context.Context, e Event) error {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	record := &kgo.Record{
+		Key:     []byte(e.Subject),
+		Value:   value,
+		Headers: []kgo.RecordHeader{{Key: "content-type", Value: []byte("application/cloudevents+json")}},
+	}
+	if err := s.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		return fmt.Errorf("producing to kafka topic %s: %w", s.topic, err)
+	}
+	return nil
+}
+
+// Close closes the connections to the brokers
+func (s *KafkaSink) Close() error {
+	s.client.Close()
+	return nil
+}
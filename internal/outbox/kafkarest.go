@@ -0,0 +1,114 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// kafkaRESTTimeout bounds each produce request
+const kafkaRESTTimeout = 10 * time.Second
+
+// KafkaRESTSink produces events to a Kafka topic through a Confluent Kafka
+// REST Proxy (v2 API), for deployments where the brokers are only reachable
+// through it. Records are keyed by item ID, so the events of one item land on
+// one partition and keep their order. The proxy's own producer decides
+// whether retried records are deduplicated; KafkaSink talks to the brokers
+// directly with an idempotent producer.
+type KafkaRESTSink struct {
+	endpoint string
+	user     *url.Userinfo
+	client   *http.Client
+}
+
+// NewKafkaRESTSink creates a sink for a kafka-rest+http:// or
+// kafka-rest+https:// URL naming the REST Proxy and, as its path, the topic
+func NewKafkaRESTSink(u *url.URL) (*KafkaRESTSink, error) {
+	topic := strings.Trim(u.Path, "/")
+	if u.Host == "" || topic == "" || strings.Contains(topic, "/") {
+		return nil, fmt.Errorf("kafka-rest outbox sink needs a URL like kafka-rest+http://host:8082/topic")
+	}
+	endpoint := url.URL{
+		Scheme: strings.TrimPrefix(u.Scheme, "kafka-rest+"),
+		Host:   u.Host,
+		Path:   "/topics/" + topic,
+	}
+	return &KafkaRESTSink{
+		endpoint: endpoint.String(),
+		user:     u.User,
+		client:   &http.Client{Timeout: kafkaRESTTimeout},
+	}, nil
+}
+
+// kafkaRecords is a REST Proxy produce request with JSON values
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Event  `json:"value"`
+}
+
+// kafkaOffsets is a REST Proxy produce response. Records that failed carry
+// an error code.
+type kafkaOffsets struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Publish produces e and waits for the proxy to report its offset
+func (s *KafkaRESTSink) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(kafkaRecords{Records: []kafkaRecord{{Key: e.Subject, Value: e}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if s.user != nil {
+		pass, _ := s.user.Password()
+		req.SetBasicAuth(s.user.Username(), pass)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("producing to %s: %w", s.endpoint, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return fmt.Errorf("producing to %s: %w", s.endpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("producing to %s: proxy responded %s: %s", s.endpoint, resp.Status, bytes.TrimSpace(data))
+	}
+	var offsets kafkaOffsets
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return fmt.Errorf("producing to %s: decoding response: %w", s.endpoint, err)
+	}
+	if len(offsets.Offsets) != 1 {
+		return fmt.Errorf("producing to %s: expected 1 offset, got %d", s.endpoint, len(offsets.Offsets))
+	}
+	if o := offsets.Offsets[0]; o.ErrorCode != nil {
+		return fmt.Errorf("producing to %s: error %d: %s", s.endpoint, *o.ErrorCode, o.Error)
+	}
+	return nil
+}
+
+// Close releases idle connections
+func (s *KafkaRESTSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsTimeout bounds connecting and each publish when ctx has no deadline
+const natsTimeout = 10 * time.Second
+
+// NATSSink publishes events to JetStream on the subject
+// "<prefix>.<event type>". Publish waits for the stream's acknowledgement,
+// so a stream must capture the subjects. Events carry a Nats-Msg-Id header
+// with the event ID, which JetStream uses to drop duplicates when a publish
+// is retried. TLS is used when the server requires it.
+type NATSSink struct {
+	url    string
+	prefix string
+	user   *url.Userinfo
+
+	mu sync.Mutex
+	nc *nats.Conn
+	js jetstream.JetStream
+}
+
+// NewNATSSink creates a sink for a nats:// URL. The path, if any, is the
+// subject prefix; it defaults to "events". The connection is made on the
+// first publish.
+func NewNATSSink(u *url.URL) (*NATSSink, error) {
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("nats outbox sink needs a host")
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	prefix := strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", ".")
+	if prefix == "" {
+		prefix = "events"
+	}
+	if strings.ContainsAny(prefix, " \t\r\n*>") {
+		return nil, fmt.Errorf("invalid nats subject prefix %q", prefix)
+	}
+	return &NATSSink{url: "nats://" + host, prefix: prefix, user: u.User}, nil
+}
+
+// Publish sends e and waits for JetStream to store it. A duplicate of an
+// event already stored counts as published.
+func (s *NATSSink) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, natsTimeout)
+		defer cancel()
+	}
+	js, err := s.stream()
+	if err != nil {
+		return fmt.Errorf("connecting to nats %s: %w", s.url, err)
+	}
+
+	msg := nats.NewMsg(s.prefix + "." + e.Type)
+	msg.Header.Set("Content-Type", "application/cloudevents+json")
+	msg.Data = payload
+	if _, err := js.PublishMsg(ctx, msg, jetstream.WithMsgID(e.ID)); err != nil {
+		return fmt.Errorf("publishing to nats %s: %w", s.url, err)
+	}
+	return nil
+}
+
+// stream returns the JetStream context, connecting when there is no
+// connection or the last one was closed for good
+func (s *NATSSink) stream() (jetstream.JetStream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nc != nil && !s.nc.IsClosed() {
+		return s.js, nil
+	}
+
+	opts := []nats.Option{
+		nats.Name("go-api-sqlite-outbox"),
+		nats.Timeout(natsTimeout),
+		// Fail publishes while disconnected instead of buffering them; the
+		// relay retries them
+		nats.ReconnectBufSize(-1),
+	}
+	if s.user != nil {
+		pass, _ := s.user.Password()
+		opts = append(opts, nats.UserInfo(s.user.Username(), pass))
+	}
+	nc, err := nats.Connect(s.url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	s.nc, s.js = nc, js
+	return js, nil
+}
+
+// Close closes the connection
+func (s *NATSSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nc != nil {
+		s.nc.Close()
+		s.nc = nil
+	}
+	return nil
+}
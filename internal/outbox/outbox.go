@@ -0,0 +1,62 @@
+// Package outbox publishes item events to a message broker. Events are
+// written to the outbox table in the transaction that changes the item, and a
+// relay publishes them as CloudEvents, at least once and in order per item.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/google/uuid"
+)
+
+// Source is the CloudEvents source of every event
+const Source = "/go-api-sqlite/items"
+
+// Event is a CloudEvent in structured JSON form. Subject is the item ID, and
+// Sequence (the CloudEvents sequence extension) orders the events of one
+// database; it is zero-padded so it also sorts as a string.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Sequence        string          `json:"sequence"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Record adds an event to the outbox. Call it in the transaction that changes
+// the item, so the event is published if and only if the change commits.
+// item is the item after the change, or before it for deletions.
+func Record(ctx context.Context, tx *sql.Tx, eventType string, item models.Item) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO outbox (event_id, event_type, item_id, data, created_at) VALUES (?, ?, ?, ?, ?)",
+		uuid.New().String(), eventType, item.ID, string(data), time.Now().UTC())
+	return err
+}
+
+// newEvent builds the CloudEvent for an outbox row
+func newEvent(seq int64, id, eventType, itemID, data string, created time.Time) Event {
+	return Event{
+		SpecVersion:     "1.0",
+		ID:              id,
+		Source:          Source,
+		Type:            eventType,
+		Subject:         itemID,
+		Time:            created.UTC(),
+		DataContentType: "application/json",
+		Sequence:        fmt.Sprintf("%020d", seq),
+		Data:            json.RawMessage(data),
+	}
+}
@@ -0,0 +1,228 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+)
+
+// Config tunes the relay
+type Config struct {
+	// PollInterval is how often the outbox is checked for new events
+	PollInterval time.Duration
+	// BatchSize is the most events read per poll
+	BatchSize int
+	// PublishTimeout bounds one publish
+	PublishTimeout time.Duration
+	// BaseDelay is the delay before retrying a failed event; it doubles on
+	// each attempt up to MaxDelay. Events are retried until published.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Retention is how long published events are kept. Without a sink,
+	// events are kept this long and then dropped unpublished.
+	Retention time.Duration
+}
+
+// DefaultConfig polls twice a second and keeps published events for a day
+func DefaultConfig() Config {
+	return Config{
+		PollInterval:   500 * time.Millisecond,
+		BatchSize:      100,
+		PublishTimeout: 10 * time.Second,
+		BaseDelay:      time.Second,
+		MaxDelay:       time.Minute,
+		Retention:      24 * time.Hour,
+	}
+}
+
+// Relay publishes the events in the outbox of a database to a sink. Run one
+// relay per database: events of one item are published one at a time, in
+// commit order, and an item whose event fails waits until it succeeds.
+type Relay struct {
+	db   *sql.DB
+	sink Sink
+	cfg  Config
+}
+
+// NewRelay creates a relay, filling unset fields of cfg from DefaultConfig.
+// With a nil sink the relay only prunes the outbox.
+func NewRelay(db *sql.DB, sink Sink, cfg Config) *Relay {
+	def := DefaultConfig()
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	if cfg.PublishTimeout <= 0 {
+		cfg.PublishTimeout = def.PublishTimeout
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = def.BaseDelay
+	}
+	if cfg.MaxDelay < cfg.BaseDelay {
+		cfg.MaxDelay = max(def.MaxDelay, cfg.BaseDelay)
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = def.Retention
+	}
+	return &Relay{db: db, sink: sink, cfg: cfg}
+}
+
+// pruneInterval is how often old events are deleted, unless Retention is
+// shorter
+const pruneInterval = time.Minute
+
+// Run publishes events until ctx ends
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	var pruned time.Time
+	every := min(pruneInterval, r.cfg.Retention)
+	for {
+		n := 0
+		if r.sink != nil {
+			var err error
+			if n, err = r.relay(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Error relaying outbox events: %v", err)
+			}
+		}
+		if time.Since(pruned) >= every {
+			if err := r.prune(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Error pruning outbox: %v", err)
+			}
+			pruned = time.Now()
+		}
+		if n == r.cfg.BatchSize {
+			// More may be waiting
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pending is an outbox row waiting to be published
+type pending struct {
+	seq      int64
+	event    Event
+	itemID   string
+	attempts int
+}
+
+// relay publishes one batch of events and returns how many it read. Items
+// waiting out a failed event are left out entirely, so their later events
+// stay behind it.
+func (r *Relay) relay(ctx context.Context) (int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT seq, event_id, event_type, item_id, data, created_at, attempts
+		FROM outbox o WHERE published_at IS NULL AND NOT EXISTS (
+			SELECT 1 FROM outbox b WHERE b.item_id = o.item_id AND b.published_at IS NULL AND b.next_attempt_at > ?)
+		ORDER BY seq LIMIT ?`, time.Now().UnixMilli(), r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		var id, eventType, data string
+		var created time.Time
+		if err := rows.Scan(&p.seq, &id, &eventType, &p.itemID, &data, &created, &p.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		p.event = newEvent(p.seq, id, eventType, p.itemID, data, created)
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	blocked := make(map[string]bool)
+	var published []any
+	for _, p := range batch {
+		if blocked[p.itemID] {
+			continue
+		}
+		pctx, cancel := context.WithTimeout(ctx, r.cfg.PublishTimeout)
+		err := r.sink.Publish(pctx, p.event)
+		cancel()
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			blocked[p.itemID] = true
+			r.failed(p, err)
+			continue
+		}
+		published = append(published, p.seq)
+	}
+
+	if len(published) > 0 {
+		// Record even if ctx ends now, so published events are not sent again
+		err := database.WithTx(context.Background(), r.db, func(tx *sql.Tx) error {
+			_, err := tx.Exec("UPDATE outbox SET published_at = ? WHERE seq IN (?"+strings.Repeat(", ?", len(published)-1)+")",
+				append([]any{time.Now().UnixMilli()}, published...)...)
+			return err
+		})
+		if err != nil {
+			return len(batch), err
+		}
+	}
+	return len(batch), nil
+}
+
+// failed schedules another attempt at an event
+func (r *Relay) failed(p pending, cause error) {
+	attempts := p.attempts + 1
+	log.Printf("Error publishing outbox event %s (attempt %d): %v", p.event.ID, attempts, cause)
+	next := time.Now().Add(r.backoff(attempts)).UnixMilli()
+	err := database.WithTx(context.Background(), r.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE outbox SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE seq = ?",
+			attempts, next, cause.Error(), p.seq)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error recording outbox event %s failure: %v", p.event.ID, err)
+	}
+}
+
+// backoff returns the delay after attempt, with up to half of it added as
+// jitter
+func (r *Relay) backoff(attempt int) time.Duration {
+	delay := r.cfg.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > r.cfg.MaxDelay {
+		delay = r.cfg.MaxDelay
+	}
+	return delay + rand.N(delay/2+1)
+}
+
+// prune deletes events published more than Retention ago, or without a sink
+// any event older than that
+func (r *Relay) prune(ctx context.Context) error {
+	cutoff := time.Now().Add(-r.cfg.Retention)
+	expired, arg := "published_at < ?", any(cutoff.UnixMilli())
+	if r.sink == nil {
+		expired, arg = "created_at < ?", cutoff.UTC()
+	}
+
+	// Probe outside a transaction so an idle relay takes no write lock
+	var due bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM outbox WHERE "+expired+")", arg).Scan(&due)
+	if err != nil || !due {
+		return err
+	}
+	return database.WithTx(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM outbox WHERE "+expired, arg)
+		return err
+	})
+}
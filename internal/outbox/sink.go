@@ -0,0 +1,123 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Sink publishes events to a broker. Publish returns only once the broker
+// has accepted the event; the relay retries it otherwise, so a sink may see
+// an event more than once but never out of order for one item.
+type Sink interface {
+	Publish(ctx context.Context, e Event) error
+	Close() error
+}
+
+// OpenSink opens the sink described by a URL:
+//
+//	nats://[user:password@]host:port[/subject-prefix]
+//	kafka://[user:password@]broker:port[,broker:port...]/topic (or kafka+tls)
+//	kafka-rest+http://rest-proxy:port/topic (or kafka-rest+https)
+//	file:///path/to/events.ndjson
+//	memory:
+func OpenSink(spec string) (Sink, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid outbox sink %q: %w", spec, err)
+	}
+	switch u.Scheme {
+	case "nats":
+		return NewNATSSink(u)
+	case "kafka", "kafka+tls":
+		return NewKafkaSink(u)
+	case "kafka-rest+http", "kafka-rest+https":
+		return NewKafkaRESTSink(u)
+	case "file":
+		return NewFileSink(u.Path)
+	case "memory":
+		return NewMemorySink(), nil
+	}
+	return nil, fmt.Errorf("unknown outbox sink %q: want nats, kafka, kafka+tls, kafka-rest+http, kafka-rest+https, file or memory", u.Scheme)
+}
+
+// MemorySink keeps published events in memory, for tests. When Fail is set
+// and returns an error for an event, the event is rejected.
+type MemorySink struct {
+	Fail func(Event) error
+
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewMemorySink creates an empty memory sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Publish records e unless Fail rejects it
+func (s *MemorySink) Publish(ctx context.Context, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Fail != nil {
+		if err := s.Fail(e); err != nil {
+			return err
+		}
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+// Events returns the events published so far
+func (s *MemorySink) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+// Close does nothing
+func (s *MemorySink) Close() error { return nil }
+
+// FileSink appends events to a file as newline-delimited JSON and syncs the
+// file after each one
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileSink opens path for appending, creating it if needed
+func NewFileSink(path string) (*FileSink, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("file outbox sink needs a path")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f}, nil
+}
+
+// Publish appends e as one line
+func (s *FileSink) Publish(ctx context.Context, e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,473 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/outbox"
+	"github.com/angel/go-api-sqlite/internal/webhook"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/gorilla/mux"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/genproto/googleapis/type/decimal"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+// testConfig polls often and retries at once so tests run quickly
+var testConfig = outbox.Config{
+	PollInterval: 5 * time.Millisecond,
+	BaseDelay:    time.Millisecond,
+	MaxDelay:     5 * time.Millisecond,
+}
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := database.Open(filepath.Join(t.TempDir(), "data.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// relay publishes the outbox of db to sink until the test ends
+func relay(t *testing.T, db *sql.DB, sink outbox.Sink, cfg outbox.Config) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		outbox.NewRelay(db, sink, cfg).Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitFor(t *testing.T, sink *outbox.MemorySink, n int) []outbox.Event {
+	require.Eventually(t, func() bool { return len(sink.Events()) >= n }, 5*time.Second, 5*time.Millisecond)
+	return sink.Events()
+}
+
+func newRouter(db *sql.DB) *mux.Router {
+	h := handlers.NewHandler(db, money.Rules{})
+	router := mux.NewRouter()
+	router.HandleFunc("/api/items", h.CreateItem).Methods("POST")
+	router.HandleFunc("/api/items/{id}", h.UpdateItem).Methods("PUT")
+	router.HandleFunc("/api/items/{id}", h.DeleteItem).Methods("DELETE")
+	return router
+}
+
+func do(t *testing.T, router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rr
+}
+
+// record writes an event for item straight to the outbox
+func record(t *testing.T, db *sql.DB, eventType string, item models.Item) {
+	err := database.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		return outbox.Record(context.Background(), tx, eventType, item)
+	})
+	require.NoError(t, err)
+}
+
+func TestPublishesCloudEvents(t *testing.T) {
+	db := openDB(t)
+	router := newRouter(db)
+
+	// Create over REST, update and delete over gRPC
+	rr := do(t, router, "POST", "/api/items", `{"name": "Widget", "value": 1.5}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var item models.Item
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&item))
+	items := grpcserver.NewItemServer(db, money.Rules{})
+	ctx := context.Background()
+	_, err := items.UpdateItem(ctx, &pb.UpdateItemRequest{Id: item.ID, Name: "Gadget", Value: &decimal.Decimal{Value: "2"}})
+	require.NoError(t, err)
+	_, err = items.DeleteItem(ctx, &pb.DeleteItemRequest{Id: item.ID})
+	require.NoError(t, err)
+
+	// Failed changes record nothing
+	_, err = items.DeleteItem(ctx, &pb.DeleteItemRequest{Id: item.ID})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, do(t, router, "PUT", "/api/items/missing", `{"name": "x"}`).Code)
+
+	sink := outbox.NewMemorySink()
+	relay(t, db, sink, testConfig)
+	events := waitFor(t, sink, 3)
+	require.Len(t, events, 3)
+
+	types := []string{webhook.ItemCreated, webhook.ItemUpdated, webhook.ItemDeleted}
+	for i, e := range events {
+		assert.Equal(t, "1.0", e.SpecVersion)
+		assert.Equal(t, outbox.Source, e.Source)
+		assert.Equal(t, types[i], e.Type)
+		assert.Equal(t, item.ID, e.Subject)
+		assert.Equal(t, "application/json", e.DataContentType)
+		assert.NotEmpty(t, e.ID)
+		assert.WithinDuration(t, time.Now(), e.Time, time.Minute)
+		assert.Len(t, e.Sequence, 20)
+		if i > 0 {
+			assert.Less(t, events[i-1].Sequence, e.Sequence)
+		}
+	}
+	var data models.Item
+	require.NoError(t, json.Unmarshal(events[1].Data, &data))
+	assert.Equal(t, "Gadget", data.Name)
+	require.NoError(t, json.Unmarshal(events[2].Data, &data))
+	assert.Equal(t, item.ID, data.ID)
+
+	// Published events are not sent again
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, sink.Events(), 3)
+}
+
+func TestDeletingAParentUpdatesItsChildren(t *testing.T) {
+	db := openDB(t)
+	router := newRouter(db)
+	create := func(body string) models.Item {
+		rr := do(t, router, "POST", "/api/items", body)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var item models.Item
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&item))
+		return item
+	}
+	parent := create(`{"name": "Kit"}`)
+	child := create(`{"name": "Part", "parent_id": "` + parent.ID + `"}`)
+	require.Equal(t, http.StatusNoContent, do(t, router, "DELETE", "/api/items/"+parent.ID, "").Code)
+
+	sink := outbox.NewMemorySink()
+	relay(t, db, sink, testConfig)
+	events := waitFor(t, sink, 4)
+	require.Len(t, events, 4)
+	assert.Equal(t, webhook.ItemDeleted, events[2].Type)
+	assert.Equal(t, parent.ID, events[2].Subject)
+	assert.Equal(t, webhook.ItemUpdated, events[3].Type)
+	assert.Equal(t, child.ID, events[3].Subject)
+	var data models.Item
+	require.NoError(t, json.Unmarshal(events[3].Data, &data))
+	assert.Empty(t, data.ParentID)
+	assert.True(t, data.UpdatedAt.After(child.UpdatedAt))
+}
+
+func TestOrderPerItemWhileRetrying(t *testing.T) {
+	db := openDB(t)
+	for i := range 3 {
+		record(t, db, webhook.ItemUpdated, models.Item{ID: "a", Name: fmt.Sprint("a", i)})
+		record(t, db, webhook.ItemUpdated, models.Item{ID: "b", Name: fmt.Sprint("b", i)})
+	}
+
+	// Reject item a until it has been tried three times
+	var mu sync.Mutex
+	tries := 0
+	sink := outbox.NewMemorySink()
+	sink.Fail = func(e outbox.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Subject == "a" && tries < 3 {
+			tries++
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}
+	relay(t, db, sink, testConfig)
+
+	events := waitFor(t, sink, 6)
+	require.Len(t, events, 6)
+	var a, b []string
+	for _, e := range events {
+		var item models.Item
+		require.NoError(t, json.Unmarshal(e.Data, &item))
+		if e.Subject == "a" {
+			a = append(a, item.Name)
+		} else {
+			b = append(b, item.Name)
+		}
+	}
+	assert.Equal(t, []string{"a0", "a1", "a2"}, a)
+	assert.Equal(t, []string{"b0", "b1", "b2"}, b)
+	// b was not held up by a
+	assert.Equal(t, "b", events[0].Subject)
+
+	var attempts int
+	var lastError string
+	require.NoError(t, db.QueryRow("SELECT attempts, last_error FROM outbox ORDER BY seq LIMIT 1").Scan(&attempts, &lastError))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, "broker unavailable", lastError)
+}
+
+func TestPrune(t *testing.T) {
+	db := openDB(t)
+	record(t, db, webhook.ItemCreated, models.Item{ID: "a"})
+
+	// Published events go once they are older than the retention
+	sink := outbox.NewMemorySink()
+	cfg := testConfig
+	cfg.Retention = time.Millisecond
+	relay(t, db, sink, cfg)
+	waitFor(t, sink, 1)
+
+	db2 := openDB(t)
+	record(t, db2, webhook.ItemCreated, models.Item{ID: "b"})
+	time.Sleep(5 * time.Millisecond)
+	// Without a sink events are dropped unpublished
+	relay(t, db2, nil, cfg)
+
+	for _, d := range []*sql.DB{db, db2} {
+		require.Eventually(t, func() bool {
+			var n int
+			require.NoError(t, d.QueryRow("SELECT COUNT(*) FROM outbox").Scan(&n))
+			return n == 0
+		}, 5*time.Second, 5*time.Millisecond)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := outbox.OpenSink("file://" + path)
+	require.NoError(t, err)
+	db := openDB(t)
+	record(t, db, webhook.ItemCreated, models.Item{ID: "a", Name: "Widget"})
+	record(t, db, webhook.ItemDeleted, models.Item{ID: "a", Name: "Widget", Tags: []string{"blue"}})
+	relay(t, db, sink, testConfig)
+
+	var lines []string
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(path)
+		lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+		return len(lines) == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	var e outbox.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal(t, webhook.ItemDeleted, e.Type)
+	assert.JSONEq(t, `{"id":"a","name":"Widget","value":"0","description":"","tags":["blue"],"category":"","currency":"","metadata":null,
+		"parent_id":"","created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`, string(e.Data))
+	require.NoError(t, sink.Close())
+}
+
+func TestOpenSinkErrors(t *testing.T) {
+	for _, spec := range []string{"amqp://host/queue", "kafka+http://proxy:8082/topic", "kafka-rest+http://proxy:8082", "kafka://broker:9092", "file://", "nats:///subject", "nats://host/bad>"} {
+		_, err := outbox.OpenSink(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// newNATSServer starts an in-process NATS server with JetStream that
+// requires the user app with password s3cret
+func newNATSServer(t *testing.T) *server.Server {
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		Username:  "app",
+		Password:  "s3cret",
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(5*time.Second))
+	t.Cleanup(ns.Shutdown)
+	return ns
+}
+
+func TestNATSSink(t *testing.T) {
+	ns := newNATSServer(t)
+	addr := ns.Addr().String()
+	sink, err := outbox.OpenSink("nats://app:s3cret@" + addr + "/inventory")
+	require.NoError(t, err)
+	t.Cleanup(func() { sink.Close() })
+
+	// Without a stream for the subjects nothing acknowledges the publish
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = sink.Publish(ctx, outbox.Event{ID: "0", Type: "item.created", Data: json.RawMessage(`{}`)})
+	assert.Error(t, err)
+
+	nc, err := nats.Connect("nats://"+addr, nats.UserInfo("app", "s3cret"))
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+	stream, err := js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "INVENTORY", Subjects: []string{"inventory.>"}})
+	require.NoError(t, err)
+
+	db := openDB(t)
+	record(t, db, webhook.ItemCreated, models.Item{ID: "a", Name: "Widget"})
+	record(t, db, webhook.ItemDeleted, models.Item{ID: "a", Name: "Widget", Tags: []string{"blue"}})
+	relay(t, db, sink, testConfig)
+
+	require.Eventually(t, func() bool {
+		info, err := stream.Info(context.Background())
+		return err == nil && info.State.Msgs == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	first, err := stream.GetMsg(context.Background(), 1)
+	require.NoError(t, err)
+	second, err := stream.GetMsg(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "inventory.item.created", first.Subject)
+	assert.Equal(t, "inventory.item.deleted", second.Subject)
+	var e outbox.Event
+	require.NoError(t, json.Unmarshal(first.Data, &e))
+	assert.Equal(t, webhook.ItemCreated, e.Type)
+	assert.Equal(t, e.ID, first.Header.Get(jetstream.MsgIDHeader))
+	assert.Equal(t, "application/cloudevents+json", first.Header.Get("Content-Type"))
+
+	// A retried publish is acknowledged but stored once
+	require.NoError(t, sink.Publish(context.Background(), e))
+	info, err := stream.Info(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, info.State.Msgs)
+
+	u, _ := url.Parse("nats://app:wrong@" + addr)
+	bad, err := outbox.NewNATSSink(u)
+	require.NoError(t, err)
+	assert.ErrorContains(t, bad.Publish(context.Background(), e), "connecting to nats")
+}
+
+func TestKafkaRESTSink(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	fail := true
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/items", r.URL.Path)
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		var body struct {
+			Records []struct {
+				Key   string       `json:"key"`
+				Value outbox.Event `json:"value"`
+			} `json:"records"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Len(t, body.Records, 1)
+		assert.Equal(t, body.Records[0].Key, body.Records[0].Value.Subject)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			// The proxy reports per-record failures with a 200
+			fail = false
+			io.WriteString(w, `{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"leader not available"}]}`)
+			return
+		}
+		keys = append(keys, body.Records[0].Key)
+		io.WriteString(w, `{"offsets":[{"partition":0,"offset":1,"error_code":null,"error":null}]}`)
+	}))
+	t.Cleanup(proxy.Close)
+
+	sink, err := outbox.OpenSink(strings.Replace(proxy.URL, "http://", "kafka-rest+http://", 1) + "/items")
+	require.NoError(t, err)
+	t.Cleanup(func() { sink.Close() })
+
+	err = sink.Publish(context.Background(), outbox.Event{Subject: "a", Data: json.RawMessage(`{}`)})
+	assert.ErrorContains(t, err, "leader not available")
+
+	db := openDB(t)
+	record(t, db, webhook.ItemCreated, models.Item{ID: "a"})
+	record(t, db, webhook.ItemCreated, models.Item{ID: "b"})
+	relay(t, db, sink, testConfig)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(keys) == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"a", "b"}, keys)
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error_code":40401,"message":"Topic not found"}`, http.StatusNotFound)
+	}))
+	t.Cleanup(broken.Close)
+	sink, err = outbox.OpenSink(strings.Replace(broken.URL, "http://", "kafka-rest+http://", 1) + "/items")
+	require.NoError(t, err)
+	err = sink.Publish(context.Background(), outbox.Event{Subject: "a", Data: json.RawMessage(`{}`)})
+	assert.ErrorContains(t, err, "Topic not found")
+}
+
+func TestKafkaSink(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(3), kfake.SeedTopics(4, "items"))
+	require.NoError(t, err)
+	t.Cleanup(cluster.Close)
+	brokers := strings.Join(cluster.ListenAddrs(), ",")
+
+	sink, err := outbox.OpenSink("kafka://" + brokers + "/items")
+	require.NoError(t, err)
+	t.Cleanup(func() { sink.Close() })
+
+	db := openDB(t)
+	for _, id := range []string{"a", "b", "a", "c", "a"} {
+		record(t, db, webhook.ItemUpdated, models.Item{ID: id})
+	}
+	relay(t, db, sink, testConfig)
+
+	consumer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.ConsumeTopics("items"),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+	require.NoError(t, err)
+	t.Cleanup(consumer.Close)
+	var records []*kgo.Record
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for len(records) < 5 && ctx.Err() == nil {
+		fetches := consumer.PollFetches(ctx)
+		records = append(records, fetches.Records()...)
+	}
+	require.Len(t, records, 5)
+
+	// The events of one item share a partition and keep their order
+	partitions := map[string]int32{}
+	var sequences []string
+	for _, r := range records {
+		key := string(r.Key)
+		if p, ok := partitions[key]; ok {
+			assert.Equal(t, p, r.Partition, key)
+		}
+		partitions[key] = r.Partition
+		var e outbox.Event
+		require.NoError(t, json.Unmarshal(r.Value, &e))
+		assert.Equal(t, key, e.Subject)
+		assert.Equal(t, "content-type", r.Headers[0].Key)
+		assert.Equal(t, "application/cloudevents+json", string(r.Headers[0].Value))
+		if key == "a" {
+			sequences = append(sequences, e.Sequence)
+		}
+	}
+	assert.IsIncreasing(t, sequences)
+
+	// A topic the brokers do not have fails once the delivery timeout or ctx
+	// runs out, and the relay retries
+	missing, err := outbox.OpenSink("kafka://" + brokers + "/missing")
+	require.NoError(t, err)
+	t.Cleanup(func() { missing.Close() })
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	assert.ErrorContains(t, missing.Publish(ctx, outbox.Event{Subject: "a", Data: json.RawMessage(`{}`)}), "kafka topic missing")
+}
@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/auth"
+)
+
+// APIKeyHeader is the request header identifying API key clients
+const APIKeyHeader = "X-API-Key"
+
+// Config describes the budgets applied to each client
+type Config struct {
+	// Read applies to GET requests and Get/List RPCs
+	Read Rate
+	// Write applies to mutating requests and RPCs
+	Write Rate
+	// Overrides replaces the read/write budget for specific routes. The most
+	// specific matching route wins, then the first listed.
+	Overrides []Override
+	// APIKeys maps the API keys accepted from clients to client names. Other
+	// keys are ignored, so a made-up key cannot buy a fresh budget.
+	APIKeys map[string]string
+}
+
+// Override is the budget of the routes matching Route, which is
+// "METHOD /path/{var}" or a full gRPC method such as
+// "/proto.ItemService/CreateItem"
+type Override struct {
+	Route string
+	Rate  Rate
+}
+
+// ParseOverrides parses a comma-separated list of route=rate pairs
+func ParseOverrides(s string) ([]Override, error) {
+	var overrides []Override
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		route, rate, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid override %q: expected route=rate", pair)
+		}
+		r, err := ParseRate(rate)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, Override{Route: strings.TrimSpace(route), Rate: r})
+	}
+	return overrides, nil
+}
+
+// LoadAPIKeys reads a JSON object mapping API keys to client names
+func LoadAPIKeys(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string)
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parsing API keys %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// Limiter enforces per-client token buckets
+type Limiter struct {
+	cfg   Config
+	store Store
+	now   func() time.Time
+}
+
+// NewLimiter creates a limiter backed by store
+func NewLimiter(cfg Config, store Store) *Limiter {
+	return &Limiter{cfg: cfg, store: store, now: time.Now}
+}
+
+// WithClock replaces the time source, for tests
+func (l *Limiter) WithClock(now func() time.Time) *Limiter {
+	l.now = now
+	return l
+}
+
+// Allow takes a token for client on route. Store failures are logged and the
+// request is allowed, so a broken store never takes the API down.
+func (l *Limiter) Allow(ctx context.Context, client, route string, write bool) (Result, string, bool) {
+	name, rate, ok := l.budget(route, write)
+	if !ok {
+		return Result{Allowed: true}, "", false
+	}
+	res, err := l.store.Take(ctx, client+"|"+name, rate, l.now())
+	if err != nil {
+		log.Printf("Rate limit store error, allowing request: %v", err)
+		return Result{Allowed: true}, "", false
+	}
+	return res, rate.String(), true
+}
+
+// budget selects the override or read/write budget that applies to route
+func (l *Limiter) budget(route string, write bool) (string, Rate, bool) {
+	best, bestScore := -1, -1
+	for i, o := range l.cfg.Overrides {
+		if score := matchRoute(o.Route, route); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best >= 0 {
+		return l.cfg.Overrides[best].Route, l.cfg.Overrides[best].Rate, true
+	}
+	if write {
+		return "write", l.cfg.Write, l.cfg.Write.Limit > 0
+	}
+	return "read", l.cfg.Read, l.cfg.Read.Limit > 0
+}
+
+// matchRoute compares a pattern with "{var}" segments against a route. It
+// returns how specific a match is, the number of literal segments, or -1 when
+// the route does not match.
+func matchRoute(pattern, route string) int {
+	ps, rs := strings.Split(pattern, "/"), strings.Split(route, "/")
+	if len(ps) != len(rs) {
+		return -1
+	}
+	literals := 0
+	for i := range ps {
+		if strings.HasPrefix(ps[i], "{") && strings.HasSuffix(ps[i], "}") && rs[i] != "" {
+			continue
+		}
+		if ps[i] != rs[i] {
+			return -1
+		}
+		literals++
+	}
+	return literals
+}
+
+// IsWriteRPC classifies an RPC as a write unless its name starts with Get,
+// List, Search, Watch, Stream or Download
+func IsWriteRPC(fullMethod string) bool {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	for _, prefix := range []string{"Get", "List", "Search", "Watch", "Stream", "Download"} {
+		if strings.HasPrefix(method, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientKey identifies the caller by a known API key, then by principal,
+// then by IP
+func (l *Limiter) ClientKey(ctx context.Context, apiKey, remoteAddr string) string {
+	if name, ok := l.cfg.APIKeys[apiKey]; ok && apiKey != "" {
+		return "key:" + name
+	}
+	if p, ok := auth.PrincipalFromContext(ctx); ok {
+		return "principal:" + p.Name
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
+
+// httpRoute returns the route used for overrides and whether it is a write.
+// gRPC-Web and Connect calls are classified by their procedure name.
+func httpRoute(r *http.Request) (string, bool) {
+	if isProcedurePath(r.URL.Path) {
+		return r.URL.Path, IsWriteRPC(r.URL.Path)
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.Method + " " + r.URL.Path, false
+	}
+	return r.Method + " " + r.URL.Path, true
+}
+
+// IsWriteRequest reports whether an HTTP request mutates state. gRPC-Web and
+// Connect calls are classified like native RPCs with IsWriteRPC.
+func IsWriteRequest(r *http.Request) bool {
+	_, write := httpRoute(r)
+	return write
+}
+
+// isProcedurePath reports whether path has the /package.Service/Method form
+func isProcedurePath(path string) bool {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	return len(parts) == 2 && strings.Contains(parts[0], ".") && parts[1] != ""
+}
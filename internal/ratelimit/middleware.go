@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ReasonRateLimited is the error reason returned when a budget is exhausted
+const ReasonRateLimited = "RATE_LIMITED"
+
+// exhausted builds the error returned to throttled clients
+func exhausted(res Result, policy string) *apierror.Error {
+	return &apierror.Error{
+		Code:       codes.ResourceExhausted,
+		Reason:     ReasonRateLimited,
+		Message:    "rate limit exceeded",
+		RetryAfter: res.RetryAfter,
+		Metadata:   map[string]string{"policy": policy},
+	}
+}
+
+// seconds rounds d up to whole seconds for headers
+func seconds(d float64) string {
+	return strconv.Itoa(int(math.Ceil(d)))
+}
+
+// Middleware rate limits HTTP requests and reports the budget with the
+// RateLimit-* headers. Throttled requests get 429 with Retry-After.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, write := httpRoute(r)
+		client := l.ClientKey(r.Context(), r.Header.Get(APIKeyHeader), r.RemoteAddr)
+
+		res, policy, limited := l.Allow(r.Context(), client, route, write)
+		if limited {
+			h := w.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", seconds(res.Reset.Seconds()))
+		}
+		if !res.Allowed {
+			apierror.Write(w, r, exhausted(res, policy))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryInterceptor rate limits unary RPCs, returning RESOURCE_EXHAUSTED with
+// RetryInfo when the budget is exhausted
+func (l *Limiter) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := l.allowRPC(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor rate limits the start of streaming RPCs
+func (l *Limiter) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.allowRPC(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (l *Limiter) allowRPC(ctx context.Context, fullMethod string) error {
+	var apiKey, remoteAddr string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(APIKeyHeader); len(v) > 0 {
+			apiKey = v[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+
+	res, policy, _ := l.Allow(ctx, l.ClientKey(ctx, apiKey, remoteAddr), fullMethod, IsWriteRPC(fullMethod))
+	if !res.Allowed {
+		return exhausted(res, policy)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate is a token bucket budget: Limit requests per Period, which is also the
+// bucket capacity
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseRate parses a rate such as "100/s", "600/m" or "10000/h"
+func ParseRate(s string) (Rate, error) {
+	count, unit, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q: expected <count>/<s|m|h>", s)
+	}
+	limit, err := strconv.Atoi(count)
+	if err != nil || limit <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: count must be a positive integer", s)
+	}
+	periods := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	period, ok := periods[unit]
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q: unit must be s, m or h", s)
+	}
+	return Rate{Limit: limit, Period: period}, nil
+}
+
+// String formats the rate in the form accepted by ParseRate
+func (r Rate) String() string {
+	unit := map[time.Duration]string{time.Second: "s", time.Minute: "m", time.Hour: "h"}[r.Period]
+	if unit == "" {
+		return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+	}
+	return fmt.Sprintf("%d/%s", r.Limit, unit)
+}
+
+// refill returns the tokens added per nanosecond
+func (r Rate) refill() float64 {
+	return float64(r.Limit) / float64(r.Period)
+}
+
+// Result describes the outcome of taking a token from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait before a token is available
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// bucket is the persisted state of a token bucket
+type bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// refill adds the tokens earned up to now; a new bucket starts full
+func (b *bucket) refill(rate Rate, now time.Time) {
+	if b.Updated.IsZero() {
+		b.Tokens = float64(rate.Limit)
+	} else if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		b.Tokens = math.Min(float64(rate.Limit), b.Tokens+float64(elapsed)*rate.refill())
+	}
+	b.Updated = now
+}
+
+// take refills b up to now and removes one token when available
+func (b *bucket) take(rate Rate, now time.Time) Result {
+	b.refill(rate, now)
+
+	res := Result{Limit: rate.Limit}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.Tokens) / rate.refill())
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = time.Duration((float64(rate.Limit) - b.Tokens) / rate.refill())
+	return res
+}
@@ -0,0 +1,232 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+)
+
+// Store holds token bucket state
+type Store interface {
+	// Take removes a token from the bucket identified by key
+	Take(ctx context.Context, key string, rate Rate, now time.Time) (Result, error)
+}
+
+// MemoryStore keeps buckets in process memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(_ context.Context, key string, rate Rate, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{}
+		s.buckets[key] = b
+	}
+	return b.take(rate, now), nil
+}
+
+// sweep drops buckets idle for an hour, which are full again by then
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+	for key, b := range s.buckets {
+		if now.Sub(b.Updated) > time.Hour {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// FlushInterval is how often SQLiteStore writes its buckets back
+const FlushInterval = time.Second
+
+// SQLiteStore persists buckets in a SQLite table so limits survive restarts
+// and can be shared by processes using the same database. Tokens are taken
+// from buckets cached in memory; Run writes the tokens taken back every
+// FlushInterval, merging them with what other processes took, so a shared
+// budget can be overdrawn by up to one interval's worth of requests.
+type SQLiteStore struct {
+	db *sql.DB
+
+	mu      sync.Mutex
+	buckets map[string]*cachedBucket
+	swept   time.Time
+}
+
+// cachedBucket is a bucket with the tokens taken since it was last written
+type cachedBucket struct {
+	bucket
+	rate  Rate
+	taken int
+}
+
+// NewSQLiteStore creates the rate_limits table if needed
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS rate_limits (
+		key TEXT PRIMARY KEY,
+		tokens REAL NOT NULL,
+		updated_at INTEGER NOT NULL
+	);`)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{db: db, buckets: make(map[string]*cachedBucket)}, nil
+}
+
+// Take implements Store. Only the first take of a key reads the database.
+func (s *SQLiteStore) Take(ctx context.Context, key string, rate Rate, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		stored, err := s.load(ctx, key)
+		if err != nil {
+			return Result{}, err
+		}
+		b = &cachedBucket{bucket: stored}
+		s.buckets[key] = b
+	}
+	b.rate = rate
+	res := b.take(rate, now)
+	if res.Allowed {
+		b.taken++
+	}
+	return res, nil
+}
+
+// sweep drops buckets written back and idle for an hour
+func (s *SQLiteStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+	for key, b := range s.buckets {
+		if b.taken == 0 && now.Sub(b.Updated) > time.Hour {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *SQLiteStore) load(ctx context.Context, key string) (bucket, error) {
+	var b bucket
+	var updated int64
+	err := s.db.QueryRowContext(ctx, "SELECT tokens, updated_at FROM rate_limits WHERE key = ?", key).
+		Scan(&b.Tokens, &updated)
+	if err == sql.ErrNoRows {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	b.Updated = time.Unix(0, updated)
+	return b, nil
+}
+
+// Run writes buckets back every FlushInterval until ctx ends, then once more
+func (s *SQLiteStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(context.Background()); err != nil {
+				log.Printf("Error writing rate limits: %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+		if err := s.Flush(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error writing rate limits: %v", err)
+		}
+	}
+}
+
+// Flush writes the tokens taken since the last flush to the database in one
+// transaction. Each stored bucket is refilled up to the last take here,
+// charged the tokens taken here and cached again, so takes by other processes
+// are seen too.
+func (s *SQLiteStore) Flush(ctx context.Context) error {
+	type pending struct {
+		key   string
+		rate  Rate
+		taken int
+		at    time.Time
+	}
+	s.mu.Lock()
+	var dirty []pending
+	for key, b := range s.buckets {
+		if b.taken > 0 {
+			dirty = append(dirty, pending{key: key, rate: b.rate, taken: b.taken, at: b.Updated})
+			b.taken = 0
+		}
+	}
+	s.mu.Unlock()
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]bucket, len(dirty))
+	err := database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, p := range dirty {
+			var b bucket
+			var updated int64
+			err := tx.QueryRowContext(ctx, "SELECT tokens, updated_at FROM rate_limits WHERE key = ?", p.key).
+				Scan(&b.Tokens, &updated)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			if err == nil {
+				b.Updated = time.Unix(0, updated)
+			}
+			b.refill(p.rate, p.at)
+			b.Tokens = max(0, b.Tokens-float64(p.taken))
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO rate_limits (key, tokens, updated_at) VALUES (?, ?, ?)
+				ON CONFLICT(key) DO UPDATE SET tokens = excluded.tokens, updated_at = excluded.updated_at`,
+				p.key, b.Tokens, b.Updated.UnixNano())
+			if err != nil {
+				return err
+			}
+			merged[p.key] = b
+		}
+		return nil
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range dirty {
+		b, ok := s.buckets[p.key]
+		if !ok {
+			continue
+		}
+		if err != nil {
+			// Written back on the next flush
+			b.taken += p.taken
+			continue
+		}
+		// Tokens taken during the flush stay charged
+		b.Tokens = max(0, merged[p.key].Tokens-float64(b.taken))
+	}
+	return err
+}
@@ -0,0 +1,287 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/ratelimit"
+	pb "github.com/angel/go-api-sqlite/proto"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeClock is a manually advanced time source
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ratelimit.Rate
+		wantErr bool
+	}{
+		{input: "100/s", want: ratelimit.Rate{Limit: 100, Period: time.Second}},
+		{input: "600/m", want: ratelimit.Rate{Limit: 600, Period: time.Minute}},
+		{input: "10/h", want: ratelimit.Rate{Limit: 10, Period: time.Hour}},
+		{input: "10", wantErr: true},
+		{input: "-1/s", wantErr: true},
+		{input: "10/d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ratelimit.ParseRate(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Read: ratelimit.Rate{Limit: 2, Period: time.Second},
+	}, ratelimit.NewMemoryStore()).WithClock(clock.Now)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		res, _, _ := limiter.Allow(ctx, "ip:1.2.3.4", "GET /api/items", false)
+		assert.True(t, res.Allowed)
+	}
+
+	res, _, _ := limiter.Allow(ctx, "ip:1.2.3.4", "GET /api/items", false)
+	assert.False(t, res.Allowed)
+	assert.InDelta(t, 500*time.Millisecond, res.RetryAfter, float64(time.Millisecond))
+
+	// Other clients have their own bucket
+	res, _, _ = limiter.Allow(ctx, "ip:5.6.7.8", "GET /api/items", false)
+	assert.True(t, res.Allowed)
+
+	// Tokens refill over time
+	clock.Advance(500 * time.Millisecond)
+	res, _, _ = limiter.Allow(ctx, "ip:1.2.3.4", "GET /api/items", false)
+	assert.True(t, res.Allowed)
+}
+
+func TestReadWriteAndOverrideBudgets(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Read:  ratelimit.Rate{Limit: 10, Period: time.Minute},
+		Write: ratelimit.Rate{Limit: 1, Period: time.Minute},
+		Overrides: []ratelimit.Override{
+			{Route: "DELETE /api/{collection}/{id}", Rate: ratelimit.Rate{Limit: 5, Period: time.Minute}},
+			{Route: "DELETE /api/items/{id}", Rate: ratelimit.Rate{Limit: 3, Period: time.Minute}},
+			{Route: "DELETE /api/items/{item_id}", Rate: ratelimit.Rate{Limit: 4, Period: time.Minute}},
+		},
+	}, ratelimit.NewMemoryStore())
+	ctx := context.Background()
+
+	res, _, _ := limiter.Allow(ctx, "c", "POST /api/items", true)
+	assert.True(t, res.Allowed)
+	res, _, _ = limiter.Allow(ctx, "c", "POST /api/items", true)
+	assert.False(t, res.Allowed, "write budget is exhausted")
+
+	res, _, _ = limiter.Allow(ctx, "c", "GET /api/items", false)
+	assert.True(t, res.Allowed, "reads use a separate budget")
+
+	res, policy, _ := limiter.Allow(ctx, "c", "DELETE /api/items/123", true)
+	assert.True(t, res.Allowed, "the override replaces the write budget")
+	assert.Equal(t, "3/m", policy, "the most specific override, then the first listed, wins")
+	assert.Equal(t, 2, res.Remaining)
+
+	_, policy, _ = limiter.Allow(ctx, "c", "DELETE /api/collections/123", true)
+	assert.Equal(t, "5/m", policy)
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Write:   ratelimit.Rate{Limit: 1, Period: time.Minute},
+		APIKeys: map[string]string{"key-a": "a", "key-b": "b"},
+	}, ratelimit.NewMemoryStore())
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	send := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/items", nil)
+		req.Header.Set(ratelimit.APIKeyHeader, apiKey)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("key-a")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+
+	w = send("key-a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, apierror.ProblemContentType, w.Header().Get("Content-Type"))
+
+	var problem apierror.Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, ratelimit.ReasonRateLimited, problem.Code)
+	assert.Equal(t, 60, problem.RetryAfter)
+
+	// A different API key has its own budget
+	assert.Equal(t, http.StatusCreated, send("key-b").Code)
+
+	// Unknown keys share the budget of the client address
+	assert.Equal(t, http.StatusCreated, send("made-up-1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("made-up-2").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("").Code)
+}
+
+func TestGRPCInterceptor(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	require.NoError(t, database.Migrate(db))
+
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Read:  ratelimit.Rate{Limit: 100, Period: time.Second},
+		Write: ratelimit.Rate{Limit: 1, Period: time.Minute},
+	}, ratelimit.NewMemoryStore())
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpclib.NewServer(grpclib.ChainUnaryInterceptor(limiter.UnaryInterceptor))
+	pb.RegisterItemServiceServer(s, grpcserver.NewItemServer(db, money.Rules{}))
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpclib.NewClient("passthrough:///bufnet",
+		grpclib.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewItemServiceClient(conn)
+	ctx := context.Background()
+
+	_, err = client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Test Item", Value: &decimal.Decimal{Value: "1"}})
+	require.NoError(t, err)
+
+	_, err = client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Test Item", Value: &decimal.Decimal{Value: "1"}})
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+
+	var retry *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	require.NotNil(t, retry)
+	assert.InDelta(t, time.Minute, retry.RetryDelay.AsDuration(), float64(time.Second))
+
+	// Reads are not affected by the exhausted write budget
+	_, err = client.ListItems(ctx, &pb.ListItemsRequest{})
+	assert.NoError(t, err)
+}
+
+func TestSQLiteStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.db")
+	rate := ratelimit.Rate{Limit: 1, Period: time.Hour}
+	now := time.Now()
+	ctx := context.Background()
+
+	open := func() *ratelimit.SQLiteStore {
+		db, err := sql.Open("sqlite3", path)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		store, err := ratelimit.NewSQLiteStore(db)
+		require.NoError(t, err)
+		return store
+	}
+
+	store := open()
+	res, err := store.Take(ctx, "key:a", rate, now)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	require.NoError(t, store.Flush(ctx))
+
+	// A new store over the same database sees the exhausted bucket
+	res, err = open().Take(ctx, "key:a", rate, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+}
+
+func TestSQLiteStoreSharesBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.db")
+	rate := ratelimit.Rate{Limit: 4, Period: time.Hour}
+	now := time.Now()
+	ctx := context.Background()
+
+	db, err := database.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+	a, err := ratelimit.NewSQLiteStore(db)
+	require.NoError(t, err)
+	b, err := ratelimit.NewSQLiteStore(db)
+	require.NoError(t, err)
+
+	// Takes are cached until flushed
+	for i := 0; i < 2; i++ {
+		res, err := a.Take(ctx, "key:a", rate, now)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+	}
+	var rows int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM rate_limits").Scan(&rows))
+	assert.Zero(t, rows)
+	require.NoError(t, a.Flush(ctx))
+
+	res, err := b.Take(ctx, "key:a", rate, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Remaining)
+	require.NoError(t, b.Flush(ctx))
+
+	// A flush merges what the other store took
+	res, err = a.Take(ctx, "key:a", rate, now)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	require.NoError(t, a.Flush(ctx))
+	res, err = a.Take(ctx, "key:a", rate, now)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+}
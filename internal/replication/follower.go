@@ -0,0 +1,225 @@
+package replication
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+)
+
+// Reconnect backoff bounds
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// ackInterval is how often the applied position is reported to the primary
+const ackInterval = time.Second
+
+// Status describes how far a replica is behind its primary
+type Status struct {
+	Connected  bool    `json:"connected"`
+	AppliedSeq int64   `json:"applied_seq"`
+	HeadSeq    int64   `json:"head_seq"`
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+// Follower applies the primary's change stream to the local database
+type Follower struct {
+	db      *sql.DB
+	client  pb.ReplicationServiceClient
+	id      string
+	started time.Time
+	acked   time.Time
+
+	mu        sync.Mutex
+	connected bool
+	applied   int64
+	head      int64
+	caughtUp  time.Time
+}
+
+// NewFollower creates a follower that streams changes over conn. The applied
+// position is kept in the replication_state table of db so a restarted
+// replica resumes where it stopped, and reported to the primary under an ID
+// kept in replica_identity so the primary can prune what it has applied.
+func NewFollower(db *sql.DB, conn grpc.ClientConnInterface) (*Follower, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS replication_state (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		applied_seq INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS replica_identity (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		replica_id TEXT NOT NULL
+	);
+
+	INSERT OR IGNORE INTO replica_identity (id, replica_id) VALUES (1, ?);`, uuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Follower{db: db, client: pb.NewReplicationServiceClient(conn), started: time.Now()}
+	if err := db.QueryRow("SELECT replica_id FROM replica_identity WHERE id = 1").Scan(&f.id); err != nil {
+		return nil, err
+	}
+	err = db.QueryRow("SELECT applied_seq FROM replication_state WHERE id = 1").Scan(&f.applied)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A database restored from a backup of the primary has applied the
+		// primary's change log up to the backup
+		err = db.QueryRow(`SELECT MAX(COALESCE((SELECT MAX(seq) FROM item_changes), 0),
+			(SELECT seq FROM item_changes_pruned WHERE id = 1))`).Scan(&f.applied)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.head = f.applied
+	return f, nil
+}
+
+// Run follows the primary until ctx is cancelled, reconnecting with
+// exponential backoff when the stream breaks
+func (f *Follower) Run(ctx context.Context) {
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		applied, err := f.follow(ctx)
+		f.setConnected(false)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Replication stream from primary ended: %v", err)
+
+		if applied {
+			backoff = minBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// follow streams and applies changes until the stream fails. It reports
+// whether any message was received.
+func (f *Follower) follow(ctx context.Context) (bool, error) {
+	stream, err := f.client.StreamChanges(ctx, &pb.StreamChangesRequest{AfterSeq: f.Status().AppliedSeq, ReplicaId: f.id})
+	if err != nil {
+		return false, err
+	}
+	f.acked = time.Now()
+
+	received := false
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return received, errors.New("primary closed the stream")
+		}
+		if err != nil {
+			return received, err
+		}
+		received = true
+		f.setConnected(true)
+		if err := f.Apply(ctx, resp); err != nil {
+			return received, err
+		}
+		if time.Since(f.acked) >= ackInterval {
+			f.ack(ctx)
+		}
+	}
+}
+
+// ack reports the applied position to the primary. A failed report is
+// retried on a later batch.
+func (f *Follower) ack(ctx context.Context) {
+	_, err := f.client.AckChanges(ctx, &pb.AckChangesRequest{ReplicaId: f.id, AppliedSeq: f.Status().AppliedSeq})
+	if err != nil {
+		log.Printf("Error reporting replication position: %v", err)
+		return
+	}
+	f.acked = time.Now()
+}
+
+// Apply writes a batch of changes and the new position in one transaction
+func (f *Follower) Apply(ctx context.Context, resp *pb.StreamChangesResponse) error {
+	if len(resp.Changes) > 0 {
+		last := resp.Changes[len(resp.Changes)-1].Seq
+		err := database.WithTx(ctx, f.db, func(tx *sql.Tx) error {
+			for _, c := range resp.Changes {
+				if err := applyChange(ctx, tx, c); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx,
+				"INSERT INTO replication_state (id, applied_seq) VALUES (1, ?) "+
+					"ON CONFLICT(id) DO UPDATE SET applied_seq = excluded.applied_seq", last)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		f.applied = last
+		f.mu.Unlock()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.head = resp.HeadSeq
+	if f.applied >= f.head {
+		f.caughtUp = time.Now()
+	}
+	return nil
+}
+
+func applyChange(ctx context.Context, tx *sql.Tx, c *pb.Change) error {
+	switch c.Op {
+	case pb.Change_OP_UPSERT:
+		item, err := items.FromProto(c.Item)
+		if err != nil {
+			return err
+		}
+		return items.Replace(ctx, tx, item)
+	case pb.Change_OP_DELETE:
+		_, err := tx.ExecContext(ctx, "DELETE FROM items WHERE id = ?", c.ItemId)
+		return err
+	default:
+		return errors.New("unknown change operation " + c.Op.String())
+	}
+}
+
+func (f *Follower) setConnected(connected bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = connected
+}
+
+// Status reports the applied position and lag. Lag is the time since the
+// replica last applied everything the primary had, or since it started if it
+// has not caught up yet.
+func (f *Follower) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	since := f.caughtUp
+	if since.IsZero() {
+		since = f.started
+	}
+	return Status{
+		Connected:  f.connected,
+		AppliedSeq: f.applied,
+		HeadSeq:    f.head,
+		LagSeconds: time.Since(since).Seconds(),
+	}
+}
@@ -0,0 +1,136 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/ratelimit"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"google.golang.org/grpc/codes"
+)
+
+// ReasonReadOnlyReplica is the error reason for writes sent to a replica
+const ReasonReadOnlyReplica = "READ_ONLY_REPLICA"
+
+// Response headers reporting the replica position
+const (
+	LagHeader = "X-Replication-Lag"
+	SeqHeader = "X-Replication-Seq"
+)
+
+// readOnly builds the error returned for rejected writes
+func readOnly() *apierror.Error {
+	return &apierror.Error{
+		Code:    codes.FailedPrecondition,
+		Reason:  ReasonReadOnlyReplica,
+		Message: "this instance is a read-only replica, send writes to the primary",
+	}
+}
+
+// ItemServer serves reads from the local replica and forwards writes to the
+// primary, or rejects them when primary is nil
+type ItemServer struct {
+	pb.ItemServiceServer
+	primary pb.ItemServiceClient
+}
+
+// NewItemServer wraps the local item server of a replica
+func NewItemServer(local pb.ItemServiceServer, primary pb.ItemServiceClient) *ItemServer {
+	return &ItemServer{ItemServiceServer: local, primary: primary}
+}
+
+func (s *ItemServer) CreateItem(ctx context.Context, req *pb.CreateItemRequest) (*pb.Item, error) {
+	if s.primary == nil {
+		return nil, readOnly()
+	}
+	return s.primary.CreateItem(ctx, req)
+}
+
+func (s *ItemServer) UpdateItem(ctx context.Context, req *pb.UpdateItemRequest) (*pb.Item, error) {
+	if s.primary == nil {
+		return nil, readOnly()
+	}
+	return s.primary.UpdateItem(ctx, req)
+}
+
+func (s *ItemServer) DeleteItem(ctx context.Context, req *pb.DeleteItemRequest) (*pb.DeleteItemResponse, error) {
+	if s.primary == nil {
+		return nil, readOnly()
+	}
+	return s.primary.DeleteItem(ctx, req)
+}
+
+// Middleware reports the replica position in response headers and forwards
+// HTTP writes to primaryURL, or rejects them when primaryURL is nil. Admin
+// requests other than schemas are served locally. Collections, attachment
+// content, webhooks and schemas are not replicated, so every request for them
+// is treated as a write.
+func (f *Follower) Middleware(primaryURL *url.URL) func(http.Handler) http.Handler {
+	var proxy *httputil.ReverseProxy
+	if primaryURL != nil {
+		proxy = httputil.NewSingleHostReverseProxy(primaryURL)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			st := f.Status()
+			w.Header().Set(LagHeader, strconv.FormatFloat(st.LagSeconds, 'f', 3, 64))
+			w.Header().Set(SeqHeader, strconv.FormatInt(st.AppliedSeq, 10))
+
+			primaryOnly := ratelimit.IsWriteRequest(r) && !strings.HasPrefix(r.URL.Path, "/api/admin/") ||
+				!isReplicated(r.URL.Path)
+			if !primaryOnly {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if proxy == nil {
+				apierror.Write(w, r, readOnly())
+				return
+			}
+			proxy.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HealthCheck reports the replica status. It answers 503 while the replica
+// is more than maxLag behind, so load balancers stop sending it reads.
+func (f *Follower) HealthCheck(maxLag time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st := f.Status()
+		status, code := "healthy", http.StatusOK
+		if st.LagSeconds > maxLag.Seconds() {
+			status, code = "lagging", http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":      status,
+			"mode":        "replica",
+			"replication": st,
+		})
+	}
+}
+
+// isReplicated reports whether the data under path is in the change stream
+func isReplicated(path string) bool {
+	return !strings.HasPrefix(path, "/api/collections") && !strings.HasPrefix(path, "/api/webhooks") &&
+		!strings.HasPrefix(path, "/api/admin/schemas") && !isAttachmentPath(path)
+}
+
+// isAttachmentPath reports whether path is under /api/items/{id}/attachments
+func isAttachmentPath(path string) bool {
+	rest, ok := strings.CutPrefix(path, "/api/items/")
+	if !ok {
+		return false
+	}
+	_, sub, _ := strings.Cut(rest, "/")
+	return sub == "attachments" || strings.HasPrefix(sub, "attachments/")
+}
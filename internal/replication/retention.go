@@ -0,0 +1,90 @@
+package replication
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+)
+
+// changedAtLayout is how item_changes stores changed_at
+const changedAtLayout = "2006-01-02T15:04:05.000Z"
+
+// pruneInterval is how often the change log is pruned, unless MaxAge is
+// shorter
+const pruneInterval = time.Minute
+
+// Pruner keeps the item_changes log from growing without bound
+type Pruner struct {
+	db *sql.DB
+	// MaxAge is how long a change is kept for replicas that have not applied
+	// it. A replica that falls further behind must be restored from a backup.
+	MaxAge time.Duration
+}
+
+// NewPruner creates a pruner for the change log of db that keeps changes
+// for at most maxAge
+func NewPruner(db *sql.DB, maxAge time.Duration) *Pruner {
+	return &Pruner{db: db, MaxAge: maxAge}
+}
+
+// Run prunes the change log until ctx ends
+func (p *Pruner) Run(ctx context.Context) {
+	ticker := time.NewTicker(min(pruneInterval, p.MaxAge))
+	defer ticker.Stop()
+	for {
+		if n, err := p.Prune(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error pruning change log: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d changes from the change log", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune deletes the changes every replica has applied, and changes older
+// than MaxAge whatever replicas have applied. Replicas that have not
+// reported within MaxAge are forgotten. Without replicas only the age limit
+// applies, so a new replica can still start from the beginning of the log.
+// It returns the number of changes deleted.
+func (p *Pruner) Prune(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-p.MaxAge).UTC()
+
+	// Probe outside a transaction so an idle log takes no write lock
+	var bound, pruned int64
+	err := p.db.QueryRowContext(ctx, `SELECT MAX(
+			COALESCE((SELECT MIN(applied_seq) FROM replica_cursors WHERE updated_at >= ?), 0),
+			COALESCE((SELECT MAX(seq) FROM item_changes WHERE changed_at < ?), 0)),
+		(SELECT seq FROM item_changes_pruned WHERE id = 1)`,
+		cutoff, cutoff.Format(changedAtLayout)).Scan(&bound, &pruned)
+	if err != nil {
+		return 0, err
+	}
+	if bound <= pruned {
+		return 0, nil
+	}
+
+	var n int64
+	err = database.WithTx(ctx, p.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM item_changes WHERE seq <= ?", bound)
+		if err != nil {
+			return err
+		}
+		if n, err = result.RowsAffected(); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE item_changes_pruned SET seq = MAX(seq, ?) WHERE id = 1", bound); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM replica_cursors WHERE updated_at < ?", cutoff)
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,182 @@
+package replication
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/money"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// batchSize bounds the number of changes sent in one message
+const batchSize = 500
+
+// Server streams the item_changes log to replicas
+type Server struct {
+	pb.UnimplementedReplicationServiceServer
+	db    *sql.DB
+	rules money.Rules
+	// PollInterval is how often the change log is checked for new rows
+	PollInterval time.Duration
+	// HeartbeatInterval is how often an empty batch is sent while idle
+	HeartbeatInterval time.Duration
+}
+
+// NewServer creates a replication server reading the change log of db and
+// sending items written the way rules round them
+func NewServer(db *sql.DB, rules money.Rules) *Server {
+	return &Server{db: db, rules: rules, PollInterval: 100 * time.Millisecond, HeartbeatInterval: time.Second}
+}
+
+// ReasonChangesPruned is the error reason for a stream asked to start before
+// the oldest change still kept
+const ReasonChangesPruned = "CHANGES_PRUNED"
+
+// StreamChanges sends changes after req.AfterSeq until the client goes away.
+// A replica that sends its ID has AfterSeq recorded as its applied position.
+func (s *Server) StreamChanges(req *pb.StreamChangesRequest, stream pb.ReplicationService_StreamChangesServer) error {
+	ctx := stream.Context()
+	after := req.AfterSeq
+	lastSent := time.Time{}
+
+	var pruned int64
+	if err := s.db.QueryRowContext(ctx, "SELECT seq FROM item_changes_pruned WHERE id = 1").Scan(&pruned); err != nil {
+		return apierror.Internal(err, "reading change log")
+	}
+	if after < pruned {
+		return &apierror.Error{
+			Code:     codes.FailedPrecondition,
+			Reason:   ReasonChangesPruned,
+			Message:  fmt.Sprintf("changes up to %d were pruned, restore the replica from a backup", pruned),
+			Metadata: map[string]string{"pruned_seq": strconv.FormatInt(pruned, 10)},
+		}
+	}
+	if req.ReplicaId != "" {
+		if err := s.ack(ctx, req.ReplicaId, after); err != nil {
+			return apierror.Internal(err, "recording replica position")
+		}
+	}
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		changes, head, err := s.changesAfter(ctx, after)
+		if err != nil {
+			return apierror.Internal(err, "reading change log")
+		}
+
+		if len(changes) > 0 || time.Since(lastSent) >= s.HeartbeatInterval {
+			resp := &pb.StreamChangesResponse{Changes: changes, HeadSeq: head, SentAt: timestamppb.Now()}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+			lastSent = time.Now()
+		}
+		if len(changes) > 0 {
+			after = changes[len(changes)-1].Seq
+			if len(changes) == batchSize {
+				// More changes are waiting
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// AckChanges records the position a replica has applied
+func (s *Server) AckChanges(ctx context.Context, req *pb.AckChangesRequest) (*pb.AckChangesResponse, error) {
+	if req.ReplicaId == "" {
+		return nil, apierror.InvalidArgument("invalid acknowledgement",
+			apierror.FieldViolation{Field: "replica_id", Description: "replica_id is required"})
+	}
+	if err := s.ack(ctx, req.ReplicaId, req.AppliedSeq); err != nil {
+		return nil, apierror.Internal(err, "recording replica position")
+	}
+	return &pb.AckChangesResponse{}, nil
+}
+
+func (s *Server) ack(ctx context.Context, replicaID string, seq int64) error {
+	return database.WithTx(ctx, s.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO replica_cursors (replica_id, applied_seq, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(replica_id) DO UPDATE SET applied_seq = excluded.applied_seq, updated_at = excluded.updated_at`,
+			replicaID, seq, time.Now().UTC())
+		return err
+	})
+}
+
+// changesAfter reads the next batch of changes after seq and the head
+// sequence number. Upserts carry the item's current state, so a change whose
+// item was deleted later is sent as a delete. The reads run outside a
+// transaction so idle streams never take a lock; an item deleted between
+// them is sent as a delete too.
+func (s *Server) changesAfter(ctx context.Context, seq int64) ([]*pb.Change, int64, error) {
+	var head int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(seq), 0) FROM item_changes").Scan(&head); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.seq, c.item_id, c.op, c.changed_at, items.id IS NOT NULL
+		FROM item_changes c LEFT JOIN items ON items.id = c.item_id
+		WHERE c.seq > ? ORDER BY c.seq LIMIT ?`, seq, batchSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	var changes []*pb.Change
+	for rows.Next() {
+		var (
+			c         pb.Change
+			op        string
+			changedAt time.Time
+			exists    bool
+		)
+		if err := rows.Scan(&c.Seq, &c.ItemId, &op, &changedAt, &exists); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		c.ChangedAt = timestamppb.New(changedAt)
+		c.Op = pb.Change_OP_DELETE
+		if op == "upsert" && exists {
+			c.Op = pb.Change_OP_UPSERT
+		}
+		changes = append(changes, &c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(changes) > 0 {
+		// Changes committed after head was read
+		head = max(head, changes[len(changes)-1].Seq)
+	}
+
+	for _, c := range changes {
+		if c.Op == pb.Change_OP_UPSERT {
+			item, err := items.Get(ctx, s.db, s.rules, c.ItemId)
+			var apiErr *apierror.Error
+			if errors.As(err, &apiErr) && apiErr.Code == codes.NotFound {
+				c.Op = pb.Change_OP_DELETE
+				continue
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			c.Item = items.Proto(item)
+		}
+	}
+	return changes, head, nil
+}
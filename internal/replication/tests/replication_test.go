@@ -0,0 +1,288 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/replication"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// openDB opens a file database with the production schema
+func openDB(t *testing.T) *sql.DB {
+	db, err := database.Open(filepath.Join(t.TempDir(), "data.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// startPrimary serves the item and replication services of db over bufconn
+func startPrimary(t *testing.T, db *sql.DB) *grpclib.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpclib.NewServer()
+	pb.RegisterItemServiceServer(s, grpcserver.NewItemServer(db, money.Rules{}))
+	repl := replication.NewServer(db, money.Rules{})
+	repl.PollInterval = 5 * time.Millisecond
+	repl.HeartbeatInterval = 20 * time.Millisecond
+	pb.RegisterReplicationServiceServer(s, repl)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpclib.NewClient("passthrough:///bufnet",
+		grpclib.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// runFollower follows the primary until the test ends
+func runFollower(t *testing.T, db *sql.DB, conn *grpclib.ClientConn) *replication.Follower {
+	f, err := replication.NewFollower(db, conn)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go f.Run(ctx)
+	return f
+}
+
+func itemNames(t *testing.T, db *sql.DB) map[string]string {
+	rows, err := db.Query("SELECT id, name FROM items")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var id, name string
+		require.NoError(t, rows.Scan(&id, &name))
+		names[id] = name
+	}
+	return names
+}
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func TestReplicaFollowsPrimary(t *testing.T) {
+	primaryDB, replicaDB := openDB(t), openDB(t)
+	ctx := context.Background()
+
+	// Items written before the replica starts are replicated too
+	_, err := primaryDB.Exec("INSERT INTO items (id, name, value_units) VALUES ('existing', 'Existing', 10000)")
+	require.NoError(t, err)
+
+	conn := startPrimary(t, primaryDB)
+	client := pb.NewItemServiceClient(conn)
+	f := runFollower(t, replicaDB, conn)
+
+	a, err := client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Item A", Value: &decimal.Decimal{Value: "1"}})
+	require.NoError(t, err)
+	b, err := client.CreateItem(ctx, &pb.CreateItemRequest{Name: "Item B", Value: &decimal.Decimal{Value: "2"}})
+	require.NoError(t, err)
+	_, err = client.UpdateItem(ctx, &pb.UpdateItemRequest{Id: a.Id, Name: "Item A v2", Value: &decimal.Decimal{Value: "3"}})
+	require.NoError(t, err)
+	_, err = client.DeleteItem(ctx, &pb.DeleteItemRequest{Id: b.Id})
+	require.NoError(t, err)
+
+	want := map[string]string{"existing": "Existing", a.Id: "Item A v2"}
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(want, itemNames(t, replicaDB))
+	}, 5*time.Second, 10*time.Millisecond)
+
+	st := f.Status()
+	assert.True(t, st.Connected)
+	assert.Equal(t, st.HeadSeq, st.AppliedSeq)
+	assert.Less(t, st.LagSeconds, 1.0)
+
+	// The primary learns the replica's position
+	require.Eventually(t, func() bool {
+		var applied int64
+		err := primaryDB.QueryRow("SELECT applied_seq FROM replica_cursors").Scan(&applied)
+		return err == nil && applied == st.AppliedSeq
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// A restarted replica resumes from its stored position
+	restarted, err := replication.NewFollower(replicaDB, conn)
+	require.NoError(t, err)
+	assert.Equal(t, st.AppliedSeq, restarted.Status().AppliedSeq)
+}
+
+func TestReplicaWrites(t *testing.T) {
+	primaryDB, replicaDB := openDB(t), openDB(t)
+	ctx := context.Background()
+	conn := startPrimary(t, primaryDB)
+	local := grpcserver.NewItemServer(replicaDB, money.Rules{})
+
+	// Rejected writes name the reason
+	_, err := replication.NewItemServer(local, nil).CreateItem(ctx, &pb.CreateItemRequest{Name: "Item", Value: &decimal.Decimal{Value: "1"}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, replication.ReasonReadOnlyReplica, apierror.FromStatus(status.Convert(err)).Reason)
+
+	// Forwarded writes reach the primary, reads stay local
+	forwarding := replication.NewItemServer(local, pb.NewItemServiceClient(conn))
+	item, err := forwarding.CreateItem(ctx, &pb.CreateItemRequest{Name: "Item", Value: &decimal.Decimal{Value: "1"}})
+	require.NoError(t, err)
+	assert.Contains(t, itemNames(t, primaryDB), item.Id)
+
+	_, err = forwarding.GetItem(ctx, &pb.GetItemRequest{Id: item.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err), "the follower is not running")
+}
+
+func TestReplicaHTTP(t *testing.T) {
+	primaryDB, replicaDB := openDB(t), openDB(t)
+	conn := startPrimary(t, primaryDB)
+	f, err := replication.NewFollower(replicaDB, conn)
+	require.NoError(t, err)
+
+	handler := f.Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Reads are served with the replica position
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/items", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get(replication.SeqHeader))
+	assert.NotEmpty(t, w.Header().Get(replication.LagHeader))
+
+	// Writes are rejected
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/items", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem apierror.Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, replication.ReasonReadOnlyReplica, problem.Code)
+
+	// Collections are not replicated, so even reads go to the primary
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/collections", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// So is attachment content
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/items/1/attachments/2/content", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/items/attachments", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Webhook subscriptions and deliveries live on the primary
+	for _, path := range []string{"/api/webhooks", "/api/webhooks/1/deliveries"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+
+	// So are schemas, while other admin writes stay local
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/schemas/2:activate", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/schemas", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/metadata-indexes", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The health check fails while the replica has not caught up
+	w = httptest.NewRecorder()
+	f.HealthCheck(time.Nanosecond)(w, httptest.NewRequest("GET", "/api/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	f.HealthCheck(time.Hour)(w, httptest.NewRequest("GET", "/api/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var health struct {
+		Status      string
+		Mode        string
+		Replication replication.Status
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&health))
+	assert.Equal(t, "replica", health.Mode)
+	assert.False(t, health.Replication.Connected)
+}
+
+func TestChangeLogRetention(t *testing.T) {
+	primaryDB := openDB(t)
+	ctx := context.Background()
+	for _, id := range []string{"a", "b", "c"} {
+		_, err := primaryDB.Exec("INSERT INTO items (id, name, value_units) VALUES (?, 'Item', 10000)", id)
+		require.NoError(t, err)
+	}
+	conn := startPrimary(t, primaryDB)
+	client := pb.NewReplicationServiceClient(conn)
+	pruner := replication.NewPruner(primaryDB, time.Hour)
+	var head int64
+	require.NoError(t, primaryDB.QueryRow("SELECT MAX(seq) FROM item_changes").Scan(&head))
+	remaining := func() int {
+		var n int
+		require.NoError(t, primaryDB.QueryRow("SELECT COUNT(*) FROM item_changes").Scan(&n))
+		return n
+	}
+
+	// Without replicas, changes are kept until they are too old
+	n, err := pruner.Prune(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Changes are kept until every replica has applied them
+	_, err = client.AckChanges(ctx, &pb.AckChangesRequest{ReplicaId: "r1", AppliedSeq: head})
+	require.NoError(t, err)
+	_, err = client.AckChanges(ctx, &pb.AckChangesRequest{ReplicaId: "r2", AppliedSeq: head - 2})
+	require.NoError(t, err)
+	n, err = pruner.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, head-2, n)
+	assert.Equal(t, 2, remaining())
+
+	// A replica behind the pruned changes is told to restore
+	stream, err := client.StreamChanges(ctx, &pb.StreamChangesRequest{AfterSeq: 0})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, replication.ReasonChangesPruned, apierror.FromStatus(status.Convert(err)).Reason)
+
+	// Changes past the age limit are pruned whatever replicas have applied
+	_, err = primaryDB.Exec("UPDATE item_changes SET changed_at = '2000-01-01T00:00:00.000Z' WHERE seq = ?", head)
+	require.NoError(t, err)
+	n, err = pruner.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Zero(t, remaining())
+
+	// A replica restored from a copy of the primary resumes from the copy
+	path := filepath.Join(t.TempDir(), "restored.db")
+	_, err = primaryDB.Exec("VACUUM INTO ?", path)
+	require.NoError(t, err)
+	restored, err := database.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { restored.Close() })
+	f := runFollower(t, restored, conn)
+	assert.Equal(t, head, f.Status().AppliedSeq)
+	require.Eventually(t, func() bool { return f.Status().Connected }, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,171 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/items"
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc/codes"
+)
+
+// Page size limits for search results
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ReasonSearchUnavailable is reported when the server was built without FTS5
+const ReasonSearchUnavailable = "SEARCH_UNAVAILABLE"
+
+// Snippets are built with private-use delimiters so the item text can be
+// HTML-escaped before the <mark> tags are put in
+const (
+	markStart = "\uE000"
+	markEnd   = "\uE001"
+)
+
+// Request is a full-text query. Text uses the FTS5 query syntax: terms are
+// ANDed, "quoted phrases" match in order, a trailing * matches a prefix and
+// AND, OR, NOT and parentheses combine expressions.
+type Request struct {
+	Text      string
+	PageSize  int
+	PageToken string
+}
+
+// Result is one matching item with its relevance and a highlighted snippet
+type Result struct {
+	Item    models.Item `json:"item"`
+	Score   float64     `json:"score"`
+	Snippet string      `json:"snippet"`
+}
+
+// Page is one page of results ordered by relevance
+type Page struct {
+	Results       []Result `json:"results"`
+	NextPageToken string   `json:"next_page_token,omitempty"`
+}
+
+// Items runs a full-text query against the items index. Errors are
+// *apierror.Error values ready to be returned by either transport.
+func Items(ctx context.Context, db *sql.DB, rules money.Rules, req Request) (*Page, error) {
+	var violations []apierror.FieldViolation
+	if strings.TrimSpace(req.Text) == "" {
+		violations = append(violations, apierror.FieldViolation{Field: "query", Description: "query is required"})
+	}
+	if req.PageSize < 0 || req.PageSize > MaxPageSize {
+		violations = append(violations, apierror.FieldViolation{
+			Field:       "page_size",
+			Description: "page_size must be between 0 and " + strconv.Itoa(MaxPageSize),
+		})
+	}
+	offset, err := decodePageToken(req.PageToken)
+	if err != nil {
+		violations = append(violations, apierror.FieldViolation{Field: "page_token", Description: "page_token is invalid"})
+	}
+	if len(violations) > 0 {
+		return nil, apierror.InvalidArgument("invalid search request", violations...)
+	}
+
+	if !database.SearchAvailable(db) {
+		return nil, &apierror.Error{
+			Code:    codes.Unimplemented,
+			Reason:  ReasonSearchUnavailable,
+			Message: database.ErrSearchUnavailable.Error(),
+		}
+	}
+
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
+	// bm25 is lower for better matches; one extra row tells whether another
+	// page follows
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+items.Columns+`, bm25(items_fts), snippet(items_fts, 0, ?, ?, '…', 16)
+		FROM items_fts
+		JOIN items ON items.rowid = items_fts.rowid
+		WHERE items_fts MATCH ?
+		ORDER BY bm25(items_fts), items.id
+		LIMIT ? OFFSET ?`,
+		markStart, markEnd, req.Text, pageSize+1, offset)
+	if err != nil {
+		return nil, queryError(err)
+	}
+	defer rows.Close()
+
+	page := &Page{Results: make([]Result, 0, pageSize)}
+	for rows.Next() {
+		var r Result
+		var rank float64
+		if err := items.Scan(rows, rules, &r.Item, &rank, &r.Snippet); err != nil {
+			return nil, apierror.Internal(err, "scanning search result")
+		}
+		if len(page.Results) == pageSize {
+			page.NextPageToken = encodePageToken(offset + pageSize)
+			break
+		}
+		r.Score = -rank
+		r.Snippet = highlight(r.Snippet)
+		page.Results = append(page.Results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(err)
+	}
+	return page, nil
+}
+
+// queryError reports errors in the query text as invalid arguments and
+// sanitizes everything else. FTS5 rejects a bad MATCH expression with the
+// generic SQLITE_ERROR code, which the fixed SQL above never produces.
+func queryError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrError {
+		return apierror.InvalidArgument("invalid search query",
+			apierror.FieldViolation{Field: "query", Description: err.Error()})
+	}
+	return apierror.Internal(err, "searching items")
+}
+
+// highlight escapes a snippet for HTML and wraps matched terms in <mark>
+func highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, markStart, "<mark>")
+	return strings.ReplaceAll(escaped, markEnd, "</mark>")
+}
+
+// encodePageToken makes an opaque token for the result offset
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodePageToken returns the offset stored in a page token
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	s, ok := strings.CutPrefix(string(raw), "offset:")
+	if !ok {
+		return 0, errors.New("unknown page token format")
+	}
+	offset, err := strconv.Atoi(s)
+	if err != nil || offset < 0 {
+		return 0, errors.New("invalid page token offset")
+	}
+	return offset, nil
+}
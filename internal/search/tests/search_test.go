@@ -0,0 +1,230 @@
+//go:build sqlite_fts5
+
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/search"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// openTestDB opens a file database with the search index
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "search.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// insertItems adds items with the given names
+func insertItems(t *testing.T, db *sql.DB, names ...string) {
+	t.Helper()
+	for i, name := range names {
+		_, err := db.Exec("INSERT INTO items (id, name, value_units) VALUES (?, ?, ?)", name, name, i)
+		require.NoError(t, err)
+	}
+}
+
+// names returns the item names of a page in order
+func names(page *search.Page) []string {
+	out := make([]string, 0, len(page.Results))
+	for _, r := range page.Results {
+		out = append(out, r.Item.Name)
+	}
+	return out
+}
+
+func TestQuerySyntax(t *testing.T) {
+	db := openTestDB(t)
+	insertItems(t, db, "red widget", "blue widget", "red gadget", "widget red", "gizmo")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"widget", []string{"blue widget", "red widget", "widget red"}},
+		{"wid*", []string{"blue widget", "red widget", "widget red"}},
+		{`"red widget"`, []string{"red widget"}},
+		{"red AND widget", []string{"red widget", "widget red"}},
+		{"gadget OR gizmo", []string{"gizmo", "red gadget"}},
+		{"widget NOT blue", []string{"red widget", "widget red"}},
+		{"name:gizmo", []string{"gizmo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			page, err := search.Items(context.Background(), db, money.Rules{}, search.Request{Text: tt.query})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, names(page))
+		})
+	}
+}
+
+func TestRankingAndSnippets(t *testing.T) {
+	db := openTestDB(t)
+	insertItems(t, db, "lamp with a long description of many other words", "lamp lamp", "<b>lamp</b> & shade")
+
+	page, err := search.Items(context.Background(), db, money.Rules{}, search.Request{Text: "lamp"})
+	require.NoError(t, err)
+	require.Len(t, page.Results, 3)
+
+	// More occurrences in a shorter name rank first
+	assert.Equal(t, "lamp lamp", page.Results[0].Item.Name)
+	assert.Greater(t, page.Results[0].Score, page.Results[2].Score)
+
+	for _, r := range page.Results {
+		if r.Item.Name == "<b>lamp</b> & shade" {
+			assert.Equal(t, "&lt;b&gt;<mark>lamp</mark>&lt;/b&gt; &amp; shade", r.Snippet)
+		}
+	}
+}
+
+func TestPagination(t *testing.T) {
+	db := openTestDB(t)
+	insertItems(t, db, "item a", "item b", "item c", "item d", "item e")
+
+	var seen []string
+	req := search.Request{Text: "item", PageSize: 2}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		page, err := search.Items(context.Background(), db, money.Rules{}, req)
+		require.NoError(t, err)
+		seen = append(seen, names(page)...)
+		if page.NextPageToken == "" {
+			break
+		}
+		req.PageToken = page.NextPageToken
+	}
+	assert.ElementsMatch(t, []string{"item a", "item b", "item c", "item d", "item e"}, seen)
+}
+
+func TestInvalidRequests(t *testing.T) {
+	db := openTestDB(t)
+
+	tests := []struct {
+		name  string
+		req   search.Request
+		field string
+	}{
+		{"empty query", search.Request{Text: " "}, "query"},
+		{"syntax error", search.Request{Text: `"unterminated`}, "query"},
+		{"unknown column", search.Request{Text: "price:1"}, "query"},
+		{"page size", search.Request{Text: "x", PageSize: search.MaxPageSize + 1}, "page_size"},
+		{"page token", search.Request{Text: "x", PageToken: "not a token"}, "page_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := search.Items(context.Background(), db, money.Rules{}, tt.req)
+			var apiErr *apierror.Error
+			require.True(t, errors.As(err, &apiErr), "got %v", err)
+			assert.Equal(t, codes.InvalidArgument, apiErr.Code)
+			require.NotEmpty(t, apiErr.Violations)
+			assert.Equal(t, tt.field, apiErr.Violations[0].Field)
+		})
+	}
+}
+
+func TestIndexFollowsWrites(t *testing.T) {
+	db := openTestDB(t)
+	insertItems(t, db, "old name")
+
+	_, err := db.Exec("UPDATE items SET name = 'new name' WHERE id = 'old name'")
+	require.NoError(t, err)
+
+	page, err := search.Items(context.Background(), db, money.Rules{}, search.Request{Text: "old"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Results)
+	page, err = search.Items(context.Background(), db, money.Rules{}, search.Request{Text: "new"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new name"}, names(page))
+
+	_, err = db.Exec("DELETE FROM items")
+	require.NoError(t, err)
+	page, err = search.Items(context.Background(), db, money.Rules{}, search.Request{Text: "new"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Results)
+}
+
+func TestReindexExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := database.Open(path)
+	require.NoError(t, err)
+
+	// Simulate a database written by a build without FTS5
+	for _, stmt := range []string{
+		"DROP TRIGGER items_fts_insert",
+		"DROP TRIGGER items_fts_update",
+		"DROP TRIGGER items_fts_delete",
+		"DROP TABLE items_fts",
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	insertItems(t, db, "legacy widget", "legacy gadget")
+	require.NoError(t, db.Close())
+
+	// Opening with FTS5 recreates the index and indexes existing rows
+	db, err = database.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	page, err := search.Items(context.Background(), db, money.Rules{}, search.Request{Text: "legacy"})
+	require.NoError(t, err)
+	assert.Len(t, page.Results, 2)
+
+	count, err := database.Reindex(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	page, err = search.Items(context.Background(), db, money.Rules{}, search.Request{Text: "gadget"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"legacy gadget"}, names(page))
+}
+
+func TestSearchTransports(t *testing.T) {
+	db := openTestDB(t)
+	insertItems(t, db, "search me", "other")
+
+	// REST: /api/items/search must not be captured by /api/items/{id}
+	h := handlers.NewHandler(db, money.Rules{})
+	router := mux.NewRouter()
+	router.HandleFunc("/api/items/search", h.SearchItems).Methods("GET")
+	router.HandleFunc("/api/items/{id}", h.GetItem).Methods("GET")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/items/search?q=search&page_size=5", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var page search.Page
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+	require.Len(t, page.Results, 1)
+	assert.Equal(t, "<mark>search</mark> me", page.Results[0].Snippet)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/items/search?q=x&page_size=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// gRPC
+	srv := grpcserver.NewItemServer(db, money.Rules{})
+	resp, err := srv.SearchItems(context.Background(), &pb.SearchItemsRequest{Query: "other"})
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "other", resp.Results[0].Item.Name)
+	assert.Empty(t, resp.NextPageToken)
+}
@@ -0,0 +1,40 @@
+//go:build !sqlite_fts5
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSearchUnavailableWithoutFTS5(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "search.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	require.False(t, database.SearchAvailable(db))
+
+	// Writes are unaffected
+	_, err = db.Exec("INSERT INTO items (id, name, value_units) VALUES ('1', 'widget', 10000)")
+	require.NoError(t, err)
+
+	_, err = search.Items(context.Background(), db, money.Rules{}, search.Request{Text: "widget"})
+	var apiErr *apierror.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, codes.Unimplemented, apiErr.Code)
+	assert.Equal(t, search.ReasonSearchUnavailable, apiErr.Reason)
+	assert.Equal(t, http.StatusNotImplemented, apiErr.HTTPStatus())
+
+	_, err = database.Reindex(context.Background(), db)
+	assert.ErrorIs(t, err, database.ErrSearchUnavailable)
+}
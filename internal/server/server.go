@@ -0,0 +1,69 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// IsGRPCRequest reports whether r is a native gRPC call. gRPC-Web requests
+// share the application/grpc prefix but are served by the HTTP handler.
+func IsGRPCRequest(r *http.Request) bool {
+	if r.ProtoMajor != 2 {
+		return false
+	}
+	contentType := r.Header.Get("Content-Type")
+	return contentType == "application/grpc" || strings.HasPrefix(contentType, "application/grpc+")
+}
+
+// Handler multiplexes native gRPC calls to grpcServer and every other request
+// to httpHandler
+func Handler(grpcServer *grpc.Server, httpHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsGRPCRequest(r) {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	})
+}
+
+// NewSinglePort returns an HTTP server that serves both grpcServer and
+// httpHandler on one listener. Without TLS it accepts HTTP/2 cleartext (h2c)
+// next to HTTP/1.1; with TLS, HTTP/2 is negotiated through ALPN.
+func NewSinglePort(grpcServer *grpc.Server, httpHandler http.Handler, tlsConfig *tls.Config) *http.Server {
+	handler := Handler(grpcServer, httpHandler)
+	srv := &http.Server{}
+
+	if tlsConfig == nil {
+		srv.Handler = h2c.NewHandler(handler, &http2.Server{})
+		return srv
+	}
+
+	tlsConfig = tlsConfig.Clone()
+	if !slices.Contains(tlsConfig.NextProtos, "h2") {
+		tlsConfig.NextProtos = append([]string{"h2"}, tlsConfig.NextProtos...)
+	}
+	if !slices.Contains(tlsConfig.NextProtos, "http/1.1") {
+		tlsConfig.NextProtos = append(tlsConfig.NextProtos, "http/1.1")
+	}
+	srv.Handler = handler
+	srv.TLSConfig = tlsConfig
+	return srv
+}
+
+// Serve accepts connections on lis, wrapping it with TLS when the server has a
+// TLS configuration
+func Serve(srv *http.Server, lis net.Listener) error {
+	if srv.TLSConfig != nil {
+		// Certificates come from TLSConfig, so no files are passed here
+		return srv.ServeTLS(lis, "", "")
+	}
+	return srv.Serve(lis)
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/server"
+	pb "github.com/angel/go-api-sqlite/proto"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// setupTestDB creates an in-memory SQLite database for testing
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	err = database.Migrate(db)
+	require.NoError(t, err)
+	return db
+}
+
+// newServers builds a gRPC server and a REST handler that answers "rest"
+func newServers(t *testing.T) (*grpclib.Server, http.Handler) {
+	s := grpclib.NewServer()
+	pb.RegisterItemServiceServer(s, grpcserver.NewItemServer(setupTestDB(t), money.Rules{}))
+
+	rest := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "rest")
+	})
+	return s, rest
+}
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func TestSinglePortH2C(t *testing.T) {
+	grpcServer, rest := newServers(t)
+	srv := server.NewSinglePort(grpcServer, rest, nil)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(srv, lis)
+	defer srv.Close()
+
+	// Native gRPC over cleartext HTTP/2
+	conn, err := grpclib.NewClient(lis.Addr().String(), grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewItemServiceClient(conn)
+	created, err := client.CreateItem(context.Background(), &pb.CreateItemRequest{Name: "Test Item", Value: &decimal.Decimal{Value: "29.99"}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.Id)
+
+	// Plain HTTP/1.1 on the same port
+	resp, err := http.Get("http://" + lis.Addr().String() + "/api/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "rest", string(body))
+}
+
+func TestSinglePortTLS(t *testing.T) {
+	grpcServer, rest := newServers(t)
+
+	// httptest provides a self-signed certificate for 127.0.0.1
+	ts := httptest.NewUnstartedServer(nil)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	cert := ts.TLS.Certificates[0]
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	ts.Close()
+
+	srv := server.NewSinglePort(grpcServer, rest, &tls.Config{Certificates: []tls.Certificate{cert}})
+	assert.Contains(t, srv.TLSConfig.NextProtos, "h2")
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(srv, lis)
+	defer srv.Close()
+
+	// Native gRPC negotiated through ALPN
+	creds := credentials.NewTLS(&tls.Config{RootCAs: pool})
+	conn, err := grpclib.NewClient(lis.Addr().String(), grpclib.WithTransportCredentials(creds))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewItemServiceClient(conn)
+	_, err = client.ListItems(context.Background(), &pb.ListItemsRequest{})
+	require.NoError(t, err)
+
+	// HTTPS requests still reach the REST handler
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := httpClient.Get("https://" + lis.Addr().String() + "/api/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "rest", string(body))
+}
@@ -0,0 +1,266 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/filter"
+	"github.com/angel/go-api-sqlite/internal/money"
+)
+
+// Limits on a stats request
+const (
+	MaxPercentiles  = 10
+	MaxPrefixLength = 64
+)
+
+// DefaultPercentiles are reported when a request does not ask for any
+var DefaultPercentiles = []float64{50, 90, 95, 99}
+
+// bucketExprs compute the group key of a row. Times are bucketed in UTC and
+// weeks start on Monday.
+var bucketExprs = map[string]string{
+	"hour":        "strftime('%Y-%m-%dT%H:00:00Z', created_at)",
+	"day":         "strftime('%Y-%m-%d', created_at)",
+	"week":        "date(created_at, 'weekday 0', '-6 days')",
+	"month":       "strftime('%Y-%m', created_at)",
+	"name_prefix": "lower(substr(name, 1, ?))",
+	"category":    "category",
+	"currency":    "currency",
+}
+
+// Request selects the items to aggregate and how to group them
+type Request struct {
+	// Filter is an AIP-160 style filter, see package filter
+	Filter string
+	// GroupBy is empty, hour, day, week, month, name_prefix, category or
+	// currency
+	GroupBy string
+	// PrefixLength is the number of characters of the name used by
+	// name_prefix, 1 when zero
+	PrefixLength int
+	// Percentiles to report, between 0 and 100
+	Percentiles []float64
+}
+
+// Summary aggregates the values of a set of items. Sum, Min and Max are
+// exact; Mean and the percentiles, keyed by name, e.g. p50 or p99.9, and
+// interpolated between the nearest values, are approximate. Values in
+// different currencies cannot be added or compared, so a set of items in
+// more than one currency only has its count reported; filter by currency or
+// group by it to get the rest. No items sum to zero and have no other values.
+type Summary struct {
+	Count int64 `json:"count"`
+	// Currency of the items when they all have the same one
+	Currency        string             `json:"currency"`
+	MixedCurrencies bool               `json:"mixed_currencies,omitempty"`
+	Sum             *money.Decimal     `json:"sum,omitempty"`
+	Min             *money.Decimal     `json:"min,omitempty"`
+	Max             *money.Decimal     `json:"max,omitempty"`
+	Mean            *float64           `json:"mean,omitempty"`
+	Percentiles     map[string]float64 `json:"percentiles,omitempty"`
+}
+
+// Bucket is the summary of one group
+type Bucket struct {
+	Key     string  `json:"key"`
+	Summary Summary `json:"summary"`
+}
+
+// Result holds the summary over all matching items and, when grouped, one
+// bucket per group in key order
+type Result struct {
+	Summary Summary  `json:"summary"`
+	GroupBy string   `json:"group_by,omitempty"`
+	Buckets []Bucket `json:"buckets,omitempty"`
+}
+
+// PercentileKey names a percentile in a summary
+func PercentileKey(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// Items computes statistics over item values in a single query. Errors are
+// *apierror.Error values ready to be returned by either transport.
+func Items(ctx context.Context, db *sql.DB, req Request) (*Result, error) {
+	where, err := filter.ForItems(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []apierror.FieldViolation
+	bucket, grouped := bucketExprs[req.GroupBy]
+	if req.GroupBy != "" && !grouped {
+		violations = append(violations, apierror.FieldViolation{
+			Field:       "group_by",
+			Description: "group_by must be one of hour, day, week, month, name_prefix, category or currency",
+		})
+	}
+	prefixLength := req.PrefixLength
+	if prefixLength == 0 {
+		prefixLength = 1
+	}
+	if prefixLength < 0 || prefixLength > MaxPrefixLength {
+		violations = append(violations, apierror.FieldViolation{
+			Field:       "prefix_length",
+			Description: "prefix_length must be between 1 and " + strconv.Itoa(MaxPrefixLength),
+		})
+	}
+	percentiles := req.Percentiles
+	if len(percentiles) == 0 {
+		percentiles = DefaultPercentiles
+	}
+	if len(percentiles) > MaxPercentiles {
+		violations = append(violations, apierror.FieldViolation{
+			Field:       "percentiles",
+			Description: "at most " + strconv.Itoa(MaxPercentiles) + " percentiles may be requested",
+		})
+	}
+	for _, p := range percentiles {
+		if p < 0 || p > 100 {
+			violations = append(violations, apierror.FieldViolation{
+				Field:       "percentiles",
+				Description: "percentiles must be between 0 and 100",
+			})
+			break
+		}
+	}
+	if len(violations) > 0 {
+		return nil, apierror.InvalidArgument("invalid stats request", violations...)
+	}
+
+	query, args := buildQuery(where, req.GroupBy, bucket, prefixLength, percentiles)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apierror.Internal(err, "querying item stats")
+	}
+	defer rows.Close()
+
+	result := &Result{Summary: emptySummary(), GroupBy: req.GroupBy}
+	if grouped {
+		result.Buckets = []Bucket{}
+	}
+	for rows.Next() {
+		var (
+			isBucket    bool
+			key         sql.NullString
+			count       int64
+			currencies  int
+			currency    sql.NullString
+			sum, lo, hi sql.NullInt64
+			mu          sql.NullFloat64
+			p, value    float64
+		)
+		err := rows.Scan(&isBucket, &key, &count, &currencies, &currency, &sum, &lo, &hi, &mu, &p, &value)
+		if err != nil {
+			return nil, apierror.Internal(err, "scanning item stats")
+		}
+
+		// Rows arrive ordered by group, one per percentile
+		target := &result.Summary
+		if isBucket {
+			n := len(result.Buckets)
+			if n == 0 || result.Buckets[n-1].Key != key.String {
+				result.Buckets = append(result.Buckets, Bucket{Key: key.String, Summary: emptySummary()})
+				n++
+			}
+			target = &result.Buckets[n-1].Summary
+		}
+		target.Count = count
+		if currencies > 1 {
+			target.MixedCurrencies = true
+			target.Sum, target.Percentiles = nil, nil
+			continue
+		}
+		target.Currency = currency.String
+		total, least, most := money.FromUnits(sum.Int64).Trim(0), money.FromUnits(lo.Int64).Trim(0), money.FromUnits(hi.Int64).Trim(0)
+		mean := mu.Float64 / unitsPerOne
+		target.Sum, target.Min, target.Max, target.Mean = &total, &least, &most, &mean
+		target.Percentiles[PercentileKey(p)] = value / unitsPerOne
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "reading item stats")
+	}
+	return result, nil
+}
+
+// unitsPerOne is the number of stored units in a value of 1
+var unitsPerOne = math.Pow10(money.Scale)
+
+// buildQuery assembles the stats query from fixed fragments. The filter and
+// every request value are bound as arguments. Values are summed as integer
+// units, so totals are exact; SQLite fails the query should they overflow.
+// Each group also reports how many currencies its items have, so values in
+// different currencies are never reported as one total.
+//
+// Rows of the matching items appear once in the overall group and, when
+// grouped, once more in their bucket. Within each group, window functions
+// rank the values so each percentile interpolates linearly between the two
+// values around rank p/100 * (count - 1).
+func buildQuery(where *filter.Clause, groupBy, bucket string, prefixLength int, percentiles []float64) (string, []any) {
+	var args []any
+	var b strings.Builder
+
+	b.WriteString("WITH matched AS (SELECT ")
+	if groupBy != "" {
+		b.WriteString(bucket)
+		if groupBy == "name_prefix" {
+			args = append(args, prefixLength)
+		}
+	} else {
+		b.WriteString("NULL")
+	}
+	b.WriteString(" AS bucket, value_units AS value, currency FROM items WHERE " + where.SQL + "),\n")
+	args = append(args, where.Args...)
+
+	b.WriteString("grouped AS (SELECT 0 AS is_bucket, NULL AS bucket, value, currency FROM matched")
+	if groupBy != "" {
+		b.WriteString(" UNION ALL SELECT 1, bucket, value, currency FROM matched")
+	}
+	b.WriteString("),\n")
+
+	b.WriteString(`ranked AS (
+	SELECT is_bucket, bucket, value,
+		ROW_NUMBER() OVER (PARTITION BY is_bucket, bucket ORDER BY value) - 1 AS rn,
+		COUNT(*) OVER (PARTITION BY is_bucket, bucket) AS n
+	FROM grouped
+),
+wanted(p) AS (VALUES `)
+	for i, p := range percentiles {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(?)")
+		args = append(args, p)
+	}
+	b.WriteString(`),
+points AS (
+	SELECT r.is_bucket, r.bucket, w.p,
+		w.p / 100.0 * (MAX(r.n) - 1) AS pos,
+		MAX(CASE WHEN r.rn = CAST(w.p / 100.0 * (r.n - 1) AS INTEGER) THEN r.value END) AS below,
+		MAX(CASE WHEN r.rn = CAST(w.p / 100.0 * (r.n - 1) AS INTEGER) + 1 THEN r.value END) AS above
+	FROM ranked r CROSS JOIN wanted w
+	GROUP BY r.is_bucket, r.bucket, w.p
+),
+totals AS (
+	SELECT is_bucket, bucket, COUNT(*) AS n, COUNT(DISTINCT currency) AS currencies, MIN(currency) AS currency,
+		SUM(value) AS total, MIN(value) AS lo, MAX(value) AS hi, AVG(value) AS mean
+	FROM grouped
+	GROUP BY is_bucket, bucket
+)
+SELECT t.is_bucket, t.bucket, t.n, t.currencies, t.currency, t.total, t.lo, t.hi, t.mean, pt.p,
+	pt.below + (pt.pos - CAST(pt.pos AS INTEGER)) * (COALESCE(pt.above, pt.below) - pt.below)
+FROM totals t
+JOIN points pt ON pt.is_bucket = t.is_bucket AND pt.bucket IS t.bucket
+ORDER BY t.is_bucket, t.bucket, pt.p`)
+	return b.String(), args
+}
+
+// emptySummary is the summary of no items
+func emptySummary() Summary {
+	return Summary{Sum: &money.Decimal{}, Percentiles: make(map[string]float64)}
+}
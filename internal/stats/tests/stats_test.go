@@ -0,0 +1,270 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/stats"
+	pb "github.com/angel/go-api-sqlite/proto"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+// setupTestDB creates an in-memory items table
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+		CREATE TABLE items (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			value_units INTEGER NOT NULL,
+			currency TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	require.NoError(t, err)
+	return db
+}
+
+func insert(t *testing.T, db *sql.DB, name string, value float64, createdAt time.Time) {
+	t.Helper()
+	_, err := db.Exec("INSERT INTO items (id, name, value_units, created_at) VALUES (?, ?, ?, ?)",
+		fmt.Sprintf("%s-%v-%d", name, value, createdAt.UnixNano()), name, int64(value*10000), createdAt)
+	require.NoError(t, err)
+}
+
+func TestSummaryAndPercentiles(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+	for v := 10; v >= 1; v-- {
+		insert(t, db, "item", float64(v), now)
+	}
+
+	result, err := stats.Items(context.Background(), db, stats.Request{Percentiles: []float64{0, 50, 90, 99.9, 100}})
+	require.NoError(t, err)
+
+	s := result.Summary
+	assert.Equal(t, int64(10), s.Count)
+	assert.Equal(t, "55", s.Sum.String())
+	assert.Equal(t, "1", s.Min.String())
+	assert.Equal(t, "10", s.Max.String())
+	assert.InDelta(t, 5.5, *s.Mean, 1e-9)
+	assert.InDelta(t, 1, s.Percentiles["p0"], 1e-9)
+	assert.InDelta(t, 5.5, s.Percentiles["p50"], 1e-9)
+	assert.InDelta(t, 9.1, s.Percentiles["p90"], 1e-9)
+	assert.InDelta(t, 9.991, s.Percentiles["p99.9"], 1e-9)
+	assert.InDelta(t, 10, s.Percentiles["p100"], 1e-9)
+	assert.Nil(t, result.Buckets)
+}
+
+func TestDefaultPercentilesAndEmpty(t *testing.T) {
+	db := setupTestDB(t)
+
+	result, err := stats.Items(context.Background(), db, stats.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Summary.Count)
+	assert.Equal(t, "0", result.Summary.Sum.String())
+	assert.Nil(t, result.Summary.Min)
+	assert.Empty(t, result.Summary.Percentiles)
+
+	insert(t, db, "only", 7, time.Now())
+	result, err = stats.Items(context.Background(), db, stats.Request{})
+	require.NoError(t, err)
+	require.Len(t, result.Summary.Percentiles, len(stats.DefaultPercentiles))
+	for _, p := range stats.DefaultPercentiles {
+		assert.InDelta(t, 7, result.Summary.Percentiles[stats.PercentileKey(p)], 1e-9)
+	}
+}
+
+func TestGroupByTime(t *testing.T) {
+	db := setupTestDB(t)
+	est := time.FixedZone("EST", -5*60*60)
+	insert(t, db, "a", 1, time.Date(2026, 3, 29, 23, 30, 0, 0, time.UTC)) // Sunday
+	insert(t, db, "b", 2, time.Date(2026, 3, 30, 0, 15, 0, 0, time.UTC))  // Monday
+	insert(t, db, "c", 3, time.Date(2026, 3, 29, 20, 45, 0, 0, est))      // Monday 01:45 UTC
+	insert(t, db, "d", 4, time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		groupBy string
+		keys    []string
+		counts  []int64
+	}{
+		{"hour", []string{"2026-03-29T23:00:00Z", "2026-03-30T00:00:00Z", "2026-03-30T01:00:00Z", "2026-04-01T12:00:00Z"}, []int64{1, 1, 1, 1}},
+		{"day", []string{"2026-03-29", "2026-03-30", "2026-04-01"}, []int64{1, 2, 1}},
+		{"week", []string{"2026-03-23", "2026-03-30"}, []int64{1, 3}},
+		{"month", []string{"2026-03", "2026-04"}, []int64{3, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			result, err := stats.Items(context.Background(), db, stats.Request{GroupBy: tt.groupBy})
+			require.NoError(t, err)
+			assert.Equal(t, tt.groupBy, result.GroupBy)
+			assert.Equal(t, int64(4), result.Summary.Count)
+
+			var keys []string
+			var counts []int64
+			for _, b := range result.Buckets {
+				keys = append(keys, b.Key)
+				counts = append(counts, b.Summary.Count)
+			}
+			assert.Equal(t, tt.keys, keys)
+			assert.Equal(t, tt.counts, counts)
+		})
+	}
+}
+
+func TestGroupByNamePrefixWithFilter(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+	insert(t, db, "Widget small", 10, now)
+	insert(t, db, "widget large", 30, now)
+	insert(t, db, "Gadget", 5, now)
+	insert(t, db, "Gizmo", 100, now)
+
+	result, err := stats.Items(context.Background(), db, stats.Request{
+		Filter:       "value < 50",
+		GroupBy:      "name_prefix",
+		PrefixLength: 3,
+		Percentiles:  []float64{50},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Summary.Count)
+	require.Len(t, result.Buckets, 2)
+
+	assert.Equal(t, "gad", result.Buckets[0].Key)
+	assert.Equal(t, int64(1), result.Buckets[0].Summary.Count)
+	assert.Equal(t, "wid", result.Buckets[1].Key)
+	assert.Equal(t, int64(2), result.Buckets[1].Summary.Count)
+	assert.Equal(t, "40", result.Buckets[1].Summary.Sum.String())
+	assert.InDelta(t, 20, result.Buckets[1].Summary.Percentiles["p50"], 1e-9)
+}
+
+func TestMixedCurrencies(t *testing.T) {
+	db := setupTestDB(t)
+	for i, item := range []struct {
+		currency string
+		value    int64
+	}{{"USD", 10}, {"USD", 30}, {"JPY", 1000}} {
+		_, err := db.Exec("INSERT INTO items (id, name, value_units, currency) VALUES (?, 'item', ?, ?)",
+			fmt.Sprint(i), item.value*10000, item.currency)
+		require.NoError(t, err)
+	}
+
+	// Dollars and yen are not added together
+	result, err := stats.Items(context.Background(), db, stats.Request{GroupBy: "currency"})
+	require.NoError(t, err)
+	s := result.Summary
+	assert.Equal(t, int64(3), s.Count)
+	assert.True(t, s.MixedCurrencies)
+	assert.Empty(t, s.Currency)
+	assert.Nil(t, s.Sum)
+	assert.Nil(t, s.Mean)
+	assert.Nil(t, s.Percentiles)
+	require.Len(t, result.Buckets, 2)
+	assert.Equal(t, "JPY", result.Buckets[0].Summary.Currency)
+	assert.Equal(t, "1000", result.Buckets[0].Summary.Sum.String())
+	assert.Equal(t, "USD", result.Buckets[1].Summary.Currency)
+	assert.False(t, result.Buckets[1].Summary.MixedCurrencies)
+	assert.Equal(t, "40", result.Buckets[1].Summary.Sum.String())
+	assert.InDelta(t, 20, result.Buckets[1].Summary.Percentiles["p50"], 1e-9)
+
+	result, err = stats.Items(context.Background(), db, stats.Request{Filter: `currency = "USD"`})
+	require.NoError(t, err)
+	assert.Equal(t, "USD", result.Summary.Currency)
+	assert.Equal(t, "10", result.Summary.Min.String())
+
+	// Over both transports
+	rr := httptest.NewRecorder()
+	handlers.NewHandler(db, money.Rules{}).GetItemStats(rr, httptest.NewRequest("GET", "/api/items/stats", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"summary":{"count":3,"currency":"","mixed_currencies":true}}`, rr.Body.String())
+	resp, err := grpcserver.NewItemServer(db, money.Rules{}).GetItemStats(context.Background(), &pb.GetItemStatsRequest{})
+	require.NoError(t, err)
+	assert.True(t, resp.Summary.MixedCurrencies)
+	assert.Empty(t, resp.Summary.Sum)
+}
+
+func TestInvalidRequests(t *testing.T) {
+	db := setupTestDB(t)
+
+	tests := []struct {
+		name  string
+		req   stats.Request
+		field string
+	}{
+		{"group_by", stats.Request{GroupBy: "year"}, "group_by"},
+		{"prefix_length", stats.Request{GroupBy: "name_prefix", PrefixLength: stats.MaxPrefixLength + 1}, "prefix_length"},
+		{"percentile range", stats.Request{Percentiles: []float64{101}}, "percentiles"},
+		{"too many percentiles", stats.Request{Percentiles: make([]float64, stats.MaxPercentiles+1)}, "percentiles"},
+		{"filter", stats.Request{Filter: "value >"}, "filter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stats.Items(context.Background(), db, tt.req)
+			var apiErr *apierror.Error
+			require.True(t, errors.As(err, &apiErr), "got %v", err)
+			assert.Equal(t, codes.InvalidArgument, apiErr.Code)
+			require.Len(t, apiErr.Violations, 1)
+			assert.Equal(t, tt.field, apiErr.Violations[0].Field)
+		})
+	}
+}
+
+func TestStatsTransports(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+	insert(t, db, "a", 2, now)
+	insert(t, db, "b", 4, now)
+
+	// REST
+	h := handlers.NewHandler(db, money.Rules{})
+	rr := httptest.NewRecorder()
+	h.GetItemStats(rr, httptest.NewRequest("GET", "/api/items/stats?group_by=day&percentiles=25,75", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var result stats.Result
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&result))
+	assert.Equal(t, int64(2), result.Summary.Count)
+	assert.InDelta(t, 2.5, result.Summary.Percentiles["p25"], 1e-9)
+	assert.InDelta(t, 3.5, result.Summary.Percentiles["p75"], 1e-9)
+	require.Len(t, result.Buckets, 1)
+	assert.Equal(t, now.UTC().Format("2006-01-02"), result.Buckets[0].Key)
+
+	rr = httptest.NewRecorder()
+	h.GetItemStats(rr, httptest.NewRequest("GET", "/api/items/stats?percentiles=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// gRPC
+	srv := grpcserver.NewItemServer(db, money.Rules{})
+	resp, err := srv.GetItemStats(context.Background(), &pb.GetItemStatsRequest{Filter: "value > 3", Percentiles: []float64{50}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), resp.Summary.Count)
+	assert.InDelta(t, 4, resp.Summary.Percentiles["p50"], 1e-9)
+	assert.Empty(t, resp.Buckets)
+}
@@ -0,0 +1,203 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ClientAuth controls whether clients must present a certificate
+type ClientAuth int
+
+const (
+	// RequireClientCert rejects clients without a valid certificate
+	RequireClientCert ClientAuth = iota
+	// VerifyClientCertIfGiven accepts anonymous clients but verifies any
+	// certificate that is presented
+	VerifyClientCertIfGiven
+)
+
+// Config describes the certificate files used by a TLS listener
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS when set
+	ClientCAFile string
+	ClientAuth   ClientAuth
+	// ReloadInterval is how often the files are checked for changes
+	ReloadInterval time.Duration
+}
+
+// Reloader keeps a certificate and client CA pool in memory and reloads them
+// when the underlying files change on disk, without restarting listeners
+type Reloader struct {
+	cfg Config
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+// NewReloader loads the configured files. It fails if they cannot be read, so
+// a server never starts with a broken configuration.
+func NewReloader(cfg Config) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("both a certificate and a key file are required")
+	}
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = 30 * time.Second
+	}
+	r := &Reloader{cfg: cfg}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Start polls the files for changes until ctx is cancelled. Reload failures
+// are logged and the previous certificate stays in use.
+func (r *Reloader) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				log.Printf("TLS reload failed, keeping previous certificate: %v", err)
+				continue
+			}
+			log.Printf("TLS certificate reloaded from %s", r.cfg.CertFile)
+		}
+	}
+}
+
+// Certificate returns the current server certificate
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// ServerConfig returns a TLS configuration that always presents the latest
+// certificate and verifies client certificates against the latest CA bundle
+func (r *Reloader) ServerConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		},
+	}
+	if r.cfg.ClientCAFile == "" {
+		return cfg
+	}
+
+	// Client certificates are verified in VerifyConnection rather than through
+	// ClientCAs, so a reloaded CA bundle applies to new handshakes immediately
+	cfg.ClientAuth = tls.RequireAnyClientCert
+	if r.cfg.ClientAuth == VerifyClientCertIfGiven {
+		cfg.ClientAuth = tls.RequestClientCert
+	}
+	cfg.VerifyConnection = r.verifyClient
+	return cfg
+}
+
+// verifyClient checks the peer certificate chain against the client CA pool
+func (r *Reloader) verifyClient(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		if r.cfg.ClientAuth == VerifyClientCertIfGiven {
+			return nil
+		}
+		return errors.New("client certificate required")
+	}
+
+	r.mu.RLock()
+	pool := r.clientCA
+	r.mu.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, c := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("verifying client certificate: %w", err)
+	}
+	return nil
+}
+
+// files returns the paths watched for changes
+func (r *Reloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+// changed reports whether any watched file has a new modification time
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload reads all files and swaps them in atomically
+func (r *Reloader) reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes[f] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading key pair: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("reading client CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCA = pool
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
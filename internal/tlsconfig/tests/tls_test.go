@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/auth"
+	"github.com/angel/go-api-sqlite/internal/database"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/tlsconfig"
+	pb "github.com/angel/go-api-sqlite/proto"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+var reportingSubject = pkix.Name{CommonName: "reporting", Organization: []string{"Example"}}
+
+// setupTLS writes a server certificate and client CA bundle to a temp dir
+func setupTLS(t *testing.T) (*testCA, tlsconfig.Config) {
+	ca := newCA(t, "Test CA")
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+
+	return ca, tlsconfig.Config{
+		CertFile:       writeFile(t, dir, "server.crt", certPEM),
+		KeyFile:        writeFile(t, dir, "server.key", keyPEM),
+		ClientCAFile:   writeFile(t, dir, "client-ca.crt", ca.pem),
+		ReloadInterval: 10 * time.Millisecond,
+	}
+}
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func TestReloadOnChange(t *testing.T) {
+	ca, cfg := setupTLS(t)
+	reloader, err := tlsconfig.NewReloader(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Start(ctx)
+
+	original := reloader.Certificate()
+
+	// Rotate the certificate on disk, making sure the modification time moves
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "rotated"}, x509.ExtKeyUsageServerAuth)
+	future := time.Now().Add(time.Second)
+	require.NoError(t, os.WriteFile(cfg.KeyFile, keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(cfg.CertFile, certPEM, 0o600))
+	require.NoError(t, os.Chtimes(cfg.CertFile, future, future))
+	require.NoError(t, os.Chtimes(cfg.KeyFile, future, future))
+
+	assert.Eventually(t, func() bool {
+		return reloader.Certificate() != original
+	}, 2*time.Second, 10*time.Millisecond)
+
+	leaf, err := x509.ParseCertificate(reloader.Certificate().Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", leaf.Subject.CommonName)
+}
+
+func TestReloadKeepsPreviousCertificateOnError(t *testing.T) {
+	_, cfg := setupTLS(t)
+	reloader, err := tlsconfig.NewReloader(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Start(ctx)
+
+	original := reloader.Certificate()
+	require.NoError(t, os.WriteFile(cfg.CertFile, []byte("not a certificate"), 0o600))
+	future := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(cfg.CertFile, future, future))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Same(t, original, reloader.Certificate())
+}
+
+func TestMutualTLSHTTP(t *testing.T) {
+	ca, cfg := setupTLS(t)
+	reloader, err := tlsconfig.NewReloader(cfg)
+	require.NoError(t, err)
+
+	mapper := auth.NewPrincipalMapper(map[string]string{
+		"CN=reporting,O=Example": "reporting-service",
+	})
+	srv := &http.Server{
+		Handler: mapper.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, _ := auth.PrincipalFromContext(r.Context())
+			io.WriteString(w, p.Name)
+		})),
+		TLSConfig: reloader.ServerConfig(),
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.ServeTLS(lis, "", "")
+	defer srv.Close()
+	url := "https://" + lis.Addr().String()
+
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      ca.pool(),
+			Certificates: certs,
+		}}}
+	}
+
+	t.Run("Mapped client certificate", func(t *testing.T) {
+		resp, err := newClient(ca.clientCert(t, reportingSubject)).Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "reporting-service", string(body))
+	})
+
+	t.Run("Unmapped client certificate", func(t *testing.T) {
+		resp, err := newClient(ca.clientCert(t, pkix.Name{CommonName: "stranger"})).Get(url)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Missing client certificate", func(t *testing.T) {
+		_, err := newClient().Get(url)
+		assert.Error(t, err)
+	})
+
+	t.Run("Certificate from another CA", func(t *testing.T) {
+		other := newCA(t, "Other CA")
+		_, err := newClient(other.clientCert(t, reportingSubject)).Get(url)
+		assert.Error(t, err)
+	})
+}
+
+func TestMutualTLSGRPC(t *testing.T) {
+	ca, cfg := setupTLS(t)
+	reloader, err := tlsconfig.NewReloader(cfg)
+	require.NoError(t, err)
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	require.NoError(t, database.Migrate(db))
+
+	// Record the principal seen by the RPC handler
+	var principal auth.Principal
+	mapper := auth.NewPrincipalMapper(nil)
+	s := grpclib.NewServer(
+		grpclib.Creds(credentials.NewTLS(reloader.ServerConfig())),
+		grpclib.ChainUnaryInterceptor(mapper.UnaryInterceptor,
+			func(ctx context.Context, req any, _ *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+				principal, _ = auth.PrincipalFromContext(ctx)
+				return handler(ctx, req)
+			}),
+	)
+	pb.RegisterItemServiceServer(s, grpcserver.NewItemServer(db, money.Rules{}))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	dial := func(certs ...tls.Certificate) pb.ItemServiceClient {
+		creds := credentials.NewTLS(&tls.Config{RootCAs: ca.pool(), Certificates: certs})
+		conn, err := grpclib.NewClient(lis.Addr().String(), grpclib.WithTransportCredentials(creds))
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return pb.NewItemServiceClient(conn)
+	}
+
+	t.Run("With client certificate", func(t *testing.T) {
+		_, err := dial(ca.clientCert(t, reportingSubject)).ListItems(context.Background(), &pb.ListItemsRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, "reporting", principal.Name)
+		assert.Equal(t, "CN=reporting,O=Example", principal.Subject)
+	})
+
+	t.Run("Without client certificate", func(t *testing.T) {
+		_, err := dial().ListItems(context.Background(), &pb.ListItemsRequest{})
+		require.Error(t, err)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
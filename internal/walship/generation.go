@@ -0,0 +1,157 @@
+package walship
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timeFormat names generations and segments so they sort chronologically
+const timeFormat = "20060102T150405.000000000Z"
+
+// Generation is a snapshot of the database followed by the WAL segments
+// shipped after it. A new generation starts whenever continuity with the
+// previous WAL cannot be proven, and periodically so old ones can be pruned.
+type Generation struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	PageSize  int       `json:"page_size"`
+}
+
+// segment is a compressed run of committed WAL frames
+type segment struct {
+	path string
+	// syncedAt is when the segment was shipped; every transaction in it
+	// committed at or before this time
+	syncedAt time.Time
+}
+
+func generationsDir(dir string) string {
+	return filepath.Join(dir, "generations")
+}
+
+func (g *Generation) path(dir string) string {
+	return filepath.Join(generationsDir(dir), g.ID)
+}
+
+func (g *Generation) snapshotPath(dir string) string {
+	return filepath.Join(g.path(dir), "snapshot.db.gz")
+}
+
+func (g *Generation) walDir(dir string) string {
+	return filepath.Join(g.path(dir), "wal")
+}
+
+// segmentName names the seq-th segment of a generation
+func segmentName(seq int, syncedAt time.Time) string {
+	return fmt.Sprintf("%08d-%s.wal.gz", seq, syncedAt.UTC().Format(timeFormat))
+}
+
+// Generations lists the generations in a replica directory, oldest first
+func Generations(dir string) ([]*Generation, error) {
+	entries, err := os.ReadDir(generationsDir(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var gens []*Generation
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(generationsDir(dir), e.Name(), "generation.json"))
+		if errors.Is(err, os.ErrNotExist) {
+			// The snapshot is still being written or was abandoned
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var g Generation
+		if err := json.Unmarshal(data, &g); err != nil {
+			return nil, fmt.Errorf("parsing generation %s: %w", e.Name(), err)
+		}
+		gens = append(gens, &g)
+	}
+	sort.Slice(gens, func(i, j int) bool { return gens[i].CreatedAt.Before(gens[j].CreatedAt) })
+	return gens, nil
+}
+
+// segments lists the segments of g in order
+func (g *Generation) segments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(g.walDir(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var segs []segment
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".wal.gz")
+		if !ok {
+			continue
+		}
+		_, ts, ok := strings.Cut(name, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid segment name %s", e.Name())
+		}
+		syncedAt, err := time.Parse(timeFormat, ts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid segment name %s: %w", e.Name(), err)
+		}
+		segs = append(segs, segment{path: filepath.Join(g.walDir(dir), e.Name()), syncedAt: syncedAt})
+	}
+	// Names start with a zero-padded sequence number
+	sort.Slice(segs, func(i, j int) bool { return segs[i].path < segs[j].path })
+	return segs, nil
+}
+
+// writeGzip atomically writes the gzip-compressed contents of r to path
+func writeGzip(path string, r io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(zw, r); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readGzip returns the decompressed contents of the file at path
+func readGzip(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
@@ -0,0 +1,119 @@
+package walship
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RestoreResult describes a completed point-in-time restore
+type RestoreResult struct {
+	Generation string
+	Segments   int
+	// RestoredTo is when the last applied segment was shipped
+	RestoredTo time.Time
+}
+
+// Restore rebuilds the database at dbPath as of time at from the replica in
+// dir. A zero at restores the latest state. The server must be stopped.
+// Precision is bounded by the sync interval: transactions are restored if
+// the segment containing them was shipped by at.
+func Restore(dir, dbPath string, at time.Time) (*RestoreResult, error) {
+	gens, err := Generations(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(gens) == 0 {
+		return nil, fmt.Errorf("no replication generations in %s", dir)
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	// Use the latest generation that started by the target time
+	var gen *Generation
+	for _, g := range gens {
+		if !g.CreatedAt.After(at) {
+			gen = g
+		}
+	}
+	if gen == nil {
+		return nil, fmt.Errorf("%s is before the oldest generation %s", at.Format(time.RFC3339), gens[0].CreatedAt.Format(time.RFC3339))
+	}
+
+	snapshot, err := readGzip(gen.snapshotPath(dir))
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot of generation %s: %w", gen.ID, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), ".restore-*.db")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := tmp.Write(snapshot); err != nil {
+		return nil, err
+	}
+
+	res := &RestoreResult{Generation: gen.ID, RestoredTo: gen.CreatedAt}
+	segs, err := gen.segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, seg := range segs {
+		if seg.syncedAt.After(at) {
+			break
+		}
+		frames, err := readGzip(seg.path)
+		if err != nil {
+			return nil, fmt.Errorf("reading segment %s: %w", filepath.Base(seg.path), err)
+		}
+		if err := applyFrames(tmp, frames, gen.PageSize); err != nil {
+			return nil, fmt.Errorf("applying segment %s: %w", filepath.Base(seg.path), err)
+		}
+		res.Segments++
+		res.RestoredTo = seg.syncedAt
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := checkIntegrity(tmp.Name()); err != nil {
+		return nil, err
+	}
+
+	// Stale WAL files would be replayed on top of the restored database
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if err := os.Rename(tmp.Name(), dbPath); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// checkIntegrity runs PRAGMA integrity_check on the database at path
+func checkIntegrity(path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package walship
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// errDiscontinuity means frames may have been checkpointed and overwritten
+// before they were shipped, so a new generation is needed
+var errDiscontinuity = errors.New("WAL was restarted by another checkpointer")
+
+// checkpointAttempts bounds how often a checkpoint is retried while readers
+// hold old frames
+const checkpointAttempts = 10
+
+// Config configures a Shipper
+type Config struct {
+	// DBPath is the database file; its WAL is DBPath + "-wal"
+	DBPath string
+	// Dir is the replica directory
+	Dir string
+	// SyncInterval is how often new WAL frames are shipped
+	SyncInterval time.Duration
+	// SnapshotInterval is how often a new generation is started
+	SnapshotInterval time.Duration
+	// Retention is how far back restores must remain possible
+	Retention time.Duration
+	// CheckpointSize is the WAL size in bytes after which the shipper
+	// checkpoints it
+	CheckpointSize int64
+}
+
+// Stats describes the replication state, published as the "replication"
+// expvar
+type Stats struct {
+	Generation string    `json:"generation"`
+	Segments   int       `json:"segments"`
+	LastSync   time.Time `json:"last_sync"`
+	// LagSeconds is the time since the last successful sync
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+// Shipper continuously copies committed WAL frames of a database into a
+// replica directory. The database must be opened with
+// database.WithManualCheckpoints: the shipper owns checkpoints so that no
+// frame is copied into the database file and overwritten before it has been
+// shipped.
+type Shipper struct {
+	db  *sql.DB
+	cfg Config
+
+	mu sync.Mutex
+	// conn is held for the lifetime of the shipper so that closing idle
+	// pool connections never checkpoints and deletes the WAL
+	conn          *sql.Conn
+	gen           *Generation
+	pos           position
+	known         bool
+	expectRestart bool
+	seq           int
+	lastSync      time.Time
+}
+
+// New creates a shipper for db. Unset intervals get defaults.
+func New(db *sql.DB, cfg Config) *Shipper {
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = time.Second
+	}
+	if cfg.SnapshotInterval <= 0 {
+		cfg.SnapshotInterval = 24 * time.Hour
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+	if cfg.CheckpointSize <= 0 {
+		cfg.CheckpointSize = 4 << 20
+	}
+	return &Shipper{db: db, cfg: cfg}
+}
+
+// Run syncs at SyncInterval until ctx is cancelled. Failures are logged and
+// retried on the next tick.
+func (s *Shipper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("WAL shipping failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close releases the connection held by the shipper
+func (s *Shipper) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Stats returns the current replication state
+func (s *Shipper) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := Stats{Segments: s.seq, LastSync: s.lastSync}
+	if s.gen != nil {
+		st.Generation = s.gen.ID
+	}
+	if !s.lastSync.IsZero() {
+		st.LagSeconds = time.Since(s.lastSync).Seconds()
+	}
+	return st
+}
+
+// Sync ships the transactions committed since the last sync, checkpoints
+// the WAL once it exceeds CheckpointSize and prunes expired generations
+func (s *Shipper) Sync(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := s.db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	if s.gen == nil || time.Since(s.gen.CreatedAt) >= s.cfg.SnapshotInterval {
+		if err := s.newGeneration(ctx); err != nil {
+			return err
+		}
+	} else if err := s.ship(); errors.Is(err, errDiscontinuity) {
+		log.Printf("Starting a new replication generation: %v", err)
+		if err := s.newGeneration(ctx); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if s.pos.offset >= s.cfg.CheckpointSize {
+		if err := s.checkpoint(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.lastSync = time.Now()
+	return s.prune()
+}
+
+// ship copies new committed frames into the next segment
+func (s *Shipper) ship() error {
+	f, err := os.Open(s.cfg.DBPath + "-wal")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hdr, err := readWALHeader(f)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !s.known:
+		s.pos, s.known = hdr.start(), true
+	case hdr.salt1 == s.pos.salt1 && hdr.salt2 == s.pos.salt2:
+	case s.expectRestart && hdr.salt1 == s.pos.salt1+1:
+		// The first write after our checkpoint restarted the WAL
+		s.pos, s.expectRestart = hdr.start(), false
+	default:
+		return errDiscontinuity
+	}
+
+	frames, next, err := readTransactions(f, hdr, s.pos)
+	if err != nil || len(frames) == 0 {
+		return err
+	}
+
+	if err := os.MkdirAll(s.gen.walDir(s.cfg.Dir), 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(s.gen.walDir(s.cfg.Dir), segmentName(s.seq+1, time.Now()))
+	if err := writeGzip(path, bytes.NewReader(frames)); err != nil {
+		return err
+	}
+	s.seq++
+	s.pos = next
+	// Frames were appended, so the WAL will not restart until the next
+	// checkpoint
+	s.expectRestart = false
+	return nil
+}
+
+// lockWriters blocks other writers until the returned function is called
+func (s *Shipper) lockWriters(ctx context.Context) (func(), error) {
+	if _, err := s.conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return nil, err
+	}
+	return func() {
+		if _, err := s.conn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+			log.Printf("Error releasing replication write lock: %v", err)
+		}
+	}, nil
+}
+
+// checkpointAll copies every WAL frame into the database file. Writers must
+// be locked out, so the WAL restarts on the next write.
+func (s *Shipper) checkpointAll(ctx context.Context) error {
+	for attempt := 0; attempt < checkpointAttempts; attempt++ {
+		var busy, logFrames, checkpointed int
+		err := s.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &logFrames, &checkpointed)
+		if err != nil {
+			return err
+		}
+		if logFrames < 0 {
+			return errors.New("database is not in WAL mode")
+		}
+		if logFrames == checkpointed {
+			return nil
+		}
+		// A reader still uses old frames
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return errors.New("checkpoint blocked by readers")
+}
+
+// checkpoint ships the remaining frames and checkpoints the WAL while
+// writers are locked out, so no unshipped frame is ever overwritten
+func (s *Shipper) checkpoint(ctx context.Context) error {
+	unlock, err := s.lockWriters(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.ship(); err != nil {
+		return err
+	}
+	if err := s.checkpointAll(ctx); err != nil {
+		return err
+	}
+	s.expectRestart = true
+	return nil
+}
+
+// newGeneration checkpoints the WAL into the database file and copies the
+// file as the snapshot of a new generation
+func (s *Shipper) newGeneration(ctx context.Context) error {
+	unlock, err := s.lockWriters(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.checkpointAll(ctx); err != nil {
+		return err
+	}
+	var pageSize int
+	if err := s.conn.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	gen := &Generation{ID: now.Format(timeFormat), CreatedAt: now, PageSize: pageSize}
+	if err := os.MkdirAll(gen.walDir(s.cfg.Dir), 0o755); err != nil {
+		return err
+	}
+
+	// Writers are locked out and every frame is in the database file, so
+	// the file is a consistent snapshot
+	db, err := os.Open(s.cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := writeGzip(gen.snapshotPath(s.cfg.Dir), db); err != nil {
+		return err
+	}
+
+	// Continue from the end of the checkpointed WAL
+	s.known, s.expectRestart = false, false
+	if wal, err := os.Open(s.cfg.DBPath + "-wal"); err == nil {
+		defer wal.Close()
+		if hdr, err := readWALHeader(wal); err == nil {
+			_, s.pos, err = readTransactions(wal, hdr, hdr.start())
+			if err != nil {
+				return err
+			}
+			s.known, s.expectRestart = true, true
+		}
+	}
+
+	data, err := json.MarshalIndent(gen, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(gen.path(s.cfg.Dir), "generation.json"), data, 0o644); err != nil {
+		return err
+	}
+	s.gen, s.seq = gen, 0
+	log.Printf("Started replication generation %s", gen.ID)
+	return nil
+}
+
+// prune removes generations that are no longer needed to restore to any
+// time within the retention window
+func (s *Shipper) prune() error {
+	gens, err := Generations(s.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-s.cfg.Retention)
+	for i := 0; i+1 < len(gens); i++ {
+		// Restores to times after the next generation started use it instead
+		if gens[i+1].CreatedAt.After(cutoff) || gens[i].ID == s.gen.ID {
+			break
+		}
+		if err := os.RemoveAll(gens[i].path(s.cfg.Dir)); err != nil {
+			return fmt.Errorf("pruning generation %s: %w", gens[i].ID, err)
+		}
+		log.Printf("Pruned replication generation %s", gens[i].ID)
+	}
+	return nil
+}
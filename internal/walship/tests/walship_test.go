@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/angel/go-api-sqlite/internal/walship"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setup opens a database with manual checkpoints and a shipper for it
+func setup(t *testing.T, cfg walship.Config) (*sql.DB, *walship.Shipper, walship.Config) {
+	dir := t.TempDir()
+	cfg.DBPath = filepath.Join(dir, "data.db")
+	cfg.Dir = filepath.Join(dir, "replica")
+
+	db, err := database.Open(cfg.DBPath, database.WithManualCheckpoints())
+	require.NoError(t, err)
+	s := walship.New(db, cfg)
+	t.Cleanup(func() {
+		s.Close()
+		db.Close()
+	})
+	return db, s, cfg
+}
+
+// insert adds n items with a large name so the WAL grows quickly
+func insert(t *testing.T, db *sql.DB, prefix string, n int) {
+	for i := 0; i < n; i++ {
+		_, err := db.Exec("INSERT INTO items (id, name, value_units) VALUES (?, ?, ?)",
+			fmt.Sprintf("%s-%d", prefix, i), strings.Repeat("x", 512), i)
+		require.NoError(t, err)
+	}
+}
+
+// restoredCount restores the replica as of at and counts the items
+func restoredCount(t *testing.T, cfg walship.Config, at time.Time) int {
+	target := filepath.Join(t.TempDir(), "restored.db")
+	_, err := walship.Restore(cfg.Dir, target, at)
+	require.NoError(t, err)
+
+	db, err := sql.Open("sqlite3", target)
+	require.NoError(t, err)
+	defer db.Close()
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM items").Scan(&count))
+	return count
+}
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+func TestShipAndRestoreLatest(t *testing.T) {
+	// A small checkpoint size forces several WAL restarts
+	db, s, cfg := setup(t, walship.Config{CheckpointSize: 64 << 10})
+	ctx := context.Background()
+
+	for batch := 0; batch < 10; batch++ {
+		insert(t, db, fmt.Sprint(batch), 50)
+		require.NoError(t, s.Sync(ctx))
+	}
+
+	gens, err := walship.Generations(cfg.Dir)
+	require.NoError(t, err)
+	assert.Len(t, gens, 1, "checkpoints must not break continuity")
+
+	assert.Equal(t, 500, restoredCount(t, cfg, time.Time{}))
+}
+
+func TestPointInTimeRestore(t *testing.T) {
+	db, s, cfg := setup(t, walship.Config{})
+	ctx := context.Background()
+
+	require.NoError(t, s.Sync(ctx))
+	insert(t, db, "a", 10)
+	require.NoError(t, s.Sync(ctx))
+	afterA := time.Now()
+
+	time.Sleep(10 * time.Millisecond)
+	insert(t, db, "b", 5)
+	require.NoError(t, s.Sync(ctx))
+
+	assert.Equal(t, 10, restoredCount(t, cfg, afterA))
+	assert.Equal(t, 15, restoredCount(t, cfg, time.Time{}))
+
+	_, err := walship.Restore(cfg.Dir, filepath.Join(t.TempDir(), "r.db"), afterA.Add(-time.Hour))
+	assert.ErrorContains(t, err, "before the oldest generation")
+}
+
+func TestExternalCheckpointStartsNewGeneration(t *testing.T) {
+	db, s, cfg := setup(t, walship.Config{})
+	ctx := context.Background()
+
+	insert(t, db, "a", 10)
+	require.NoError(t, s.Sync(ctx))
+
+	// Frames checkpointed behind the shipper's back cannot be shipped
+	insert(t, db, "b", 10)
+	_, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	require.NoError(t, err)
+	insert(t, db, "c", 10)
+	require.NoError(t, s.Sync(ctx))
+
+	gens, err := walship.Generations(cfg.Dir)
+	require.NoError(t, err)
+	assert.Len(t, gens, 2)
+	assert.Equal(t, 30, restoredCount(t, cfg, time.Time{}))
+}
+
+func TestStatsAndPrune(t *testing.T) {
+	db, s, cfg := setup(t, walship.Config{SnapshotInterval: time.Nanosecond, Retention: time.Nanosecond})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		insert(t, db, fmt.Sprint(i), 1)
+		require.NoError(t, s.Sync(ctx))
+	}
+
+	stats := s.Stats()
+	assert.NotEmpty(t, stats.Generation)
+	assert.Less(t, stats.LagSeconds, 5.0)
+
+	// Only the current generation is needed to restore within the window
+	gens, err := walship.Generations(cfg.Dir)
+	require.NoError(t, err)
+	require.Len(t, gens, 1)
+	assert.Equal(t, stats.Generation, gens[0].ID)
+	assert.Equal(t, 3, restoredCount(t, cfg, time.Time{}))
+}
@@ -0,0 +1,153 @@
+package walship
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WAL file layout, see https://www.sqlite.org/fileformat.html#the_write_ahead_log
+const (
+	walHeaderSize   = 32
+	frameHeaderSize = 24
+
+	// walMagic is the WAL magic number; the low bit selects big-endian
+	// checksums
+	walMagic = 0x377f0682
+)
+
+// walHeader is the part of the WAL header needed to follow the log
+type walHeader struct {
+	bigEndian bool
+	pageSize  int
+	salt1     uint32
+	salt2     uint32
+	// checksum seeds the running checksum of the first frame
+	checksum [2]uint32
+}
+
+// readWALHeader reads and validates the WAL header of f. It returns
+// io.ErrUnexpectedEOF when the file is shorter than a header, which happens
+// before the first write after a checkpoint truncated it.
+func readWALHeader(f *os.File) (walHeader, error) {
+	buf := make([]byte, walHeaderSize)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return walHeader{}, io.ErrUnexpectedEOF
+		}
+		return walHeader{}, err
+	}
+
+	magic := binary.BigEndian.Uint32(buf[0:])
+	if magic&^1 != walMagic {
+		return walHeader{}, fmt.Errorf("invalid WAL magic %#x", magic)
+	}
+	hdr := walHeader{
+		bigEndian: magic&1 == 1,
+		pageSize:  int(binary.BigEndian.Uint32(buf[8:])),
+		salt1:     binary.BigEndian.Uint32(buf[16:]),
+		salt2:     binary.BigEndian.Uint32(buf[20:]),
+	}
+	if hdr.pageSize == 1 {
+		hdr.pageSize = 65536
+	}
+
+	sum := checksum(hdr.bigEndian, [2]uint32{}, buf[:24])
+	if sum[0] != binary.BigEndian.Uint32(buf[24:]) || sum[1] != binary.BigEndian.Uint32(buf[28:]) {
+		return walHeader{}, errors.New("invalid WAL header checksum")
+	}
+	hdr.checksum = sum
+	return hdr, nil
+}
+
+// position identifies a point in the WAL: the header salts, the byte offset
+// of the next frame and the running checksum up to it
+type position struct {
+	salt1, salt2 uint32
+	offset       int64
+	checksum     [2]uint32
+}
+
+// start returns the position of the first frame of the WAL with header hdr
+func (hdr walHeader) start() position {
+	return position{salt1: hdr.salt1, salt2: hdr.salt2, offset: walHeaderSize, checksum: hdr.checksum}
+}
+
+// readTransactions reads the complete, checksummed transactions written after
+// pos. It returns the raw frames and the position after the last commit
+// frame. Reading stops at the first frame from an earlier WAL generation, a
+// checksum mismatch or a frame that is still being written.
+func readTransactions(f *os.File, hdr walHeader, pos position) ([]byte, position, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, pos, err
+	}
+	frameSize := int64(frameHeaderSize + hdr.pageSize)
+	if info.Size() <= pos.offset {
+		return nil, pos, nil
+	}
+
+	buf := make([]byte, (info.Size()-pos.offset)/frameSize*frameSize)
+	n, err := f.ReadAt(buf, pos.offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, pos, err
+	}
+	buf = buf[:int64(n)/frameSize*frameSize]
+
+	committed := pos
+	sum := pos.checksum
+	for off := int64(0); off < int64(len(buf)); off += frameSize {
+		frame := buf[off : off+frameSize]
+		if binary.BigEndian.Uint32(frame[8:]) != pos.salt1 || binary.BigEndian.Uint32(frame[12:]) != pos.salt2 {
+			break
+		}
+		sum = checksum(hdr.bigEndian, sum, frame[:8])
+		sum = checksum(hdr.bigEndian, sum, frame[frameHeaderSize:])
+		if sum[0] != binary.BigEndian.Uint32(frame[16:]) || sum[1] != binary.BigEndian.Uint32(frame[20:]) {
+			break
+		}
+		if binary.BigEndian.Uint32(frame[4:]) != 0 {
+			committed.offset = pos.offset + off + frameSize
+			committed.checksum = sum
+		}
+	}
+	return buf[:committed.offset-pos.offset], committed, nil
+}
+
+// checksum continues the WAL checksum s over b, whose length is a multiple
+// of 8
+func checksum(bigEndian bool, s [2]uint32, b []byte) [2]uint32 {
+	order := binary.ByteOrder(binary.LittleEndian)
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(b); i += 8 {
+		s[0] += order.Uint32(b[i:]) + s[1]
+		s[1] += order.Uint32(b[i+4:]) + s[0]
+	}
+	return s
+}
+
+// applyFrames writes the pages of WAL frames into the database file f,
+// truncating it to the database size recorded in each commit frame
+func applyFrames(f *os.File, frames []byte, pageSize int) error {
+	frameSize := frameHeaderSize + pageSize
+	if len(frames)%frameSize != 0 {
+		return fmt.Errorf("WAL segment size %d is not a multiple of the frame size %d", len(frames), frameSize)
+	}
+	for off := 0; off < len(frames); off += frameSize {
+		frame := frames[off : off+frameSize]
+		pgno := int64(binary.BigEndian.Uint32(frame[0:]))
+		if _, err := f.WriteAt(frame[frameHeaderSize:], (pgno-1)*int64(pageSize)); err != nil {
+			return err
+		}
+		if commit := int64(binary.BigEndian.Uint32(frame[4:])); commit != 0 {
+			if err := f.Truncate(commit * int64(pageSize)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+)
+
+// Delivery states. A delivery is retried while pending and dead-lettered
+// once it runs out of attempts.
+const (
+	StatePending   = "pending"
+	StateDelivered = "delivered"
+	StateDead      = "dead"
+)
+
+// Paging limits of ListDeliveries
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// Delivery is one event sent, or to be sent, to one subscription.
+// LastStatus is the HTTP status of the last attempt, zero if none was
+// received.
+type Delivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	State          string          `json:"state"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatus     int             `json:"last_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+const deliveryColumns = `id, subscription_id, event_id, event_type, state, attempts, next_attempt_at,
+	last_status, last_error, created_at, delivered_at, payload`
+
+func scanDelivery(row interface{ Scan(...any) error }) (*Delivery, error) {
+	var d Delivery
+	var nextAttempt int64
+	var deliveredAt sql.NullTime
+	var payload string
+	err := row.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.State, &d.Attempts, &nextAttempt,
+		&d.LastStatus, &d.LastError, &d.CreatedAt, &deliveredAt, &payload)
+	if err != nil {
+		return nil, err
+	}
+	if d.State == StatePending {
+		t := time.UnixMilli(nextAttempt).UTC()
+		d.NextAttemptAt = &t
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	d.Payload = json.RawMessage(payload)
+	return &d, nil
+}
+
+// DeliveryQuery selects deliveries of a subscription, newest first. State
+// narrows them to one state; Before pages past a delivery ID.
+type DeliveryQuery struct {
+	State    string
+	Before   int64
+	PageSize int
+}
+
+// ListDeliveries returns the deliveries of a subscription. Errors are
+// *apierror.Error values.
+func ListDeliveries(ctx context.Context, db *sql.DB, subscriptionID string, q DeliveryQuery) ([]*Delivery, error) {
+	var violations []apierror.FieldViolation
+	if q.State != "" && q.State != StatePending && q.State != StateDelivered && q.State != StateDead {
+		violations = append(violations, apierror.FieldViolation{Field: "state", Description: "state must be pending, delivered or dead"})
+	}
+	if q.PageSize < 0 || q.PageSize > MaxPageSize {
+		violations = append(violations, apierror.FieldViolation{Field: "page_size", Description: "page_size must be between 1 and 1000"})
+	}
+	if len(violations) > 0 {
+		return nil, apierror.InvalidArgument("invalid delivery query", violations...)
+	}
+	if q.PageSize == 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if _, err := Get(ctx, db, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT "+deliveryColumns+` FROM webhook_deliveries
+		WHERE subscription_id = ? AND (? = '' OR state = ?) AND (? = 0 OR id < ?)
+		ORDER BY id DESC LIMIT ?`,
+		subscriptionID, q.State, q.State, q.Before, q.Before, q.PageSize)
+	if err != nil {
+		return nil, apierror.Internal(err, "listing deliveries")
+	}
+	defer rows.Close()
+
+	deliveries := []*Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, apierror.Internal(err, "scanning delivery")
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "listing deliveries")
+	}
+	return deliveries, nil
+}
+
+// Replay sends a delivery again from its first attempt, whatever its state.
+// Errors are *apierror.Error values.
+func Replay(ctx context.Context, db *sql.DB, subscriptionID string, id int64) (*Delivery, error) {
+	var d *Delivery
+	err := database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE webhook_deliveries SET state = 'pending', attempts = 0,
+			next_attempt_at = ?, last_error = '', delivered_at = NULL WHERE id = ? AND subscription_id = ?`,
+			time.Now().UnixMilli(), id, subscriptionID)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return apierror.NotFound("delivery", strconv.FormatInt(id, 10))
+		}
+		d, err = scanDelivery(tx.QueryRowContext(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = ?", id))
+		return err
+	})
+	if err != nil {
+		return nil, apierror.From(err, "replaying delivery")
+	}
+	return d, nil
+}
+
+// ReplayDead sends every dead-lettered delivery of a subscription again and
+// returns how many there were. Errors are *apierror.Error values.
+func ReplayDead(ctx context.Context, db *sql.DB, subscriptionID string) (int64, error) {
+	if _, err := Get(ctx, db, subscriptionID); err != nil {
+		return 0, err
+	}
+	var n int64
+	err := database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE webhook_deliveries SET state = 'pending', attempts = 0,
+			next_attempt_at = ?, last_error = '' WHERE subscription_id = ? AND state = 'dead'`,
+			time.Now().UnixMilli(), subscriptionID)
+		if err != nil {
+			return err
+		}
+		n, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, apierror.Internal(err, "replaying deliveries")
+	}
+	return n, nil
+}
@@ -0,0 +1,319 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+)
+
+// Config tunes how deliveries are sent
+type Config struct {
+	// Concurrency is the number of deliveries sent at once, each to a
+	// different subscription
+	Concurrency int
+	// PollInterval is how often the outbox is checked for due deliveries
+	PollInterval time.Duration
+	// Timeout bounds one attempt, including reading the response
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts before a delivery is dead-lettered
+	MaxAttempts int
+	// BaseDelay is the delay before the second attempt; it doubles on each
+	// attempt up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Retention is how long delivered and dead-lettered deliveries are kept;
+	// dead ones can be replayed until then
+	Retention time.Duration
+	// Policy is checked for every connection to a receiver
+	Policy Policy
+}
+
+// DefaultConfig retries for about an hour before dead-lettering and keeps
+// finished deliveries for a week
+func DefaultConfig() Config {
+	return Config{
+		Concurrency:  4,
+		PollInterval: time.Second,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		BaseDelay:    15 * time.Second,
+		MaxDelay:     30 * time.Minute,
+		Retention:    7 * 24 * time.Hour,
+	}
+}
+
+// Dispatcher sends the deliveries in the outbox of a database
+type Dispatcher struct {
+	db     *sql.DB
+	cfg    Config
+	client *http.Client
+}
+
+// NewDispatcher creates a dispatcher, filling unset fields of cfg from
+// DefaultConfig. Redirects are not followed; they count as failures, as do
+// receivers at addresses cfg.Policy does not allow. Proxy settings from the
+// environment are ignored so that the policy applies to the receiver itself.
+func NewDispatcher(db *sql.DB, cfg Config) *Dispatcher {
+	def := DefaultConfig()
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = def.Concurrency
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = def.BaseDelay
+	}
+	if cfg.MaxDelay < cfg.BaseDelay {
+		cfg.MaxDelay = max(def.MaxDelay, cfg.BaseDelay)
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = def.Retention
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: cfg.Policy.control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &Dispatcher{db: db, cfg: cfg, client: client}
+}
+
+// due is a claimed delivery with what is needed to send it
+type due struct {
+	id        int64
+	eventID   string
+	eventType string
+	payload   []byte
+	attempts  int
+	url       string
+	secret    string
+}
+
+// pruneInterval is how often finished deliveries are deleted, unless
+// Retention is shorter
+const pruneInterval = time.Minute
+
+// Run sends due deliveries until ctx ends. The deliveries of a subscription
+// are sent one at a time in the order their events happened, so a receiver
+// never sees item.deleted before item.created; a delivery waiting for a retry
+// holds back the later ones until it is delivered or dead-lettered.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	var pruned time.Time
+	every := min(pruneInterval, d.cfg.Retention)
+	for {
+		batch, err := d.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error claiming webhook deliveries: %v", err)
+		}
+		if time.Since(pruned) >= every {
+			if _, err := d.Prune(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Error pruning webhook deliveries: %v", err)
+			}
+			pruned = time.Now()
+		}
+
+		var wg sync.WaitGroup
+		for _, del := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(ctx, del)
+			}()
+		}
+		wg.Wait()
+		if len(batch) > 0 {
+			// The next deliveries of these subscriptions may be due
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune deletes deliveries delivered more than Retention ago, and dead ones
+// created more than Retention ago. It returns how many were deleted.
+func (d *Dispatcher) Prune(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-d.cfg.Retention).UTC()
+	const finished = "(state = 'delivered' AND delivered_at < ?) OR (state = 'dead' AND created_at < ?)"
+
+	// Probe outside a transaction so an idle dispatcher takes no write lock
+	var expired bool
+	err := d.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE "+finished+")",
+		cutoff, cutoff).Scan(&expired)
+	if err != nil || !expired {
+		return 0, err
+	}
+	var n int64
+	err = database.WithTx(ctx, d.db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE "+finished, cutoff, cutoff)
+		if err != nil {
+			return err
+		}
+		n, err = result.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+// dueDeliveries selects, for each active subscription, its oldest pending
+// delivery when its next attempt is due. A claimed delivery is not due until
+// its attempt ends, so at most one per subscription is in flight.
+const dueDeliveries = `FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+	WHERE d.state = 'pending' AND d.next_attempt_at <= ? AND s.active
+	AND d.id = (SELECT MIN(id) FROM webhook_deliveries
+		WHERE subscription_id = d.subscription_id AND state = 'pending')`
+
+// claim takes up to Concurrency due deliveries of active subscriptions,
+// oldest first and at most one per subscription. Claimed deliveries are
+// pushed past the end of their attempt so a dispatcher that dies mid-attempt
+// leaves them to be retried.
+func (d *Dispatcher) claim(ctx context.Context) ([]due, error) {
+	// Probe outside a transaction so an idle dispatcher takes no write lock
+	var pending bool
+	err := d.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 "+dueDeliveries+")", time.Now().UnixMilli()).Scan(&pending)
+	if err != nil || !pending {
+		return nil, err
+	}
+
+	var batch []due
+	err = database.WithTx(ctx, d.db, func(tx *sql.Tx) error {
+		batch = batch[:0]
+		now := time.Now()
+		rows, err := tx.QueryContext(ctx, `SELECT d.id, d.event_id, d.event_type, d.payload, d.attempts, s.url, s.secret
+			`+dueDeliveries+`
+			ORDER BY d.id LIMIT ?`, now.UnixMilli(), d.cfg.Concurrency)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var del due
+			var payload string
+			if err := rows.Scan(&del.id, &del.eventID, &del.eventType, &payload, &del.attempts, &del.url, &del.secret); err != nil {
+				rows.Close()
+				return err
+			}
+			del.payload = []byte(payload)
+			batch = append(batch, del)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		lease := now.Add(2 * d.cfg.Timeout).UnixMilli()
+		for _, del := range batch {
+			if _, err := tx.ExecContext(ctx, "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?", lease, del.id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return batch, err
+}
+
+// deliver makes one attempt and records its outcome
+func (d *Dispatcher) deliver(ctx context.Context, del due) {
+	status, err := d.send(ctx, del)
+	if ctx.Err() != nil {
+		// Shutting down; the claim runs out and the attempt is made again
+		return
+	}
+
+	attempts := del.attempts + 1
+	now := time.Now().UTC()
+	var query string
+	var args []any
+	switch {
+	case err == nil:
+		query = `UPDATE webhook_deliveries SET state = 'delivered', attempts = ?, last_status = ?, last_error = '',
+			delivered_at = ? WHERE id = ?`
+		args = []any{attempts, status, now, del.id}
+	case attempts >= d.cfg.MaxAttempts:
+		log.Printf("Webhook delivery %d dead-lettered after %d attempts: %v", del.id, attempts, err)
+		query = "UPDATE webhook_deliveries SET state = 'dead', attempts = ?, last_status = ?, last_error = ? WHERE id = ?"
+		args = []any{attempts, status, err.Error(), del.id}
+	default:
+		query = "UPDATE webhook_deliveries SET attempts = ?, last_status = ?, last_error = ?, next_attempt_at = ? WHERE id = ?"
+		args = []any{attempts, status, err.Error(), now.Add(d.backoff(attempts)).UnixMilli(), del.id}
+	}
+
+	// Record the outcome even if ctx ends now, so a delivered event is not
+	// sent again
+	err = database.WithTx(context.Background(), d.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(query, args...)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error recording webhook delivery %d: %v", del.id, err)
+	}
+}
+
+// send posts a delivery and returns the response status. Any status other
+// than 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, del due) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.url, bytes.NewReader(del.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-api-sqlite-webhooks")
+	req.Header.Set(HeaderEventID, del.eventID)
+	req.Header.Set(HeaderEventType, del.eventType)
+	req.Header.Set(HeaderDeliveryID, strconv.FormatInt(del.id, 10))
+	req.Header.Set(HeaderSignature, Sign(del.secret, time.Now(), del.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		var urlErr interface{ Timeout() bool }
+		if errors.As(err, &urlErr) && urlErr.Timeout() {
+			return 0, fmt.Errorf("no response within %s", d.cfg.Timeout)
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay after attempt, with up to half of it added as
+// jitter so failed deliveries do not retry in lockstep
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > d.cfg.MaxDelay {
+		delay = d.cfg.MaxDelay
+	}
+	return delay + rand.N(delay/2+1)
+}
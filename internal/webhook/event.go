@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/models"
+	"github.com/google/uuid"
+)
+
+// Event is the JSON body of a delivery. Data is the item after the change,
+// or before it for deletions.
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       models.Item `json:"data"`
+}
+
+// Record queues an event for every active subscription that wants it. Call
+// it in the transaction that changes the item, so the event is delivered if
+// and only if the change commits.
+func Record(ctx context.Context, tx *sql.Tx, eventType string, item models.Item) error {
+	event := Event{ID: uuid.New().String(), Type: eventType, OccurredAt: time.Now().UTC(), Data: item}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO webhook_deliveries
+		(subscription_id, event_id, event_type, payload, state, next_attempt_at, created_at)
+		SELECT id, ?, ?, ?, 'pending', ?, ? FROM webhook_subscriptions
+		WHERE active AND (events = '' OR instr(',' || events || ',', ?) > 0)`,
+		event.ID, event.Type, string(payload), event.OccurredAt.UnixMilli(), event.OccurredAt, ","+eventType+",")
+	return err
+}
+
+// Headers set on every delivery
+const (
+	HeaderEventID    = "Webhook-Id"
+	HeaderEventType  = "Webhook-Event"
+	HeaderDeliveryID = "Webhook-Delivery"
+	HeaderSignature  = "Webhook-Signature"
+)
+
+// Sign returns the signature header for a body sent at t: the Unix time and
+// the hex HMAC-SHA256 of "<time>.<body>" keyed with the subscription secret,
+// as "t=<time>,v1=<signature>"
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Errors returned by Verify
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrExpired          = errors.New("webhook: signature timestamp outside tolerance")
+)
+
+// Verify checks a signature header made by Sign, for receivers. Signatures
+// older or newer than tolerance are rejected so captured deliveries cannot be
+// replayed later; zero skips the check.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return ErrExpired
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// Policy decides which addresses receivers may have. Loopback, private,
+// link-local and other internal or reserved addresses are refused unless they
+// are in AllowedNetworks, so a subscription cannot reach services that only
+// the API host can.
+type Policy struct {
+	AllowedNetworks []netip.Prefix
+}
+
+// reservedNetworks are the special-purpose ranges not covered by the
+// netip.Addr predicates used in Allows
+var reservedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which can reach internal IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("fec0::/10"),       // deprecated site-local
+}
+
+// ParseNetworks parses a comma-separated list of CIDR prefixes, as taken by
+// -webhook-allow-networks
+func ParseNetworks(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Allows reports whether receivers may have addr
+func (p Policy) Allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.AllowedNetworks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() {
+		return false
+	}
+	for _, prefix := range reservedNetworks {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHost resolves host and returns an error unless every address it has
+// is allowed
+func (p Policy) checkHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("url host %s could not be resolved", host)
+	}
+	for _, addr := range addrs {
+		if !p.Allows(addr) {
+			return fmt.Errorf("url host %s resolves to %s, an internal address", host, addr.Unmap())
+		}
+	}
+	return nil
+}
+
+// control is a net.Dialer Control function that refuses connections to
+// addresses the policy does not allow. Checking when dialing, rather than
+// only when the subscription is saved, also covers hosts whose DNS records
+// change afterwards.
+func (p Policy) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !p.Allows(addrPort.Addr()) {
+		return fmt.Errorf("webhook receiver address %s is internal", addrPort.Addr().Unmap())
+	}
+	return nil
+}
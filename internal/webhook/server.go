@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the WebhookService gRPC service
+type Server struct {
+	pb.UnimplementedWebhookServiceServer
+	db     *sql.DB
+	policy Policy
+}
+
+// NewServer creates a webhook server for the subscriptions in db whose
+// receivers must have addresses policy allows
+func NewServer(db *sql.DB, policy Policy) *Server {
+	return &Server{db: db, policy: policy}
+}
+
+// CreateWebhook adds a subscription
+func (s *Server) CreateWebhook(ctx context.Context, req *pb.CreateWebhookRequest) (*pb.Webhook, error) {
+	sub, err := Create(ctx, s.db, s.policy, Params{URL: req.Url, Events: req.Events, Secret: req.Secret, Active: req.Active})
+	if err != nil {
+		return nil, err
+	}
+	return webhookProto(sub), nil
+}
+
+// GetWebhook returns a subscription
+func (s *Server) GetWebhook(ctx context.Context, req *pb.GetWebhookRequest) (*pb.Webhook, error) {
+	sub, err := Get(ctx, s.db, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return webhookProto(sub), nil
+}
+
+// ListWebhooks returns every subscription
+func (s *Server) ListWebhooks(ctx context.Context, req *pb.ListWebhooksRequest) (*pb.ListWebhooksResponse, error) {
+	subs, err := List(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListWebhooksResponse{}
+	for _, sub := range subs {
+		resp.Webhooks = append(resp.Webhooks, webhookProto(sub))
+	}
+	return resp, nil
+}
+
+// UpdateWebhook changes a subscription
+func (s *Server) UpdateWebhook(ctx context.Context, req *pb.UpdateWebhookRequest) (*pb.Webhook, error) {
+	sub, err := Update(ctx, s.db, s.policy, req.Id, Params{URL: req.Url, Events: req.Events, Secret: req.Secret, Active: req.Active})
+	if err != nil {
+		return nil, err
+	}
+	return webhookProto(sub), nil
+}
+
+// DeleteWebhook removes a subscription and its deliveries
+func (s *Server) DeleteWebhook(ctx context.Context, req *pb.DeleteWebhookRequest) (*pb.DeleteWebhookResponse, error) {
+	if err := Delete(ctx, s.db, req.Id); err != nil {
+		return nil, err
+	}
+	return &pb.DeleteWebhookResponse{Success: true}, nil
+}
+
+var deliveryStates = map[string]pb.Delivery_State{
+	StatePending:   pb.Delivery_STATE_PENDING,
+	StateDelivered: pb.Delivery_STATE_DELIVERED,
+	StateDead:      pb.Delivery_STATE_DEAD,
+}
+
+// ListDeliveries returns the deliveries of a subscription
+func (s *Server) ListDeliveries(ctx context.Context, req *pb.ListDeliveriesRequest) (*pb.ListDeliveriesResponse, error) {
+	q := DeliveryQuery{Before: req.BeforeId, PageSize: int(req.PageSize)}
+	for name, state := range deliveryStates {
+		if state == req.State {
+			q.State = name
+		}
+	}
+	deliveries, err := ListDeliveries(ctx, s.db, req.WebhookId, q)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListDeliveriesResponse{}
+	for _, d := range deliveries {
+		msg, err := deliveryProto(d)
+		if err != nil {
+			return nil, err
+		}
+		resp.Deliveries = append(resp.Deliveries, msg)
+	}
+	return resp, nil
+}
+
+// ReplayDelivery sends a delivery again
+func (s *Server) ReplayDelivery(ctx context.Context, req *pb.ReplayDeliveryRequest) (*pb.Delivery, error) {
+	d, err := Replay(ctx, s.db, req.WebhookId, req.DeliveryId)
+	if err != nil {
+		return nil, err
+	}
+	return deliveryProto(d)
+}
+
+// ReplayDeadDeliveries sends the dead-lettered deliveries of a subscription
+// again
+func (s *Server) ReplayDeadDeliveries(ctx context.Context, req *pb.ReplayDeadDeliveriesRequest) (*pb.ReplayDeadDeliveriesResponse, error) {
+	n, err := ReplayDead(ctx, s.db, req.WebhookId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ReplayDeadDeliveriesResponse{Replayed: n}, nil
+}
+
+func webhookProto(sub *Subscription) *pb.Webhook {
+	return &pb.Webhook{
+		Id:        sub.ID,
+		Url:       sub.URL,
+		Events:    sub.Events,
+		Secret:    sub.Secret,
+		Active:    sub.Active,
+		CreatedAt: timestamppb.New(sub.CreatedAt),
+		UpdatedAt: timestamppb.New(sub.UpdatedAt),
+	}
+}
+
+func deliveryProto(d *Delivery) (*pb.Delivery, error) {
+	var payload map[string]any
+	if err := json.Unmarshal(d.Payload, &payload); err != nil {
+		return nil, apierror.Internal(err, "decoding delivery payload")
+	}
+	body, err := structpb.NewStruct(payload)
+	if err != nil {
+		return nil, apierror.Internal(err, "converting delivery payload")
+	}
+	msg := &pb.Delivery{
+		Id:         d.ID,
+		WebhookId:  d.SubscriptionID,
+		EventId:    d.EventID,
+		EventType:  d.EventType,
+		State:      deliveryStates[d.State],
+		Attempts:   int32(d.Attempts),
+		LastStatus: int32(d.LastStatus),
+		LastError:  d.LastError,
+		Payload:    body,
+		CreatedAt:  timestamppb.New(d.CreatedAt),
+	}
+	if d.NextAttemptAt != nil {
+		msg.NextAttemptAt = timestamppb.New(*d.NextAttemptAt)
+	}
+	if d.DeliveredAt != nil {
+		msg.DeliveredAt = timestamppb.New(*d.DeliveredAt)
+	}
+	return msg, nil
+}
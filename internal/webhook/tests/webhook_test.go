@@ -0,0 +1,497 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/database"
+	grpcserver "github.com/angel/go-api-sqlite/internal/grpc"
+	"github.com/angel/go-api-sqlite/internal/handlers"
+	"github.com/angel/go-api-sqlite/internal/money"
+	"github.com/angel/go-api-sqlite/internal/webhook"
+	pb "github.com/angel/go-api-sqlite/proto"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress log output during tests
+	log.SetOutput(os.NewFile(0, os.DevNull))
+
+	os.Exit(m.Run())
+}
+
+// testPolicy allows the loopback receivers started by httptest
+var testPolicy = webhook.Policy{AllowedNetworks: []netip.Prefix{
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("::1/128"),
+}}
+
+// testConfig polls often and retries at once so tests run quickly
+var testConfig = webhook.Config{
+	PollInterval: 5 * time.Millisecond,
+	Timeout:      2 * time.Second,
+	MaxAttempts:  3,
+	BaseDelay:    time.Millisecond,
+	MaxDelay:     5 * time.Millisecond,
+	Policy:       testPolicy,
+}
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := database.Open(filepath.Join(t.TempDir(), "data.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// dispatch delivers webhooks until the test ends
+func dispatch(t *testing.T, db *sql.DB) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		webhook.NewDispatcher(db, testConfig).Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func newRouter(db *sql.DB) *mux.Router {
+	h := handlers.NewHandler(db, money.Rules{})
+	wh := handlers.NewWebhookHandler(db, testPolicy)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/items", h.CreateItem).Methods("POST")
+	router.HandleFunc("/api/items/{id}", h.UpdateItem).Methods("PUT")
+	router.HandleFunc("/api/items/{id}", h.DeleteItem).Methods("DELETE")
+	router.HandleFunc("/api/webhooks", wh.ListWebhooks).Methods("GET")
+	router.HandleFunc("/api/webhooks", wh.CreateWebhook).Methods("POST")
+	router.HandleFunc("/api/webhooks/{id:[^/:]+}", wh.GetWebhook).Methods("GET")
+	router.HandleFunc("/api/webhooks/{id:[^/:]+}", wh.UpdateWebhook).Methods("PUT")
+	router.HandleFunc("/api/webhooks/{id:[^/:]+}", wh.DeleteWebhook).Methods("DELETE")
+	router.HandleFunc("/api/webhooks/{id:[^/:]+}:replay", wh.ReplayDeadDeliveries).Methods("POST")
+	router.HandleFunc("/api/webhooks/{id}/deliveries", wh.ListDeliveries).Methods("GET")
+	router.HandleFunc("/api/webhooks/{id}/deliveries/{delivery_id:[^/:]+}:replay", wh.ReplayDelivery).Methods("POST")
+	return router
+}
+
+func do(t *testing.T, router http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		r = bytes.NewReader(data)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(method, path, r))
+	return rr
+}
+
+// receiver records the events posted to it after checking their signature.
+// fail makes it answer 500 while it returns true.
+type receiver struct {
+	*httptest.Server
+	secret string
+	fail   func() bool
+
+	mu      sync.Mutex
+	events  []webhook.Event
+	headers []http.Header
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	rc := &receiver{secret: secret, fail: func() bool { return false }}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(rc.secret, r.Header.Get(webhook.HeaderSignature), body, time.Minute); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if rc.fail() {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		var event webhook.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rc.mu.Lock()
+		rc.events = append(rc.events, event)
+		rc.headers = append(rc.headers, r.Header.Clone())
+		rc.mu.Unlock()
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) received() []webhook.Event {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]webhook.Event(nil), rc.events...)
+}
+
+func (rc *receiver) waitFor(t *testing.T, n int) []webhook.Event {
+	require.Eventually(t, func() bool { return len(rc.received()) >= n }, 5*time.Second, 5*time.Millisecond)
+	return rc.received()
+}
+
+func createWebhook(t *testing.T, router http.Handler, p map[string]any) webhook.Subscription {
+	rr := do(t, router, "POST", "/api/webhooks", p)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var sub webhook.Subscription
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&sub))
+	return sub
+}
+
+func deliveries(t *testing.T, router http.Handler, subID, query string) []webhook.Delivery {
+	rr := do(t, router, "GET", "/api/webhooks/"+subID+"/deliveries"+query, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var ds []webhook.Delivery
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&ds))
+	return ds
+}
+
+func TestSubscriptionEndpoints(t *testing.T) {
+	router := newRouter(openDB(t))
+
+	rr := do(t, router, "POST", "/api/webhooks", map[string]any{"url": "ftp://example.com", "events": []string{"item.renamed"}})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	var problem struct {
+		Errors []struct{ Field string } `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+	assert.Len(t, problem.Errors, 2)
+
+	sub := createWebhook(t, router, map[string]any{"url": "https://93.184.215.14/hook", "events": []string{"item.deleted", "item.created"}})
+	assert.Len(t, sub.Secret, 64)
+	assert.True(t, sub.Active)
+	assert.Equal(t, []string{"item.created", "item.deleted"}, sub.Events)
+
+	// The secret is only returned on creation
+	rr = do(t, router, "GET", "/api/webhooks/"+sub.ID, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got webhook.Subscription
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	assert.Empty(t, got.Secret)
+	assert.Equal(t, sub.URL, got.URL)
+
+	rr = do(t, router, "PUT", "/api/webhooks/"+sub.ID, map[string]any{"url": "https://93.184.215.14/v2", "active": false})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	assert.Equal(t, "https://93.184.215.14/v2", got.URL)
+	assert.Equal(t, []string{}, got.Events)
+	assert.False(t, got.Active)
+
+	rr = do(t, router, "GET", "/api/webhooks", nil)
+	var list []webhook.Subscription
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list, 1)
+
+	assert.Equal(t, http.StatusNoContent, do(t, router, "DELETE", "/api/webhooks/"+sub.ID, nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(t, router, "DELETE", "/api/webhooks/"+sub.ID, nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(t, router, "GET", "/api/webhooks/"+sub.ID+"/deliveries", nil).Code)
+}
+
+func TestDeliversSignedEvents(t *testing.T) {
+	db := openDB(t)
+	router := newRouter(db)
+	all := newReceiver(t, "all-secret")
+	deletes := newReceiver(t, "deletes-secret")
+	paused := newReceiver(t, "paused-secret")
+	createWebhook(t, router, map[string]any{"url": all.URL, "secret": all.secret})
+	createWebhook(t, router, map[string]any{"url": deletes.URL, "secret": deletes.secret, "events": []string{"item.deleted"}})
+	createWebhook(t, router, map[string]any{"url": paused.URL, "secret": paused.secret, "active": false})
+
+	// Create over REST, update and delete over gRPC
+	rr := do(t, router, "POST", "/api/items", map[string]any{"name": "Widget", "value": 1.5})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var item struct{ ID string }
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&item))
+	items := grpcserver.NewItemServer(db, money.Rules{})
+	ctx := context.Background()
+	_, err := items.UpdateItem(ctx, &pb.UpdateItemRequest{Id: item.ID, Name: "Gadget", Value: &decimal.Decimal{Value: "2"}})
+	require.NoError(t, err)
+	_, err = items.DeleteItem(ctx, &pb.DeleteItemRequest{Id: item.ID})
+	require.NoError(t, err)
+
+	// A failed change records nothing
+	_, err = items.DeleteItem(ctx, &pb.DeleteItemRequest{Id: item.ID})
+	require.Error(t, err)
+
+	dispatch(t, db)
+	events := all.waitFor(t, 3)
+	require.Len(t, events, 3)
+	assert.Equal(t, webhook.ItemCreated, events[0].Type)
+	assert.Equal(t, webhook.ItemUpdated, events[1].Type)
+	assert.Equal(t, "Gadget", events[1].Data.Name)
+	assert.Equal(t, webhook.ItemDeleted, events[2].Type)
+	assert.Equal(t, item.ID, events[2].Data.ID)
+	assert.Equal(t, "Gadget", events[2].Data.Name)
+	assert.Equal(t, events[0].ID, all.headers[0].Get(webhook.HeaderEventID))
+	assert.Equal(t, webhook.ItemCreated, all.headers[0].Get(webhook.HeaderEventType))
+
+	events = deletes.waitFor(t, 1)
+	assert.Equal(t, webhook.ItemDeleted, events[0].Type)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, deletes.received(), 1)
+	assert.Empty(t, paused.received())
+}
+
+func TestRetriesAndDeadLetters(t *testing.T) {
+	db := openDB(t)
+	router := newRouter(db)
+
+	// Fails once, then recovers
+	var flakyCalls atomic.Int32
+	flaky := newReceiver(t, "flaky")
+	flaky.fail = func() bool { return flakyCalls.Add(1) == 1 }
+	flakySub := createWebhook(t, router, map[string]any{"url": flaky.URL, "secret": flaky.secret})
+
+	// Fails until told otherwise
+	var down atomic.Bool
+	down.Store(true)
+	broken := newReceiver(t, "broken")
+	broken.fail = down.Load
+	brokenSub := createWebhook(t, router, map[string]any{"url": broken.URL, "secret": broken.secret})
+
+	require.Equal(t, http.StatusCreated, do(t, router, "POST", "/api/items", map[string]any{"name": "Widget", "value": 1}).Code)
+	dispatch(t, db)
+
+	flaky.waitFor(t, 1)
+	require.Eventually(t, func() bool {
+		ds := deliveries(t, router, flakySub.ID, "")
+		return len(ds) == 1 && ds[0].State == webhook.StateDelivered
+	}, 5*time.Second, 5*time.Millisecond)
+	d := deliveries(t, router, flakySub.ID, "")[0]
+	assert.Equal(t, 2, d.Attempts)
+	assert.Equal(t, http.StatusOK, d.LastStatus)
+	assert.NotNil(t, d.DeliveredAt)
+
+	require.Eventually(t, func() bool {
+		return len(deliveries(t, router, brokenSub.ID, "?state=dead")) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	d = deliveries(t, router, brokenSub.ID, "?state=dead")[0]
+	assert.Equal(t, testConfig.MaxAttempts, d.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, d.LastStatus)
+	assert.Contains(t, d.LastError, "500")
+	assert.Empty(t, broken.received())
+
+	// Replay the dead letters once the receiver is back
+	down.Store(false)
+	rr := do(t, router, "POST", "/api/webhooks/"+brokenSub.ID+":replay", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"replayed":1}`, rr.Body.String())
+	events := broken.waitFor(t, 1)
+	assert.Equal(t, d.EventID, events[0].ID)
+
+	// A delivered event can be sent again by hand
+	rr = do(t, router, "POST", "/api/webhooks/"+flakySub.ID+"/deliveries/"+jsonNumber(d.ID)+":replay", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "delivery of another subscription")
+	fd := deliveries(t, router, flakySub.ID, "")[0]
+	rr = do(t, router, "POST", "/api/webhooks/"+flakySub.ID+"/deliveries/"+jsonNumber(fd.ID)+":replay", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	events = flaky.waitFor(t, 2)
+	assert.Equal(t, events[0].ID, events[1].ID)
+
+	rr = do(t, router, "GET", "/api/webhooks/"+flakySub.ID+"/deliveries?state=lost", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestFinishedDeliveriesArePruned(t *testing.T) {
+	db := openDB(t)
+	router := newRouter(db)
+
+	ok := newReceiver(t, "ok")
+	okSub := createWebhook(t, router, map[string]any{"url": ok.URL, "secret": ok.secret})
+	broken := newReceiver(t, "broken")
+	broken.fail = func() bool { return true }
+	brokenSub := createWebhook(t, router, map[string]any{"url": broken.URL, "secret": broken.secret})
+	paused := createWebhook(t, router, map[string]any{"url": ok.URL, "secret": ok.secret})
+
+	require.Equal(t, http.StatusCreated, do(t, router, "POST", "/api/items", map[string]any{"name": "Widget", "value": 1}).Code)
+	rr := do(t, router, "PUT", "/api/webhooks/"+paused.ID, map[string]any{"url": ok.URL, "active": false})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		webhook.NewDispatcher(db, testConfig).Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return len(deliveries(t, router, okSub.ID, "?state=delivered")) == 1 &&
+			len(deliveries(t, router, brokenSub.ID, "?state=dead")) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	cfg := testConfig
+	cfg.Retention = time.Hour
+	n, err := webhook.NewDispatcher(db, cfg).Prune(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n, "nothing finished an hour ago")
+
+	time.Sleep(10 * time.Millisecond)
+	cfg.Retention = 5 * time.Millisecond
+	n, err = webhook.NewDispatcher(db, cfg).Prune(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	assert.Empty(t, deliveries(t, router, okSub.ID, ""))
+	assert.Empty(t, deliveries(t, router, brokenSub.ID, ""))
+	assert.Len(t, deliveries(t, router, paused.ID, "?state=pending"), 1, "pending deliveries are kept")
+}
+
+func TestInternalReceiversAreRefused(t *testing.T) {
+	db := openDB(t)
+	wh := handlers.NewWebhookHandler(db, webhook.Policy{})
+	router := mux.NewRouter()
+	router.HandleFunc("/api/webhooks", wh.CreateWebhook).Methods("POST")
+	router.HandleFunc("/api/webhooks/{id:[^/:]+}", wh.UpdateWebhook).Methods("PUT")
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:10.0.0.1]/hook",
+		"http://10.1.2.3/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://0.0.0.0/hook",
+		"http://[fd00::1]/hook",
+		"http://[64:ff9b::a00:1]/hook",
+	} {
+		rr := do(t, router, "POST", "/api/webhooks", map[string]any{"url": url})
+		assert.Equal(t, http.StatusBadRequest, rr.Code, url)
+		assert.Contains(t, rr.Body.String(), "internal address", url)
+	}
+
+	allowed := webhook.Policy{AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}}
+	assert.True(t, allowed.Allows(netip.MustParseAddr("10.1.2.3")))
+	assert.False(t, allowed.Allows(netip.MustParseAddr("10.2.0.1")))
+	assert.True(t, allowed.Allows(netip.MustParseAddr("93.184.215.14")))
+	assert.True(t, allowed.Allows(netip.MustParseAddr("2606:4700::1111")))
+
+	// An allowed subscription cannot be moved to an internal address
+	sub := createWebhook(t, newRouter(db), map[string]any{"url": "http://127.0.0.1:9/hook"})
+	rr := do(t, router, "PUT", "/api/webhooks/"+sub.ID, map[string]any{"url": "http://127.0.0.1:8080/hook"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// The dispatcher checks the address it connects to, whatever the URL
+	// resolved to when the subscription was saved
+	rc := newReceiver(t, "secret")
+	sub = createWebhook(t, newRouter(db), map[string]any{"url": rc.URL, "secret": rc.secret})
+	require.Equal(t, http.StatusCreated, do(t, newRouter(db), "POST", "/api/items", map[string]any{"name": "Widget", "value": 1}).Code)
+	cfg := testConfig
+	cfg.Policy = webhook.Policy{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		webhook.NewDispatcher(db, cfg).Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return len(deliveries(t, newRouter(db), sub.ID, "?state=dead")) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	d := deliveries(t, newRouter(db), sub.ID, "?state=dead")[0]
+	assert.Contains(t, d.LastError, "is internal")
+	assert.Empty(t, rc.received())
+}
+
+func jsonNumber(n int64) string {
+	data, _ := json.Marshal(n)
+	return string(data)
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	header := webhook.Sign("secret", time.Now(), body)
+	assert.NoError(t, webhook.Verify("secret", header, body, time.Minute))
+	assert.ErrorIs(t, webhook.Verify("other", header, body, time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("secret", header, []byte(`{"id":"2"}`), time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("secret", "garbage", body, time.Minute), webhook.ErrInvalidSignature)
+
+	old := webhook.Sign("secret", time.Now().Add(-time.Hour), body)
+	assert.ErrorIs(t, webhook.Verify("secret", old, body, time.Minute), webhook.ErrExpired)
+	assert.NoError(t, webhook.Verify("secret", old, body, 0))
+	assert.True(t, strings.HasPrefix(header, "t="))
+}
+
+func TestWebhookService(t *testing.T) {
+	db := openDB(t)
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpclib.NewServer()
+	pb.RegisterWebhookServiceServer(s, webhook.NewServer(db, testPolicy))
+	pb.RegisterItemServiceServer(s, grpcserver.NewItemServer(db, money.Rules{}))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpclib.NewClient("passthrough:///bufnet",
+		grpclib.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := pb.NewWebhookServiceClient(conn)
+	ctx := context.Background()
+
+	rc := newReceiver(t, "grpc-secret")
+	sub, err := client.CreateWebhook(ctx, &pb.CreateWebhookRequest{Url: rc.URL, Secret: rc.secret, Events: []string{webhook.ItemCreated}})
+	require.NoError(t, err)
+	assert.Equal(t, rc.secret, sub.Secret)
+
+	_, err = client.CreateWebhook(ctx, &pb.CreateWebhookRequest{Url: "not a url"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = pb.NewItemServiceClient(conn).CreateItem(ctx, &pb.CreateItemRequest{Name: "Widget", Value: &decimal.Decimal{Value: "1"}})
+	require.NoError(t, err)
+	dispatch(t, db)
+	rc.waitFor(t, 1)
+
+	var list *pb.ListDeliveriesResponse
+	require.Eventually(t, func() bool {
+		list, err = client.ListDeliveries(ctx, &pb.ListDeliveriesRequest{WebhookId: sub.Id, State: pb.Delivery_STATE_DELIVERED})
+		require.NoError(t, err)
+		return len(list.Deliveries) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	d := list.Deliveries[0]
+	assert.Equal(t, webhook.ItemCreated, d.EventType)
+	assert.Equal(t, "Widget", d.Payload.Fields["data"].GetStructValue().Fields["name"].GetStringValue())
+
+	replayed, err := client.ReplayDelivery(ctx, &pb.ReplayDeliveryRequest{WebhookId: sub.Id, DeliveryId: d.Id})
+	require.NoError(t, err)
+	assert.Equal(t, pb.Delivery_STATE_PENDING, replayed.State)
+	rc.waitFor(t, 2)
+
+	n, err := client.ReplayDeadDeliveries(ctx, &pb.ReplayDeadDeliveriesRequest{WebhookId: sub.Id})
+	require.NoError(t, err)
+	assert.Zero(t, n.Replayed)
+
+	_, err = client.DeleteWebhook(ctx, &pb.DeleteWebhookRequest{Id: sub.Id})
+	require.NoError(t, err)
+	_, err = client.GetWebhook(ctx, &pb.GetWebhookRequest{Id: sub.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
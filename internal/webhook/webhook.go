@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/angel/go-api-sqlite/internal/apierror"
+	"github.com/angel/go-api-sqlite/internal/database"
+	"github.com/google/uuid"
+)
+
+// Event types sent to subscribers
+const (
+	ItemCreated = "item.created"
+	ItemUpdated = "item.updated"
+	ItemDeleted = "item.deleted"
+)
+
+// EventTypes lists every event type, in the order they are documented
+var EventTypes = []string{ItemCreated, ItemUpdated, ItemDeleted}
+
+// Subscription is a URL that receives events. Events lists the event types
+// it receives; empty means all of them. Secret signs the deliveries and is
+// only returned when the subscription is created.
+type Subscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Params are the settable fields of a subscription. A new subscription
+// without a secret gets a random one; an update without one keeps it. Active
+// defaults to true and is kept by updates that leave it unset.
+type Params struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+	Active *bool    `json:"active"`
+}
+
+// validate checks p, resolving the URL host to check its addresses against
+// policy. Errors are *apierror.Error values.
+func (p Params) validate(ctx context.Context, policy Policy) error {
+	var violations []apierror.FieldViolation
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		violations = append(violations, apierror.FieldViolation{Field: "url", Description: "url must be an absolute http or https URL"})
+	} else if err := policy.checkHost(ctx, u.Hostname()); err != nil {
+		violations = append(violations, apierror.FieldViolation{Field: "url", Description: err.Error()})
+	}
+	for _, e := range p.Events {
+		if !slices.Contains(EventTypes, e) {
+			violations = append(violations, apierror.FieldViolation{Field: "events",
+				Description: "unknown event " + e + "; must be one of " + strings.Join(EventTypes, ", ")})
+		}
+	}
+	if len(violations) > 0 {
+		return apierror.InvalidArgument("invalid webhook", violations...)
+	}
+	return nil
+}
+
+const subscriptionColumns = "id, url, events, active, created_at, updated_at"
+
+func scanSubscription(row interface{ Scan(...any) error }) (*Subscription, error) {
+	var s Subscription
+	var events string
+	if err := row.Scan(&s.ID, &s.URL, &events, &s.Active, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	s.Events = splitEvents(events)
+	return &s, nil
+}
+
+func splitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+	return strings.Split(events, ",")
+}
+
+// joinEvents stores event types sorted and without duplicates
+func joinEvents(events []string) string {
+	events = slices.Clone(events)
+	slices.Sort(events)
+	return strings.Join(slices.Compact(events), ",")
+}
+
+// Create adds a subscription and returns it with its secret. The URL must
+// resolve to addresses policy allows. Errors are *apierror.Error values.
+func Create(ctx context.Context, db *sql.DB, policy Policy, p Params) (*Subscription, error) {
+	if err := p.validate(ctx, policy); err != nil {
+		return nil, err
+	}
+	secret := p.Secret
+	if secret == "" {
+		b := make([]byte, 32)
+		rand.Read(b)
+		secret = hex.EncodeToString(b)
+	}
+	active := p.Active == nil || *p.Active
+	id := uuid.New().String()
+	now := time.Now().UTC()
+
+	err := database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO webhook_subscriptions (id, url, events, secret, active, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, id, p.URL, joinEvents(p.Events), secret, active, now, now)
+		return err
+	})
+	if err != nil {
+		return nil, apierror.Internal(err, "creating webhook")
+	}
+	sub, err := Get(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = secret
+	return sub, nil
+}
+
+// Get returns a subscription without its secret. Errors are *apierror.Error
+// values.
+func Get(ctx context.Context, db *sql.DB, id string) (*Subscription, error) {
+	sub, err := scanSubscription(db.QueryRowContext(ctx,
+		"SELECT "+subscriptionColumns+" FROM webhook_subscriptions WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, apierror.NotFound("webhook", id)
+	}
+	if err != nil {
+		return nil, apierror.Internal(err, "reading webhook")
+	}
+	return sub, nil
+}
+
+// List returns every subscription, oldest first, without secrets
+func List(ctx context.Context, db *sql.DB) ([]*Subscription, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+subscriptionColumns+" FROM webhook_subscriptions ORDER BY created_at, id")
+	if err != nil {
+		return nil, apierror.Internal(err, "listing webhooks")
+	}
+	defer rows.Close()
+
+	subs := []*Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, apierror.Internal(err, "scanning webhook")
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apierror.Internal(err, "listing webhooks")
+	}
+	return subs, nil
+}
+
+// Update replaces the URL and events of a subscription, and its secret and
+// active flag when given. Pending deliveries go to the new URL, which must
+// resolve to addresses policy allows. Errors are *apierror.Error values.
+func Update(ctx context.Context, db *sql.DB, policy Policy, id string, p Params) (*Subscription, error) {
+	if err := p.validate(ctx, policy); err != nil {
+		return nil, err
+	}
+	err := database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE webhook_subscriptions SET url = ?, events = ?,
+			secret = CASE WHEN ? = '' THEN secret ELSE ? END, active = COALESCE(?, active), updated_at = ?
+			WHERE id = ?`,
+			p.URL, joinEvents(p.Events), p.Secret, p.Secret, p.Active, time.Now().UTC(), id)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return apierror.NotFound("webhook", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, apierror.From(err, "updating webhook")
+	}
+	return Get(ctx, db, id)
+}
+
+// Delete removes a subscription and its deliveries. Errors are
+// *apierror.Error values.
+func Delete(ctx context.Context, db *sql.DB, id string) error {
+	err := database.WithTx(ctx, db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return apierror.NotFound("webhook", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return apierror.From(err, "deleting webhook")
+	}
+	return nil
+}
@@ -7,8 +7,11 @@
 package proto
 
 import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	decimal "google.golang.org/genproto/googleapis/type/decimal"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
@@ -23,11 +26,28 @@ const (
 )
 
 type Item struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Value         float64                `protobuf:"fixed64,3,opt,name=value,proto3" json:"value,omitempty"`
-	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// value as a double, for clients that predate value
+	//
+	// Deprecated: Marked as deprecated in proto/item.proto.
+	LegacyValue float64                `protobuf:"fixed64,3,opt,name=legacy_value,json=legacyValue,proto3" json:"legacy_value,omitempty"`
+	CreatedAt   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Description string                 `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`
+	// Lowercase, sorted and unique
+	Tags     []string `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	Category string   `protobuf:"bytes,7,opt,name=category,proto3" json:"category,omitempty"`
+	// ISO 4217 code such as USD, or empty
+	Currency string `protobuf:"bytes,8,opt,name=currency,proto3" json:"currency,omitempty"`
+	// Set by the server on every change
+	UpdatedAt *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	// Exact decimal such as "9.50", rounded to the minor units of currency
+	Value *decimal.Decimal `protobuf:"bytes,10,opt,name=value,proto3" json:"value,omitempty"`
+	// Free-form attributes
+	Metadata *structpb.Struct `protobuf:"bytes,11,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// The item this one belongs under, or empty for a root
+	ParentId      string `protobuf:"bytes,12,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -76,9 +96,10 @@ func (x *Item) GetName() string {
 	return ""
 }
 
-func (x *Item) GetValue() float64 {
+// Deprecated: Marked as deprecated in proto/item.proto.
+func (x *Item) GetLegacyValue() float64 {
 	if x != nil {
-		return x.Value
+		return x.LegacyValue
 	}
 	return 0
 }
@@ -90,10 +111,77 @@ func (x *Item) GetCreatedAt() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *Item) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Item) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Item) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Item) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Item) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Item) GetValue() *decimal.Decimal {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Item) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Item) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
 type CreateItemRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Value         float64                `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Used when value is unset
+	//
+	// Deprecated: Marked as deprecated in proto/item.proto.
+	LegacyValue float64  `protobuf:"fixed64,2,opt,name=legacy_value,json=legacyValue,proto3" json:"legacy_value,omitempty"`
+	Description string   `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Tags        []string `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	Category    string   `protobuf:"bytes,5,opt,name=category,proto3" json:"category,omitempty"`
+	Currency    string   `protobuf:"bytes,6,opt,name=currency,proto3" json:"currency,omitempty"`
+	// Decimal such as "9.5" or "1e3"
+	Value         *decimal.Decimal `protobuf:"bytes,7,opt,name=value,proto3" json:"value,omitempty"`
+	Metadata      *structpb.Struct `protobuf:"bytes,8,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	ParentId      string           `protobuf:"bytes,9,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -135,13 +223,63 @@ func (x *CreateItemRequest) GetName() string {
 	return ""
 }
 
-func (x *CreateItemRequest) GetValue() float64 {
+// Deprecated: Marked as deprecated in proto/item.proto.
+func (x *CreateItemRequest) GetLegacyValue() float64 {
 	if x != nil {
-		return x.Value
+		return x.LegacyValue
 	}
 	return 0
 }
 
+func (x *CreateItemRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *CreateItemRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *CreateItemRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *CreateItemRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *CreateItemRequest) GetValue() *decimal.Decimal {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *CreateItemRequest) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *CreateItemRequest) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
 type GetItemRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -189,8 +327,17 @@ func (x *GetItemRequest) GetId() string {
 type ListItemsRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// For future pagination
-	PageSize      int32  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
-	PageToken     string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	PageSize  int32  `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// AIP-160 style filter, e.g. value > 10 AND name ~ "widget*"
+	Filter string `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`
+	// Tags the items must all have
+	Tags []string `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	// Category the items must be in
+	Category string `protobuf:"bytes,5,opt,name=category,proto3" json:"category,omitempty"`
+	// Metadata values the items must have, keyed by dotted path, e.g. color
+	// or size.width
+	Metadata      map[string]string `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -239,6 +386,34 @@ func (x *ListItemsRequest) GetPageToken() string {
 	return ""
 }
 
+func (x *ListItemsRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *ListItemsRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ListItemsRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *ListItemsRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
 type ListItemsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Items         []*Item                `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
@@ -291,29 +466,30 @@ func (x *ListItemsResponse) GetNextPageToken() string {
 	return ""
 }
 
-type UpdateItemRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Value         float64                `protobuf:"fixed64,3,opt,name=value,proto3" json:"value,omitempty"`
+type SearchItemsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// FTS5 query: terms, "phrases", prefix* and AND/OR/NOT
+	Query         string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	PageSize      int32  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UpdateItemRequest) Reset() {
-	*x = UpdateItemRequest{}
+func (x *SearchItemsRequest) Reset() {
+	*x = SearchItemsRequest{}
 	mi := &file_proto_item_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UpdateItemRequest) String() string {
+func (x *SearchItemsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UpdateItemRequest) ProtoMessage() {}
+func (*SearchItemsRequest) ProtoMessage() {}
 
-func (x *UpdateItemRequest) ProtoReflect() protoreflect.Message {
+func (x *SearchItemsRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_item_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -325,53 +501,57 @@ func (x *UpdateItemRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UpdateItemRequest.ProtoReflect.Descriptor instead.
-func (*UpdateItemRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use SearchItemsRequest.ProtoReflect.Descriptor instead.
+func (*SearchItemsRequest) Descriptor() ([]byte, []int) {
 	return file_proto_item_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *UpdateItemRequest) GetId() string {
+func (x *SearchItemsRequest) GetQuery() string {
 	if x != nil {
-		return x.Id
+		return x.Query
 	}
 	return ""
 }
 
-func (x *UpdateItemRequest) GetName() string {
+func (x *SearchItemsRequest) GetPageSize() int32 {
 	if x != nil {
-		return x.Name
+		return x.PageSize
 	}
-	return ""
+	return 0
 }
 
-func (x *UpdateItemRequest) GetValue() float64 {
+func (x *SearchItemsRequest) GetPageToken() string {
 	if x != nil {
-		return x.Value
+		return x.PageToken
 	}
-	return 0
+	return ""
 }
 
-type DeleteItemRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+type SearchResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Item  *Item                  `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	// bm25 relevance; higher is a better match
+	Score float64 `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	// HTML-escaped name with matched terms wrapped in <mark>
+	Snippet       string `protobuf:"bytes,3,opt,name=snippet,proto3" json:"snippet,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DeleteItemRequest) Reset() {
-	*x = DeleteItemRequest{}
+func (x *SearchResult) Reset() {
+	*x = SearchResult{}
 	mi := &file_proto_item_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DeleteItemRequest) String() string {
+func (x *SearchResult) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DeleteItemRequest) ProtoMessage() {}
+func (*SearchResult) ProtoMessage() {}
 
-func (x *DeleteItemRequest) ProtoReflect() protoreflect.Message {
+func (x *SearchResult) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_item_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -383,39 +563,54 @@ func (x *DeleteItemRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DeleteItemRequest.ProtoReflect.Descriptor instead.
-func (*DeleteItemRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use SearchResult.ProtoReflect.Descriptor instead.
+func (*SearchResult) Descriptor() ([]byte, []int) {
 	return file_proto_item_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *DeleteItemRequest) GetId() string {
+func (x *SearchResult) GetItem() *Item {
 	if x != nil {
-		return x.Id
+		return x.Item
+	}
+	return nil
+}
+
+func (x *SearchResult) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *SearchResult) GetSnippet() string {
+	if x != nil {
+		return x.Snippet
 	}
 	return ""
 }
 
-type DeleteItemResponse struct {
+type SearchItemsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Results       []*SearchResult        `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *DeleteItemResponse) Reset() {
-	*x = DeleteItemResponse{}
+func (x *SearchItemsResponse) Reset() {
+	*x = SearchItemsResponse{}
 	mi := &file_proto_item_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *DeleteItemResponse) String() string {
+func (x *SearchItemsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DeleteItemResponse) ProtoMessage() {}
+func (*SearchItemsResponse) ProtoMessage() {}
 
-func (x *DeleteItemResponse) ProtoReflect() protoreflect.Message {
+func (x *SearchItemsResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_proto_item_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -427,58 +622,852 @@ func (x *DeleteItemResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DeleteItemResponse.ProtoReflect.Descriptor instead.
-func (*DeleteItemResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use SearchItemsResponse.ProtoReflect.Descriptor instead.
+func (*SearchItemsResponse) Descriptor() ([]byte, []int) {
 	return file_proto_item_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *DeleteItemResponse) GetSuccess() bool {
+func (x *SearchItemsResponse) GetResults() []*SearchResult {
 	if x != nil {
-		return x.Success
+		return x.Results
 	}
-	return false
+	return nil
 }
 
-var File_proto_item_proto protoreflect.FileDescriptor
+func (x *SearchItemsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
 
-const file_proto_item_proto_rawDesc = "" +
-	"\n" +
-	"\x10proto/item.proto\x12\x05proto\x1a\x1fgoogle/protobuf/timestamp.proto\"{\n" +
-	"\x04Item\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
-	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
-	"\x05value\x18\x03 \x01(\x01R\x05value\x129\n" +
-	"\n" +
-	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"=\n" +
-	"\x11CreateItemRequest\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\x01R\x05value\" \n" +
-	"\x0eGetItemRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\"N\n" +
-	"\x10ListItemsRequest\x12\x1b\n" +
-	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
-	"\n" +
-	"page_token\x18\x02 \x01(\tR\tpageToken\"^\n" +
-	"\x11ListItemsResponse\x12!\n" +
-	"\x05items\x18\x01 \x03(\v2\v.proto.ItemR\x05items\x12&\n" +
-	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"M\n" +
+type GetItemStatsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// AIP-160 style filter selecting the items to aggregate
+	Filter string `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	// Empty, hour, day, week, month, name_prefix, category or currency
+	GroupBy string `protobuf:"bytes,2,opt,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	// Name characters used by name_prefix, 1 when unset
+	PrefixLength int32 `protobuf:"varint,3,opt,name=prefix_length,json=prefixLength,proto3" json:"prefix_length,omitempty"`
+	// Percentiles between 0 and 100; 50, 90, 95 and 99 when empty
+	Percentiles   []float64 `protobuf:"fixed64,4,rep,packed,name=percentiles,proto3" json:"percentiles,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetItemStatsRequest) Reset() {
+	*x = GetItemStatsRequest{}
+	mi := &file_proto_item_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetItemStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetItemStatsRequest) ProtoMessage() {}
+
+func (x *GetItemStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetItemStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetItemStatsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetItemStatsRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *GetItemStatsRequest) GetGroupBy() string {
+	if x != nil {
+		return x.GroupBy
+	}
+	return ""
+}
+
+func (x *GetItemStatsRequest) GetPrefixLength() int32 {
+	if x != nil {
+		return x.PrefixLength
+	}
+	return 0
+}
+
+func (x *GetItemStatsRequest) GetPercentiles() []float64 {
+	if x != nil {
+		return x.Percentiles
+	}
+	return nil
+}
+
+type StatsSummary struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Count int64                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	// Deprecated: Marked as deprecated in proto/item.proto.
+	LegacySum float64 `protobuf:"fixed64,2,opt,name=legacy_sum,json=legacySum,proto3" json:"legacy_sum,omitempty"`
+	// Deprecated: Marked as deprecated in proto/item.proto.
+	LegacyMin float64 `protobuf:"fixed64,3,opt,name=legacy_min,json=legacyMin,proto3" json:"legacy_min,omitempty"`
+	// Deprecated: Marked as deprecated in proto/item.proto.
+	LegacyMax float64 `protobuf:"fixed64,4,opt,name=legacy_max,json=legacyMax,proto3" json:"legacy_max,omitempty"`
+	// Approximate, as are the percentiles
+	Mean float64 `protobuf:"fixed64,5,opt,name=mean,proto3" json:"mean,omitempty"`
+	// Keyed by name, e.g. p50 or p99.9
+	Percentiles map[string]float64 `protobuf:"bytes,6,rep,name=percentiles,proto3" json:"percentiles,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	// Exact decimals, unset when the currencies are mixed; min and max are
+	// also unset when count is 0
+	Sum *decimal.Decimal `protobuf:"bytes,7,opt,name=sum,proto3" json:"sum,omitempty"`
+	Min *decimal.Decimal `protobuf:"bytes,8,opt,name=min,proto3" json:"min,omitempty"`
+	Max *decimal.Decimal `protobuf:"bytes,9,opt,name=max,proto3" json:"max,omitempty"`
+	// The currency of the items when they all have the same one
+	Currency string `protobuf:"bytes,10,opt,name=currency,proto3" json:"currency,omitempty"`
+	// Set when the items have more than one currency. Values in different
+	// currencies cannot be added or compared, so only count is reported.
+	MixedCurrencies bool `protobuf:"varint,11,opt,name=mixed_currencies,json=mixedCurrencies,proto3" json:"mixed_currencies,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *StatsSummary) Reset() {
+	*x = StatsSummary{}
+	mi := &file_proto_item_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsSummary) ProtoMessage() {}
+
+func (x *StatsSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsSummary.ProtoReflect.Descriptor instead.
+func (*StatsSummary) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StatsSummary) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// Deprecated: Marked as deprecated in proto/item.proto.
+func (x *StatsSummary) GetLegacySum() float64 {
+	if x != nil {
+		return x.LegacySum
+	}
+	return 0
+}
+
+// Deprecated: Marked as deprecated in proto/item.proto.
+func (x *StatsSummary) GetLegacyMin() float64 {
+	if x != nil {
+		return x.LegacyMin
+	}
+	return 0
+}
+
+// Deprecated: Marked as deprecated in proto/item.proto.
+func (x *StatsSummary) GetLegacyMax() float64 {
+	if x != nil {
+		return x.LegacyMax
+	}
+	return 0
+}
+
+func (x *StatsSummary) GetMean() float64 {
+	if x != nil {
+		return x.Mean
+	}
+	return 0
+}
+
+func (x *StatsSummary) GetPercentiles() map[string]float64 {
+	if x != nil {
+		return x.Percentiles
+	}
+	return nil
+}
+
+func (x *StatsSummary) GetSum() *decimal.Decimal {
+	if x != nil {
+		return x.Sum
+	}
+	return nil
+}
+
+func (x *StatsSummary) GetMin() *decimal.Decimal {
+	if x != nil {
+		return x.Min
+	}
+	return nil
+}
+
+func (x *StatsSummary) GetMax() *decimal.Decimal {
+	if x != nil {
+		return x.Max
+	}
+	return nil
+}
+
+func (x *StatsSummary) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *StatsSummary) GetMixedCurrencies() bool {
+	if x != nil {
+		return x.MixedCurrencies
+	}
+	return false
+}
+
+type StatsBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Summary       *StatsSummary          `protobuf:"bytes,2,opt,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsBucket) Reset() {
+	*x = StatsBucket{}
+	mi := &file_proto_item_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsBucket) ProtoMessage() {}
+
+func (x *StatsBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsBucket.ProtoReflect.Descriptor instead.
+func (*StatsBucket) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StatsBucket) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *StatsBucket) GetSummary() *StatsSummary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+type ItemStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Summary       *StatsSummary          `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
+	GroupBy       string                 `protobuf:"bytes,2,opt,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	Buckets       []*StatsBucket         `protobuf:"bytes,3,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ItemStats) Reset() {
+	*x = ItemStats{}
+	mi := &file_proto_item_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ItemStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ItemStats) ProtoMessage() {}
+
+func (x *ItemStats) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ItemStats.ProtoReflect.Descriptor instead.
+func (*ItemStats) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ItemStats) GetSummary() *StatsSummary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+func (x *ItemStats) GetGroupBy() string {
+	if x != nil {
+		return x.GroupBy
+	}
+	return ""
+}
+
+func (x *ItemStats) GetBuckets() []*StatsBucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+// Replaces every field of the item; fields left out are cleared
+type UpdateItemRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Used when value is unset
+	//
+	// Deprecated: Marked as deprecated in proto/item.proto.
+	LegacyValue float64  `protobuf:"fixed64,3,opt,name=legacy_value,json=legacyValue,proto3" json:"legacy_value,omitempty"`
+	Description string   `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	Tags        []string `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty"`
+	Category    string   `protobuf:"bytes,6,opt,name=category,proto3" json:"category,omitempty"`
+	Currency    string   `protobuf:"bytes,7,opt,name=currency,proto3" json:"currency,omitempty"`
+	// Decimal such as "9.5" or "1e3"
+	Value         *decimal.Decimal `protobuf:"bytes,8,opt,name=value,proto3" json:"value,omitempty"`
+	Metadata      *structpb.Struct `protobuf:"bytes,9,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	ParentId      string           `protobuf:"bytes,10,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateItemRequest) Reset() {
+	*x = UpdateItemRequest{}
+	mi := &file_proto_item_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateItemRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateItemRequest) ProtoMessage() {}
+
+func (x *UpdateItemRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateItemRequest.ProtoReflect.Descriptor instead.
+func (*UpdateItemRequest) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UpdateItemRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateItemRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// Deprecated: Marked as deprecated in proto/item.proto.
+func (x *UpdateItemRequest) GetLegacyValue() float64 {
+	if x != nil {
+		return x.LegacyValue
+	}
+	return 0
+}
+
+func (x *UpdateItemRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *UpdateItemRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *UpdateItemRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *UpdateItemRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *UpdateItemRequest) GetValue() *decimal.Decimal {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *UpdateItemRequest) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *UpdateItemRequest) GetParentId() string {
+	if x != nil {
+		return x.ParentId
+	}
+	return ""
+}
+
+type DeleteItemRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteItemRequest) Reset() {
+	*x = DeleteItemRequest{}
+	mi := &file_proto_item_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteItemRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteItemRequest) ProtoMessage() {}
+
+func (x *DeleteItemRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteItemRequest.ProtoReflect.Descriptor instead.
+func (*DeleteItemRequest) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeleteItemRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteItemResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteItemResponse) Reset() {
+	*x = DeleteItemResponse{}
+	mi := &file_proto_item_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteItemResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteItemResponse) ProtoMessage() {}
+
+func (x *DeleteItemResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteItemResponse.ProtoReflect.Descriptor instead.
+func (*DeleteItemResponse) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DeleteItemResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+type GetItemTreeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Levels of descendants to return, 1 when unset and at most 10
+	Depth         int32 `protobuf:"varint,2,opt,name=depth,proto3" json:"depth,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetItemTreeRequest) Reset() {
+	*x = GetItemTreeRequest{}
+	mi := &file_proto_item_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetItemTreeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetItemTreeRequest) ProtoMessage() {}
+
+func (x *GetItemTreeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetItemTreeRequest.ProtoReflect.Descriptor instead.
+func (*GetItemTreeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetItemTreeRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetItemTreeRequest) GetDepth() int32 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+type ItemTreeNode struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Item  *Item                  `protobuf:"bytes,1,opt,name=item,proto3" json:"item,omitempty"`
+	// Ordered by name
+	Children      []*ItemTreeNode `protobuf:"bytes,2,rep,name=children,proto3" json:"children,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ItemTreeNode) Reset() {
+	*x = ItemTreeNode{}
+	mi := &file_proto_item_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ItemTreeNode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ItemTreeNode) ProtoMessage() {}
+
+func (x *ItemTreeNode) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ItemTreeNode.ProtoReflect.Descriptor instead.
+func (*ItemTreeNode) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ItemTreeNode) GetItem() *Item {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+func (x *ItemTreeNode) GetChildren() []*ItemTreeNode {
+	if x != nil {
+		return x.Children
+	}
+	return nil
+}
+
+type ItemTree struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The parent first and the root last
+	Ancestors []*Item       `protobuf:"bytes,1,rep,name=ancestors,proto3" json:"ancestors,omitempty"`
+	Tree      *ItemTreeNode `protobuf:"bytes,2,opt,name=tree,proto3" json:"tree,omitempty"`
+	// Set when descendants past 1000 were left out
+	Truncated     bool `protobuf:"varint,3,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ItemTree) Reset() {
+	*x = ItemTree{}
+	mi := &file_proto_item_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ItemTree) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ItemTree) ProtoMessage() {}
+
+func (x *ItemTree) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_item_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ItemTree.ProtoReflect.Descriptor instead.
+func (*ItemTree) Descriptor() ([]byte, []int) {
+	return file_proto_item_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ItemTree) GetAncestors() []*Item {
+	if x != nil {
+		return x.Ancestors
+	}
+	return nil
+}
+
+func (x *ItemTree) GetTree() *ItemTreeNode {
+	if x != nil {
+		return x.Tree
+	}
+	return nil
+}
+
+func (x *ItemTree) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+var File_proto_item_proto protoreflect.FileDescriptor
+
+const file_proto_item_proto_rawDesc = "" +
+	"\n" +
+	"\x10proto/item.proto\x12\x05proto\x1a\x1cgoogle/api/annotations.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x19google/type/decimal.proto\"\xb3\x03\n" +
+	"\x04Item\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12%\n" +
+	"\flegacy_value\x18\x03 \x01(\x01B\x02\x18\x01R\vlegacyValue\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12 \n" +
+	"\vdescription\x18\x05 \x01(\tR\vdescription\x12\x12\n" +
+	"\x04tags\x18\x06 \x03(\tR\x04tags\x12\x1a\n" +
+	"\bcategory\x18\a \x01(\tR\bcategory\x12\x1a\n" +
+	"\bcurrency\x18\b \x01(\tR\bcurrency\x129\n" +
+	"\n" +
+	"updated_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12*\n" +
+	"\x05value\x18\n" +
+	" \x01(\v2\x14.google.type.DecimalR\x05value\x123\n" +
+	"\bmetadata\x18\v \x01(\v2\x17.google.protobuf.StructR\bmetadata\x12\x1b\n" +
+	"\tparent_id\x18\f \x01(\tR\bparentId\"\xba\x02\n" +
+	"\x11CreateItemRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12%\n" +
+	"\flegacy_value\x18\x02 \x01(\x01B\x02\x18\x01R\vlegacyValue\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x12\n" +
+	"\x04tags\x18\x04 \x03(\tR\x04tags\x12\x1a\n" +
+	"\bcategory\x18\x05 \x01(\tR\bcategory\x12\x1a\n" +
+	"\bcurrency\x18\x06 \x01(\tR\bcurrency\x12*\n" +
+	"\x05value\x18\a \x01(\v2\x14.google.type.DecimalR\x05value\x123\n" +
+	"\bmetadata\x18\b \x01(\v2\x17.google.protobuf.StructR\bmetadata\x12\x1b\n" +
+	"\tparent_id\x18\t \x01(\tR\bparentId\" \n" +
+	"\x0eGetItemRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x96\x02\n" +
+	"\x10ListItemsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12\x16\n" +
+	"\x06filter\x18\x03 \x01(\tR\x06filter\x12\x12\n" +
+	"\x04tags\x18\x04 \x03(\tR\x04tags\x12\x1a\n" +
+	"\bcategory\x18\x05 \x01(\tR\bcategory\x12A\n" +
+	"\bmetadata\x18\x06 \x03(\v2%.proto.ListItemsRequest.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"^\n" +
+	"\x11ListItemsResponse\x12!\n" +
+	"\x05items\x18\x01 \x03(\v2\v.proto.ItemR\x05items\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"f\n" +
+	"\x12SearchItemsRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x03 \x01(\tR\tpageToken\"_\n" +
+	"\fSearchResult\x12\x1f\n" +
+	"\x04item\x18\x01 \x01(\v2\v.proto.ItemR\x04item\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x01R\x05score\x12\x18\n" +
+	"\asnippet\x18\x03 \x01(\tR\asnippet\"l\n" +
+	"\x13SearchItemsResponse\x12-\n" +
+	"\aresults\x18\x01 \x03(\v2\x13.proto.SearchResultR\aresults\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\x8f\x01\n" +
+	"\x13GetItemStatsRequest\x12\x16\n" +
+	"\x06filter\x18\x01 \x01(\tR\x06filter\x12\x19\n" +
+	"\bgroup_by\x18\x02 \x01(\tR\agroupBy\x12#\n" +
+	"\rprefix_length\x18\x03 \x01(\x05R\fprefixLength\x12 \n" +
+	"\vpercentiles\x18\x04 \x03(\x01R\vpercentiles\"\xe8\x03\n" +
+	"\fStatsSummary\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x03R\x05count\x12!\n" +
+	"\n" +
+	"legacy_sum\x18\x02 \x01(\x01B\x02\x18\x01R\tlegacySum\x12!\n" +
+	"\n" +
+	"legacy_min\x18\x03 \x01(\x01B\x02\x18\x01R\tlegacyMin\x12!\n" +
+	"\n" +
+	"legacy_max\x18\x04 \x01(\x01B\x02\x18\x01R\tlegacyMax\x12\x12\n" +
+	"\x04mean\x18\x05 \x01(\x01R\x04mean\x12F\n" +
+	"\vpercentiles\x18\x06 \x03(\v2$.proto.StatsSummary.PercentilesEntryR\vpercentiles\x12&\n" +
+	"\x03sum\x18\a \x01(\v2\x14.google.type.DecimalR\x03sum\x12&\n" +
+	"\x03min\x18\b \x01(\v2\x14.google.type.DecimalR\x03min\x12&\n" +
+	"\x03max\x18\t \x01(\v2\x14.google.type.DecimalR\x03max\x12\x1a\n" +
+	"\bcurrency\x18\n" +
+	" \x01(\tR\bcurrency\x12)\n" +
+	"\x10mixed_currencies\x18\v \x01(\bR\x0fmixedCurrencies\x1a>\n" +
+	"\x10PercentilesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"N\n" +
+	"\vStatsBucket\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12-\n" +
+	"\asummary\x18\x02 \x01(\v2\x13.proto.StatsSummaryR\asummary\"\x83\x01\n" +
+	"\tItemStats\x12-\n" +
+	"\asummary\x18\x01 \x01(\v2\x13.proto.StatsSummaryR\asummary\x12\x19\n" +
+	"\bgroup_by\x18\x02 \x01(\tR\agroupBy\x12,\n" +
+	"\abuckets\x18\x03 \x03(\v2\x12.proto.StatsBucketR\abuckets\"\xca\x02\n" +
 	"\x11UpdateItemRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
-	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
-	"\x05value\x18\x03 \x01(\x01R\x05value\"#\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12%\n" +
+	"\flegacy_value\x18\x03 \x01(\x01B\x02\x18\x01R\vlegacyValue\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12\x12\n" +
+	"\x04tags\x18\x05 \x03(\tR\x04tags\x12\x1a\n" +
+	"\bcategory\x18\x06 \x01(\tR\bcategory\x12\x1a\n" +
+	"\bcurrency\x18\a \x01(\tR\bcurrency\x12*\n" +
+	"\x05value\x18\b \x01(\v2\x14.google.type.DecimalR\x05value\x123\n" +
+	"\bmetadata\x18\t \x01(\v2\x17.google.protobuf.StructR\bmetadata\x12\x1b\n" +
+	"\tparent_id\x18\n" +
+	" \x01(\tR\bparentId\"#\n" +
 	"\x11DeleteItemRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\".\n" +
 	"\x12DeleteItemResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess2\xb3\x02\n" +
-	"\vItemService\x125\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\":\n" +
+	"\x12GetItemTreeRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05depth\x18\x02 \x01(\x05R\x05depth\"`\n" +
+	"\fItemTreeNode\x12\x1f\n" +
+	"\x04item\x18\x01 \x01(\v2\v.proto.ItemR\x04item\x12/\n" +
+	"\bchildren\x18\x02 \x03(\v2\x13.proto.ItemTreeNodeR\bchildren\"|\n" +
+	"\bItemTree\x12)\n" +
+	"\tancestors\x18\x01 \x03(\v2\v.proto.ItemR\tancestors\x12'\n" +
+	"\x04tree\x18\x02 \x01(\v2\x13.proto.ItemTreeNodeR\x04tree\x12\x1c\n" +
+	"\ttruncated\x18\x03 \x01(\bR\ttruncated2\xac\x05\n" +
+	"\vItemService\x12I\n" +
 	"\n" +
-	"CreateItem\x12\x18.proto.CreateItemRequest\x1a\v.proto.Item\"\x00\x12/\n" +
-	"\aGetItem\x12\x15.proto.GetItemRequest\x1a\v.proto.Item\"\x00\x12@\n" +
-	"\tListItems\x12\x17.proto.ListItemsRequest\x1a\x18.proto.ListItemsResponse\"\x00\x125\n" +
+	"CreateItem\x12\x18.proto.CreateItemRequest\x1a\v.proto.Item\"\x14\x82\xd3\xe4\x93\x02\x0e:\x01*\"\t/v1/items\x12E\n" +
+	"\aGetItem\x12\x15.proto.GetItemRequest\x1a\v.proto.Item\"\x16\x82\xd3\xe4\x93\x02\x10\x12\x0e/v1/items/{id}\x12Q\n" +
+	"\tListItems\x12\x17.proto.ListItemsRequest\x1a\x18.proto.ListItemsResponse\"\x11\x82\xd3\xe4\x93\x02\v\x12\t/v1/items\x12^\n" +
+	"\vSearchItems\x12\x19.proto.SearchItemsRequest\x1a\x1a.proto.SearchItemsResponse\"\x18\x82\xd3\xe4\x93\x02\x12\x12\x10/v1/items:search\x12U\n" +
+	"\fGetItemStats\x12\x1a.proto.GetItemStatsRequest\x1a\x10.proto.ItemStats\"\x17\x82\xd3\xe4\x93\x02\x11\x12\x0f/v1/items:stats\x12N\n" +
 	"\n" +
-	"UpdateItem\x12\x18.proto.UpdateItemRequest\x1a\v.proto.Item\"\x00\x12C\n" +
+	"UpdateItem\x12\x18.proto.UpdateItemRequest\x1a\v.proto.Item\"\x19\x82\xd3\xe4\x93\x02\x13:\x01*\x1a\x0e/v1/items/{id}\x12Y\n" +
 	"\n" +
-	"DeleteItem\x12\x18.proto.DeleteItemRequest\x1a\x19.proto.DeleteItemResponse\"\x00B&Z$github.com/angel/go-api-sqlite/protob\x06proto3"
+	"DeleteItem\x12\x18.proto.DeleteItemRequest\x1a\x19.proto.DeleteItemResponse\"\x16\x82\xd3\xe4\x93\x02\x10*\x0e/v1/items/{id}\x12V\n" +
+	"\vGetItemTree\x12\x19.proto.GetItemTreeRequest\x1a\x0f.proto.ItemTree\"\x1b\x82\xd3\xe4\x93\x02\x15\x12\x13/v1/items/{id}/treeB&Z$github.com/angel/go-api-sqlite/protob\x06proto3"
 
 var (
 	file_proto_item_proto_rawDescOnce sync.Once
@@ -492,36 +1481,77 @@ func file_proto_item_proto_rawDescGZIP() []byte {
 	return file_proto_item_proto_rawDescData
 }
 
-var file_proto_item_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_item_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
 var file_proto_item_proto_goTypes = []any{
 	(*Item)(nil),                  // 0: proto.Item
 	(*CreateItemRequest)(nil),     // 1: proto.CreateItemRequest
 	(*GetItemRequest)(nil),        // 2: proto.GetItemRequest
 	(*ListItemsRequest)(nil),      // 3: proto.ListItemsRequest
 	(*ListItemsResponse)(nil),     // 4: proto.ListItemsResponse
-	(*UpdateItemRequest)(nil),     // 5: proto.UpdateItemRequest
-	(*DeleteItemRequest)(nil),     // 6: proto.DeleteItemRequest
-	(*DeleteItemResponse)(nil),    // 7: proto.DeleteItemResponse
-	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+	(*SearchItemsRequest)(nil),    // 5: proto.SearchItemsRequest
+	(*SearchResult)(nil),          // 6: proto.SearchResult
+	(*SearchItemsResponse)(nil),   // 7: proto.SearchItemsResponse
+	(*GetItemStatsRequest)(nil),   // 8: proto.GetItemStatsRequest
+	(*StatsSummary)(nil),          // 9: proto.StatsSummary
+	(*StatsBucket)(nil),           // 10: proto.StatsBucket
+	(*ItemStats)(nil),             // 11: proto.ItemStats
+	(*UpdateItemRequest)(nil),     // 12: proto.UpdateItemRequest
+	(*DeleteItemRequest)(nil),     // 13: proto.DeleteItemRequest
+	(*DeleteItemResponse)(nil),    // 14: proto.DeleteItemResponse
+	(*GetItemTreeRequest)(nil),    // 15: proto.GetItemTreeRequest
+	(*ItemTreeNode)(nil),          // 16: proto.ItemTreeNode
+	(*ItemTree)(nil),              // 17: proto.ItemTree
+	nil,                           // 18: proto.ListItemsRequest.MetadataEntry
+	nil,                           // 19: proto.StatsSummary.PercentilesEntry
+	(*timestamppb.Timestamp)(nil), // 20: google.protobuf.Timestamp
+	(*decimal.Decimal)(nil),       // 21: google.type.Decimal
+	(*structpb.Struct)(nil),       // 22: google.protobuf.Struct
 }
 var file_proto_item_proto_depIdxs = []int32{
-	8, // 0: proto.Item.created_at:type_name -> google.protobuf.Timestamp
-	0, // 1: proto.ListItemsResponse.items:type_name -> proto.Item
-	1, // 2: proto.ItemService.CreateItem:input_type -> proto.CreateItemRequest
-	2, // 3: proto.ItemService.GetItem:input_type -> proto.GetItemRequest
-	3, // 4: proto.ItemService.ListItems:input_type -> proto.ListItemsRequest
-	5, // 5: proto.ItemService.UpdateItem:input_type -> proto.UpdateItemRequest
-	6, // 6: proto.ItemService.DeleteItem:input_type -> proto.DeleteItemRequest
-	0, // 7: proto.ItemService.CreateItem:output_type -> proto.Item
-	0, // 8: proto.ItemService.GetItem:output_type -> proto.Item
-	4, // 9: proto.ItemService.ListItems:output_type -> proto.ListItemsResponse
-	0, // 10: proto.ItemService.UpdateItem:output_type -> proto.Item
-	7, // 11: proto.ItemService.DeleteItem:output_type -> proto.DeleteItemResponse
-	7, // [7:12] is the sub-list for method output_type
-	2, // [2:7] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	20, // 0: proto.Item.created_at:type_name -> google.protobuf.Timestamp
+	20, // 1: proto.Item.updated_at:type_name -> google.protobuf.Timestamp
+	21, // 2: proto.Item.value:type_name -> google.type.Decimal
+	22, // 3: proto.Item.metadata:type_name -> google.protobuf.Struct
+	21, // 4: proto.CreateItemRequest.value:type_name -> google.type.Decimal
+	22, // 5: proto.CreateItemRequest.metadata:type_name -> google.protobuf.Struct
+	18, // 6: proto.ListItemsRequest.metadata:type_name -> proto.ListItemsRequest.MetadataEntry
+	0,  // 7: proto.ListItemsResponse.items:type_name -> proto.Item
+	0,  // 8: proto.SearchResult.item:type_name -> proto.Item
+	6,  // 9: proto.SearchItemsResponse.results:type_name -> proto.SearchResult
+	19, // 10: proto.StatsSummary.percentiles:type_name -> proto.StatsSummary.PercentilesEntry
+	21, // 11: proto.StatsSummary.sum:type_name -> google.type.Decimal
+	21, // 12: proto.StatsSummary.min:type_name -> google.type.Decimal
+	21, // 13: proto.StatsSummary.max:type_name -> google.type.Decimal
+	9,  // 14: proto.StatsBucket.summary:type_name -> proto.StatsSummary
+	9,  // 15: proto.ItemStats.summary:type_name -> proto.StatsSummary
+	10, // 16: proto.ItemStats.buckets:type_name -> proto.StatsBucket
+	21, // 17: proto.UpdateItemRequest.value:type_name -> google.type.Decimal
+	22, // 18: proto.UpdateItemRequest.metadata:type_name -> google.protobuf.Struct
+	0,  // 19: proto.ItemTreeNode.item:type_name -> proto.Item
+	16, // 20: proto.ItemTreeNode.children:type_name -> proto.ItemTreeNode
+	0,  // 21: proto.ItemTree.ancestors:type_name -> proto.Item
+	16, // 22: proto.ItemTree.tree:type_name -> proto.ItemTreeNode
+	1,  // 23: proto.ItemService.CreateItem:input_type -> proto.CreateItemRequest
+	2,  // 24: proto.ItemService.GetItem:input_type -> proto.GetItemRequest
+	3,  // 25: proto.ItemService.ListItems:input_type -> proto.ListItemsRequest
+	5,  // 26: proto.ItemService.SearchItems:input_type -> proto.SearchItemsRequest
+	8,  // 27: proto.ItemService.GetItemStats:input_type -> proto.GetItemStatsRequest
+	12, // 28: proto.ItemService.UpdateItem:input_type -> proto.UpdateItemRequest
+	13, // 29: proto.ItemService.DeleteItem:input_type -> proto.DeleteItemRequest
+	15, // 30: proto.ItemService.GetItemTree:input_type -> proto.GetItemTreeRequest
+	0,  // 31: proto.ItemService.CreateItem:output_type -> proto.Item
+	0,  // 32: proto.ItemService.GetItem:output_type -> proto.Item
+	4,  // 33: proto.ItemService.ListItems:output_type -> proto.ListItemsResponse
+	7,  // 34: proto.ItemService.SearchItems:output_type -> proto.SearchItemsResponse
+	11, // 35: proto.ItemService.GetItemStats:output_type -> proto.ItemStats
+	0,  // 36: proto.ItemService.UpdateItem:output_type -> proto.Item
+	14, // 37: proto.ItemService.DeleteItem:output_type -> proto.DeleteItemResponse
+	17, // 38: proto.ItemService.GetItemTree:output_type -> proto.ItemTree
+	31, // [31:39] is the sub-list for method output_type
+	23, // [23:31] is the sub-list for method input_type
+	23, // [23:23] is the sub-list for extension type_name
+	23, // [23:23] is the sub-list for extension extendee
+	0,  // [0:23] is the sub-list for field type_name
 }
 
 func init() { file_proto_item_proto_init() }
@@ -535,7 +1565,7 @@ func file_proto_item_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_item_proto_rawDesc), len(file_proto_item_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
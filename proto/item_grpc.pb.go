@@ -19,11 +19,14 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ItemService_CreateItem_FullMethodName = "/proto.ItemService/CreateItem"
-	ItemService_GetItem_FullMethodName    = "/proto.ItemService/GetItem"
-	ItemService_ListItems_FullMethodName  = "/proto.ItemService/ListItems"
-	ItemService_UpdateItem_FullMethodName = "/proto.ItemService/UpdateItem"
-	ItemService_DeleteItem_FullMethodName = "/proto.ItemService/DeleteItem"
+	ItemService_CreateItem_FullMethodName   = "/proto.ItemService/CreateItem"
+	ItemService_GetItem_FullMethodName      = "/proto.ItemService/GetItem"
+	ItemService_ListItems_FullMethodName    = "/proto.ItemService/ListItems"
+	ItemService_SearchItems_FullMethodName  = "/proto.ItemService/SearchItems"
+	ItemService_GetItemStats_FullMethodName = "/proto.ItemService/GetItemStats"
+	ItemService_UpdateItem_FullMethodName   = "/proto.ItemService/UpdateItem"
+	ItemService_DeleteItem_FullMethodName   = "/proto.ItemService/DeleteItem"
+	ItemService_GetItemTree_FullMethodName  = "/proto.ItemService/GetItemTree"
 )
 
 // ItemServiceClient is the client API for ItemService service.
@@ -33,8 +36,12 @@ type ItemServiceClient interface {
 	CreateItem(ctx context.Context, in *CreateItemRequest, opts ...grpc.CallOption) (*Item, error)
 	GetItem(ctx context.Context, in *GetItemRequest, opts ...grpc.CallOption) (*Item, error)
 	ListItems(ctx context.Context, in *ListItemsRequest, opts ...grpc.CallOption) (*ListItemsResponse, error)
+	SearchItems(ctx context.Context, in *SearchItemsRequest, opts ...grpc.CallOption) (*SearchItemsResponse, error)
+	GetItemStats(ctx context.Context, in *GetItemStatsRequest, opts ...grpc.CallOption) (*ItemStats, error)
 	UpdateItem(ctx context.Context, in *UpdateItemRequest, opts ...grpc.CallOption) (*Item, error)
 	DeleteItem(ctx context.Context, in *DeleteItemRequest, opts ...grpc.CallOption) (*DeleteItemResponse, error)
+	// GetItemTree returns an item with its ancestors and descendants
+	GetItemTree(ctx context.Context, in *GetItemTreeRequest, opts ...grpc.CallOption) (*ItemTree, error)
 }
 
 type itemServiceClient struct {
@@ -75,6 +82,26 @@ func (c *itemServiceClient) ListItems(ctx context.Context, in *ListItemsRequest,
 	return out, nil
 }
 
+func (c *itemServiceClient) SearchItems(ctx context.Context, in *SearchItemsRequest, opts ...grpc.CallOption) (*SearchItemsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchItemsResponse)
+	err := c.cc.Invoke(ctx, ItemService_SearchItems_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *itemServiceClient) GetItemStats(ctx context.Context, in *GetItemStatsRequest, opts ...grpc.CallOption) (*ItemStats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ItemStats)
+	err := c.cc.Invoke(ctx, ItemService_GetItemStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *itemServiceClient) UpdateItem(ctx context.Context, in *UpdateItemRequest, opts ...grpc.CallOption) (*Item, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Item)
@@ -95,6 +122,16 @@ func (c *itemServiceClient) DeleteItem(ctx context.Context, in *DeleteItemReques
 	return out, nil
 }
 
+func (c *itemServiceClient) GetItemTree(ctx context.Context, in *GetItemTreeRequest, opts ...grpc.CallOption) (*ItemTree, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ItemTree)
+	err := c.cc.Invoke(ctx, ItemService_GetItemTree_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ItemServiceServer is the server API for ItemService service.
 // All implementations must embed UnimplementedItemServiceServer
 // for forward compatibility.
@@ -102,8 +139,12 @@ type ItemServiceServer interface {
 	CreateItem(context.Context, *CreateItemRequest) (*Item, error)
 	GetItem(context.Context, *GetItemRequest) (*Item, error)
 	ListItems(context.Context, *ListItemsRequest) (*ListItemsResponse, error)
+	SearchItems(context.Context, *SearchItemsRequest) (*SearchItemsResponse, error)
+	GetItemStats(context.Context, *GetItemStatsRequest) (*ItemStats, error)
 	UpdateItem(context.Context, *UpdateItemRequest) (*Item, error)
 	DeleteItem(context.Context, *DeleteItemRequest) (*DeleteItemResponse, error)
+	// GetItemTree returns an item with its ancestors and descendants
+	GetItemTree(context.Context, *GetItemTreeRequest) (*ItemTree, error)
 	mustEmbedUnimplementedItemServiceServer()
 }
 
@@ -123,12 +164,21 @@ func (UnimplementedItemServiceServer) GetItem(context.Context, *GetItemRequest)
 func (UnimplementedItemServiceServer) ListItems(context.Context, *ListItemsRequest) (*ListItemsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListItems not implemented")
 }
+func (UnimplementedItemServiceServer) SearchItems(context.Context, *SearchItemsRequest) (*SearchItemsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchItems not implemented")
+}
+func (UnimplementedItemServiceServer) GetItemStats(context.Context, *GetItemStatsRequest) (*ItemStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetItemStats not implemented")
+}
 func (UnimplementedItemServiceServer) UpdateItem(context.Context, *UpdateItemRequest) (*Item, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateItem not implemented")
 }
 func (UnimplementedItemServiceServer) DeleteItem(context.Context, *DeleteItemRequest) (*DeleteItemResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteItem not implemented")
 }
+func (UnimplementedItemServiceServer) GetItemTree(context.Context, *GetItemTreeRequest) (*ItemTree, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetItemTree not implemented")
+}
 func (UnimplementedItemServiceServer) mustEmbedUnimplementedItemServiceServer() {}
 func (UnimplementedItemServiceServer) testEmbeddedByValue()                     {}
 
@@ -204,6 +254,42 @@ func _ItemService_ListItems_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ItemService_SearchItems_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchItemsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemServiceServer).SearchItems(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ItemService_SearchItems_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemServiceServer).SearchItems(ctx, req.(*SearchItemsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ItemService_GetItemStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetItemStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemServiceServer).GetItemStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ItemService_GetItemStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemServiceServer).GetItemStats(ctx, req.(*GetItemStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ItemService_UpdateItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateItemRequest)
 	if err := dec(in); err != nil {
@@ -240,6 +326,24 @@ func _ItemService_DeleteItem_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ItemService_GetItemTree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetItemTreeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ItemServiceServer).GetItemTree(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ItemService_GetItemTree_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ItemServiceServer).GetItemTree(ctx, req.(*GetItemTreeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ItemService_ServiceDesc is the grpc.ServiceDesc for ItemService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -259,6 +363,14 @@ var ItemService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListItems",
 			Handler:    _ItemService_ListItems_Handler,
 		},
+		{
+			MethodName: "SearchItems",
+			Handler:    _ItemService_SearchItems_Handler,
+		},
+		{
+			MethodName: "GetItemStats",
+			Handler:    _ItemService_GetItemStats_Handler,
+		},
 		{
 			MethodName: "UpdateItem",
 			Handler:    _ItemService_UpdateItem_Handler,
@@ -267,6 +379,10 @@ var ItemService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteItem",
 			Handler:    _ItemService_DeleteItem_Handler,
 		},
+		{
+			MethodName: "GetItemTree",
+			Handler:    _ItemService_GetItemTree_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/item.proto",
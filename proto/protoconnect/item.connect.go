@@ -0,0 +1,307 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: proto/item.proto
+
+package protoconnect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	proto "github.com/angel/go-api-sqlite/proto"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// ItemServiceName is the fully-qualified name of the ItemService service.
+	ItemServiceName = "proto.ItemService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// ItemServiceCreateItemProcedure is the fully-qualified name of the ItemService's CreateItem RPC.
+	ItemServiceCreateItemProcedure = "/proto.ItemService/CreateItem"
+	// ItemServiceGetItemProcedure is the fully-qualified name of the ItemService's GetItem RPC.
+	ItemServiceGetItemProcedure = "/proto.ItemService/GetItem"
+	// ItemServiceListItemsProcedure is the fully-qualified name of the ItemService's ListItems RPC.
+	ItemServiceListItemsProcedure = "/proto.ItemService/ListItems"
+	// ItemServiceSearchItemsProcedure is the fully-qualified name of the ItemService's SearchItems RPC.
+	ItemServiceSearchItemsProcedure = "/proto.ItemService/SearchItems"
+	// ItemServiceGetItemStatsProcedure is the fully-qualified name of the ItemService's GetItemStats
+	// RPC.
+	ItemServiceGetItemStatsProcedure = "/proto.ItemService/GetItemStats"
+	// ItemServiceUpdateItemProcedure is the fully-qualified name of the ItemService's UpdateItem RPC.
+	ItemServiceUpdateItemProcedure = "/proto.ItemService/UpdateItem"
+	// ItemServiceDeleteItemProcedure is the fully-qualified name of the ItemService's DeleteItem RPC.
+	ItemServiceDeleteItemProcedure = "/proto.ItemService/DeleteItem"
+	// ItemServiceGetItemTreeProcedure is the fully-qualified name of the ItemService's GetItemTree RPC.
+	ItemServiceGetItemTreeProcedure = "/proto.ItemService/GetItemTree"
+)
+
+// ItemServiceClient is a client for the proto.ItemService service.
+type ItemServiceClient interface {
+	CreateItem(context.Context, *connect.Request[proto.CreateItemRequest]) (*connect.Response[proto.Item], error)
+	GetItem(context.Context, *connect.Request[proto.GetItemRequest]) (*connect.Response[proto.Item], error)
+	ListItems(context.Context, *connect.Request[proto.ListItemsRequest]) (*connect.Response[proto.ListItemsResponse], error)
+	SearchItems(context.Context, *connect.Request[proto.SearchItemsRequest]) (*connect.Response[proto.SearchItemsResponse], error)
+	GetItemStats(context.Context, *connect.Request[proto.GetItemStatsRequest]) (*connect.Response[proto.ItemStats], error)
+	UpdateItem(context.Context, *connect.Request[proto.UpdateItemRequest]) (*connect.Response[proto.Item], error)
+	DeleteItem(context.Context, *connect.Request[proto.DeleteItemRequest]) (*connect.Response[proto.DeleteItemResponse], error)
+	// GetItemTree returns an item with its ancestors and descendants
+	GetItemTree(context.Context, *connect.Request[proto.GetItemTreeRequest]) (*connect.Response[proto.ItemTree], error)
+}
+
+// NewItemServiceClient constructs a client for the proto.ItemService service. By default, it uses
+// the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewItemServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) ItemServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	itemServiceMethods := proto.File_proto_item_proto.Services().ByName("ItemService").Methods()
+	return &itemServiceClient{
+		createItem: connect.NewClient[proto.CreateItemRequest, proto.Item](
+			httpClient,
+			baseURL+ItemServiceCreateItemProcedure,
+			connect.WithSchema(itemServiceMethods.ByName("CreateItem")),
+			connect.WithClientOptions(opts...),
+		),
+		getItem: connect.NewClient[proto.GetItemRequest, proto.Item](
+			httpClient,
+			baseURL+ItemServiceGetItemProcedure,
+			connect.WithSchema(itemServiceMethods.ByName("GetItem")),
+			connect.WithClientOptions(opts...),
+		),
+		listItems: connect.NewClient[proto.ListItemsRequest, proto.ListItemsResponse](
+			httpClient,
+			baseURL+ItemServiceListItemsProcedure,
+			connect.WithSchema(itemServiceMethods.ByName("ListItems")),
+			connect.WithClientOptions(opts...),
+		),
+		searchItems: connect.NewClient[proto.SearchItemsRequest, proto.SearchItemsResponse](
+			httpClient,
+			baseURL+ItemServiceSearchItemsProcedure,
+			connect.WithSchema(itemServiceMethods.ByName("SearchItems")),
+			connect.WithClientOptions(opts...),
+		),
+		getItemStats: connect.NewClient[proto.GetItemStatsRequest, proto.ItemStats](
+			httpClient,
+			baseURL+ItemServiceGetItemStatsProcedure,
+			connect.WithSchema(itemServiceMethods.ByName("GetItemStats")),
+			connect.WithClientOptions(opts...),
+		),
+		updateItem: connect.NewClient[proto.UpdateItemRequest, proto.Item](
+			httpClient,
+			baseURL+ItemServiceUpdateItemProcedure,
+			connect.WithSchema(itemServiceMethods.ByName("UpdateItem")),
+			connect.WithClientOptions(opts...),
+		),
+		deleteItem: connect.NewClient[proto.DeleteItemRequest, proto.DeleteItemResponse](
+			httpClient,
+			baseURL+ItemServiceDeleteItemProcedure,
+			connect.WithSchema(itemServiceMethods.ByName("DeleteItem")),
+			connect.WithClientOptions(opts...),
+		),
+		getItemTree: connect.NewClient[proto.GetItemTreeRequest, proto.ItemTree](
+			httpClient,
+			baseURL+ItemServiceGetItemTreeProcedure,
+			connect.WithSchema(itemServiceMethods.ByName("GetItemTree")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// itemServiceClient implements ItemServiceClient.
+type itemServiceClient struct {
+	createItem   *connect.Client[proto.CreateItemRequest, proto.Item]
+	getItem      *connect.Client[proto.GetItemRequest, proto.Item]
+	listItems    *connect.Client[proto.ListItemsRequest, proto.ListItemsResponse]
+	searchItems  *connect.Client[proto.SearchItemsRequest, proto.SearchItemsResponse]
+	getItemStats *connect.Client[proto.GetItemStatsRequest, proto.ItemStats]
+	updateItem   *connect.Client[proto.UpdateItemRequest, proto.Item]
+	deleteItem   *connect.Client[proto.DeleteItemRequest, proto.DeleteItemResponse]
+	getItemTree  *connect.Client[proto.GetItemTreeRequest, proto.ItemTree]
+}
+
+// CreateItem calls proto.ItemService.CreateItem.
+func (c *itemServiceClient) CreateItem(ctx context.Context, req *connect.Request[proto.CreateItemRequest]) (*connect.Response[proto.Item], error) {
+	return c.createItem.CallUnary(ctx, req)
+}
+
+// GetItem calls proto.ItemService.GetItem.
+func (c *itemServiceClient) GetItem(ctx context.Context, req *connect.Request[proto.GetItemRequest]) (*connect.Response[proto.Item], error) {
+	return c.getItem.CallUnary(ctx, req)
+}
+
+// ListItems calls proto.ItemService.ListItems.
+func (c *itemServiceClient) ListItems(ctx context.Context, req *connect.Request[proto.ListItemsRequest]) (*connect.Response[proto.ListItemsResponse], error) {
+	return c.listItems.CallUnary(ctx, req)
+}
+
+// SearchItems calls proto.ItemService.SearchItems.
+func (c *itemServiceClient) SearchItems(ctx context.Context, req *connect.Request[proto.SearchItemsRequest]) (*connect.Response[proto.SearchItemsResponse], error) {
+	return c.searchItems.CallUnary(ctx, req)
+}
+
+// GetItemStats calls proto.ItemService.GetItemStats.
+func (c *itemServiceClient) GetItemStats(ctx context.Context, req *connect.Request[proto.GetItemStatsRequest]) (*connect.Response[proto.ItemStats], error) {
+	return c.getItemStats.CallUnary(ctx, req)
+}
+
+// UpdateItem calls proto.ItemService.UpdateItem.
+func (c *itemServiceClient) UpdateItem(ctx context.Context, req *connect.Request[proto.UpdateItemRequest]) (*connect.Response[proto.Item], error) {
+	return c.updateItem.CallUnary(ctx, req)
+}
+
+// DeleteItem calls proto.ItemService.DeleteItem.
+func (c *itemServiceClient) DeleteItem(ctx context.Context, req *connect.Request[proto.DeleteItemRequest]) (*connect.Response[proto.DeleteItemResponse], error) {
+	return c.deleteItem.CallUnary(ctx, req)
+}
+
+// GetItemTree calls proto.ItemService.GetItemTree.
+func (c *itemServiceClient) GetItemTree(ctx context.Context, req *connect.Request[proto.GetItemTreeRequest]) (*connect.Response[proto.ItemTree], error) {
+	return c.getItemTree.CallUnary(ctx, req)
+}
+
+// ItemServiceHandler is an implementation of the proto.ItemService service.
+type ItemServiceHandler interface {
+	CreateItem(context.Context, *connect.Request[proto.CreateItemRequest]) (*connect.Response[proto.Item], error)
+	GetItem(context.Context, *connect.Request[proto.GetItemRequest]) (*connect.Response[proto.Item], error)
+	ListItems(context.Context, *connect.Request[proto.ListItemsRequest]) (*connect.Response[proto.ListItemsResponse], error)
+	SearchItems(context.Context, *connect.Request[proto.SearchItemsRequest]) (*connect.Response[proto.SearchItemsResponse], error)
+	GetItemStats(context.Context, *connect.Request[proto.GetItemStatsRequest]) (*connect.Response[proto.ItemStats], error)
+	UpdateItem(context.Context, *connect.Request[proto.UpdateItemRequest]) (*connect.Response[proto.Item], error)
+	DeleteItem(context.Context, *connect.Request[proto.DeleteItemRequest]) (*connect.Response[proto.DeleteItemResponse], error)
+	// GetItemTree returns an item with its ancestors and descendants
+	GetItemTree(context.Context, *connect.Request[proto.GetItemTreeRequest]) (*connect.Response[proto.ItemTree], error)
+}
+
+// NewItemServiceHandler builds an HTTP handler from the service implementation. It returns the path
+// on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewItemServiceHandler(svc ItemServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	itemServiceMethods := proto.File_proto_item_proto.Services().ByName("ItemService").Methods()
+	itemServiceCreateItemHandler := connect.NewUnaryHandler(
+		ItemServiceCreateItemProcedure,
+		svc.CreateItem,
+		connect.WithSchema(itemServiceMethods.ByName("CreateItem")),
+		connect.WithHandlerOptions(opts...),
+	)
+	itemServiceGetItemHandler := connect.NewUnaryHandler(
+		ItemServiceGetItemProcedure,
+		svc.GetItem,
+		connect.WithSchema(itemServiceMethods.ByName("GetItem")),
+		connect.WithHandlerOptions(opts...),
+	)
+	itemServiceListItemsHandler := connect.NewUnaryHandler(
+		ItemServiceListItemsProcedure,
+		svc.ListItems,
+		connect.WithSchema(itemServiceMethods.ByName("ListItems")),
+		connect.WithHandlerOptions(opts...),
+	)
+	itemServiceSearchItemsHandler := connect.NewUnaryHandler(
+		ItemServiceSearchItemsProcedure,
+		svc.SearchItems,
+		connect.WithSchema(itemServiceMethods.ByName("SearchItems")),
+		connect.WithHandlerOptions(opts...),
+	)
+	itemServiceGetItemStatsHandler := connect.NewUnaryHandler(
+		ItemServiceGetItemStatsProcedure,
+		svc.GetItemStats,
+		connect.WithSchema(itemServiceMethods.ByName("GetItemStats")),
+		connect.WithHandlerOptions(opts...),
+	)
+	itemServiceUpdateItemHandler := connect.NewUnaryHandler(
+		ItemServiceUpdateItemProcedure,
+		svc.UpdateItem,
+		connect.WithSchema(itemServiceMethods.ByName("UpdateItem")),
+		connect.WithHandlerOptions(opts...),
+	)
+	itemServiceDeleteItemHandler := connect.NewUnaryHandler(
+		ItemServiceDeleteItemProcedure,
+		svc.DeleteItem,
+		connect.WithSchema(itemServiceMethods.ByName("DeleteItem")),
+		connect.WithHandlerOptions(opts...),
+	)
+	itemServiceGetItemTreeHandler := connect.NewUnaryHandler(
+		ItemServiceGetItemTreeProcedure,
+		svc.GetItemTree,
+		connect.WithSchema(itemServiceMethods.ByName("GetItemTree")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/proto.ItemService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ItemServiceCreateItemProcedure:
+			itemServiceCreateItemHandler.ServeHTTP(w, r)
+		case ItemServiceGetItemProcedure:
+			itemServiceGetItemHandler.ServeHTTP(w, r)
+		case ItemServiceListItemsProcedure:
+			itemServiceListItemsHandler.ServeHTTP(w, r)
+		case ItemServiceSearchItemsProcedure:
+			itemServiceSearchItemsHandler.ServeHTTP(w, r)
+		case ItemServiceGetItemStatsProcedure:
+			itemServiceGetItemStatsHandler.ServeHTTP(w, r)
+		case ItemServiceUpdateItemProcedure:
+			itemServiceUpdateItemHandler.ServeHTTP(w, r)
+		case ItemServiceDeleteItemProcedure:
+			itemServiceDeleteItemHandler.ServeHTTP(w, r)
+		case ItemServiceGetItemTreeProcedure:
+			itemServiceGetItemTreeHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedItemServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedItemServiceHandler struct{}
+
+func (UnimplementedItemServiceHandler) CreateItem(context.Context, *connect.Request[proto.CreateItemRequest]) (*connect.Response[proto.Item], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("proto.ItemService.CreateItem is not implemented"))
+}
+
+func (UnimplementedItemServiceHandler) GetItem(context.Context, *connect.Request[proto.GetItemRequest]) (*connect.Response[proto.Item], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("proto.ItemService.GetItem is not implemented"))
+}
+
+func (UnimplementedItemServiceHandler) ListItems(context.Context, *connect.Request[proto.ListItemsRequest]) (*connect.Response[proto.ListItemsResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("proto.ItemService.ListItems is not implemented"))
+}
+
+func (UnimplementedItemServiceHandler) SearchItems(context.Context, *connect.Request[proto.SearchItemsRequest]) (*connect.Response[proto.SearchItemsResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("proto.ItemService.SearchItems is not implemented"))
+}
+
+func (UnimplementedItemServiceHandler) GetItemStats(context.Context, *connect.Request[proto.GetItemStatsRequest]) (*connect.Response[proto.ItemStats], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("proto.ItemService.GetItemStats is not implemented"))
+}
+
+func (UnimplementedItemServiceHandler) UpdateItem(context.Context, *connect.Request[proto.UpdateItemRequest]) (*connect.Response[proto.Item], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("proto.ItemService.UpdateItem is not implemented"))
+}
+
+func (UnimplementedItemServiceHandler) DeleteItem(context.Context, *connect.Request[proto.DeleteItemRequest]) (*connect.Response[proto.DeleteItemResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("proto.ItemService.DeleteItem is not implemented"))
+}
+
+func (UnimplementedItemServiceHandler) GetItemTree(context.Context, *connect.Request[proto.GetItemTreeRequest]) (*connect.Response[proto.ItemTree], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("proto.ItemService.GetItemTree is not implemented"))
+}
@@ -0,0 +1,453 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: proto/replication.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Change_Op int32
+
+const (
+	Change_OP_UNSPECIFIED Change_Op = 0
+	Change_OP_UPSERT      Change_Op = 1
+	Change_OP_DELETE      Change_Op = 2
+)
+
+// Enum value maps for Change_Op.
+var (
+	Change_Op_name = map[int32]string{
+		0: "OP_UNSPECIFIED",
+		1: "OP_UPSERT",
+		2: "OP_DELETE",
+	}
+	Change_Op_value = map[string]int32{
+		"OP_UNSPECIFIED": 0,
+		"OP_UPSERT":      1,
+		"OP_DELETE":      2,
+	}
+)
+
+func (x Change_Op) Enum() *Change_Op {
+	p := new(Change_Op)
+	*p = x
+	return p
+}
+
+func (x Change_Op) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Change_Op) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_replication_proto_enumTypes[0].Descriptor()
+}
+
+func (Change_Op) Type() protoreflect.EnumType {
+	return &file_proto_replication_proto_enumTypes[0]
+}
+
+func (x Change_Op) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Change_Op.Descriptor instead.
+func (Change_Op) EnumDescriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{4, 0}
+}
+
+type StreamChangesRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	AfterSeq int64                  `protobuf:"varint,1,opt,name=after_seq,json=afterSeq,proto3" json:"after_seq,omitempty"`
+	// Identifies the replica; after_seq is recorded as its applied position
+	ReplicaId     string `protobuf:"bytes,2,opt,name=replica_id,json=replicaId,proto3" json:"replica_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamChangesRequest) Reset() {
+	*x = StreamChangesRequest{}
+	mi := &file_proto_replication_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamChangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamChangesRequest) ProtoMessage() {}
+
+func (x *StreamChangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamChangesRequest.ProtoReflect.Descriptor instead.
+func (*StreamChangesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StreamChangesRequest) GetAfterSeq() int64 {
+	if x != nil {
+		return x.AfterSeq
+	}
+	return 0
+}
+
+func (x *StreamChangesRequest) GetReplicaId() string {
+	if x != nil {
+		return x.ReplicaId
+	}
+	return ""
+}
+
+type AckChangesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReplicaId     string                 `protobuf:"bytes,1,opt,name=replica_id,json=replicaId,proto3" json:"replica_id,omitempty"`
+	AppliedSeq    int64                  `protobuf:"varint,2,opt,name=applied_seq,json=appliedSeq,proto3" json:"applied_seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AckChangesRequest) Reset() {
+	*x = AckChangesRequest{}
+	mi := &file_proto_replication_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AckChangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AckChangesRequest) ProtoMessage() {}
+
+func (x *AckChangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AckChangesRequest.ProtoReflect.Descriptor instead.
+func (*AckChangesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AckChangesRequest) GetReplicaId() string {
+	if x != nil {
+		return x.ReplicaId
+	}
+	return ""
+}
+
+func (x *AckChangesRequest) GetAppliedSeq() int64 {
+	if x != nil {
+		return x.AppliedSeq
+	}
+	return 0
+}
+
+type AckChangesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AckChangesResponse) Reset() {
+	*x = AckChangesResponse{}
+	mi := &file_proto_replication_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AckChangesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AckChangesResponse) ProtoMessage() {}
+
+func (x *AckChangesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AckChangesResponse.ProtoReflect.Descriptor instead.
+func (*AckChangesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{2}
+}
+
+type StreamChangesResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Changes []*Change              `protobuf:"bytes,1,rep,name=changes,proto3" json:"changes,omitempty"`
+	// The primary's latest sequence number when the batch was sent
+	HeadSeq       int64                  `protobuf:"varint,2,opt,name=head_seq,json=headSeq,proto3" json:"head_seq,omitempty"`
+	SentAt        *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=sent_at,json=sentAt,proto3" json:"sent_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamChangesResponse) Reset() {
+	*x = StreamChangesResponse{}
+	mi := &file_proto_replication_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamChangesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamChangesResponse) ProtoMessage() {}
+
+func (x *StreamChangesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamChangesResponse.ProtoReflect.Descriptor instead.
+func (*StreamChangesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamChangesResponse) GetChanges() []*Change {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *StreamChangesResponse) GetHeadSeq() int64 {
+	if x != nil {
+		return x.HeadSeq
+	}
+	return 0
+}
+
+func (x *StreamChangesResponse) GetSentAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SentAt
+	}
+	return nil
+}
+
+type Change struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Seq    int64                  `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Op     Change_Op              `protobuf:"varint,2,opt,name=op,proto3,enum=proto.Change_Op" json:"op,omitempty"`
+	ItemId string                 `protobuf:"bytes,3,opt,name=item_id,json=itemId,proto3" json:"item_id,omitempty"`
+	// The current state of the item, set for upserts
+	Item          *Item                  `protobuf:"bytes,4,opt,name=item,proto3" json:"item,omitempty"`
+	ChangedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Change) Reset() {
+	*x = Change{}
+	mi := &file_proto_replication_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Change) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Change) ProtoMessage() {}
+
+func (x *Change) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Change.ProtoReflect.Descriptor instead.
+func (*Change) Descriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Change) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Change) GetOp() Change_Op {
+	if x != nil {
+		return x.Op
+	}
+	return Change_OP_UNSPECIFIED
+}
+
+func (x *Change) GetItemId() string {
+	if x != nil {
+		return x.ItemId
+	}
+	return ""
+}
+
+func (x *Change) GetItem() *Item {
+	if x != nil {
+		return x.Item
+	}
+	return nil
+}
+
+func (x *Change) GetChangedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return nil
+}
+
+var File_proto_replication_proto protoreflect.FileDescriptor
+
+const file_proto_replication_proto_rawDesc = "" +
+	"\n" +
+	"\x17proto/replication.proto\x12\x05proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x10proto/item.proto\"R\n" +
+	"\x14StreamChangesRequest\x12\x1b\n" +
+	"\tafter_seq\x18\x01 \x01(\x03R\bafterSeq\x12\x1d\n" +
+	"\n" +
+	"replica_id\x18\x02 \x01(\tR\treplicaId\"S\n" +
+	"\x11AckChangesRequest\x12\x1d\n" +
+	"\n" +
+	"replica_id\x18\x01 \x01(\tR\treplicaId\x12\x1f\n" +
+	"\vapplied_seq\x18\x02 \x01(\x03R\n" +
+	"appliedSeq\"\x14\n" +
+	"\x12AckChangesResponse\"\x90\x01\n" +
+	"\x15StreamChangesResponse\x12'\n" +
+	"\achanges\x18\x01 \x03(\v2\r.proto.ChangeR\achanges\x12\x19\n" +
+	"\bhead_seq\x18\x02 \x01(\x03R\aheadSeq\x123\n" +
+	"\asent_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x06sentAt\"\xe9\x01\n" +
+	"\x06Change\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x03R\x03seq\x12 \n" +
+	"\x02op\x18\x02 \x01(\x0e2\x10.proto.Change.OpR\x02op\x12\x17\n" +
+	"\aitem_id\x18\x03 \x01(\tR\x06itemId\x12\x1f\n" +
+	"\x04item\x18\x04 \x01(\v2\v.proto.ItemR\x04item\x129\n" +
+	"\n" +
+	"changed_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tchangedAt\"6\n" +
+	"\x02Op\x12\x12\n" +
+	"\x0eOP_UNSPECIFIED\x10\x00\x12\r\n" +
+	"\tOP_UPSERT\x10\x01\x12\r\n" +
+	"\tOP_DELETE\x10\x022\xa5\x01\n" +
+	"\x12ReplicationService\x12L\n" +
+	"\rStreamChanges\x12\x1b.proto.StreamChangesRequest\x1a\x1c.proto.StreamChangesResponse0\x01\x12A\n" +
+	"\n" +
+	"AckChanges\x12\x18.proto.AckChangesRequest\x1a\x19.proto.AckChangesResponseB&Z$github.com/angel/go-api-sqlite/protob\x06proto3"
+
+var (
+	file_proto_replication_proto_rawDescOnce sync.Once
+	file_proto_replication_proto_rawDescData []byte
+)
+
+func file_proto_replication_proto_rawDescGZIP() []byte {
+	file_proto_replication_proto_rawDescOnce.Do(func() {
+		file_proto_replication_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_replication_proto_rawDesc), len(file_proto_replication_proto_rawDesc)))
+	})
+	return file_proto_replication_proto_rawDescData
+}
+
+var file_proto_replication_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_replication_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_proto_replication_proto_goTypes = []any{
+	(Change_Op)(0),                // 0: proto.Change.Op
+	(*StreamChangesRequest)(nil),  // 1: proto.StreamChangesRequest
+	(*AckChangesRequest)(nil),     // 2: proto.AckChangesRequest
+	(*AckChangesResponse)(nil),    // 3: proto.AckChangesResponse
+	(*StreamChangesResponse)(nil), // 4: proto.StreamChangesResponse
+	(*Change)(nil),                // 5: proto.Change
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+	(*Item)(nil),                  // 7: proto.Item
+}
+var file_proto_replication_proto_depIdxs = []int32{
+	5, // 0: proto.StreamChangesResponse.changes:type_name -> proto.Change
+	6, // 1: proto.StreamChangesResponse.sent_at:type_name -> google.protobuf.Timestamp
+	0, // 2: proto.Change.op:type_name -> proto.Change.Op
+	7, // 3: proto.Change.item:type_name -> proto.Item
+	6, // 4: proto.Change.changed_at:type_name -> google.protobuf.Timestamp
+	1, // 5: proto.ReplicationService.StreamChanges:input_type -> proto.StreamChangesRequest
+	2, // 6: proto.ReplicationService.AckChanges:input_type -> proto.AckChangesRequest
+	4, // 7: proto.ReplicationService.StreamChanges:output_type -> proto.StreamChangesResponse
+	3, // 8: proto.ReplicationService.AckChanges:output_type -> proto.AckChangesResponse
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_proto_replication_proto_init() }
+func file_proto_replication_proto_init() {
+	if File_proto_replication_proto != nil {
+		return
+	}
+	file_proto_item_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_replication_proto_rawDesc), len(file_proto_replication_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_replication_proto_goTypes,
+		DependencyIndexes: file_proto_replication_proto_depIdxs,
+		EnumInfos:         file_proto_replication_proto_enumTypes,
+		MessageInfos:      file_proto_replication_proto_msgTypes,
+	}.Build()
+	File_proto_replication_proto = out.File
+	file_proto_replication_proto_goTypes = nil
+	file_proto_replication_proto_depIdxs = nil
+}
@@ -0,0 +1,747 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: proto/schema.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Schema struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Version   int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Schema    *structpb.Struct       `protobuf:"bytes,2,opt,name=schema,proto3" json:"schema,omitempty"`
+	Active    bool                   `protobuf:"varint,3,opt,name=active,proto3" json:"active,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// Unset unless active
+	ActivatedAt   *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=activated_at,json=activatedAt,proto3" json:"activated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Schema) Reset() {
+	*x = Schema{}
+	mi := &file_proto_schema_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Schema) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Schema) ProtoMessage() {}
+
+func (x *Schema) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Schema.ProtoReflect.Descriptor instead.
+func (*Schema) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Schema) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Schema) GetSchema() *structpb.Struct {
+	if x != nil {
+		return x.Schema
+	}
+	return nil
+}
+
+func (x *Schema) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+func (x *Schema) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Schema) GetActivatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ActivatedAt
+	}
+	return nil
+}
+
+type CreateSchemaRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Schema        *structpb.Struct       `protobuf:"bytes,1,opt,name=schema,proto3" json:"schema,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSchemaRequest) Reset() {
+	*x = CreateSchemaRequest{}
+	mi := &file_proto_schema_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSchemaRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSchemaRequest) ProtoMessage() {}
+
+func (x *CreateSchemaRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSchemaRequest.ProtoReflect.Descriptor instead.
+func (*CreateSchemaRequest) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateSchemaRequest) GetSchema() *structpb.Struct {
+	if x != nil {
+		return x.Schema
+	}
+	return nil
+}
+
+type GetSchemaRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSchemaRequest) Reset() {
+	*x = GetSchemaRequest{}
+	mi := &file_proto_schema_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSchemaRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSchemaRequest) ProtoMessage() {}
+
+func (x *GetSchemaRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSchemaRequest.ProtoReflect.Descriptor instead.
+func (*GetSchemaRequest) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetSchemaRequest) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type ListSchemasRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSchemasRequest) Reset() {
+	*x = ListSchemasRequest{}
+	mi := &file_proto_schema_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSchemasRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSchemasRequest) ProtoMessage() {}
+
+func (x *ListSchemasRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSchemasRequest.ProtoReflect.Descriptor instead.
+func (*ListSchemasRequest) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{3}
+}
+
+type ListSchemasResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Schemas       []*Schema              `protobuf:"bytes,1,rep,name=schemas,proto3" json:"schemas,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSchemasResponse) Reset() {
+	*x = ListSchemasResponse{}
+	mi := &file_proto_schema_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSchemasResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSchemasResponse) ProtoMessage() {}
+
+func (x *ListSchemasResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSchemasResponse.ProtoReflect.Descriptor instead.
+func (*ListSchemasResponse) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListSchemasResponse) GetSchemas() []*Schema {
+	if x != nil {
+		return x.Schemas
+	}
+	return nil
+}
+
+type DryRunSchemaRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Version int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// Checks only the items in this collection when set
+	CollectionId  string `protobuf:"bytes,2,opt,name=collection_id,json=collectionId,proto3" json:"collection_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DryRunSchemaRequest) Reset() {
+	*x = DryRunSchemaRequest{}
+	mi := &file_proto_schema_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DryRunSchemaRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DryRunSchemaRequest) ProtoMessage() {}
+
+func (x *DryRunSchemaRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DryRunSchemaRequest.ProtoReflect.Descriptor instead.
+func (*DryRunSchemaRequest) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DryRunSchemaRequest) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *DryRunSchemaRequest) GetCollectionId() string {
+	if x != nil {
+		return x.CollectionId
+	}
+	return ""
+}
+
+type DryRunSchemaResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Version int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Checked int64                  `protobuf:"varint,2,opt,name=checked,proto3" json:"checked,omitempty"`
+	Failed  int64                  `protobuf:"varint,3,opt,name=failed,proto3" json:"failed,omitempty"`
+	// The first 100 failing items by id
+	Failures      []*DryRunSchemaResponse_Failure `protobuf:"bytes,4,rep,name=failures,proto3" json:"failures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DryRunSchemaResponse) Reset() {
+	*x = DryRunSchemaResponse{}
+	mi := &file_proto_schema_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DryRunSchemaResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DryRunSchemaResponse) ProtoMessage() {}
+
+func (x *DryRunSchemaResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DryRunSchemaResponse.ProtoReflect.Descriptor instead.
+func (*DryRunSchemaResponse) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DryRunSchemaResponse) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *DryRunSchemaResponse) GetChecked() int64 {
+	if x != nil {
+		return x.Checked
+	}
+	return 0
+}
+
+func (x *DryRunSchemaResponse) GetFailed() int64 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *DryRunSchemaResponse) GetFailures() []*DryRunSchemaResponse_Failure {
+	if x != nil {
+		return x.Failures
+	}
+	return nil
+}
+
+type ActivateSchemaRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActivateSchemaRequest) Reset() {
+	*x = ActivateSchemaRequest{}
+	mi := &file_proto_schema_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActivateSchemaRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActivateSchemaRequest) ProtoMessage() {}
+
+func (x *ActivateSchemaRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActivateSchemaRequest.ProtoReflect.Descriptor instead.
+func (*ActivateSchemaRequest) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ActivateSchemaRequest) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type DeactivateSchemaRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeactivateSchemaRequest) Reset() {
+	*x = DeactivateSchemaRequest{}
+	mi := &file_proto_schema_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeactivateSchemaRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeactivateSchemaRequest) ProtoMessage() {}
+
+func (x *DeactivateSchemaRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeactivateSchemaRequest.ProtoReflect.Descriptor instead.
+func (*DeactivateSchemaRequest) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{8}
+}
+
+type DeactivateSchemaResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeactivateSchemaResponse) Reset() {
+	*x = DeactivateSchemaResponse{}
+	mi := &file_proto_schema_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeactivateSchemaResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeactivateSchemaResponse) ProtoMessage() {}
+
+func (x *DeactivateSchemaResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeactivateSchemaResponse.ProtoReflect.Descriptor instead.
+func (*DeactivateSchemaResponse) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DeactivateSchemaResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+type DryRunSchemaResponse_Failure struct {
+	state         protoimpl.MessageState            `protogen:"open.v1"`
+	Id            string                            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Violations    []*DryRunSchemaResponse_Violation `protobuf:"bytes,2,rep,name=violations,proto3" json:"violations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DryRunSchemaResponse_Failure) Reset() {
+	*x = DryRunSchemaResponse_Failure{}
+	mi := &file_proto_schema_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DryRunSchemaResponse_Failure) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DryRunSchemaResponse_Failure) ProtoMessage() {}
+
+func (x *DryRunSchemaResponse_Failure) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DryRunSchemaResponse_Failure.ProtoReflect.Descriptor instead.
+func (*DryRunSchemaResponse_Failure) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{6, 0}
+}
+
+func (x *DryRunSchemaResponse_Failure) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DryRunSchemaResponse_Failure) GetViolations() []*DryRunSchemaResponse_Violation {
+	if x != nil {
+		return x.Violations
+	}
+	return nil
+}
+
+type DryRunSchemaResponse_Violation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Field         string                 `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DryRunSchemaResponse_Violation) Reset() {
+	*x = DryRunSchemaResponse_Violation{}
+	mi := &file_proto_schema_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DryRunSchemaResponse_Violation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DryRunSchemaResponse_Violation) ProtoMessage() {}
+
+func (x *DryRunSchemaResponse_Violation) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_schema_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DryRunSchemaResponse_Violation.ProtoReflect.Descriptor instead.
+func (*DryRunSchemaResponse_Violation) Descriptor() ([]byte, []int) {
+	return file_proto_schema_proto_rawDescGZIP(), []int{6, 1}
+}
+
+func (x *DryRunSchemaResponse_Violation) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *DryRunSchemaResponse_Violation) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+var File_proto_schema_proto protoreflect.FileDescriptor
+
+const file_proto_schema_proto_rawDesc = "" +
+	"\n" +
+	"\x12proto/schema.proto\x12\x05proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe5\x01\n" +
+	"\x06Schema\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\x12/\n" +
+	"\x06schema\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x06schema\x12\x16\n" +
+	"\x06active\x18\x03 \x01(\bR\x06active\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12=\n" +
+	"\factivated_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vactivatedAt\"F\n" +
+	"\x13CreateSchemaRequest\x12/\n" +
+	"\x06schema\x18\x01 \x01(\v2\x17.google.protobuf.StructR\x06schema\",\n" +
+	"\x10GetSchemaRequest\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\"\x14\n" +
+	"\x12ListSchemasRequest\">\n" +
+	"\x13ListSchemasResponse\x12'\n" +
+	"\aschemas\x18\x01 \x03(\v2\r.proto.SchemaR\aschemas\"T\n" +
+	"\x13DryRunSchemaRequest\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\x12#\n" +
+	"\rcollection_id\x18\x02 \x01(\tR\fcollectionId\"\xca\x02\n" +
+	"\x14DryRunSchemaResponse\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\x12\x18\n" +
+	"\achecked\x18\x02 \x01(\x03R\achecked\x12\x16\n" +
+	"\x06failed\x18\x03 \x01(\x03R\x06failed\x12?\n" +
+	"\bfailures\x18\x04 \x03(\v2#.proto.DryRunSchemaResponse.FailureR\bfailures\x1a`\n" +
+	"\aFailure\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12E\n" +
+	"\n" +
+	"violations\x18\x02 \x03(\v2%.proto.DryRunSchemaResponse.ViolationR\n" +
+	"violations\x1aC\n" +
+	"\tViolation\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\"1\n" +
+	"\x15ActivateSchemaRequest\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\"\x19\n" +
+	"\x17DeactivateSchemaRequest\"4\n" +
+	"\x18DeactivateSchemaResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess2\xa2\x03\n" +
+	"\rSchemaService\x129\n" +
+	"\fCreateSchema\x12\x1a.proto.CreateSchemaRequest\x1a\r.proto.Schema\x123\n" +
+	"\tGetSchema\x12\x17.proto.GetSchemaRequest\x1a\r.proto.Schema\x12D\n" +
+	"\vListSchemas\x12\x19.proto.ListSchemasRequest\x1a\x1a.proto.ListSchemasResponse\x12G\n" +
+	"\fDryRunSchema\x12\x1a.proto.DryRunSchemaRequest\x1a\x1b.proto.DryRunSchemaResponse\x12=\n" +
+	"\x0eActivateSchema\x12\x1c.proto.ActivateSchemaRequest\x1a\r.proto.Schema\x12S\n" +
+	"\x10DeactivateSchema\x12\x1e.proto.DeactivateSchemaRequest\x1a\x1f.proto.DeactivateSchemaResponseB&Z$github.com/angel/go-api-sqlite/protob\x06proto3"
+
+var (
+	file_proto_schema_proto_rawDescOnce sync.Once
+	file_proto_schema_proto_rawDescData []byte
+)
+
+func file_proto_schema_proto_rawDescGZIP() []byte {
+	file_proto_schema_proto_rawDescOnce.Do(func() {
+		file_proto_schema_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_schema_proto_rawDesc), len(file_proto_schema_proto_rawDesc)))
+	})
+	return file_proto_schema_proto_rawDescData
+}
+
+var file_proto_schema_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_proto_schema_proto_goTypes = []any{
+	(*Schema)(nil),                         // 0: proto.Schema
+	(*CreateSchemaRequest)(nil),            // 1: proto.CreateSchemaRequest
+	(*GetSchemaRequest)(nil),               // 2: proto.GetSchemaRequest
+	(*ListSchemasRequest)(nil),             // 3: proto.ListSchemasRequest
+	(*ListSchemasResponse)(nil),            // 4: proto.ListSchemasResponse
+	(*DryRunSchemaRequest)(nil),            // 5: proto.DryRunSchemaRequest
+	(*DryRunSchemaResponse)(nil),           // 6: proto.DryRunSchemaResponse
+	(*ActivateSchemaRequest)(nil),          // 7: proto.ActivateSchemaRequest
+	(*DeactivateSchemaRequest)(nil),        // 8: proto.DeactivateSchemaRequest
+	(*DeactivateSchemaResponse)(nil),       // 9: proto.DeactivateSchemaResponse
+	(*DryRunSchemaResponse_Failure)(nil),   // 10: proto.DryRunSchemaResponse.Failure
+	(*DryRunSchemaResponse_Violation)(nil), // 11: proto.DryRunSchemaResponse.Violation
+	(*structpb.Struct)(nil),                // 12: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil),          // 13: google.protobuf.Timestamp
+}
+var file_proto_schema_proto_depIdxs = []int32{
+	12, // 0: proto.Schema.schema:type_name -> google.protobuf.Struct
+	13, // 1: proto.Schema.created_at:type_name -> google.protobuf.Timestamp
+	13, // 2: proto.Schema.activated_at:type_name -> google.protobuf.Timestamp
+	12, // 3: proto.CreateSchemaRequest.schema:type_name -> google.protobuf.Struct
+	0,  // 4: proto.ListSchemasResponse.schemas:type_name -> proto.Schema
+	10, // 5: proto.DryRunSchemaResponse.failures:type_name -> proto.DryRunSchemaResponse.Failure
+	11, // 6: proto.DryRunSchemaResponse.Failure.violations:type_name -> proto.DryRunSchemaResponse.Violation
+	1,  // 7: proto.SchemaService.CreateSchema:input_type -> proto.CreateSchemaRequest
+	2,  // 8: proto.SchemaService.GetSchema:input_type -> proto.GetSchemaRequest
+	3,  // 9: proto.SchemaService.ListSchemas:input_type -> proto.ListSchemasRequest
+	5,  // 10: proto.SchemaService.DryRunSchema:input_type -> proto.DryRunSchemaRequest
+	7,  // 11: proto.SchemaService.ActivateSchema:input_type -> proto.ActivateSchemaRequest
+	8,  // 12: proto.SchemaService.DeactivateSchema:input_type -> proto.DeactivateSchemaRequest
+	0,  // 13: proto.SchemaService.CreateSchema:output_type -> proto.Schema
+	0,  // 14: proto.SchemaService.GetSchema:output_type -> proto.Schema
+	4,  // 15: proto.SchemaService.ListSchemas:output_type -> proto.ListSchemasResponse
+	6,  // 16: proto.SchemaService.DryRunSchema:output_type -> proto.DryRunSchemaResponse
+	0,  // 17: proto.SchemaService.ActivateSchema:output_type -> proto.Schema
+	9,  // 18: proto.SchemaService.DeactivateSchema:output_type -> proto.DeactivateSchemaResponse
+	13, // [13:19] is the sub-list for method output_type
+	7,  // [7:13] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_proto_schema_proto_init() }
+func file_proto_schema_proto_init() {
+	if File_proto_schema_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_schema_proto_rawDesc), len(file_proto_schema_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_schema_proto_goTypes,
+		DependencyIndexes: file_proto_schema_proto_depIdxs,
+		MessageInfos:      file_proto_schema_proto_msgTypes,
+	}.Build()
+	File_proto_schema_proto = out.File
+	file_proto_schema_proto_goTypes = nil
+	file_proto_schema_proto_depIdxs = nil
+}